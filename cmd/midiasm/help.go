@@ -43,6 +43,12 @@ func (h help) help() {
 	fmt.Println()
 	fmt.Println("  Defaults to 'disassemble' if the command is not provided.")
 	fmt.Println()
+	fmt.Println("  Commands that take a single MIDI file also accept multiple files, globs and directories:")
+	fmt.Println()
+	fmt.Println("    --jobs <N>         Number of files to process concurrently. Defaults to the number of CPUs.")
+	fmt.Println("    --out-dir <dir>    Output directory. Defaults to the input directory.")
+	fmt.Println("    --name <template>  Output file naming template. Defaults to {{.Dir}}/{{.Name}}{{.Ext}}.")
+	fmt.Println()
 	fmt.Println("  Use 'midiasm help <command>' for command specific information.")
 	fmt.Println()
 }
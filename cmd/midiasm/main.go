@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"os"
@@ -23,15 +24,29 @@ var cli = []struct {
 	{"transpose", &commands.Transpose},
 	{"tsv", &commands.TSV},
 	{"humanise", &commands.Humanise},
+	{"key", &commands.Key},
+	{"chords", &commands.Chords},
+	{"diff", &commands.Diff},
+	{"query", &commands.Query},
+	{"musicxml", &commands.MusicXML},
+	{"abc", &commands.ABC},
+	{"lilypond", &commands.LilyPond},
+	{"pianoroll", &commands.PianoRoll},
+	{"render", &commands.Render},
+	{"sf2render", &commands.SF2Render},
+	{"lyrics", &commands.Lyrics},
+	{"markers", &commands.Markers},
+	{"validate-json", &commands.ValidateJSON},
 	{"help", &Help},
 	{"version", &Version},
 }
 
 var options = struct {
-	conf    string
-	c4      bool
-	verbose bool
-	debug   bool
+	conf     string
+	c4       bool
+	encoding lib.Encoding
+	verbose  bool
+	debug    bool
 }{}
 
 const VERSION = "v0.3.x"
@@ -77,8 +92,27 @@ func main() {
 		context.SetMiddleC(lib.C3)
 	}
 
+	context.SetEncoding(options.encoding)
+
 	// ... process
-	if err := cmd.Execute(flagset); err != nil {
+	if b, ok := cmd.(commands.Batchable); ok && commands.Batch.IsBatch(flagset.Args()) {
+		err = commands.Batch.Execute(b, flagset.Args())
+	} else {
+		err = cmd.Execute(flagset)
+	}
+
+	if err != nil {
+		var x commands.ExitCode
+		if errors.As(err, &x) {
+			if x.Err != nil {
+				fmt.Println()
+				fmt.Printf("   *** ERROR: %v\n", x.Err)
+				fmt.Println()
+			}
+
+			os.Exit(x.Code)
+		}
+
 		fmt.Println()
 		fmt.Printf("   *** ERROR: %v\n", err)
 		fmt.Println()
@@ -91,6 +125,7 @@ func parse() (commands.Command, *flag.FlagSet, error) {
 	flagset := flag.NewFlagSet("midiasm", flag.ExitOnError)
 
 	flagset.BoolVar(&options.c4, "C4", options.c4, "Sets middle C to C4 (Yamaho convention). Defaults to C3")
+	flagset.Var(&options.encoding, "encoding", "Character encoding of the text meta events ('auto', 'utf-8', 'latin-1', 'windows-1252', 'shift-jis' or 'euc-jp'). Defaults to 'auto'")
 	flagset.BoolVar(&options.verbose, "verbose", false, "Enable progress information")
 	flagset.BoolVar(&options.debug, "debug", false, "Enable debugging information")
 
@@ -99,6 +134,10 @@ func parse() (commands.Command, *flag.FlagSet, error) {
 			if c.cmd == os.Args[1] {
 				cmd := c.command
 				flagset = cmd.Flagset(flagset)
+				if _, ok := cmd.(commands.Batchable); ok {
+					flagset = commands.Batch.Flagset(flagset)
+				}
+
 				if err := flagset.Parse(os.Args[2:]); err != nil {
 					return cmd, flagset, err
 				} else {
@@ -110,6 +149,7 @@ func parse() (commands.Command, *flag.FlagSet, error) {
 
 	cmd := &commands.Disassemble
 	flagset = cmd.Flagset(flagset)
+	flagset = commands.Batch.Flagset(flagset)
 	if err := flagset.Parse(os.Args[1:]); err != nil {
 		return cmd, flagset, err
 	}
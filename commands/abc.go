@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/export"
+)
+
+type abc struct {
+	out      string
+	quantise int
+	triplets bool
+}
+
+var ABC = abc{}
+
+func (x *abc) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&x.out, "out", "", "Output file path")
+	flagset.IntVar(&x.quantise, "quantise", 16, "Shortest notated value (1, 2, 4, 8, 16, 32 or 64). Defaults to 16")
+	flagset.BoolVar(&x.triplets, "triplets", true, "Notates notes that fall on a triplet grid as triplets. Defaults to true")
+
+	return flagset
+}
+
+func (x abc) Help() {
+	fmt.Println()
+	fmt.Println("  Converts the notes in a MIDI file to an ABC tune, with a voice for each track and channel.")
+	fmt.Println()
+	fmt.Println("    midiasm abc [--debug] [--verbose] [--quantise <value>] [--triplets=false] [--out <file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <MIDI file>  MIDI file to convert to ABC notation.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --quantise <value>  Shortest notated value e.g. 16 for sixteenth notes. Note start and end times are")
+	fmt.Println("                          quantised to this value. Defaults to 16.")
+	fmt.Println("      --triplets          Notates notes that fall on a triplet grid as triplets. Defaults to true.")
+	fmt.Println("      --out <file>        Writes the ABC tune to a file. Default is to write to stdout.")
+	fmt.Println("      --debug             Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose           Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm abc --quantise 8 --out greensleeves.abc greensleeves.mid")
+	fmt.Println()
+}
+
+func (x abc) Execute(flagset *flag.FlagSet) error {
+	return x.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (x abc) Process(filename string, out string) error {
+	x.out = out
+
+	return x.process(filename)
+}
+
+func (x abc) Inputs() []string {
+	return midifiles
+}
+
+func (x abc) Extension() string {
+	return ".abc"
+}
+
+func (x abc) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return x.execute(smf)
+}
+
+func (x abc) execute(smf *midi.SMF) error {
+	op, err := impl.NewABC()
+	if err != nil {
+		return err
+	}
+
+	op.Quantise = x.quantise
+	op.Triplets = x.triplets
+
+	out := os.Stdout
+	if x.out != "" {
+		w, err := os.Create(x.out)
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+
+		out = w
+	}
+
+	return op.Export(smf, out)
+}
@@ -7,29 +7,53 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/transcriptaze/midiasm/encoding/midi"
 	impl "github.com/transcriptaze/midiasm/ops/assemble"
 )
 
 type assemble struct {
-	out string
+	out           string
+	runningStatus string
+	delimiter     string
+	tabular       bool
+	ppqn          uint
+	format        string
 }
 
 var Assemble = assemble{}
 
 func (a *assemble) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.StringVar(&a.out, "out", "", "Output file path")
+	flagset.StringVar(&a.runningStatus, "running-status", "", "Running status encoding for CSV and TSV files ('none', 'notes' or 'all'). Defaults to 'none' for CSV and 'notes' for TSV")
+	flagset.StringVar(&a.delimiter, "delimiter", "", "Column delimiter for TSV files. Defaults to TAB")
+	flagset.BoolVar(&a.tabular, "tabular", false, "Assembles the fixed width column output of 'tsv --tabular'")
+	flagset.UintVar(&a.ppqn, "ppqn", 480, "Pulses per quarter note for MusicXML, ABC and score files (and TSV files without an MThd row). Defaults to 480")
+	flagset.StringVar(&a.format, "format", "", "Source format ('text', 'json', 'yaml', 'csv', 'tsv', 'musicxml', 'abc' or 'score'). Defaults to the file extension")
 
 	return flagset
 }
 
 func (a assemble) Help() {
 	fmt.Println()
-	fmt.Println("  Assembles a MIDI file from a text or JSON source.")
+	fmt.Println("  Assembles a MIDI file from a text, JSON (or NDJSON), YAML, midicsv CSV, 'tsv' command, MusicXML, ABC or score source.")
 	fmt.Println()
-	fmt.Println("    midiasm assemble [--debug] [--verbose] [--C4] [--out <MIDI file>] <file>")
+	fmt.Println("    midiasm assemble [--debug] [--verbose] [--C4] [--encoding <name>] [--running-status none|notes|all] [--delimiter <string>] [--tabular] [--ppqn <N>] [--format <format>] [--out <MIDI file>] <file>")
 	fmt.Println()
-	fmt.Println("      --out <file>  Output MIDI file. Default is to use the input file name with a .midi extension.")
+	fmt.Println("      --out <file>                 Output MIDI file. Default is to use the input file name with a .midi extension.")
+	fmt.Println("      --running-status <encoding>  Running status encoding for CSV and TSV files ('none', 'notes' or 'all'). Defaults")
+	fmt.Println("                                   to 'none' for CSV and 'notes' for TSV. Use the running status of the original MIDI")
+	fmt.Println("                                   file to reassemble an export to the original file.")
+	fmt.Println("      --delimiter <string>         Column separator for TSV files (defaults to TAB).")
+	fmt.Println("      --tabular                    Assembles the fixed width column output of 'tsv --tabular'. Defaults to true for")
+	fmt.Println("                                   .tab files.")
+	fmt.Println("      --ppqn <N>                   Pulses per quarter note for MusicXML, ABC and score files (and TSV files without an")
+	fmt.Println("                                   MThd row). Defaults to 480.")
+	fmt.Println("      --format <format>            Source format ('text', 'json', 'yaml', 'csv', 'tsv', 'musicxml', 'abc' or 'score').")
+	fmt.Println("                                   Defaults to the format for the file extension. The JSON format includes NDJSON.")
+	fmt.Println("      --encoding <name>            Character encoding of the text events ('auto', 'utf-8', 'latin-1', 'windows-1252',")
+	fmt.Println("                                   'shift-jis' or 'euc-jp'). Defaults to 'auto'.")
 	fmt.Println()
 	fmt.Println("    Options:")
 	fmt.Println()
@@ -40,12 +64,36 @@ func (a assemble) Help() {
 	fmt.Println("    Example:")
 	fmt.Println()
 	fmt.Println("      midiasm assemble --debug --verbose --out one-time.midi one-time.txt")
+	fmt.Println("      midiasm assemble --out one-time.midi one-time.csv")
+	fmt.Println("      midiasm assemble --out one-time.midi one-time.ndjson")
+	fmt.Println("      midiasm assemble --out one-time.midi one-time.yaml")
+	fmt.Println("      midiasm assemble --out one-time.midi one-time.tsv")
+	fmt.Println("      midiasm assemble --out one-time.midi one-time.musicxml")
+	fmt.Println("      midiasm assemble --out greensleeves.midi greensleeves.abc")
+	fmt.Println("      midiasm assemble --out twinkle.midi twinkle.score")
 	fmt.Println()
 }
 
 func (a assemble) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return a.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (a assemble) Process(filename string, out string) error {
+	a.out = out
+
+	return a.process(filename)
+}
+
+func (a assemble) Inputs() []string {
+	return []string{".txt", ".json", ".ndjson", ".jsonl", ".yaml", ".yml", ".csv", ".tsv", ".tab", ".musicxml", ".xml", ".abc", ".score"}
+}
+
+func (a assemble) Extension() string {
+	return ".mid"
+}
 
+func (a assemble) process(filename string) error {
 	var r io.Reader
 	if b, err := os.ReadFile(filename); err != nil {
 		return err
@@ -55,12 +103,75 @@ func (a assemble) Execute(flagset *flag.FlagSet) error {
 
 	var assembler impl.Assembler
 
-	switch filepath.Ext(filename) {
-	case ".json":
+	if a.ppqn == 0 || a.ppqn > 0x7fff {
+		return fmt.Errorf("invalid PPQN (%v): expected a value in the interval [1..32767]", a.ppqn)
+	}
+
+	format, err := a.formatOf(filename)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "tsv", "tab":
+		tsv := impl.NewTSVAssembler()
+		tsv.Tabular = a.tabular || format == "tab"
+
+		if a.delimiter != "" && a.delimiter != `\t` {
+			tsv.Delimiter = []rune(a.delimiter)[0]
+		}
+
+		tsv.PPQN = uint16(a.ppqn)
+
+		if a.runningStatus != "" {
+			if tsv.RunningStatus, err = runningStatus(a.runningStatus); err != nil {
+				return err
+			}
+		}
+
+		assembler = tsv
+
+	case "musicxml":
+		musicxml := impl.NewMusicXMLAssembler()
+		musicxml.PPQN = uint16(a.ppqn)
+
+		assembler = musicxml
+
+	case "abc":
+		abc := impl.NewABCAssembler()
+		abc.PPQN = uint16(a.ppqn)
+
+		assembler = abc
+
+	case "score":
+		score := impl.NewScoreAssembler()
+		score.PPQN = uint16(a.ppqn)
+		score.Filename = filename
+
+		assembler = score
+
+	case "json":
 		assembler = impl.NewJSONAssembler()
 
+	case "yaml":
+		assembler = impl.NewYAMLAssembler()
+
+	case "csv":
+		csv := impl.NewCSVAssembler()
+
+		if a.runningStatus != "" {
+			if csv.RunningStatus, err = runningStatus(a.runningStatus); err != nil {
+				return err
+			}
+		}
+
+		assembler = csv
+
 	default:
-		assembler = impl.NewTextAssembler()
+		text := impl.NewTextAssembler()
+		text.Filename = filename
+
+		assembler = text
 	}
 
 	midi, err := assembler.Assemble(r)
@@ -76,3 +187,66 @@ func (a assemble) Execute(flagset *flag.FlagSet) error {
 
 	return nil
 }
+
+// formatOf returns the --format option or, if not specified, the source format for the file
+// extension (defaulting to text).
+func (a assemble) formatOf(filename string) (string, error) {
+	if a.tabular {
+		return "tsv", nil
+	}
+
+	switch a.format {
+	case "text", "json", "yaml", "csv", "tsv", "musicxml", "abc", "score":
+		return a.format, nil
+
+	case "":
+
+	default:
+		return "", fmt.Errorf("invalid format (%v): expected 'text', 'json', 'yaml', 'csv', 'tsv', 'musicxml', 'abc' or 'score'", a.format)
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".tsv":
+		return "tsv", nil
+
+	case ".tab":
+		return "tab", nil
+
+	case ".musicxml", ".xml":
+		return "musicxml", nil
+
+	case ".abc":
+		return "abc", nil
+
+	case ".score":
+		return "score", nil
+
+	case ".json", ".ndjson", ".jsonl":
+		return "json", nil
+
+	case ".yaml", ".yml":
+		return "yaml", nil
+
+	case ".csv":
+		return "csv", nil
+
+	default:
+		return "text", nil
+	}
+}
+
+func runningStatus(encoding string) (midifile.RunningStatus, error) {
+	switch encoding {
+	case "none":
+		return midifile.RunningStatusNone, nil
+
+	case "notes":
+		return midifile.RunningStatusNotes, nil
+
+	case "all":
+		return midifile.RunningStatusAll, nil
+
+	default:
+		return midifile.RunningStatusNone, fmt.Errorf("invalid running status (%v): expected 'none', 'notes' or 'all'", encoding)
+	}
+}
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVRoundTrip(t *testing.T) {
+	tests := []string{
+		"../examples/example-01.mid",
+		"../examples/greensleeves.mid",
+		"../examples/reference-01.mid",
+	}
+
+	for _, file := range tests {
+		dir := t.TempDir()
+		csv := filepath.Join(dir, "export.csv")
+		mid := filepath.Join(dir, "assembled.mid")
+
+		x := export{format: "csv"}
+		if err := x.Process(file, csv); err != nil {
+			t.Fatalf("error exporting %v (%v)", file, err)
+		}
+
+		a := assemble{ppqn: 480}
+		if err := a.Process(csv, mid); err != nil {
+			t.Fatalf("error assembling %v (%v)", file, err)
+		}
+
+		if expected, err := os.ReadFile(file); err != nil {
+			t.Fatalf("%v", err)
+		} else if assembled, err := os.ReadFile(mid); err != nil {
+			t.Fatalf("%v", err)
+		} else if !bytes.Equal(assembled, expected) {
+			t.Errorf("CSV export of %v does not reassemble to the original MIDI file", file)
+		}
+	}
+}
+
+func TestTSVRoundTrip(t *testing.T) {
+	tests := []struct {
+		file          string
+		mthd          bool
+		ppqn          uint
+		runningStatus string
+	}{
+		{"../examples/reference-01.mid", true, 480, ""},
+		{"../examples/reference-01.mid", false, 96, ""},
+		{"../examples/greensleeves.mid", true, 480, "none"},
+	}
+
+	for _, test := range tests {
+		for _, tabular := range []bool{false, true} {
+			x := TSV
+			x.tabular = tabular
+			x.mthd = test.mthd
+
+			dir := t.TempDir()
+			tsv := filepath.Join(dir, "export"+x.Extension())
+			mid := filepath.Join(dir, "assembled.mid")
+
+			if err := x.Process(test.file, tsv); err != nil {
+				t.Fatalf("error exporting %v (%v)", test.file, err)
+			}
+
+			// ... .tab files are assembled as 'tsv --tabular' output
+			a := assemble{ppqn: test.ppqn, runningStatus: test.runningStatus}
+			if err := a.Process(tsv, mid); err != nil {
+				t.Fatalf("error assembling %v (%v)", test.file, err)
+			}
+
+			if expected, err := os.ReadFile(test.file); err != nil {
+				t.Fatalf("%v", err)
+			} else if assembled, err := os.ReadFile(mid); err != nil {
+				t.Fatalf("%v", err)
+			} else if !bytes.Equal(assembled, expected) {
+				t.Errorf("TSV export of %v (tabular:%v, mthd:%v) does not reassemble to the original MIDI file", test.file, tabular, test.mthd)
+			}
+		}
+	}
+}
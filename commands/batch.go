@@ -0,0 +1,256 @@
+package commands
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Batchable is implemented by commands that can process multiple files in a single run.
+type Batchable interface {
+	Command
+
+	// Process processes a single file, writing the output to 'out' (or the default output if 'out' is blank).
+	Process(file string, out string) error
+
+	// Inputs returns the file extensions to include when scanning a directory.
+	Inputs() []string
+
+	// Extension returns the extension for output files.
+	Extension() string
+}
+
+type batch struct {
+	jobs   int
+	outdir string
+	name   string
+}
+
+type job struct {
+	file string
+	root string
+}
+
+type failure struct {
+	file string
+	err  error
+}
+
+// Batch runs a command over multiple files, globs and directories (recursively) with a bounded
+// pool of workers. The outputs are written to a directory structure that mirrors the inputs and
+// the per-file errors are collected into a summary rather than aborting on the first failure.
+var Batch = batch{}
+
+const DefaultNaming = "{{.Dir}}/{{.Name}}{{.Ext}}"
+
+func (b *batch) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.IntVar(&b.jobs, "jobs", runtime.NumCPU(), "Number of files to process concurrently when processing multiple files")
+	flagset.StringVar(&b.outdir, "out-dir", "", "Output directory when processing multiple files. Defaults to the input directory")
+	flagset.StringVar(&b.name, "name", DefaultNaming, "Output file naming template when processing multiple files")
+
+	return flagset
+}
+
+// IsBatch returns true if the arguments are more than a single MIDI file, i.e. multiple files, a
+// glob or a directory. An argument is only treated as a glob if it isn't an existing file, so that
+// file names containing *, ? or [ are processed as a single file.
+func (b batch) IsBatch(args []string) bool {
+	if len(args) > 1 {
+		return true
+	}
+
+	for _, arg := range args {
+		if info, err := os.Stat(arg); err == nil {
+			return info.IsDir()
+		} else if isGlob(arg) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b batch) Execute(cmd Batchable, args []string) error {
+	naming, err := template.New("name").Parse(b.name)
+	if err != nil {
+		return fmt.Errorf("invalid output naming template (%v)", err)
+	}
+
+	jobs, err := b.expand(args, cmd.Inputs())
+	if err != nil {
+		return err
+	} else if len(jobs) == 0 {
+		return fmt.Errorf("no files to process")
+	}
+
+	queue := make(chan job)
+	failures := []failure{}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	for i := 0; i < max(b.jobs, 1); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := range queue {
+				if err := b.process(cmd, naming, j); err != nil {
+					mutex.Lock()
+					failures = append(failures, failure{j.file, err})
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, j := range jobs {
+		queue <- j
+	}
+
+	close(queue)
+	wg.Wait()
+
+	return summarise(len(jobs), failures)
+}
+
+func (b batch) process(cmd Batchable, naming *template.Template, j job) error {
+	out, err := b.output(cmd, naming, j)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(out); dir != "" {
+		if err := os.MkdirAll(dir, 0770); err != nil {
+			return err
+		}
+	}
+
+	return cmd.Process(j.file, out)
+}
+
+// output renders the output file path from the naming template. The template fields are
+//   - Dir:  the input file directory relative to the input directory (or '.' for files and globs)
+//   - Name: the input file name without the extension
+//   - Ext:  the output file extension for the command
+func (b batch) output(cmd Batchable, naming *template.Template, j job) (string, error) {
+	dir, err := filepath.Rel(j.root, filepath.Dir(j.file))
+	if err != nil {
+		return "", err
+	}
+
+	base := filepath.Base(j.file)
+	fields := struct {
+		Dir  string
+		Name string
+		Ext  string
+	}{
+		Dir:  dir,
+		Name: strings.TrimSuffix(base, filepath.Ext(base)),
+		Ext:  cmd.Extension(),
+	}
+
+	var s bytes.Buffer
+	if err := naming.Execute(&s, fields); err != nil {
+		return "", err
+	}
+
+	root := j.root
+	if b.outdir != "" {
+		root = b.outdir
+	}
+
+	out := filepath.Join(root, s.String())
+	if filepath.Clean(out) == filepath.Clean(j.file) {
+		return "", fmt.Errorf("output file %v would overwrite the input file", out)
+	}
+
+	return out, nil
+}
+
+// expand builds the list of files to process from the command line arguments, walking the
+// directories recursively for files with one of the input file extensions.
+func (b batch) expand(args []string, extensions []string) ([]job, error) {
+	jobs := []job{}
+	seen := map[string]bool{}
+
+	add := func(file, root string) {
+		if !seen[file] {
+			seen[file] = true
+			jobs = append(jobs, job{file: file, root: root})
+		}
+	}
+
+	for _, arg := range args {
+		matches := []string{arg}
+		if _, err := os.Stat(arg); err != nil && isGlob(arg) {
+			if list, err := filepath.Glob(arg); err != nil {
+				return nil, err
+			} else if len(list) == 0 {
+				return nil, fmt.Errorf("no files match %v", arg)
+			} else {
+				matches = list
+			}
+		}
+
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				return nil, err
+			}
+
+			if !info.IsDir() {
+				add(path, filepath.Dir(path))
+				continue
+			}
+
+			err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if !d.IsDir() && slices.Contains(extensions, strings.ToLower(filepath.Ext(file))) {
+					add(file, path)
+				}
+
+				return nil
+			})
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return jobs, nil
+}
+
+func isGlob(arg string) bool {
+	return strings.ContainsAny(arg, "*?[")
+}
+
+func summarise(N int, failures []failure) error {
+	slices.SortFunc(failures, func(p, q failure) int {
+		return strings.Compare(p.file, q.file)
+	})
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "Processed %v files: %v succeeded, %v failed\n", N, N-len(failures), len(failures))
+
+	for _, f := range failures {
+		fmt.Fprintf(os.Stderr, "   ** %v: %v\n", f.file, f.err)
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%v of %v files failed", len(failures), N)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"text/template"
+)
+
+type mockBatchable struct {
+	notes
+	mutex     *sync.Mutex
+	processed map[string]string
+}
+
+func (m mockBatchable) Process(file string, out string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.processed[file] = out
+
+	return nil
+}
+
+func (m mockBatchable) Extension() string {
+	return ".txt"
+}
+
+func TestBatchExpand(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range []string{"a.mid", "b.MID", "c.txt", "x/d.midi", "x/y/e.mid"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatalf("%v", err)
+		} else if err := os.WriteFile(path, []byte{}, 0660); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	jobs, err := batch{}.expand([]string{dir, filepath.Join(dir, "x", "*.midi")}, midifiles)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []string{"a.mid", "b.MID", "x/d.midi", "x/y/e.mid"}
+	if len(jobs) != len(expected) {
+		t.Fatalf("Incorrect file list - expected:%v, got:%v", expected, jobs)
+	}
+
+	for i, j := range jobs {
+		if j.file != filepath.Join(dir, expected[i]) || j.root != dir {
+			t.Errorf("Incorrect file %v - expected:%v, got:%+v", i, expected[i], j)
+		}
+	}
+}
+
+func TestBatchExecute(t *testing.T) {
+	dir := t.TempDir()
+	out := t.TempDir()
+
+	for _, f := range []string{"a.mid", "x/b.mid", "x/y/c.mid"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatalf("%v", err)
+		} else if err := os.WriteFile(path, []byte{}, 0660); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	cmd := mockBatchable{
+		mutex:     &sync.Mutex{},
+		processed: map[string]string{},
+	}
+
+	b := batch{jobs: 2, outdir: out, name: "{{.Dir}}/{{.Name}}-notes{{.Ext}}"}
+	if err := b.Execute(&cmd, []string{dir}); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := map[string]string{
+		filepath.Join(dir, "a.mid"):     filepath.Join(out, "a-notes.txt"),
+		filepath.Join(dir, "x/b.mid"):   filepath.Join(out, "x/b-notes.txt"),
+		filepath.Join(dir, "x/y/c.mid"): filepath.Join(out, "x/y/c-notes.txt"),
+	}
+
+	if len(cmd.processed) != len(expected) {
+		t.Fatalf("Incorrect number of processed files - expected:%v, got:%v", len(expected), len(cmd.processed))
+	}
+
+	for in, o := range expected {
+		if cmd.processed[in] != o {
+			t.Errorf("Incorrect output file for %v - expected:%v, got:%v", in, o, cmd.processed[in])
+		}
+
+		if info, err := os.Stat(filepath.Dir(o)); err != nil || !info.IsDir() {
+			t.Errorf("Missing output directory %v", filepath.Dir(o))
+		}
+	}
+}
+
+func TestBatchOutputOverwrite(t *testing.T) {
+	naming := template.Must(template.New("name").Parse(DefaultNaming))
+
+	for _, cmd := range []Batchable{&Transpose, &Assemble} {
+		if _, err := (batch{}).output(cmd, naming, job{file: "lib/a.mid", root: "lib"}); err == nil {
+			t.Errorf("Expected error for %v output file that overwrites input file", cmd.Extension())
+		}
+	}
+
+	if _, err := (batch{}).output(&Notes, naming, job{file: "lib/a.mid", root: "lib"}); err != nil {
+		t.Errorf("Unexpected error for notes output file (%v)", err)
+	}
+}
+
+func TestIsBatch(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, f := range []string{"a.mid", "b[1].mid", "x/c.mid"} {
+		path := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0770); err != nil {
+			t.Fatalf("%v", err)
+		} else if err := os.WriteFile(path, []byte{}, 0660); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{[]string{filepath.Join(dir, "a.mid")}, false},
+		{[]string{filepath.Join(dir, "b[1].mid")}, false},
+		{[]string{filepath.Join(dir, "x")}, true},
+		{[]string{filepath.Join(dir, "*.mid")}, true},
+		{[]string{filepath.Join(dir, "a.mid"), filepath.Join(dir, "x/c.mid")}, true},
+	}
+
+	for _, test := range tests {
+		if batch := Batch.IsBatch(test.args); batch != test.expected {
+			t.Errorf("Incorrect IsBatch for %v - expected:%v, got:%v", test.args, test.expected, batch)
+		}
+	}
+
+	jobs, err := batch{}.expand([]string{filepath.Join(dir, "b[1].mid")}, midifiles)
+	if err != nil {
+		t.Fatalf("%v", err)
+	} else if len(jobs) != 1 || jobs[0].file != filepath.Join(dir, "b[1].mid") {
+		t.Errorf("Incorrect file list - expected:%v, got:%v", "b[1].mid", jobs)
+	}
+}
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	impl "github.com/transcriptaze/midiasm/ops/chords"
+)
+
+type chords struct {
+	out    string
+	per    string
+	json   bool
+	insert string
+	event  string
+}
+
+var Chords = chords{}
+
+func (c *chords) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&c.out, "out", "", "Output file path")
+	flagset.StringVar(&c.per, "per", "beat", "Chord resolution ('beat' or 'bar'). Defaults to 'beat'")
+	flagset.BoolVar(&c.json, "json", false, "Formats the output as JSON")
+	flagset.StringVar(&c.insert, "insert", "", "Writes a copy of the MIDI file with the chords inserted into track 0")
+	flagset.StringVar(&c.event, "event", "text", "Meta event type for inserted chords ('text' or 'marker'). Defaults to 'text'")
+
+	return flagset
+}
+
+func (c chords) Help() {
+	fmt.Println()
+	fmt.Println("  Groups the notes into chords per beat or per bar and generates a chord chart.")
+	fmt.Println()
+	fmt.Println("    midiasm chords [--debug] [--verbose] [--C4] [--per beat|bar] [--json] [--out <file>] [--insert <MIDI file>] [--event text|marker] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      --per <beat|bar>      Identifies the chord for each beat or for each bar. Defaults to beat.")
+	fmt.Println("      --json                Formats the output as JSON.")
+	fmt.Println("      --out <file>          Writes the chord chart to a file. Default is to write to stdout.")
+	fmt.Println("      --insert <MIDI file>  Writes a copy of the MIDI file with the chords as Text or Marker events in track 0.")
+	fmt.Println("      --event <text|marker> Meta event type for the inserted chords. Defaults to text.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --C4       Uses C4 as middle C (Yamaha convention). Defaults to C3.")
+	fmt.Println("      --debug    Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose  Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm chords --debug --verbose --per bar --out one-time.chords one-time.mid")
+	fmt.Println()
+}
+
+func (c chords) Execute(flagset *flag.FlagSet) error {
+	return c.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (c chords) Process(filename string, out string) error {
+	c.out = out
+
+	if c.insert != "" {
+		return fmt.Errorf("--insert is not supported when processing multiple files")
+	}
+
+	return c.process(filename)
+}
+
+func (c chords) Inputs() []string {
+	return midifiles
+}
+
+func (c chords) Extension() string {
+	if c.json {
+		return ".json"
+	}
+
+	return ".chords"
+}
+
+func (c chords) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return c.execute(smf)
+}
+
+func (c chords) execute(smf *midi.SMF) error {
+	resolution, err := impl.ParseResolution(c.per)
+	if err != nil {
+		return err
+	}
+
+	op := impl.Chords{
+		Resolution: resolution,
+	}
+
+	list, err := op.Analyse(smf)
+	if err != nil {
+		return err
+	}
+
+	if c.insert != "" {
+		tag := lib.TagText
+		switch strings.ToLower(c.event) {
+		case "text":
+			tag = lib.TagText
+		case "marker":
+			tag = lib.TagMarker
+		default:
+			return fmt.Errorf("invalid --event (%v): expected 'text' or 'marker'", c.event)
+		}
+
+		if encoded, err := op.Insert(smf, list, tag); err != nil {
+			return err
+		} else if err := os.WriteFile(c.insert, encoded, 0660); err != nil {
+			return err
+		}
+	}
+
+	w := os.Stdout
+	if c.out != "" {
+		if w, err = os.Create(c.out); err != nil {
+			return err
+		}
+
+		defer w.Close()
+	}
+
+	if c.json {
+		return impl.Export(list, w)
+	} else {
+		return impl.Print(list, w)
+	}
+}
@@ -10,40 +10,91 @@ import (
 )
 
 type click struct {
-	out string
+	out            string
+	format         string
+	track          bool
+	accent         uint
+	note           uint
+	accentVelocity uint
+	velocity       uint
+	countIn        int
 }
 
 var Click = click{}
 
 func (c *click) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.StringVar(&c.out, "out", "", "Output file path")
+	flagset.StringVar(&c.format, "format", "text", "Output format ('text', 'audacity', 'reaper' or 'json'). Defaults to 'text'")
+	flagset.BoolVar(&c.track, "track", false, "Adds a GM percussion click track to the MIDI file")
+	flagset.UintVar(&c.accent, "accent", 76, "Click track note for the first beat of a bar. Defaults to 76 (Hi Wood Block)")
+	flagset.UintVar(&c.note, "note", 77, "Click track note for the other beats. Defaults to 77 (Low Wood Block)")
+	flagset.UintVar(&c.accentVelocity, "accent-velocity", 127, "Click track velocity for the first beat of a bar. Defaults to 127")
+	flagset.UintVar(&c.velocity, "velocity", 100, "Click track velocity for the other beats. Defaults to 100")
+	flagset.IntVar(&c.countIn, "count-in", 0, "Number of count-in bars for the click track. Defaults to 0")
 
 	return flagset
 }
 
 func (c click) Help() {
 	fmt.Println()
-	fmt.Println("  Extracts the _beats_ from the MIDI file in a format that can be used to create a click track.")
+	fmt.Println("  Lists the metronome clicks for a MIDI file, or adds a General MIDI percussion click track to the MIDI file.")
 	fmt.Println()
-	fmt.Println("    midiasm click [--debug] [--verbose] [--C4] [--out <file>] <MIDI file>`")
+	fmt.Println("    midiasm click [--debug] [--verbose] [--format text|audacity|reaper|json] [--track] [--accent <note>] [--note <note>] [--accent-velocity <velocity>] [--velocity <velocity>] [--count-in <bars>] [--out <file>] <MIDI file>")
 	fmt.Println()
-	fmt.Println("      --out <file>  Writes the click track to a file. Default is to write to stdout.")
+	fmt.Println("      --format <format>              Output format for the clicks:")
+	fmt.Println("                                     - text:     bar, beat, tick, time, tempo and time signature of each click (default)")
+	fmt.Println("                                     - audacity: Audacity label track with a label for each beat")
+	fmt.Println("                                     - reaper:   Reaper marker list CSV with a marker for each beat")
+	fmt.Println("                                     - json:     JSON beat grid with the bar, beat, tick and seconds for each beat")
+	fmt.Println("      --track                        Adds a click track (on channel 9) to the MIDI file and writes the MIDI file to the --out file.")
+	fmt.Println("      --accent <note>                Click track note for the first beat of a bar. Defaults to 76 (Hi Wood Block).")
+	fmt.Println("      --note <note>                  Click track note for the other beats. Defaults to 77 (Low Wood Block).")
+	fmt.Println("      --accent-velocity <velocity>   Click track note velocity for the first beat of a bar. Defaults to 127.")
+	fmt.Println("      --velocity <velocity>          Click track note velocity for the other beats. Defaults to 100.")
+	fmt.Println("      --count-in <bars>              Number of count-in bars for the click track. Defaults to 0.")
+	fmt.Println("      --out <file>                   Writes the clicks (or MIDI file) to a file. Default is to write the clicks to stdout.")
 	fmt.Println()
 	fmt.Println("    Options:")
 	fmt.Println()
-	fmt.Println("      --C4       Uses C4 as middle C (Yamaha convention). Defaults to C3.")
 	fmt.Println("      --debug    Displays internal information while processing a MIDI file. Defaults to false")
 	fmt.Println("      --verbose  Enables 'verbose' logging. Defaults to false")
 	fmt.Println()
 	fmt.Println("    Example:")
 	fmt.Println()
-	fmt.Println("      midiasm click --debug --verbose --out one-time.click one-time.mid")
+	fmt.Println("      midiasm click --out one-time.click one-time.mid")
+	fmt.Println("      midiasm click --format audacity --out one-time.txt one-time.mid")
+	fmt.Println("      midiasm click --track --count-in 2 --out one-time-click.mid one-time.mid")
 	fmt.Println()
 }
 
 func (c click) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return c.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (c click) Process(filename string, out string) error {
+	c.out = out
+
+	return c.process(filename)
+}
+
+func (c click) Inputs() []string {
+	return midifiles
+}
+
+func (c click) Extension() string {
+	if c.track {
+		return ".mid"
+	}
+
+	if format, err := impl.ParseFormat(c.format); err == nil {
+		return format.Extension()
+	}
 
+	return ".click"
+}
+
+func (c click) process(filename string) error {
 	smf, err := decode(filename)
 	if err != nil {
 		return err
@@ -62,8 +113,46 @@ func (c click) Execute(flagset *flag.FlagSet) error {
 }
 
 func (c click) execute(smf *midi.SMF) error {
+	op, err := impl.NewClickTrack()
+	if err != nil {
+		return err
+	}
+
+	for _, v := range []uint{c.accent, c.note, c.accentVelocity, c.velocity} {
+		if v > 127 {
+			return fmt.Errorf("invalid click track note/velocity (%v): expected a value in the interval [0..127]", v)
+		}
+	}
+
+	op.Accent = byte(c.accent)
+	op.Note = byte(c.note)
+	op.AccentVelocity = byte(c.accentVelocity)
+	op.Velocity = byte(c.velocity)
+	op.CountIn = c.countIn
+
+	if c.track {
+		if c.out == "" {
+			return fmt.Errorf("--track requires an output file (--out)")
+		}
+
+		if encoded, err := op.Insert(smf); err != nil {
+			return err
+		} else {
+			return os.WriteFile(c.out, encoded, 0660)
+		}
+	}
+
+	format, err := impl.ParseFormat(c.format)
+	if err != nil {
+		return err
+	}
+
+	clicks, err := op.Clicks(smf)
+	if err != nil {
+		return err
+	}
+
 	var w = os.Stdout
-	var err error
 
 	if c.out != "" {
 		if w, err = os.Create(c.out); err != nil {
@@ -73,7 +162,17 @@ func (c click) execute(smf *midi.SMF) error {
 		defer w.Close()
 	}
 
-	track := impl.ClickTrack{w}
+	switch format {
+	case impl.Audacity:
+		return impl.Labels(clicks, w)
+
+	case impl.Reaper:
+		return impl.Markers(clicks, w)
 
-	return track.Execute(smf)
+	case impl.JSON:
+		return impl.Export(clicks, w)
+
+	default:
+		return impl.Print(clicks, w)
+	}
 }
@@ -12,12 +12,34 @@ import (
 	"github.com/transcriptaze/midiasm/midi"
 )
 
+var midifiles = []string{".mid", ".midi"}
+var karaokefiles = []string{".mid", ".midi", ".kar"}
+
 type Command interface {
 	Flagset(flagset *flag.FlagSet) *flag.FlagSet
 	Execute(flagset *flag.FlagSet) error
 	Help()
 }
 
+// ExitCode is returned by a command to exit with a specific exit code, optionally reporting an
+// error (e.g. diff exits with 1 if the files are different and 2 if there was a problem).
+type ExitCode struct {
+	Code int
+	Err  error
+}
+
+func (x ExitCode) Error() string {
+	if x.Err != nil {
+		return fmt.Sprintf("%v", x.Err)
+	}
+
+	return fmt.Sprintf("exit code %v", x.Code)
+}
+
+func (x ExitCode) Unwrap() error {
+	return x.Err
+}
+
 func decode(filename string) (*midi.SMF, error) {
 	var r io.Reader
 
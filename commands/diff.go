@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	impl "github.com/transcriptaze/midiasm/ops/diff"
+)
+
+type diff struct {
+	out            string
+	align          string
+	json           bool
+	notes          bool
+	ignoreDeltas   bool
+	ignoreTicks    bool
+	ignoreVelocity bool
+}
+
+var Diff = diff{}
+
+func (d *diff) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&d.out, "out", "", "Output file path")
+	flagset.StringVar(&d.align, "align", "index", "Track alignment ('index' or 'name'). Defaults to 'index'")
+	flagset.BoolVar(&d.json, "json", false, "Formats the output as JSON")
+	flagset.BoolVar(&d.notes, "notes", false, "Compares the notes rather than the events")
+	flagset.BoolVar(&d.ignoreDeltas, "ignore-deltas", false, "Ignores differences in event deltas")
+	flagset.BoolVar(&d.ignoreTicks, "ignore-ticks", false, "Ignores differences in absolute event ticks")
+	flagset.BoolVar(&d.ignoreVelocity, "ignore-velocity", false, "Ignores differences in note velocities")
+
+	return flagset
+}
+
+func (d diff) Help() {
+	fmt.Println()
+	fmt.Println("  Compares two MIDI files and reports the added, removed and changed events (or notes) in each track.")
+	fmt.Println()
+	fmt.Println("  Exits with 0 if the files are the same, 1 if they are different and 2 if there was an error.")
+	fmt.Println()
+	fmt.Println("    midiasm diff [--debug] [--verbose] [--C4] [--align index|name] [--notes] [--ignore-deltas] [--ignore-ticks] [--ignore-velocity] [--json] [--out <file>] <MIDI file> <MIDI file>")
+	fmt.Println()
+	fmt.Println("      --align <index|name>  Aligns the tracks by track number or by track name. Defaults to index.")
+	fmt.Println("      --notes               Compares the notes rather than the events.")
+	fmt.Println("      --ignore-deltas       Ignores differences in event deltas.")
+	fmt.Println("      --ignore-ticks        Ignores differences in absolute event ticks.")
+	fmt.Println("      --ignore-velocity     Ignores differences in note velocities.")
+	fmt.Println("      --json                Formats the output as JSON.")
+	fmt.Println("      --out <file>          Writes the differences to a file. Default is to write to stdout.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --C4       Uses C4 as middle C (Yamaha convention). Defaults to C3.")
+	fmt.Println("      --debug    Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose  Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm diff --align name --ignore-deltas one-time.mid one-time-v2.mid")
+	fmt.Println()
+}
+
+func (d diff) Execute(flagset *flag.FlagSet) error {
+	if flagset.NArg() < 2 {
+		return ExitCode{2, fmt.Errorf("diff requires two MIDI files")}
+	}
+
+	if same, err := d.execute(flagset.Arg(0), flagset.Arg(1)); err != nil {
+		return ExitCode{2, err}
+	} else if !same {
+		return ExitCode{Code: 1}
+	}
+
+	return nil
+}
+
+func (d diff) execute(a, b string) (bool, error) {
+	align, err := impl.ParseAlign(d.align)
+	if err != nil {
+		return false, err
+	}
+
+	p, err := decode(a)
+	if err != nil {
+		return false, err
+	}
+
+	q, err := decode(b)
+	if err != nil {
+		return false, err
+	}
+
+	op := impl.Diff{
+		Align:          align,
+		IgnoreDeltas:   d.ignoreDeltas,
+		IgnoreTicks:    d.ignoreTicks,
+		IgnoreVelocity: d.ignoreVelocity,
+		Notes:          d.notes,
+	}
+
+	result, err := op.Compare(p, q)
+	if err != nil {
+		return false, err
+	}
+
+	w := os.Stdout
+	if d.out != "" {
+		if w, err = os.Create(d.out); err != nil {
+			return false, err
+		}
+
+		defer w.Close()
+	}
+
+	if d.json {
+		err = impl.Export(result, w)
+	} else {
+		err = impl.Print(result, w)
+	}
+
+	return result.Equal(), err
+}
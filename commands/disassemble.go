@@ -9,12 +9,14 @@ import (
 
 	"github.com/transcriptaze/midiasm/midi"
 	impl "github.com/transcriptaze/midiasm/ops/disassemble"
+	mql "github.com/transcriptaze/midiasm/ops/query"
 )
 
 type disassemble struct {
 	out       string
 	split     bool
 	templates string
+	where     string
 }
 
 var Disassemble = disassemble{}
@@ -23,6 +25,7 @@ func (d *disassemble) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.StringVar(&d.out, "out", "", "Output file path (or directory for split files)")
 	flagset.BoolVar(&d.split, "split", false, "Create separate file for each track. Defaults to the same directory as the MIDI file.")
 	flagset.StringVar(&d.templates, "templates", "", "Loads the formatting templates from a file")
+	flagset.StringVar(&d.where, "where", "", "Only includes the events that match the query expression")
 
 	return flagset
 }
@@ -31,10 +34,13 @@ func (d disassemble) Help() {
 	fmt.Println()
 	fmt.Println("  Disassembles a MIDI file and displays the tracks in a human readable format.")
 	fmt.Println()
-	fmt.Println("    midiasm [--debug] [--verbose] [--C4] [--split] [--out <file>] <MIDI file>")
+	fmt.Println("    midiasm [--debug] [--verbose] [--C4] [--encoding <name>] [--split] [--out <file>] [--where <query>] <MIDI file>")
 	fmt.Println()
-	fmt.Println("      --out <file>  Writes the disassembly to a file. Default is to write to stdout.")
-	fmt.Println("      --split       Writes each track to a separate file. Default is `false`.")
+	fmt.Println("      --out <file>       Writes the disassembly to a file. Default is to write to stdout.")
+	fmt.Println("      --split            Writes each track to a separate file. Default is `false`.")
+	fmt.Println("      --where <query>    Only includes the events that match the query e.g. \"tag=NoteOn and channel=1\".")
+	fmt.Println("      --encoding <name>  Character encoding of the text events ('auto', 'utf-8', 'latin-1', 'windows-1252',")
+	fmt.Println("                         'shift-jis' or 'euc-jp'). Defaults to 'auto'.")
 	fmt.Println()
 	fmt.Println("    Options:")
 	fmt.Println()
@@ -49,8 +55,29 @@ func (d disassemble) Help() {
 }
 
 func (p disassemble) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return p.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (p disassemble) Process(filename string, out string) error {
+	p.out = out
+
+	return p.process(filename)
+}
+
+func (p disassemble) Inputs() []string {
+	return midifiles
+}
+
+func (p disassemble) Extension() string {
+	if p.split {
+		return ""
+	}
 
+	return ".txt"
+}
+
+func (p disassemble) process(filename string) error {
 	smf, err := decode(filename)
 	if err != nil {
 		return err
@@ -65,6 +92,14 @@ func (p disassemble) Execute(flagset *flag.FlagSet) error {
 		fmt.Fprintln(os.Stderr)
 	}
 
+	if p.where != "" {
+		if q, err := mql.Parse(p.where); err != nil {
+			return err
+		} else if smf, err = mql.Filter(smf, q); err != nil {
+			return err
+		}
+	}
+
 	op, err := impl.NewDisassemble()
 	if err != nil {
 		return err
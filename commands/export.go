@@ -3,48 +3,101 @@ package commands
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/transcriptaze/midiasm/midi"
 	impl "github.com/transcriptaze/midiasm/ops/export"
+	mql "github.com/transcriptaze/midiasm/ops/query"
 )
 
 type export struct {
-	out string
+	out    string
+	where  string
+	format string
 }
 
 var Export = export{}
 
+type exporter interface {
+	Export(*midi.SMF, io.Writer) error
+}
+
 func (x *export) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.StringVar(&x.out, "out", "", "Output file path (or directory for split files)")
+	flagset.StringVar(&x.where, "where", "", "Only includes the events that match the query expression")
+	flagset.StringVar(&x.format, "format", "", "Export format ('json', 'ndjson', 'yaml' or 'csv'). Defaults to the --out file extension or 'json'")
 
 	return flagset
 }
 
 func (x export) Help() {
 	fmt.Println()
-	fmt.Println("  Extracts the MIDI information as JSON for use with other tools (e.g. jq).")
+	fmt.Println("  Extracts the MIDI information as JSON, NDJSON or YAML for use with other tools (e.g. jq) or as midicsv")
+	fmt.Println("  compatible CSV.")
 	fmt.Println()
-	fmt.Println("    midiasm export [--debug] [--verbose] [--C4] [--out <file>] <MIDI file>")
+	fmt.Println("    midiasm export [--debug] [--verbose] [--C4] [--encoding <name>] [--format json|ndjson|yaml|csv] [--out <file>] [--where <query>] <MIDI file>")
 	fmt.Println()
-	fmt.Println("      <MIDI file>  MIDI file to export as JSON.")
+	fmt.Println("      <MIDI file>  MIDI file to export as JSON, NDJSON, YAML or CSV.")
 	fmt.Println()
 	fmt.Println("    Options:")
 	fmt.Println()
-	fmt.Println("      --out <file>  Writes the JSON to a file. Default is to write to stdout.")
-	fmt.Println("      --C4          Uses C4 as middle C (Yamaha convention). Defaults to C3.")
-	fmt.Println("      --debug       Displays internal information while processing a MIDI file. Defaults to false")
-	fmt.Println("      --verbose     Enables 'verbose' logging. Defaults to false")
+	fmt.Println("      --format <format>  Export format ('json', 'ndjson', 'yaml' or 'csv'). NDJSON is one JSON object per line i.e. a")
+	fmt.Println("                         header record, then a track record and an event record for each event. YAML has the")
+	fmt.Println("                         same document model as JSON. The CSV format is the midicsv record format. Defaults to")
+	fmt.Println("                         the format for the --out file extension (.json, .ndjson, .jsonl, .yaml, .yml or .csv)")
+	fmt.Println("                         or json.")
+	fmt.Println("      --out <file>       Writes the export to a file. Default is to write to stdout.")
+	fmt.Println("      --where <query>    Only includes the events that match the query e.g. \"tag=Tempo\".")
+	fmt.Println("      --encoding <name>  Character encoding of the text events ('auto', 'utf-8', 'latin-1', 'windows-1252',")
+	fmt.Println("                         'shift-jis' or 'euc-jp'). Defaults to 'auto'.")
+	fmt.Println("      --C4               Uses C4 as middle C (Yamaha convention). Defaults to C3.")
+	fmt.Println("      --debug            Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose          Enables 'verbose' logging. Defaults to false")
 	fmt.Println()
 	fmt.Println("    Example:")
 	fmt.Println()
 	fmt.Println("      midiasm export --debug --verbose --out one-time.json one-time.mid")
+	fmt.Println("      midiasm export --format csv --out one-time.csv one-time.mid")
+	fmt.Println("      midiasm export --format ndjson --out one-time.ndjson one-time.mid")
+	fmt.Println("      midiasm export --out one-time.yaml one-time.mid")
 	fmt.Println()
 }
 
 func (x export) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return x.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (x export) Process(filename string, out string) error {
+	x.out = out
+
+	return x.process(filename)
+}
+
+func (x export) Inputs() []string {
+	return midifiles
+}
+
+func (x export) Extension() string {
+	switch x.format {
+	case "csv":
+		return ".csv"
 
+	case "ndjson":
+		return ".ndjson"
+
+	case "yaml":
+		return ".yaml"
+
+	default:
+		return ".json"
+	}
+}
+
+func (x export) process(filename string) error {
 	smf, err := decode(filename)
 	if err != nil {
 		return err
@@ -63,7 +116,34 @@ func (x export) Execute(flagset *flag.FlagSet) error {
 }
 
 func (x export) execute(smf *midi.SMF) error {
-	op, err := impl.NewExport()
+	if x.where != "" {
+		if q, err := mql.Parse(x.where); err != nil {
+			return err
+		} else if smf, err = mql.Filter(smf, q); err != nil {
+			return err
+		}
+	}
+
+	var op exporter
+	var err error
+
+	switch x.formatOf() {
+	case "json":
+		op, err = impl.NewExport()
+
+	case "ndjson":
+		op, err = impl.NewNDJSON()
+
+	case "yaml":
+		op, err = impl.NewYAML()
+
+	case "csv":
+		op, err = impl.NewCSV()
+
+	default:
+		return fmt.Errorf("invalid export format (%v): expected 'json', 'ndjson', 'yaml' or 'csv'", x.format)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -71,7 +151,29 @@ func (x export) execute(smf *midi.SMF) error {
 	return x.write(op, smf)
 }
 
-func (x export) write(op *impl.Export, smf *midi.SMF) error {
+// formatOf returns the --format option or, if not specified, the format for the --out file
+// extension (defaulting to JSON).
+func (x export) formatOf() string {
+	if x.format != "" {
+		return x.format
+	}
+
+	switch strings.ToLower(filepath.Ext(x.out)) {
+	case ".csv":
+		return "csv"
+
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+
+	case ".yaml", ".yml":
+		return "yaml"
+
+	default:
+		return "json"
+	}
+}
+
+func (x export) write(op exporter, smf *midi.SMF) error {
 	out := os.Stdout
 
 	if x.out != "" {
@@ -0,0 +1,130 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/key"
+)
+
+type key struct {
+	out    string
+	window uint
+	json   bool
+	insert string
+}
+
+var Key = key{}
+
+func (k *key) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&k.out, "out", "", "Output file path")
+	flagset.UintVar(&k.window, "window", 0, "Analysis window (in bars). Defaults to the whole piece")
+	flagset.BoolVar(&k.json, "json", false, "Formats the output as JSON")
+	flagset.StringVar(&k.insert, "insert", "", "Writes a copy of the MIDI file with the detected KeySignature events inserted into track 0")
+
+	return flagset
+}
+
+func (k key) Help() {
+	fmt.Println()
+	fmt.Println("  Estimates the key (or keys) of a MIDI file from the notes and reports the detected key(s) with a confidence score.")
+	fmt.Println()
+	fmt.Println("    midiasm key [--debug] [--verbose] [--C4] [--window <bars>] [--json] [--out <file>] [--insert <MIDI file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      --window <bars>       Analyses the notes in windows of N bars. Defaults to the whole piece.")
+	fmt.Println("      --json                Formats the output as JSON.")
+	fmt.Println("      --out <file>          Writes the detected keys to a file. Default is to write to stdout.")
+	fmt.Println("      --insert <MIDI file>  Writes a copy of the MIDI file with the detected keys as KeySignature events in track 0.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --C4       Uses C4 as middle C (Yamaha convention). Defaults to C3.")
+	fmt.Println("      --debug    Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose  Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm key --debug --verbose --window 8 --insert one-time+key.mid one-time.mid")
+	fmt.Println()
+}
+
+func (k key) Execute(flagset *flag.FlagSet) error {
+	return k.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (k key) Process(filename string, out string) error {
+	k.out = out
+
+	if k.insert != "" {
+		return fmt.Errorf("--insert is not supported when processing multiple files")
+	}
+
+	return k.process(filename)
+}
+
+func (k key) Inputs() []string {
+	return midifiles
+}
+
+func (k key) Extension() string {
+	if k.json {
+		return ".json"
+	}
+
+	return ".key"
+}
+
+func (k key) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return k.execute(smf)
+}
+
+func (k key) execute(smf *midi.SMF) error {
+	op := impl.Key{
+		Window: k.window,
+	}
+
+	estimates, err := op.Detect(smf)
+	if err != nil {
+		return err
+	}
+
+	if k.insert != "" {
+		if encoded, err := op.Insert(smf, estimates); err != nil {
+			return err
+		} else if err := os.WriteFile(k.insert, encoded, 0660); err != nil {
+			return err
+		}
+	}
+
+	w := os.Stdout
+	if k.out != "" {
+		if w, err = os.Create(k.out); err != nil {
+			return err
+		}
+
+		defer w.Close()
+	}
+
+	if k.json {
+		return impl.Export(estimates, w)
+	} else {
+		return impl.Print(estimates, w)
+	}
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/export"
+)
+
+type lilypond struct {
+	out      string
+	quantise int
+	triplets bool
+}
+
+var LilyPond = lilypond{}
+
+func (x *lilypond) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&x.out, "out", "", "Output file path")
+	flagset.IntVar(&x.quantise, "quantise", 16, "Shortest notated value (1, 2, 4, 8, 16, 32 or 64). Defaults to 16")
+	flagset.BoolVar(&x.triplets, "triplets", true, "Notates notes that fall on a triplet grid as triplets. Defaults to true")
+
+	return flagset
+}
+
+func (x lilypond) Help() {
+	fmt.Println()
+	fmt.Println("  Converts the notes in a MIDI file to a LilyPond score, with a staff for each track and channel.")
+	fmt.Println()
+	fmt.Println("    midiasm lilypond [--debug] [--verbose] [--C4] [--quantise <value>] [--triplets=false] [--out <file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <MIDI file>  MIDI file to convert to LilyPond.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --quantise <value>  Shortest notated value e.g. 16 for sixteenth notes. Note start and end times are")
+	fmt.Println("                          quantised to this value. Defaults to 16.")
+	fmt.Println("      --triplets          Notates notes that fall on a triplet grid as triplets. Defaults to true.")
+	fmt.Println("      --out <file>        Writes the LilyPond score to a file. Default is to write to stdout.")
+	fmt.Println("      --C4                Uses C4 as middle C (Yamaha convention) for the note octaves. Defaults to C3.")
+	fmt.Println("      --debug             Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose           Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm lilypond --quantise 8 --out greensleeves.ly greensleeves.mid")
+	fmt.Println()
+}
+
+func (x lilypond) Execute(flagset *flag.FlagSet) error {
+	return x.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (x lilypond) Process(filename string, out string) error {
+	x.out = out
+
+	return x.process(filename)
+}
+
+func (x lilypond) Inputs() []string {
+	return midifiles
+}
+
+func (x lilypond) Extension() string {
+	return ".ly"
+}
+
+func (x lilypond) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return x.execute(smf)
+}
+
+func (x lilypond) execute(smf *midi.SMF) error {
+	op, err := impl.NewLilyPond()
+	if err != nil {
+		return err
+	}
+
+	op.Quantise = x.quantise
+	op.Triplets = x.triplets
+
+	out := os.Stdout
+	if x.out != "" {
+		w, err := os.Create(x.out)
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+
+		out = w
+	}
+
+	return op.Export(smf, out)
+}
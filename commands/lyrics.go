@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/lyrics"
+)
+
+type lyrics struct {
+	out      string
+	format   string
+	enhanced bool
+}
+
+var Lyrics = lyrics{}
+
+func (l *lyrics) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&l.out, "out", "", "Output file path")
+	flagset.StringVar(&l.format, "format", "lrc", "Output format ('lrc', 'srt' or 'vtt'). Defaults to 'lrc'")
+	flagset.BoolVar(&l.enhanced, "enhanced", false, "Includes the syllable times (LRC and WebVTT only)")
+
+	return flagset
+}
+
+func (l lyrics) Help() {
+	fmt.Println()
+	fmt.Println("  Extracts the lyrics from a MIDI or karaoke (.kar) file as LRC, SRT or WebVTT subtitles.")
+	fmt.Println()
+	fmt.Println("    midiasm lyrics [--debug] [--verbose] [--format lrc|srt|vtt] [--enhanced] [--out <file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <MIDI file>  MIDI or .kar file with Lyric or .kar Text events.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --format <format>  Output format ('lrc', 'srt' or 'vtt'). Defaults to lrc.")
+	fmt.Println("      --enhanced         Includes the time of each syllable (enhanced LRC or WebVTT cue timestamps).")
+	fmt.Println("      --out <file>       Writes the lyrics to a file. Default is to write to stdout.")
+	fmt.Println("      --debug            Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose          Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm lyrics --format lrc --enhanced --out song.lrc song.kar")
+	fmt.Println()
+}
+
+func (l lyrics) Execute(flagset *flag.FlagSet) error {
+	return l.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (l lyrics) Process(filename string, out string) error {
+	l.out = out
+
+	return l.process(filename)
+}
+
+func (l lyrics) Inputs() []string {
+	return karaokefiles
+}
+
+func (l lyrics) Extension() string {
+	if format, err := impl.ParseFormat(l.format); err == nil {
+		return format.Extension()
+	}
+
+	return ".lrc"
+}
+
+func (l lyrics) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return l.execute(smf)
+}
+
+func (l lyrics) execute(smf *midi.SMF) error {
+	format, err := impl.ParseFormat(l.format)
+	if err != nil {
+		return err
+	}
+
+	op := impl.Lyrics{
+		Enhanced: l.enhanced,
+	}
+
+	song, err := op.Extract(smf)
+	if err != nil {
+		return err
+	}
+
+	out := os.Stdout
+	if l.out != "" {
+		w, err := os.Create(l.out)
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+
+		out = w
+	}
+
+	return op.Write(song, format, out)
+}
@@ -0,0 +1,187 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/markers"
+)
+
+type markers struct {
+	out      string
+	format   string
+	importf  string
+	filename string
+}
+
+var Markers = markers{}
+
+func (m *markers) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&m.out, "out", "", "Output file path")
+	flagset.StringVar(&m.format, "format", "", "Output format ('text', 'audacity', 'reaper', 'cue' or 'json'). Defaults to 'text'")
+	flagset.StringVar(&m.importf, "import", "", "Inserts the markers from an Audacity, Reaper, CUE or JSON file into track 0")
+
+	return flagset
+}
+
+func (m markers) Help() {
+	fmt.Println()
+	fmt.Println("  Exports the Marker and CuePoint events in a MIDI file with bar:beat and wall-clock positions, or inserts Marker")
+	fmt.Println("  events into track 0 from an exported markers file.")
+	fmt.Println()
+	fmt.Println("    midiasm markers [--debug] [--verbose] [--format text|audacity|reaper|cue|json] [--out <file>] <MIDI file>")
+	fmt.Println("    midiasm markers [--debug] [--verbose] --import <file> [--format audacity|reaper|cue|json] --out <MIDI file> <MIDI file>")
+	fmt.Println()
+	fmt.Println("      --format <format>  Output format ('text', 'audacity', 'reaper', 'cue' or 'json'). Defaults to 'text'.")
+	fmt.Println("                         For --import the format defaults to the format for the file extension (.txt, .csv,")
+	fmt.Println("                         .cue or .json).")
+	fmt.Println("      --import <file>    Replaces the Marker events in track 0 with the markers from an Audacity label track,")
+	fmt.Println("                         Reaper region/marker list, CUE sheet or JSON file.")
+	fmt.Println("      --out <file>       Writes the markers (or MIDI file for --import) to a file. Default is to write to stdout.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --debug    Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose  Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm markers --format reaper --out song.csv song.mid")
+	fmt.Println("      midiasm markers --import song.csv --out song+markers.mid song.mid")
+	fmt.Println()
+}
+
+func (m markers) Execute(flagset *flag.FlagSet) error {
+	return m.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (m markers) Process(filename string, out string) error {
+	m.out = out
+
+	if m.importf != "" {
+		return fmt.Errorf("--import is not supported when processing multiple files")
+	}
+
+	return m.process(filename)
+}
+
+func (m markers) Inputs() []string {
+	return midifiles
+}
+
+func (m markers) Extension() string {
+	if m.importf != "" {
+		return ".mid"
+	}
+
+	if format, err := impl.ParseFormat(m.format); err == nil {
+		return format.Extension()
+	}
+
+	return ".markers"
+}
+
+func (m markers) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	m.filename = filename
+
+	return m.execute(smf)
+}
+
+func (m markers) execute(smf *midi.SMF) error {
+	op := impl.Markers{}
+
+	if m.importf != "" {
+		return m.insert(op, smf)
+	}
+
+	format, err := impl.ParseFormat(m.format)
+	if err != nil {
+		return err
+	}
+
+	markers, err := op.Extract(smf)
+	if err != nil {
+		return err
+	}
+
+	var w = os.Stdout
+
+	if m.out != "" {
+		if w, err = os.Create(m.out); err != nil {
+			return err
+		}
+
+		defer w.Close()
+	}
+
+	switch format {
+	case impl.Audacity:
+		return impl.Labels(markers, w)
+
+	case impl.Reaper:
+		return impl.Regions(markers, w)
+
+	case impl.CUE:
+		file := strings.TrimSuffix(filepath.Base(m.filename), filepath.Ext(m.filename)) + ".wav"
+
+		return impl.Cue(markers, file, w)
+
+	case impl.JSON:
+		return impl.Export(markers, w)
+
+	default:
+		return impl.Print(markers, w)
+	}
+}
+
+func (m markers) insert(op impl.Markers, smf *midi.SMF) error {
+	if m.out == "" {
+		return fmt.Errorf("--import requires an output file (--out)")
+	}
+
+	format, err := impl.FormatOf(m.importf)
+	if m.format != "" {
+		format, err = impl.ParseFormat(m.format)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(m.importf)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	markers, err := op.Import(smf, format, f)
+	if err != nil {
+		return err
+	}
+
+	if encoded, err := op.Insert(smf, markers); err != nil {
+		return err
+	} else {
+		return os.WriteFile(m.out, encoded, 0660)
+	}
+}
@@ -7,12 +7,14 @@ import (
 
 	"github.com/transcriptaze/midiasm/midi"
 	impl "github.com/transcriptaze/midiasm/ops/notes"
+	mql "github.com/transcriptaze/midiasm/ops/query"
 )
 
 type notes struct {
 	out       string
 	transpose int
 	json      bool
+	where     string
 }
 
 var Notes = notes{}
@@ -21,6 +23,7 @@ func (n *notes) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.StringVar(&n.out, "out", "", "Output file path")
 	flagset.IntVar(&n.transpose, "transpose", 0, "Transpose notes up or down")
 	flagset.BoolVar(&n.json, "json", false, "Formats the output as JSON")
+	flagset.StringVar(&n.where, "where", "", "Only includes the notes that match the query expression")
 
 	return flagset
 }
@@ -29,9 +32,10 @@ func (n notes) Help() {
 	fmt.Println()
 	fmt.Println("  Extracts the NoteOn and NoteOff events to generate a list of notes with start times and durations.")
 	fmt.Println()
-	fmt.Println("    midiasm notes [--debug] [--verbose] [--C4] [--out <file>] <MIDI file>")
+	fmt.Println("    midiasm notes [--debug] [--verbose] [--C4] [--out <file>] [--where <query>] <MIDI file>")
 	fmt.Println()
-	fmt.Println("      --out <file>  Writes the notes to a file. Default is to write to stdout.")
+	fmt.Println("      --out <file>     Writes the notes to a file. Default is to write to stdout.")
+	fmt.Println("      --where <query>  Only includes the notes that match the query e.g. \"channel=1 and note>=C3\".")
 	fmt.Println()
 	fmt.Println("    Options:")
 	fmt.Println()
@@ -46,8 +50,29 @@ func (n notes) Help() {
 }
 
 func (n notes) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return n.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (n notes) Process(filename string, out string) error {
+	n.out = out
 
+	return n.process(filename)
+}
+
+func (n notes) Inputs() []string {
+	return midifiles
+}
+
+func (n notes) Extension() string {
+	if n.json {
+		return ".json"
+	}
+
+	return ".notes"
+}
+
+func (n notes) process(filename string) error {
 	smf, err := decode(filename)
 	if err != nil {
 		return err
@@ -84,5 +109,16 @@ func (n notes) execute(smf *midi.SMF) error {
 		Writer:    w,
 	}
 
+	if n.where != "" {
+		q, err := mql.Parse(n.where)
+		if err != nil {
+			return err
+		}
+
+		op.Where = func(note impl.Note) bool {
+			return q.Match(mql.Note(note))
+		}
+	}
+
 	return op.Execute(smf)
 }
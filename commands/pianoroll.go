@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/pianoroll"
+)
+
+type pianoroll struct {
+	out    string
+	axis   string
+	colour string
+	bars   string
+	tracks string
+	lanes  string
+}
+
+var PianoRoll = pianoroll{}
+
+func (p *pianoroll) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&p.out, "out", "", "Output file path")
+	flagset.StringVar(&p.axis, "axis", "ticks", "Time axis ('ticks' or 'seconds'). Defaults to 'ticks'")
+	flagset.StringVar(&p.colour, "colour", "channel", "Colours the notes by 'channel' or 'track'. Defaults to 'channel'")
+	flagset.StringVar(&p.bars, "bars", "", "Bar range e.g. 5-8. Defaults to all bars")
+	flagset.StringVar(&p.tracks, "tracks", "", "Comma separated list of tracks. Defaults to all tracks")
+	flagset.StringVar(&p.lanes, "lanes", "", "Comma separated list of controller numbers and 'pitch-bend' to render as lanes")
+
+	return flagset
+}
+
+func (p pianoroll) Help() {
+	fmt.Println()
+	fmt.Println("  Renders the notes in a MIDI file as an SVG piano roll.")
+	fmt.Println()
+	fmt.Println("    midiasm pianoroll [--debug] [--verbose] [--C4] [--axis ticks|seconds] [--colour channel|track] [--bars <range>] [--tracks <list>] [--lanes <list>] [--out <file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <MIDI file>  MIDI file to render.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --axis <ticks|seconds>    Time axis, with the bar lines from the time signatures. Defaults to ticks.")
+	fmt.Println("      --colour <channel|track>  Colours the notes by channel or by track. Defaults to channel.")
+	fmt.Println("      --bars <range>            Renders a range of bars e.g. 5-8, 5- or 5. Defaults to all bars.")
+	fmt.Println("      --tracks <list>           Renders the notes in a comma separated list of tracks e.g. 1,3. Defaults to all tracks.")
+	fmt.Println("      --lanes <list>            Comma separated list of controller numbers and 'pitch-bend' to render as lanes")
+	fmt.Println("                                below the piano roll e.g. 7,64,pitch-bend.")
+	fmt.Println("      --out <file>              Writes the SVG to a file. Default is to write to stdout.")
+	fmt.Println("      --C4                      Uses C4 as middle C (Yamaha convention) for the keyboard legend. Defaults to C3.")
+	fmt.Println("      --debug                   Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose                 Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm pianoroll --axis seconds --bars 1-8 --lanes 7,pitch-bend --out greensleeves.svg greensleeves.mid")
+	fmt.Println()
+}
+
+func (p pianoroll) Execute(flagset *flag.FlagSet) error {
+	return p.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (p pianoroll) Process(filename string, out string) error {
+	p.out = out
+
+	return p.process(filename)
+}
+
+func (p pianoroll) Inputs() []string {
+	return midifiles
+}
+
+func (p pianoroll) Extension() string {
+	return ".svg"
+}
+
+func (p pianoroll) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return p.execute(smf)
+}
+
+func (p pianoroll) execute(smf *midi.SMF) error {
+	op := impl.PianoRoll{}
+
+	if axis, err := impl.ParseAxis(p.axis); err != nil {
+		return err
+	} else {
+		op.Axis = axis
+	}
+
+	if colour, err := impl.ParseColour(p.colour); err != nil {
+		return err
+	} else {
+		op.Colour = colour
+	}
+
+	if from, to, err := impl.ParseBars(p.bars); err != nil {
+		return err
+	} else {
+		op.From = from
+		op.To = to
+	}
+
+	if tracks, err := impl.ParseTracks(p.tracks); err != nil {
+		return err
+	} else {
+		op.Tracks = tracks
+	}
+
+	if lanes, err := impl.ParseLanes(p.lanes); err != nil {
+		return err
+	} else {
+		op.Lanes = lanes
+	}
+
+	out := os.Stdout
+	if p.out != "" {
+		w, err := os.Create(p.out)
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+
+		out = w
+	}
+
+	return op.Render(smf, out)
+}
@@ -0,0 +1,186 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	disassembler "github.com/transcriptaze/midiasm/ops/disassemble"
+	impl "github.com/transcriptaze/midiasm/ops/query"
+)
+
+type query struct {
+	out  string
+	json bool
+	tsv  bool
+}
+
+var Query = query{}
+
+func (q *query) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&q.out, "out", "", "Output file path")
+	flagset.BoolVar(&q.json, "json", false, "Formats the output as JSON")
+	flagset.BoolVar(&q.tsv, "tsv", false, "Formats the output as TSV")
+
+	return flagset
+}
+
+func (q query) Help() {
+	fmt.Println()
+	fmt.Println("  Lists the events in a MIDI file that match a query expression.")
+	fmt.Println()
+	fmt.Println("    midiasm query [--debug] [--verbose] [--C4] [--json] [--tsv] [--out <file>] <query> <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <query>       Filter expression e.g. \"track=2 and tag=Controller and controller=64 and tick>=1920\".")
+	fmt.Println("      --json        Formats the output as JSON.")
+	fmt.Println("      --tsv         Formats the output as TSV.")
+	fmt.Println("      --out <file>  Writes the matching events to a file. Default is to write to stdout.")
+	fmt.Println()
+	fmt.Println("    Query expressions:")
+	fmt.Println()
+	fmt.Println("      Comparisons (=, !=, <, <=, >, >= and ~ for regular expressions) combined with 'and', 'or', 'not'")
+	fmt.Println("      and parentheses. The fields are:")
+	fmt.Println()
+	fmt.Println("        track, tick, delta, tag, channel, note, velocity, controller, value, bank, program,")
+	fmt.Println("        pressure, bend, tempo, key, numerator, denominator, text, manufacturer")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --C4       Uses C4 as middle C (Yamaha convention). Defaults to C3.")
+	fmt.Println("      --debug    Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose  Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm query --tsv \"tag=NoteOn and note>=C4\" one-time.mid")
+	fmt.Println()
+}
+
+func (q query) Execute(flagset *flag.FlagSet) error {
+	if flagset.NArg() < 2 {
+		return fmt.Errorf("query requires a query expression and a MIDI file")
+	}
+
+	expression := flagset.Arg(0)
+	filename := flagset.Arg(1)
+
+	qq, err := impl.Parse(expression)
+	if err != nil {
+		return err
+	}
+
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	if filtered, err := impl.Filter(smf, qq); err != nil {
+		return err
+	} else {
+		return q.execute(filtered)
+	}
+}
+
+func (q query) execute(smf *midi.SMF) error {
+	var err error
+
+	w := os.Stdout
+	if q.out != "" {
+		if w, err = os.Create(q.out); err != nil {
+			return err
+		}
+
+		defer w.Close()
+	}
+
+	switch {
+	case q.json:
+		return q.exportJSON(smf, w)
+
+	case q.tsv:
+		return q.exportTSV(smf, w)
+
+	default:
+		return q.print(smf, w)
+	}
+}
+
+func (q query) print(smf *midi.SMF, w io.Writer) error {
+	op, err := disassembler.NewDisassemble()
+	if err != nil {
+		return err
+	}
+
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			fmt.Fprintf(w, "MTrk %v ", track.TrackNumber)
+
+			if err := op.Print(e, "event", w); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (q query) exportTSV(smf *midi.SMF, w io.Writer) error {
+	header := []string{"Track", "Tick", "Delta", "Tag", "Channel", "Note", "Velocity", "Details"}
+	records := [][]string{}
+
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			record := append([]string{fmt.Sprintf("%v", uint(track.TrackNumber))}, fields(e.Event)...)
+			records = append(records, record)
+		}
+	}
+
+	return writeTSV(header, records, '\t', w)
+}
+
+func (q query) exportJSON(smf *midi.SMF, w io.Writer) error {
+	type event struct {
+		Track lib.TrackNumber `json:"track"`
+		Tick  uint64          `json:"tick"`
+		Event events.IEvent   `json:"event"`
+	}
+
+	list := []event{}
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			list = append(list, event{
+				Track: track.TrackNumber,
+				Tick:  e.Tick(),
+				Event: e.Event,
+			})
+		}
+	}
+
+	object := struct {
+		Events []event `json:"events"`
+	}{
+		Events: list,
+	}
+
+	if bytes, err := json.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(bytes); err != nil {
+		return err
+	}
+
+	return nil
+}
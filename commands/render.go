@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/render"
+)
+
+type render struct {
+	out        string
+	sampleRate int
+	bits       int
+}
+
+var Render = render{}
+
+func (r *render) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&r.out, "out", "", "Output file path")
+	flagset.IntVar(&r.sampleRate, "sample-rate", 44100, "Sample rate (Hz). Defaults to 44100")
+	flagset.IntVar(&r.bits, "bits", 16, "Bit depth (16 or 24). Defaults to 16")
+
+	return flagset
+}
+
+func (r render) Help() {
+	fmt.Println()
+	fmt.Println("  Synthesises a MIDI file to a stereo WAV file using simple built-in instruments.")
+	fmt.Println()
+	fmt.Println("    midiasm render [--debug] [--verbose] [--sample-rate <Hz>] [--bits 16|24] [--out <file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <MIDI file>  MIDI file to render.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --sample-rate <Hz>  Sample rate in the range 8000 to 192000. Defaults to 44100.")
+	fmt.Println("      --bits <16|24>      Bit depth. Defaults to 16.")
+	fmt.Println("      --out <file>        Writes the WAV to a file. Default is to write to stdout.")
+	fmt.Println("      --debug             Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose           Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm render --sample-rate 48000 --bits 24 --out greensleeves.wav greensleeves.mid")
+	fmt.Println()
+}
+
+func (r render) Execute(flagset *flag.FlagSet) error {
+	return r.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (r render) Process(filename string, out string) error {
+	r.out = out
+
+	return r.process(filename)
+}
+
+func (r render) Inputs() []string {
+	return midifiles
+}
+
+func (r render) Extension() string {
+	return ".wav"
+}
+
+func (r render) process(filename string) error {
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return r.execute(smf)
+}
+
+func (r render) execute(smf *midi.SMF) error {
+	op, err := impl.NewRender()
+	if err != nil {
+		return err
+	}
+
+	op.SampleRate = r.sampleRate
+	op.BitDepth = r.bits
+
+	out := os.Stdout
+	if r.out != "" {
+		w, err := os.Create(r.out)
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+
+		out = w
+	}
+
+	return op.Render(smf, out)
+}
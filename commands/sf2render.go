@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/transcriptaze/midiasm/midi"
+	impl "github.com/transcriptaze/midiasm/ops/render"
+)
+
+type sf2render struct {
+	out        string
+	soundfont  string
+	sampleRate int
+	bits       int
+}
+
+var SF2Render = sf2render{}
+
+func (r *sf2render) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&r.out, "out", "", "Output file path")
+	flagset.StringVar(&r.soundfont, "soundfont", "", "SoundFont (SF2) file")
+	flagset.IntVar(&r.sampleRate, "sample-rate", 44100, "Sample rate (Hz). Defaults to 44100")
+	flagset.IntVar(&r.bits, "bits", 16, "Bit depth (16 or 24). Defaults to 16")
+
+	return flagset
+}
+
+func (r sf2render) Help() {
+	fmt.Println()
+	fmt.Println("  Renders a MIDI file to a stereo WAV file using the instruments from a SoundFont (SF2) file.")
+	fmt.Println()
+	fmt.Println("    midiasm sf2render [--debug] [--verbose] --soundfont <SF2 file> [--sample-rate <Hz>] [--bits 16|24] [--out <file>] <MIDI file>")
+	fmt.Println()
+	fmt.Println("      <MIDI file>  MIDI file to render.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --soundfont <file>  SoundFont (SF2) file with the presets for the MIDI bank and program numbers.")
+	fmt.Println("      --sample-rate <Hz>  Sample rate in the range 8000 to 192000. Defaults to 44100.")
+	fmt.Println("      --bits <16|24>      Bit depth. Defaults to 16.")
+	fmt.Println("      --out <file>        Writes the WAV to a file. Default is to write to stdout.")
+	fmt.Println("      --debug             Displays internal information while processing a MIDI file. Defaults to false")
+	fmt.Println("      --verbose           Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm sf2render --soundfont GeneralUser.sf2 --out greensleeves.wav greensleeves.mid")
+	fmt.Println()
+}
+
+func (r sf2render) Execute(flagset *flag.FlagSet) error {
+	return r.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (r sf2render) Process(filename string, out string) error {
+	r.out = out
+
+	return r.process(filename)
+}
+
+func (r sf2render) Inputs() []string {
+	return midifiles
+}
+
+func (r sf2render) Extension() string {
+	return ".wav"
+}
+
+func (r sf2render) process(filename string) error {
+	if r.soundfont == "" {
+		return fmt.Errorf("missing SoundFont file (--soundfont)")
+	}
+
+	smf, err := decode(filename)
+	if err != nil {
+		return err
+	}
+
+	if errors := smf.Validate(); len(errors) > 0 {
+		fmt.Fprintln(os.Stderr)
+		fmt.Fprintf(os.Stderr, "WARNING: there are validation errors:\n")
+		for _, e := range errors {
+			fmt.Fprintf(os.Stderr, "         ** %v\n", e)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+
+	return r.execute(smf)
+}
+
+func (r sf2render) execute(smf *midi.SMF) error {
+	f, err := os.Open(r.soundfont)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	sf, err := impl.LoadSoundFont(f)
+	if err != nil {
+		return err
+	}
+
+	op, err := impl.NewSF2Render(sf)
+	if err != nil {
+		return err
+	}
+
+	op.SampleRate = r.sampleRate
+	op.BitDepth = r.bits
+
+	out := os.Stdout
+	if r.out != "" {
+		w, err := os.Create(r.out)
+		if err != nil {
+			return err
+		}
+
+		defer w.Close()
+
+		out = w
+	}
+
+	return op.Render(smf, out)
+}
@@ -45,8 +45,25 @@ func (t transpose) Help() {
 }
 
 func (t transpose) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return t.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (t transpose) Process(filename string, out string) error {
+	t.out = out
+
+	return t.process(filename)
+}
+
+func (t transpose) Inputs() []string {
+	return midifiles
+}
+
+func (t transpose) Extension() string {
+	return ".mid"
+}
 
+func (t transpose) process(filename string) error {
 	smf, err := decode(filename)
 	if err != nil {
 		return err
@@ -6,7 +6,10 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/transcriptaze/midiasm/encoding/midi"
@@ -15,12 +18,25 @@ import (
 	"github.com/transcriptaze/midiasm/midi/events/meta"
 	"github.com/transcriptaze/midiasm/midi/events/midi"
 	"github.com/transcriptaze/midiasm/midi/events/sysex"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	mql "github.com/transcriptaze/midiasm/ops/query"
 )
 
 type tsv struct {
 	out       string
 	delimiter string
 	tabular   bool
+	long      bool
+	columns   string
+	where     string
+	mthd      bool
+}
+
+// column is a long format TSV column, with the function that extracts the column value from an event.
+type column struct {
+	name   string
+	header string
+	value  func(e mql.Event) string
 }
 
 var TSV = tsv{}
@@ -29,6 +45,10 @@ func (t *tsv) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
 	flagset.StringVar(&TSV.out, "out", "", "Output file path (or directory for split files)")
 	flagset.StringVar(&TSV.delimiter, "delimiter", "", "Column delimiter.Defaults to TAB")
 	flagset.BoolVar(&TSV.tabular, "tabular", false, "Formats the output as fixed width columns")
+	flagset.BoolVar(&TSV.long, "long", false, "Formats the output as one row per event with typed columns")
+	flagset.StringVar(&TSV.columns, "columns", "", "Comma separated list of columns for the long format. Defaults to all columns")
+	flagset.StringVar(&TSV.where, "where", "", "Only includes the events that match the query expression")
+	flagset.BoolVar(&TSV.mthd, "mthd", false, "Includes the MIDI header (format, tracks and division) as the first row")
 
 	return flagset
 }
@@ -37,7 +57,7 @@ func (t tsv) Help() {
 	fmt.Println()
 	fmt.Println("  Extracts the MIDI information as TSV for use with e.g. a spreadsheet.")
 	fmt.Println()
-	fmt.Println("    midiasm tsv [--debug] [--verbose] [--C4] [--out <file>] [--delimiter <string>] <MIDI file>")
+	fmt.Println("    midiasm tsv [--debug] [--verbose] [--C4] [--encoding <name>] [--out <file>] [--delimiter <string>] [--tabular] [--long] [--columns <list>] [--where <query>] [--mthd] <MIDI file>")
 	fmt.Println()
 	fmt.Println("      <MIDI file>  MIDI file to export as JSON.")
 	fmt.Println()
@@ -46,6 +66,17 @@ func (t tsv) Help() {
 	fmt.Println("      --out <file>          Writes the TSV to a file. Default is to write to stdout.")
 	fmt.Println("      --delimiter <string>  Column separator (defaults to TAB).")
 	fmt.Println("      --tabular             Formats the output as fixed width columns.")
+	fmt.Println("      --long                Formats the output as one row per event (for all tracks) with typed columns.")
+	fmt.Println("      --columns <list>      Comma separated list of columns for the long format e.g. \"track,tick,tag,note,velocity\".")
+	fmt.Println("                            The columns are:")
+	fmt.Println("                              track, tick, delta, tag, channel, note, note-name, velocity, controller,")
+	fmt.Println("                              controller-name, value, bank, program, pressure, bend, tempo, bpm, numerator,")
+	fmt.Println("                              denominator, key, text, manufacturer, data")
+	fmt.Println("      --where <query>       Only includes the events that match the query e.g. \"track=1 and tag=NoteOn\".")
+	fmt.Println("      --mthd                Includes the MIDI header (format, tracks and division) as the first row, so that")
+	fmt.Println("                            'assemble' uses the original format and division instead of --ppqn.")
+	fmt.Println("      --encoding <name>     Character encoding of the text events ('auto', 'utf-8', 'latin-1', 'windows-1252',")
+	fmt.Println("                            'shift-jis' or 'euc-jp'). Defaults to 'auto'.")
 	fmt.Println("      --C4                  Uses C4 as middle C (Yamaha convention). Defaults to C3.")
 	fmt.Println("      --debug               Displays internal information while processing a MIDI file. Defaults to false")
 	fmt.Println("      --verbose             Enables 'verbose' logging. Defaults to false")
@@ -53,30 +84,54 @@ func (t tsv) Help() {
 	fmt.Println("    Example:")
 	fmt.Println()
 	fmt.Println("      midiasm tsv --debug --verbose --out one-time.tsv one-time.mid")
+	fmt.Println("      midiasm tsv --long --columns track,tick,tag,note,velocity --out one-time.tsv one-time.mid")
 	fmt.Println()
 }
 
 func (t tsv) Execute(flagset *flag.FlagSet) error {
-	filename := flagset.Arg(0)
+	return t.process(flagset.Arg(0))
+}
+
+// Process implements Batchable.
+func (t tsv) Process(filename string, out string) error {
+	t.out = out
+
+	return t.process(filename)
+}
+
+func (t tsv) Inputs() []string {
+	return midifiles
+}
+
+func (t tsv) Extension() string {
+	if t.tabular {
+		return ".tab"
+	}
 
+	return ".tsv"
+}
+
+func (t tsv) process(filename string) error {
 	if b, err := os.ReadFile(filename); err != nil {
 		return err
 	} else if smf, err := t.decode(bytes.NewBuffer(b)); err != nil {
 		return err
 	} else if err := t.validate(smf); err != nil {
 		return err
-	} else if header, records, err := t.export(smf); err != nil {
+	} else if smf, err := t.filter(smf); err != nil {
+		return err
+	} else if header, records, table, err := t.table(smf); err != nil {
 		return err
 	} else {
 		if t.out == "" {
-			return writeTable(records, os.Stdout)
+			return writeTable(table, os.Stdout)
 		} else if f, err := os.Create(t.out); err != nil {
 			return err
 		} else {
 			defer f.Close()
 
 			if t.tabular {
-				return writeTable(records, f)
+				return writeTable(table, f)
 			} else if t.delimiter == "" || t.delimiter == `\t` {
 				return writeTSV(header, records, '\t', f)
 			} else {
@@ -86,6 +141,42 @@ func (t tsv) Execute(flagset *flag.FlagSet) error {
 	}
 }
 
+// table returns the TSV header and records along with the rows for the fixed width table.
+func (t tsv) table(smf *midi.SMF) ([]string, [][]string, [][]string, error) {
+	var header []string
+	var records [][]string
+	var err error
+
+	if t.long {
+		header, records, err = t.exportLong(smf)
+	} else {
+		header, records, err = t.export(smf)
+	}
+
+	if err != nil {
+		return nil, nil, nil, err
+	} else if t.long {
+		return header, records, append([][]string{header}, records...), nil
+	} else if t.mthd {
+		mthd := mthdRow(smf)
+
+		return mthd, append([][]string{header}, records...), append([][]string{{strings.Join(mthd, "  ")}}, records...), nil
+	}
+
+	return header, records, records, nil
+}
+
+// mthdRow returns the optional first row of the 'wide' table with the MIDI header (format, number
+// of tracks and division) so that the TSV can be reassembled with the original format and division.
+func mthdRow(smf *midi.SMF) []string {
+	return []string{
+		"MThd",
+		fmt.Sprintf("format:%v", smf.MThd.Format),
+		fmt.Sprintf("tracks:%v", smf.MThd.Tracks),
+		fmt.Sprintf("division:%v", smf.MThd.Division),
+	}
+}
+
 func (t tsv) export(smf *midi.SMF) ([]string, [][]string, error) {
 	// ... build table
 	header := []string{"Tick", "Delta", "Tag", "Channel", "Note", "Velocity", "Details"}
@@ -129,6 +220,125 @@ func (t tsv) export(smf *midi.SMF) ([]string, [][]string, error) {
 	return header, records, nil
 }
 
+// exportLong builds a 'long' table with one row per event and a column per event field.
+func (t tsv) exportLong(smf *midi.SMF) ([]string, [][]string, error) {
+	columns := longColumns()
+
+	if t.columns != "" {
+		selected := []column{}
+		for _, name := range strings.Split(t.columns, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if ix := slices.IndexFunc(columns, func(c column) bool { return c.name == name }); ix < 0 {
+				return nil, nil, fmt.Errorf("invalid column (%v)", name)
+			} else {
+				selected = append(selected, columns[ix])
+			}
+		}
+
+		columns = selected
+	}
+
+	header := []string{}
+	for _, c := range columns {
+		header = append(header, c.header)
+	}
+
+	records := [][]string{}
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			event := mql.Event{Track: track.TrackNumber, Event: e.Event}
+			record := make([]string, len(columns))
+
+			for i, c := range columns {
+				record[i] = c.value(event)
+			}
+
+			records = append(records, record)
+		}
+	}
+
+	return header, records, nil
+}
+
+func longColumns() []column {
+	number := func(name string) func(e mql.Event) string {
+		return func(e mql.Event) string {
+			if v, ok := e.Field(name); ok && v.Numeric {
+				return strconv.FormatFloat(v.Number, 'f', -1, 64)
+			}
+
+			return ""
+		}
+	}
+
+	text := func(name string) func(e mql.Event) string {
+		return func(e mql.Event) string {
+			if v, ok := e.Field(name); ok {
+				return v.Text
+			}
+
+			return ""
+		}
+	}
+
+	details := func(e mql.Event) string {
+		if v, ok := e.Event.(metaevent.SMPTEOffset); ok {
+			return fields(v)[6]
+		}
+
+		return text("text")(e)
+	}
+
+	bpm := func(e mql.Event) string {
+		if v, ok := e.Field("tempo"); ok && v.Number > 0 {
+			return strconv.FormatFloat(math.Round(60000000000/v.Number)/1000, 'f', -1, 64)
+		}
+
+		return ""
+	}
+
+	data := func(e mql.Event) string {
+		switch v := e.Event.(type) {
+		case metaevent.SequencerSpecificEvent:
+			return fmt.Sprintf("%v", lib.Hex(v.Data))
+		case sysex.SysExMessage:
+			return fmt.Sprintf("%v", lib.Hex(v.Data))
+		case sysex.SysExContinuationMessage:
+			return fmt.Sprintf("%v", lib.Hex(v.Data))
+		case sysex.SysExEscapeMessage:
+			return fmt.Sprintf("%v", lib.Hex(v.Data))
+		}
+
+		return ""
+	}
+
+	return []column{
+		{"track", "Track", number("track")},
+		{"tick", "Tick", number("tick")},
+		{"delta", "Delta", number("delta")},
+		{"tag", "Tag", text("tag")},
+		{"channel", "Channel", number("channel")},
+		{"note", "Note", number("note")},
+		{"note-name", "NoteName", text("note")},
+		{"velocity", "Velocity", number("velocity")},
+		{"controller", "Controller", number("controller")},
+		{"controller-name", "ControllerName", text("controller")},
+		{"value", "Value", number("value")},
+		{"bank", "Bank", number("bank")},
+		{"program", "Program", number("program")},
+		{"pressure", "Pressure", number("pressure")},
+		{"bend", "Bend", number("bend")},
+		{"tempo", "Tempo", number("tempo")},
+		{"bpm", "BPM", bpm},
+		{"numerator", "Numerator", number("numerator")},
+		{"denominator", "Denominator", number("denominator")},
+		{"key", "Key", text("key")},
+		{"text", "Text", details},
+		{"manufacturer", "Manufacturer", text("manufacturer")},
+		{"data", "Data", data},
+	}
+}
+
 func (t *tsv) decode(r io.Reader) (*midi.SMF, error) {
 	decoder := midifile.NewDecoder()
 
@@ -142,6 +352,16 @@ func (t *tsv) decode(r io.Reader) (*midi.SMF, error) {
 	// }
 }
 
+func (t tsv) filter(smf *midi.SMF) (*midi.SMF, error) {
+	if t.where == "" {
+		return smf, nil
+	} else if q, err := mql.Parse(t.where); err != nil {
+		return nil, err
+	} else {
+		return mql.Filter(smf, q)
+	}
+}
+
 func (t *tsv) validate(smf *midi.SMF) error {
 	errors := smf.Validate()
 
@@ -157,6 +377,8 @@ func (t *tsv) validate(smf *midi.SMF) error {
 	return nil
 }
 
+// writeTable writes the records as fixed width columns. A record with a single field (e.g. the MThd
+// row) is written as is and doesn't affect the column widths.
 func writeTable(records [][]string, w io.Writer) error {
 	columns := 0
 	for _, record := range records {
@@ -169,7 +391,7 @@ func writeTable(records [][]string, w io.Writer) error {
 
 	for _, record := range records {
 		for i, f := range record {
-			if len(f) > widths[i] {
+			if len(f) > widths[i] && len(record) > 1 {
 				widths[i] = len(f)
 			}
 		}
@@ -181,6 +403,11 @@ func writeTable(records [][]string, w io.Writer) error {
 	}
 
 	for _, record := range records {
+		if len(record) == 1 {
+			fmt.Fprintf(w, "%v\n", record[0])
+			continue
+		}
+
 		row := []string{}
 		for i, f := range record {
 			row = append(row, fmt.Sprintf(formats[i], f))
@@ -313,6 +540,7 @@ func fields(v events.IEvent) []string {
 
 	if e, ok := v.(midievent.PolyphonicPressure); ok {
 		channel = fmt.Sprintf("%v", e.Channel)
+		note = fmt.Sprintf("%v", e.Note.Value)
 		details = fmt.Sprintf("%v", e.Pressure)
 	}
 
@@ -13,6 +13,9 @@ var _SMF []byte
 //go:embed test-files/reference.tsv
 var _TSV []byte
 
+//go:embed test-files/reference-long.tsv
+var _TSVLong []byte
+
 func TestTSV(t *testing.T) {
 	var v = tsv{}
 	var b bytes.Buffer
@@ -27,3 +30,109 @@ func TestTSV(t *testing.T) {
 		t.Errorf("Incorrectly exported to TSV file")
 	}
 }
+
+func TestTSVLong(t *testing.T) {
+	var v = tsv{long: true}
+	var b bytes.Buffer
+
+	if smf, err := v.decode(bytes.NewBuffer(_SMF)); err != nil {
+		t.Fatalf("%v", err)
+	} else if header, records, err := v.exportLong(smf); err != nil {
+		t.Fatalf("%v", err)
+	} else if err := writeTSV(header, records, '\t', &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if !reflect.DeepEqual(b.Bytes(), _TSVLong) {
+		t.Errorf("Incorrectly exported to long format TSV file")
+	}
+}
+
+func TestTSVLongColumns(t *testing.T) {
+	var v = tsv{long: true, columns: "track, tick,TAG,note-name,velocity"}
+
+	smf, err := v.decode(bytes.NewBuffer(_SMF))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	header, records, err := v.exportLong(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if expected := []string{"Track", "Tick", "Tag", "NoteName", "Velocity"}; !reflect.DeepEqual(header, expected) {
+		t.Errorf("Incorrect header\n   expected:%v\n   got:     %v", expected, header)
+	}
+
+	if expected := []string{"1", "0", "NoteOn", "C♯3", "72"}; !reflect.DeepEqual(records[26], expected) {
+		t.Errorf("Incorrect record\n   expected:%v\n   got:     %v", expected, records[26])
+	}
+
+	v.columns = "track,pitch"
+	if _, _, err := v.exportLong(smf); err == nil {
+		t.Errorf("Expected error for invalid column")
+	}
+}
+
+func TestTSVLongDataColumn(t *testing.T) {
+	var v = tsv{long: true, columns: "tag,data"}
+
+	smf, err := v.decode(bytes.NewBuffer(_SMF))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	_, records, err := v.exportLong(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := [][]string{
+		{"SequencerSpecificEvent", "3A 4C 5E"},
+		{"SysExMessage", "00 09 01"},
+		{"SysExMessage", "12 00"},
+		{"SysExContinuation", "43 12 00 43 12 00"},
+		{"SysExContinuation", "43 12 00"},
+		{"SysExEscape", "F3 01"},
+	}
+
+	data := [][]string{}
+	for _, record := range records {
+		if record[1] != "" {
+			data = append(data, record)
+		}
+	}
+
+	if !reflect.DeepEqual(data, expected) {
+		t.Errorf("Incorrect data column\n   expected:%v\n   got:     %v", expected, data)
+	}
+}
+
+func TestTSVLongBendColumn(t *testing.T) {
+	var v = tsv{long: true, columns: "tag,bend"}
+
+	smf, err := v.decode(bytes.NewBuffer(_SMF))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	_, records, err := v.exportLong(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// ... E0 00 08 is LSB:0x00, MSB:0x08
+	expected := [][]string{
+		{"PitchBend", "1024"},
+	}
+
+	bend := [][]string{}
+	for _, record := range records {
+		if record[1] != "" {
+			bend = append(bend, record)
+		}
+	}
+
+	if !reflect.DeepEqual(bend, expected) {
+		t.Errorf("Incorrect bend column\n   expected:%v\n   got:     %v", expected, bend)
+	}
+}
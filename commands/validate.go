@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/transcriptaze/midiasm/ops/schema"
+)
+
+type validate struct {
+	out    string
+	json   bool
+	schema bool
+}
+
+var ValidateJSON = validate{}
+
+func (v *validate) Flagset(flagset *flag.FlagSet) *flag.FlagSet {
+	flagset.StringVar(&v.out, "out", "", "Output file path")
+	flagset.BoolVar(&v.json, "json", false, "Formats the schema violations as JSON")
+	flagset.BoolVar(&v.schema, "schema", false, "Writes the JSON schema")
+
+	return flagset
+}
+
+func (v validate) Help() {
+	fmt.Println()
+	fmt.Println("  Validates JSON files against the midiasm JSON schema (the format written by 'export' and read by 'assemble'),")
+	fmt.Println("  reporting each schema violation with the JSON pointer to the offending value.")
+	fmt.Println()
+	fmt.Println("  Exits with 0 if the files are valid, 1 if there are schema violations and 2 if there was an error.")
+	fmt.Println()
+	fmt.Println("    midiasm validate-json [--debug] [--verbose] [--json] [--out <file>] <JSON file> ...")
+	fmt.Println("    midiasm validate-json --schema [--out <file>]")
+	fmt.Println()
+	fmt.Println("      <JSON file>  JSON file(s) to validate.")
+	fmt.Println()
+	fmt.Println("    Options:")
+	fmt.Println()
+	fmt.Println("      --json        Formats the schema violations as JSON.")
+	fmt.Println("      --schema      Writes the JSON schema (draft 2020-12) instead of validating a file.")
+	fmt.Println("      --out <file>  Writes the report (or schema) to a file. Default is to write to stdout.")
+	fmt.Println("      --debug       Displays internal information while processing a file. Defaults to false")
+	fmt.Println("      --verbose     Enables 'verbose' logging. Defaults to false")
+	fmt.Println()
+	fmt.Println("    Example:")
+	fmt.Println()
+	fmt.Println("      midiasm validate-json one-time.json")
+	fmt.Println("      midiasm validate-json --schema --out midiasm.schema.json")
+	fmt.Println()
+}
+
+func (v validate) Execute(flagset *flag.FlagSet) error {
+	var w io.Writer = os.Stdout
+
+	if v.out != "" {
+		f, err := os.Create(v.out)
+		if err != nil {
+			return ExitCode{2, err}
+		}
+
+		defer f.Close()
+
+		w = f
+	}
+
+	if v.schema {
+		if _, err := w.Write(schema.Schema); err != nil {
+			return ExitCode{2, err}
+		}
+
+		return nil
+	}
+
+	if flagset.NArg() == 0 {
+		return ExitCode{2, fmt.Errorf("validate-json requires at least one JSON file")}
+	}
+
+	if valid, err := v.execute(flagset.Args(), w); err != nil {
+		return ExitCode{2, err}
+	} else if !valid {
+		return ExitCode{Code: 1}
+	}
+
+	return nil
+}
+
+func (v validate) execute(files []string, w io.Writer) (bool, error) {
+	type violation struct {
+		File    string `json:"file"`
+		Pointer string `json:"pointer"`
+		Message string `json:"message"`
+	}
+
+	violations := []violation{}
+
+	for _, file := range files {
+		list, err := v.validate(file)
+		if err != nil {
+			return false, err
+		}
+
+		for _, x := range list {
+			violations = append(violations, violation{
+				File:    file,
+				Pointer: x.Pointer,
+				Message: x.Message,
+			})
+		}
+	}
+
+	if v.json {
+		if bytes, err := json.MarshalIndent(violations, "", "  "); err != nil {
+			return false, err
+		} else if _, err := fmt.Fprintln(w, string(bytes)); err != nil {
+			return false, err
+		}
+	} else {
+		for _, x := range violations {
+			pointer := x.Pointer
+			if pointer == "" {
+				pointer = "(root)"
+			}
+
+			if _, err := fmt.Fprintf(w, "%v  %v  %v\n", x.File, pointer, x.Message); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return len(violations) == 0, nil
+}
+
+func (v validate) validate(file string) ([]schema.Violation, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	if violations, err := schema.Validate(f); err != nil {
+		return nil, fmt.Errorf("%v: %v", file, err)
+	} else {
+		return violations, nil
+	}
+}
@@ -16,13 +16,40 @@ type Encoder interface {
 }
 
 type encoder struct {
-	w io.Writer
+	w             io.Writer
+	runningStatus RunningStatus
 }
 
-func NewEncoder(w io.Writer) Encoder {
-	return &encoder{
-		w: w,
+// RunningStatus selects the MIDI events that are encoded using running status.
+type RunningStatus int
+
+const (
+	RunningStatusNotes RunningStatus = iota
+	RunningStatusNone
+	RunningStatusAll
+)
+
+type Option func(*encoder)
+
+// WithRunningStatus sets the running status encoding. The default is to use running status
+// for NoteOn and NoteOff events only.
+func WithRunningStatus(r RunningStatus) Option {
+	return func(e *encoder) {
+		e.runningStatus = r
+	}
+}
+
+func NewEncoder(w io.Writer, options ...Option) Encoder {
+	e := encoder{
+		w:             w,
+		runningStatus: RunningStatusNotes,
 	}
+
+	for _, option := range options {
+		option(&e)
+	}
+
+	return &e
 }
 
 func (e *encoder) Encode(smf midi.SMF) error {
@@ -41,7 +68,7 @@ func (e *encoder) Encode(smf midi.SMF) error {
 	}
 
 	for _, track := range smf.Tracks {
-		if bytes, err := EncodeMTrk(*track); err != nil {
+		if bytes, err := encodeMTrk(*track, e.runningStatus); err != nil {
 			return err
 		} else if _, err := e.w.Write(bytes); err != nil {
 			return err
@@ -52,6 +79,10 @@ func (e *encoder) Encode(smf midi.SMF) error {
 }
 
 func EncodeMTrk(mtrk midi.MTrk) (encoded []byte, err error) {
+	return encodeMTrk(mtrk, RunningStatusNotes)
+}
+
+func encodeMTrk(mtrk midi.MTrk, runningStatus RunningStatus) (encoded []byte, err error) {
 	type chunk struct {
 		delta []byte
 		event []byte
@@ -83,20 +114,24 @@ func EncodeMTrk(mtrk midi.MTrk) (encoded []byte, err error) {
 	}
 
 	// ... running status fixup
-	var last *chunk
+	var last byte
 	for i := range chunks {
 		chunk := &chunks[i]
+		status := chunk.event[0]
+
+		switch {
+		case runningStatus == RunningStatusNotes && ((status&0xf0) == 0x80 || (status&0xf0) == 0x90):
+			if status == last {
+				chunk.event = chunk.event[1:]
+			}
 
-		if last != nil {
-			status := chunk.event[0]
-			if (status&0xf0) == 0x80 || (status&0xf0) == 0x90 {
-				if status == last.event[0] {
-					chunk.event = chunk.event[1:]
-				}
+		case runningStatus == RunningStatusAll && status >= 0x80 && status < 0xf0:
+			if status == last {
+				chunk.event = chunk.event[1:]
 			}
 		}
 
-		last = chunk
+		last = status
 	}
 
 	// ... get length
@@ -1,3 +1,8 @@
 module github.com/transcriptaze/midiasm
 
 go 1.23
+
+require (
+	golang.org/x/text v0.22.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -10,17 +10,17 @@ import (
 )
 
 type MThd struct {
-	Tag           string
-	Length        uint32
-	Format        uint16
-	Tracks        uint16
-	Division      uint16
-	PPQN          uint16  // TODO make getter/TextUnmarshal
-	SMPTETimeCode bool    // TODO make getter/TextUnmarshal
-	SubFrames     uint16  // TODO make getter/TextUnmarshal
-	FPS           uint8   // TODO make getter/TextUnmarshal
-	DropFrame     bool    // TODO make getter/TextUnmarshal
-	Bytes         lib.Hex `json:"-"` // TODO make getter/TextUnmarshal
+	Tag           string  `json:"tag"`
+	Length        uint32  `json:"length"`
+	Format        uint16  `json:"format"`
+	Tracks        uint16  `json:"tracks"`
+	Division      uint16  `json:"division"`
+	PPQN          uint16  `json:"ppqn"`           // TODO make getter/TextUnmarshal
+	SMPTETimeCode bool    `json:"smpte-timecode"` // TODO make getter/TextUnmarshal
+	SubFrames     uint16  `json:"subframes"`      // TODO make getter/TextUnmarshal
+	FPS           uint8   `json:"fps"`            // TODO make getter/TextUnmarshal
+	DropFrame     bool    `json:"drop-frame"`     // TODO make getter/TextUnmarshal
+	Bytes         lib.Hex `json:"-"`              // TODO make getter/TextUnmarshal
 }
 
 func MakeMThd(format uint16, tracks uint16, division uint16, bytes ...byte) MThd {
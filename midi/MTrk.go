@@ -6,6 +6,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"sort"
 
 	"github.com/transcriptaze/midiasm/midi/context"
 	"github.com/transcriptaze/midiasm/midi/events"
@@ -83,6 +84,9 @@ func (chunk *MTrk) UnmarshalBinary(data []byte) error {
 				chunk.Context.PutNoteOn(k.Channel, k.Note.Value)
 				e.Event = k.Format(chunk.Context)
 
+			case midievent.PolyphonicPressure:
+				e.Event = k.Format(chunk.Context)
+
 			case midievent.ProgramChange:
 				c := uint8(k.Channel)
 				e.Event = k.SetBank(chunk.Context.ProgramBank[c])
@@ -170,7 +174,7 @@ func parse(r *bufio.Reader, tick uint32, ctx *context.Context) (*events.Event, e
 	var length = map[byte]int{
 		0x80: 2,
 		0x90: 2,
-		0xA0: 1,
+		0xA0: 2,
 		0xB0: 2,
 		0xC0: 1,
 		0xD0: 1,
@@ -189,3 +193,61 @@ func parse(r *bufio.Reader, tick uint32, ctx *context.Context) (*events.Event, e
 
 	return events.NewEvent(e), err
 }
+
+// Insert adds the events to the track at the position given by each event's tick. Inserted
+// events precede existing events at the same tick and the EndOfTrack event is kept last. The
+// event deltas are recalculated from the ticks.
+func (chunk *MTrk) Insert(list ...events.IEvent) error {
+	type item struct {
+		tick  uint64
+		order int
+		event events.IEvent
+	}
+
+	items := []item{}
+	eot := (*item)(nil)
+
+	for _, e := range list {
+		items = append(items, item{tick: e.Tick(), order: 0, event: e})
+	}
+
+	for _, e := range chunk.Events {
+		if events.Is[metaevent.EndOfTrack](*e) {
+			eot = &item{tick: e.Tick(), order: 2, event: e.Event}
+		} else {
+			items = append(items, item{tick: e.Tick(), order: 1, event: e.Event})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].tick == items[j].tick {
+			return items[i].order < items[j].order
+		}
+
+		return items[i].tick < items[j].tick
+	})
+
+	if eot != nil {
+		if len(items) > 0 && items[len(items)-1].tick > eot.tick {
+			eot.tick = items[len(items)-1].tick
+		}
+
+		items = append(items, *eot)
+	}
+
+	eventlist := make([]*events.Event, 0, len(items))
+	tick := uint64(0)
+
+	for _, v := range items {
+		if e, err := events.Retime(v.event, v.tick, uint32(v.tick-tick)); err != nil {
+			return err
+		} else {
+			eventlist = append(eventlist, events.NewEvent(e))
+			tick = v.tick
+		}
+	}
+
+	chunk.Events = eventlist
+
+	return nil
+}
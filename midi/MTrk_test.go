@@ -145,3 +145,38 @@ func TestUnmarshalWithInvalidRunningStatus(t *testing.T) {
 		t.Fatalf("Incorrect error unmarshaling SMF:\nexpected: %+v\n     got: %+v", expected, err)
 	}
 }
+
+func TestMTrkInsert(t *testing.T) {
+	mtrk := MTrk{
+		Tag: "MTrk",
+		Events: []*events.Event{
+			&events.Event{Event: metaevent.MakeTrackName(0, 0, "Example 1")},
+			&events.Event{Event: midievent.MakeNoteOn(480, 480, 1, midievent.Note{Value: 48, Name: "C3", Alias: "C3"}, 72)},
+			&events.Event{Event: midievent.MakeNoteOff(960, 480, 1, midievent.Note{Value: 48, Name: "C3", Alias: "C3"}, 64)},
+			&events.Event{Event: metaevent.MakeEndOfTrack(960, 0)},
+		},
+	}
+
+	expected := []*events.Event{
+		&events.Event{Event: metaevent.MakeTrackName(0, 0, "Example 1")},
+		&events.Event{Event: midievent.MakeNoteOn(480, 480, 1, midievent.Note{Value: 48, Name: "C3", Alias: "C3"}, 72)},
+		&events.Event{Event: metaevent.MakeKeySignature(720, 240, -6, lib.Minor)},
+		&events.Event{Event: midievent.MakeNoteOff(960, 240, 1, midievent.Note{Value: 48, Name: "C3", Alias: "C3"}, 64)},
+		&events.Event{Event: metaevent.MakeMarker(1920, 960, "Coda")},
+		&events.Event{Event: metaevent.MakeEndOfTrack(1920, 0)},
+	}
+
+	if err := mtrk.Insert(metaevent.MakeMarker(1920, 0, "Coda"), metaevent.MakeKeySignature(720, 0, -6, lib.Minor)); err != nil {
+		t.Fatalf("Unexpected error inserting events (%v)", err)
+	}
+
+	if len(mtrk.Events) != len(expected) {
+		t.Fatalf("Incorrect number of events - expected:%v, got:%v", len(expected), len(mtrk.Events))
+	}
+
+	for i := range expected {
+		if !reflect.DeepEqual(mtrk.Events[i], expected[i]) {
+			t.Errorf("Incorrect event %v\n   expected:%+v\n   got:     %+v", i, expected[i], mtrk.Events[i])
+		}
+	}
+}
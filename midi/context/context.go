@@ -51,6 +51,14 @@ func SetMiddleC(c lib.MiddleC) {
 
 var MiddleC = lib.C3
 
+// SetEncoding sets the character encoding for the text in Text, Lyric, TrackName etc. meta
+// events. Defaults to auto-detecting the encoding of each event.
+func SetEncoding(e lib.Encoding) {
+	Encoding = e
+}
+
+var Encoding = lib.Auto
+
 func NewContext() *Context {
 	return &Context{
 		scale:         Sharps,
@@ -32,7 +32,7 @@ var bytes = map[lib.Tag][]byte{
 
 	lib.TagNoteOff:            []byte{0x83, 0x60, 0x87, 0x31, 0x48},
 	lib.TagNoteOn:             []byte{0x83, 0x60, 0x97, 0x31, 0x48},
-	lib.TagPolyphonicPressure: []byte{0x83, 0x60, 0xa7, 0x64},
+	lib.TagPolyphonicPressure: []byte{0x83, 0x60, 0xa7, 0x31, 0x64},
 	lib.TagController:         []byte{0x83, 0x60, 0xb7, 0x54, 0x1d},
 	lib.TagProgramChange:      []byte{0x83, 0x60, 0xc7, 0x19},
 	lib.TagChannelPressure:    []byte{0x83, 0x60, 0xd7, 0x64},
@@ -182,7 +182,11 @@ func TestEventUnmarshalBinary(t *testing.T) {
 		{
 			bytes: bytes[lib.TagPolyphonicPressure],
 			expected: Event{
-				Event: midievent.MakePolyphonicPressure(0, 480, 7, 100, bytes[lib.TagPolyphonicPressure]...),
+				Event: midievent.MakePolyphonicPressure(0, 480, 7, midievent.Note{
+					Value: 49,
+					Name:  "C♯3",
+					Alias: "C♯3",
+				}, 100, bytes[lib.TagPolyphonicPressure]...),
 			},
 		},
 		{
@@ -206,7 +210,7 @@ func TestEventUnmarshalBinary(t *testing.T) {
 		{
 			bytes: bytes[lib.TagPitchBend],
 			expected: Event{
-				Event: midievent.MakePitchBend(0, 480, 7, 8, bytes[lib.TagPitchBend]...),
+				Event: midievent.MakePitchBend(0, 480, 7, 1024, bytes[lib.TagPitchBend]...),
 			},
 		},
 		{
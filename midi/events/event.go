@@ -77,12 +77,67 @@ func Is[E TEvent](e Event) bool {
 	return u == v
 }
 
+func Retime(e IEvent, tick uint64, delta uint32) (IEvent, error) {
+	var v any
+	var err error
+
+	switch e.(type) {
+	case metaevent.SequenceNumber,
+		metaevent.Text,
+		metaevent.Copyright,
+		metaevent.TrackName,
+		metaevent.InstrumentName,
+		metaevent.Lyric,
+		metaevent.Marker,
+		metaevent.CuePoint,
+		metaevent.ProgramName,
+		metaevent.DeviceName,
+		metaevent.MIDIChannelPrefix,
+		metaevent.MIDIPort,
+		metaevent.EndOfTrack,
+		metaevent.Tempo,
+		metaevent.SMPTEOffset,
+		metaevent.KeySignature,
+		metaevent.TimeSignature,
+		metaevent.SequencerSpecificEvent:
+		v, err = metaevent.Retime(e, tick, lib.Delta(delta))
+
+	case midievent.NoteOff,
+		midievent.NoteOn,
+		midievent.PolyphonicPressure,
+		midievent.Controller,
+		midievent.ProgramChange,
+		midievent.ChannelPressure,
+		midievent.PitchBend:
+		v, err = midievent.Retime(e, tick, lib.Delta(delta))
+
+	case sysex.SysExMessage,
+		sysex.SysExContinuationMessage,
+		sysex.SysExEscapeMessage:
+		v, err = sysex.Retime(e, tick, lib.Delta(delta))
+
+	default:
+		return nil, fmt.Errorf("Invalid event (%v)", e)
+	}
+
+	if err != nil {
+		return nil, err
+	} else if u, ok := v.(IEvent); !ok {
+		return nil, fmt.Errorf("Invalid event (%v)", e)
+	} else {
+		return u, nil
+	}
+}
+
 func IsTrack0Event(e *Event) bool {
 	switch e.Event.(type) {
 	case
 		metaevent.Tempo,
 		metaevent.TimeSignature,
+		metaevent.KeySignature,
 		metaevent.TrackName,
+		metaevent.Text,
+		metaevent.Marker,
 		metaevent.SMPTEOffset,
 		metaevent.Copyright,
 		metaevent.EndOfTrack:
@@ -28,19 +28,14 @@ func MakeCopyright(tick uint64, delta lib.Delta, copyright string, bytes ...byte
 }
 
 func (e *Copyright) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	copyright := string(data)
+	copyright := decode(data)
 	*e = MakeCopyright(tick, delta, copyright, bytes...)
 
 	return nil
 }
 
 func (e Copyright) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(e.Status),
-		byte(e.Type),
-		byte(len(e.Copyright)),
-	},
-		[]byte(e.Copyright)...), nil
+	return encode(e.event, e.Copyright)
 }
 
 func (e *Copyright) UnmarshalBinary(bytes []byte) error {
@@ -55,7 +50,7 @@ func (e *Copyright) UnmarshalBinary(bytes []byte) error {
 	} else if copyright, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeCopyright(0, delta, string(copyright), bytes...)
+		*e = MakeCopyright(0, delta, decode(copyright), bytes...)
 	}
 
 	return nil
@@ -28,7 +28,7 @@ func MakeCuePoint(tick uint64, delta lib.Delta, cuepoint string, bytes ...byte)
 }
 
 func (e *CuePoint) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	cuepoint := string(data)
+	cuepoint := decode(data)
 
 	*e = MakeCuePoint(tick, delta, cuepoint, bytes...)
 
@@ -36,12 +36,7 @@ func (e *CuePoint) unmarshal(tick uint64, delta lib.Delta, status byte, data []b
 }
 
 func (e CuePoint) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(e.Status),
-		byte(e.Type),
-		byte(len(e.CuePoint)),
-	},
-		[]byte(e.CuePoint)...), nil
+	return encode(e.event, e.CuePoint)
 }
 
 func (e *CuePoint) UnmarshalBinary(bytes []byte) error {
@@ -56,7 +51,7 @@ func (e *CuePoint) UnmarshalBinary(bytes []byte) error {
 	} else if cuepoint, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeCuePoint(0, delta, string(cuepoint), bytes...)
+		*e = MakeCuePoint(0, delta, decode(cuepoint), bytes...)
 	}
 
 	return nil
@@ -28,7 +28,7 @@ func MakeDeviceName(tick uint64, delta lib.Delta, name string, bytes ...byte) De
 }
 
 func (e *DeviceName) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	name := string(data)
+	name := decode(data)
 
 	*e = MakeDeviceName(tick, delta, name, bytes...)
 
@@ -36,12 +36,7 @@ func (e *DeviceName) unmarshal(tick uint64, delta lib.Delta, status byte, data [
 }
 
 func (d DeviceName) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(d.Status),
-		byte(d.Type),
-		byte(len(d.Name)),
-	},
-		[]byte(d.Name)...), nil
+	return encode(d.event, d.Name)
 }
 
 func (e *DeviceName) UnmarshalBinary(bytes []byte) error {
@@ -56,7 +51,7 @@ func (e *DeviceName) UnmarshalBinary(bytes []byte) error {
 	} else if name, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeDeviceName(0, delta, string(name), bytes...)
+		*e = MakeDeviceName(0, delta, decode(name), bytes...)
 	}
 
 	return nil
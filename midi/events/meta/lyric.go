@@ -28,7 +28,7 @@ func MakeLyric(tick uint64, delta lib.Delta, lyric string, bytes ...byte) Lyric
 }
 
 func (e *Lyric) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	lyric := string(data)
+	lyric := decode(data)
 
 	*e = MakeLyric(tick, delta, lyric, bytes...)
 
@@ -36,12 +36,7 @@ func (e *Lyric) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte
 }
 
 func (l Lyric) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(l.Status),
-		byte(l.Type),
-		byte(len(l.Lyric)),
-	},
-		[]byte(l.Lyric)...), nil
+	return encode(l.event, l.Lyric)
 }
 
 func (e *Lyric) UnmarshalBinary(bytes []byte) error {
@@ -56,7 +51,7 @@ func (e *Lyric) UnmarshalBinary(bytes []byte) error {
 	} else if lyric, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeLyric(0, delta, string(lyric), bytes...)
+		*e = MakeLyric(0, delta, decode(lyric), bytes...)
 	}
 
 	return nil
@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/transcriptaze/midiasm/midi/context"
 	"github.com/transcriptaze/midiasm/midi/lib"
 )
 
@@ -152,3 +153,65 @@ func TestLyricNameUnmarshalJSON(t *testing.T) {
 		t.Errorf("incorrectly unmarshalled %v\n   expected:%+v\n   got:     %+v", tag, expected, evt)
 	}
 }
+
+func TestUnmarshalShiftJISLyric(t *testing.T) {
+	bytes := []byte{0x00, 0xff, 0x05, 0x06, 0x82, 0xb3, 0x82, 0xad, 0x82, 0xe7}
+
+	e := Lyric{}
+	if err := e.unmarshal(0, 0, 0xff, bytes[4:], bytes...); err != nil {
+		t.Fatalf("error decoding Lyric (%v)", err)
+	}
+
+	if e.Lyric != "さくら" {
+		t.Errorf("incorrectly decoded Shift-JIS Lyric - expected:%q, got:%q", "さくら", e.Lyric)
+	}
+
+	// ... re-encoding an unchanged lyric uses the original bytes
+	if encoded, err := e.MarshalBinary(); err != nil {
+		t.Fatalf("error encoding Lyric (%v)", err)
+	} else if !reflect.DeepEqual(encoded, bytes[1:]) {
+		t.Errorf("incorrectly encoded Lyric\n   expected:%+v\n   got:     %+v", bytes[1:], encoded)
+	}
+}
+
+func TestLyricMarshalBinaryWithEncoding(t *testing.T) {
+	defer context.SetEncoding(context.Encoding)
+
+	tests := []struct {
+		encoding lib.Encoding
+		bytes    []byte
+		lyric    string
+		expected []byte
+	}{
+		// ... invalid text is re-encoded from the original bytes
+		{lib.UTF8, []byte{0x00, 0xff, 0x05, 0x03, 0x4c, 0x61, 0xff}, "La�", []byte{0xff, 0x05, 0x03, 0x4c, 0x61, 0xff}},
+
+		// ... changed or new text is encoded with the text encoding
+		{lib.ShiftJIS, []byte{0x00, 0xff, 0x05, 0x02, 0x4c, 0x61}, "さくら", []byte{0xff, 0x05, 0x06, 0x82, 0xb3, 0x82, 0xad, 0x82, 0xe7}},
+		{lib.EUCJP, []byte{}, "さくら", []byte{0xff, 0x05, 0x06, 0xa4, 0xb5, 0xa4, 0xaf, 0xa4, 0xe9}},
+		{lib.Windows1252, []byte{}, "Café", []byte{0xff, 0x05, 0x04, 0x43, 0x61, 0x66, 0xe9}},
+		{lib.Auto, []byte{}, "Café", []byte{0xff, 0x05, 0x05, 0x43, 0x61, 0x66, 0xc3, 0xa9}},
+	}
+
+	for _, test := range tests {
+		context.SetEncoding(test.encoding)
+
+		e := Lyric{}
+		if err := e.unmarshal(0, 0, 0xff, test.bytes[min(4, len(test.bytes)):], test.bytes...); err != nil {
+			t.Fatalf("error decoding Lyric (%v)", err)
+		}
+
+		e.Lyric = test.lyric
+
+		if encoded, err := e.MarshalBinary(); err != nil {
+			t.Fatalf("error encoding %v Lyric (%v)", test.encoding, err)
+		} else if !reflect.DeepEqual(encoded, test.expected) {
+			t.Errorf("incorrectly encoded %v Lyric\n   expected:%+v\n   got:     %+v", test.encoding, test.expected, encoded)
+		}
+	}
+
+	context.SetEncoding(lib.Latin1)
+	if _, err := MakeLyric(0, 0, "さくら").MarshalBinary(); err == nil {
+		t.Errorf("expected error encoding Latin-1 Lyric %q", "さくら")
+	}
+}
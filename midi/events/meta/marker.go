@@ -28,7 +28,7 @@ func MakeMarker(tick uint64, delta lib.Delta, marker string, bytes ...byte) Mark
 }
 
 func (e *Marker) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	marker := string(data)
+	marker := decode(data)
 
 	*e = MakeMarker(tick, delta, marker, bytes...)
 
@@ -36,12 +36,7 @@ func (e *Marker) unmarshal(tick uint64, delta lib.Delta, status byte, data []byt
 }
 
 func (m Marker) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(m.Status),
-		byte(m.Type),
-		byte(len(m.Marker)),
-	},
-		[]byte(m.Marker)...), nil
+	return encode(m.event, m.Marker)
 }
 
 func (e *Marker) UnmarshalBinary(bytes []byte) error {
@@ -56,7 +51,7 @@ func (e *Marker) UnmarshalBinary(bytes []byte) error {
 	} else if marker, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeMarker(0, delta, string(marker), bytes...)
+		*e = MakeMarker(0, delta, decode(marker), bytes...)
 	}
 
 	return nil
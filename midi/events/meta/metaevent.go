@@ -2,7 +2,9 @@ package metaevent
 
 import (
 	"fmt"
+	"reflect"
 
+	"github.com/transcriptaze/midiasm/midi/context"
 	"github.com/transcriptaze/midiasm/midi/lib"
 )
 
@@ -56,6 +58,25 @@ func (e event) Tag() string {
 	return fmt.Sprintf("%v", e.tag)
 }
 
+func (e *event) retime(tick uint64, delta lib.Delta) {
+	e.tick = tick
+	e.delta = delta
+}
+
+// Retime returns a copy of the event with the tick and delta replaced.
+func Retime(e any, tick uint64, delta lib.Delta) (any, error) {
+	v := reflect.New(reflect.TypeOf(e))
+	v.Elem().Set(reflect.ValueOf(e))
+
+	if p, ok := v.Interface().(interface{ retime(uint64, lib.Delta) }); !ok {
+		return nil, fmt.Errorf("Invalid event (%v)", e)
+	} else {
+		p.retime(tick, delta)
+	}
+
+	return v.Elem().Interface(), nil
+}
+
 func Parse(tick uint64, bytes ...byte) (any, error) {
 	var delta lib.Delta
 	var status uint8
@@ -191,3 +212,28 @@ func delta(bytes []byte) (lib.Delta, []byte, error) {
 
 	return lib.Delta(v), remaining, err
 }
+
+// decode returns the text of a text meta event (Text, Lyric, TrackName, etc.) using the
+// context text encoding.
+func decode(data []byte) string {
+	return context.Encoding.Decode(data)
+}
+
+// encode returns the encoded text meta event. The original event bytes are reused if the
+// text is unchanged so that re-encoding a decoded MIDI file is byte-exact irrespective of
+// the text encoding.
+func encode(e event, text string) ([]byte, error) {
+	if _, remaining, err := vlq(e.bytes); err == nil && len(remaining) > 2 && remaining[0] == byte(e.Status) && remaining[1] == byte(e.Type) {
+		if N, data, err := vlq(remaining[2:]); err == nil && int(N) <= len(data) && decode(data[:N]) == text {
+			return remaining[:len(remaining)-len(data)+int(N)], nil
+		}
+	}
+
+	if v, err := context.Encoding.Encode(text); err != nil {
+		return nil, err
+	} else if vlf, err := lib.VLF(v).MarshalBinary(); err != nil {
+		return nil, err
+	} else {
+		return append([]byte{byte(e.Status), byte(e.Type)}, vlf...), nil
+	}
+}
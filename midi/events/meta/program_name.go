@@ -28,7 +28,7 @@ func MakeProgramName(tick uint64, delta lib.Delta, name string, bytes ...byte) P
 }
 
 func (e *ProgramName) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	name := string(data)
+	name := decode(data)
 
 	*e = MakeProgramName(tick, delta, name, bytes...)
 
@@ -36,12 +36,7 @@ func (e *ProgramName) unmarshal(tick uint64, delta lib.Delta, status byte, data
 }
 
 func (e ProgramName) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(e.Status),
-		byte(e.Type),
-		byte(len(e.Name)),
-	},
-		[]byte(e.Name)...), nil
+	return encode(e.event, e.Name)
 }
 
 func (e *ProgramName) UnmarshalBinary(bytes []byte) error {
@@ -56,7 +51,7 @@ func (e *ProgramName) UnmarshalBinary(bytes []byte) error {
 	} else if name, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeProgramName(0, delta, string(name), bytes...)
+		*e = MakeProgramName(0, delta, decode(name), bytes...)
 	}
 
 	return nil
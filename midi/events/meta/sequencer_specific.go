@@ -44,17 +44,16 @@ func (e *SequencerSpecificEvent) unmarshal(tick uint64, delta lib.Delta, status
 	return nil
 }
 
-// FIXME encode as VLF
 func (e SequencerSpecificEvent) MarshalBinary() (encoded []byte, err error) {
-	b := []byte{
-		byte(e.Status),
-		byte(e.Type),
-		byte(len(e.Manufacturer.ID) + len(e.Data))}
+	data := []byte{}
+	data = append(data, e.Manufacturer.ID...)
+	data = append(data, e.Data...)
 
-	b = append(b, e.Manufacturer.ID...)
-	b = append(b, e.Data...)
-
-	return b, nil
+	if v, err := lib.VLF(data).MarshalBinary(); err != nil {
+		return nil, err
+	} else {
+		return append([]byte{byte(e.Status), byte(e.Type)}, v...), nil
+	}
 }
 
 func (e *SequencerSpecificEvent) UnmarshalBinary(bytes []byte) error {
@@ -28,7 +28,7 @@ func MakeText(tick uint64, delta lib.Delta, text string, bytes ...byte) Text {
 }
 
 func (e *Text) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	text := string(data)
+	text := decode(data)
 	event := MakeText(tick, delta, text, bytes...)
 
 	*e = event
@@ -37,12 +37,7 @@ func (e *Text) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte,
 }
 
 func (e Text) MarshalBinary() (encoded []byte, err error) {
-	return append([]byte{
-		byte(e.Status),
-		byte(e.Type),
-		byte(len(e.Text)),
-	},
-		[]byte(e.Text)...), nil
+	return encode(e.event, e.Text)
 }
 
 func (e *Text) UnmarshalBinary(bytes []byte) error {
@@ -57,7 +52,7 @@ func (e *Text) UnmarshalBinary(bytes []byte) error {
 	} else if text, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeText(0, delta, string(text), bytes...)
+		*e = MakeText(0, delta, decode(text), bytes...)
 	}
 
 	return nil
@@ -1,7 +1,6 @@
 package metaevent
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -29,7 +28,7 @@ func MakeTrackName(tick uint64, delta lib.Delta, name string, bytes ...byte) Tra
 }
 
 func (e *TrackName) unmarshal(tick uint64, delta lib.Delta, status byte, data []byte, bytes ...byte) error {
-	name := string(data)
+	name := decode(data)
 	event := MakeTrackName(tick, delta, name, bytes...)
 
 	*e = event
@@ -38,27 +37,7 @@ func (e *TrackName) unmarshal(tick uint64, delta lib.Delta, status byte, data []
 }
 
 func (t TrackName) MarshalBinary() (encoded []byte, err error) {
-	var b bytes.Buffer
-	var v []byte
-
-	if err = b.WriteByte(byte(t.Status)); err != nil {
-		return
-	}
-
-	if err = b.WriteByte(byte(t.Type)); err != nil {
-		return
-	}
-
-	name := lib.VLF(t.Name)
-	if v, err = name.MarshalBinary(); err != nil {
-		return
-	} else if _, err = b.Write(v); err != nil {
-		return
-	}
-
-	encoded = b.Bytes()
-
-	return
+	return encode(t.event, t.Name)
 }
 
 func (e *TrackName) UnmarshalBinary(bytes []byte) error {
@@ -73,7 +52,7 @@ func (e *TrackName) UnmarshalBinary(bytes []byte) error {
 	} else if name, err := vlf(remaining[2:]); err != nil {
 		return err
 	} else {
-		*e = MakeTrackName(0, delta, string(name), bytes...)
+		*e = MakeTrackName(0, delta, decode(name), bytes...)
 	}
 
 	return nil
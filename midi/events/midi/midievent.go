@@ -2,6 +2,7 @@ package midievent
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/transcriptaze/midiasm/midi/context"
 	"github.com/transcriptaze/midiasm/midi/lib"
@@ -47,6 +48,25 @@ func (e event) Tag() string {
 	return fmt.Sprintf("%v", e.tag)
 }
 
+func (e *event) retime(tick uint64, delta lib.Delta) {
+	e.tick = tick
+	e.delta = delta
+}
+
+// Retime returns a copy of the event with the tick and delta replaced.
+func Retime(e any, tick uint64, delta lib.Delta) (any, error) {
+	v := reflect.New(reflect.TypeOf(e))
+	v.Elem().Set(reflect.ValueOf(e))
+
+	if p, ok := v.Interface().(interface{ retime(uint64, lib.Delta) }); !ok {
+		return nil, fmt.Errorf("Invalid event (%v)", e)
+	} else {
+		p.retime(tick, delta)
+	}
+
+	return v.Elem().Interface(), nil
+}
+
 func (e event) MarshalBinary() ([]byte, error) {
 	status := byte(e.Status & 0xf0)
 	channel := byte(e.Channel & 0x0f)
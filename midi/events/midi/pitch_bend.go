@@ -11,7 +11,7 @@ import (
 
 type PitchBend struct {
 	event
-	Bend uint16
+	Bend uint16 // 14-bit value (0-16383, centred at 8192)
 }
 
 func MakePitchBend(tick uint64, delta uint32, channel lib.Channel, bend uint16, bytes ...byte) PitchBend {
@@ -42,9 +42,9 @@ func (e *PitchBend) unmarshal(tick uint64, delta uint32, status lib.Status, data
 	}
 
 	channel := lib.Channel(status & 0x0f)
-	bend := uint16(data[0])
+	bend := uint16(data[1]) & 0x7f
 	bend <<= 7
-	bend |= uint16(data[1]) & 0x7f
+	bend |= uint16(data[0]) & 0x7f
 
 	if channel > 15 {
 		return fmt.Errorf("invalid channel (%v)", channel)
@@ -58,8 +58,8 @@ func (e *PitchBend) unmarshal(tick uint64, delta uint32, status lib.Status, data
 func (b PitchBend) MarshalBinary() (encoded []byte, err error) {
 	encoded = []byte{
 		byte(0xe0 | b.Channel),
-		byte(b.Bend >> 8 & 0x00ff),
-		byte(b.Bend >> 0 & 0x00ff),
+		byte(b.Bend >> 0 & 0x007f),
+		byte(b.Bend >> 7 & 0x007f),
 	}
 
 	return
@@ -79,9 +79,9 @@ func (e *PitchBend) UnmarshalBinary(bytes []byte) error {
 	} else if pressure := data[0]; pressure > 127 {
 		return fmt.Errorf("InvalidPitchBend pressure (%v)", pressure)
 	} else {
-		bend := uint16(data[0])
+		bend := uint16(data[1]) & 0x7f
 		bend <<= 7
-		bend |= uint16(data[1]) & 0x7f
+		bend |= uint16(data[0]) & 0x7f
 
 		*e = MakePitchBend(0, delta, lib.Channel(channel), bend, bytes...)
 	}
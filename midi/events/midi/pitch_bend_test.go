@@ -18,7 +18,7 @@ func TestParsePitchBend(t *testing.T) {
 			Status:  0xe7,
 			Channel: 7,
 		},
-		Bend: 8,
+		Bend: 1024,
 	}
 
 	event, err := Parse(2400, 0xe7, []byte{0x83, 0x60, 0xe7, 0x00, 0x08}...)
@@ -47,7 +47,7 @@ func TestPitchBendMarshalBinary(t *testing.T) {
 			Status:  0xe7,
 			Channel: 7,
 		},
-		Bend: 8,
+		Bend: 1024,
 	}
 
 	expected := []byte{0xe7, 0x00, 0x08}
@@ -71,7 +71,7 @@ func TestPitchBendUnmarshalBinary(t *testing.T) {
 			Channel: 7,
 			bytes:   []byte{0x83, 0x60, 0xe7, 0x00, 0x08},
 		},
-		Bend: 8,
+		Bend: 1024,
 	}
 
 	bytes := []byte{0x83, 0x60, 0xe7, 0x00, 0x08}
@@ -88,7 +88,7 @@ func TestPitchBendUnmarshalBinary(t *testing.T) {
 }
 
 func TestPitchBendUnmarshalText(t *testing.T) {
-	text := "      81 70 E7 00 08                           tick:240        delta:240        E7 PitchBend              channel:7  bend:8"
+	text := "      81 70 E7 00 08                           tick:240        delta:240        E7 PitchBend              channel:7  bend:1024"
 	expected := PitchBend{
 		event: event{
 			tick:    0,
@@ -98,7 +98,7 @@ func TestPitchBendUnmarshalText(t *testing.T) {
 			Channel: 7,
 			bytes:   []byte{},
 		},
-		Bend: 8,
+		Bend: 1024,
 	}
 
 	evt := PitchBend{}
@@ -122,17 +122,17 @@ func TestPitchBendMarshalJSON(t *testing.T) {
 			Status:  0xe7,
 			Channel: 7,
 		},
-		Bend: 8,
+		Bend: 1024,
 	}
 
-	expected := `{"tag":"PitchBend","delta":480,"status":231,"channel":7,"bend":8}`
+	expected := `{"tag":"PitchBend","delta":480,"status":231,"channel":7,"bend":1024}`
 
 	testMarshalJSON(t, lib.TagPitchBend, e, expected)
 }
 
 func TestPitchBendNameUnmarshalJSON(t *testing.T) {
 	tag := lib.TagPitchBend
-	text := `{"tag":"PitchBend","delta":480,"status":231,"channel":7,"bend":8}`
+	text := `{"tag":"PitchBend","delta":480,"status":231,"channel":7,"bend":1024}`
 	expected := PitchBend{
 		event: event{
 			tick:    0,
@@ -142,7 +142,7 @@ func TestPitchBendNameUnmarshalJSON(t *testing.T) {
 			Status:  0xe7,
 			Channel: 7,
 		},
-		Bend: 8,
+		Bend: 1024,
 	}
 
 	e := PitchBend{}
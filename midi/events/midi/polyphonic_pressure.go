@@ -6,15 +6,17 @@ import (
 	"regexp"
 	"strconv"
 
+	"github.com/transcriptaze/midiasm/midi/context"
 	"github.com/transcriptaze/midiasm/midi/lib"
 )
 
 type PolyphonicPressure struct {
 	event
+	Note     Note
 	Pressure byte
 }
 
-func MakePolyphonicPressure(tick uint64, delta uint32, channel lib.Channel, pressure uint8, bytes ...byte) PolyphonicPressure {
+func MakePolyphonicPressure(tick uint64, delta uint32, channel lib.Channel, note Note, pressure uint8, bytes ...byte) PolyphonicPressure {
 	if channel > 15 {
 		panic(fmt.Sprintf("invalid channel (%v)", channel))
 	}
@@ -32,6 +34,7 @@ func MakePolyphonicPressure(tick uint64, delta uint32, channel lib.Channel, pres
 			Status:  or(0xA0, channel),
 			Channel: channel,
 		},
+		Note:     note,
 		Pressure: pressure,
 	}
 }
@@ -41,21 +44,82 @@ func (e *PolyphonicPressure) unmarshal(tick uint64, delta uint32, status lib.Sta
 		return fmt.Errorf("Invalid PolyphonicPressure status (%v): expected 'Ax'", status)
 	}
 
-	if len(data) < 1 {
-		return fmt.Errorf("Invalid PolyphonicPressure data (%v): expected pressure", data)
+	if len(data) < 2 {
+		return fmt.Errorf("Invalid PolyphonicPressure data (%v): expected note and pressure", data)
 	}
 
 	var channel = lib.Channel(status & 0x0f)
-	var pressure = data[0]
+	var note = Note{
+		Value: data[0],
+		Name:  FormatNote(nil, data[0]),
+		Alias: FormatNote(nil, data[0]),
+	}
+	var pressure = data[1]
+
+	if pressure > 127 {
+		return fmt.Errorf("Invalid PolyphonicPressure pressure (%v)", pressure)
+	}
 
-	*e = MakePolyphonicPressure(tick, delta, channel, pressure, bytes...)
+	*e = MakePolyphonicPressure(tick, delta, channel, note, pressure, bytes...)
 
 	return nil
 }
 
+func (e PolyphonicPressure) Transpose(ctx *context.Context, steps int) PolyphonicPressure {
+	v := int(e.Note.Value) + steps
+	note := e.Note.Value
+
+	switch {
+	case v < 0:
+		note = 0
+
+	case v > 127:
+		note = 127
+
+	default:
+		note = byte(v)
+	}
+
+	return PolyphonicPressure{
+		event: event{
+			tick:    e.tick,
+			delta:   e.delta,
+			tag:     lib.TagPolyphonicPressure,
+			Status:  lib.Status(0xA0 | e.Channel),
+			Channel: e.Channel,
+		},
+		Note: Note{
+			Value: note,
+			Name:  ctx.FormatNote(note),
+			Alias: ctx.FormatNote(note),
+		},
+		Pressure: e.Pressure,
+	}
+}
+
+func (e PolyphonicPressure) Format(ctx *context.Context) PolyphonicPressure {
+	return PolyphonicPressure{
+		event: event{
+			tick:    e.tick,
+			delta:   e.delta,
+			bytes:   e.bytes,
+			tag:     e.tag,
+			Status:  e.Status,
+			Channel: e.Channel,
+		},
+		Note: Note{
+			Value: e.Note.Value,
+			Name:  ctx.FormatNote(e.Note.Value),
+			Alias: ctx.FormatNote(e.Note.Value),
+		},
+		Pressure: e.Pressure,
+	}
+}
+
 func (e PolyphonicPressure) MarshalBinary() (encoded []byte, err error) {
 	encoded = []byte{
 		byte(0xA0 | e.Channel),
+		e.Note.Value,
 		e.Pressure,
 	}
 
@@ -65,38 +129,46 @@ func (e PolyphonicPressure) MarshalBinary() (encoded []byte, err error) {
 func (e *PolyphonicPressure) UnmarshalBinary(bytes []byte) error {
 	if delta, remaining, err := delta(bytes); err != nil {
 		return err
-	} else if len(remaining) != 2 {
+	} else if len(remaining) != 3 {
 		return fmt.Errorf("Invalid event (%v)", remaining)
 	} else if !lib.TypePolyphonicPressure.Equals(remaining[0]) {
 		return fmt.Errorf("Invalid %v event type (%02X)", lib.TagPolyphonicPressure, remaining[0])
 	} else if channel := remaining[0] & 0x0f; channel > 15 {
 		return fmt.Errorf("invalid channel (%v)", channel)
-	} else if data := remaining[1:]; len(data) < 1 {
+	} else if data := remaining[1:]; len(data) < 2 {
 		return fmt.Errorf("Invalid PolyphonicPressure data")
-	} else if pressure := data[0]; pressure > 127 {
+	} else if pressure := data[1]; pressure > 127 {
 		return fmt.Errorf("Invalid PolyphonicPressure pressure (%v)", pressure)
 	} else {
-		*e = MakePolyphonicPressure(0, delta, lib.Channel(channel), pressure, bytes...)
+		note := Note{
+			Value: data[0],
+			Name:  FormatNote(nil, data[0]),
+			Alias: FormatNote(nil, data[0]),
+		}
+
+		*e = MakePolyphonicPressure(0, delta, lib.Channel(channel), note, pressure, bytes...)
 	}
 
 	return nil
 }
 
 func (e *PolyphonicPressure) UnmarshalText(text []byte) error {
-	re := regexp.MustCompile(`(?i)delta:([0-9]+)(?:.*?)PolyphonicPressure\s+channel:([0-9]+)\s+pressure:([0-9]+)`)
+	re := regexp.MustCompile(`(?i)delta:([0-9]+)(?:.*?)PolyphonicPressure\s+channel:([0-9]+)\s+note:([A-G][♯♭]?[-]?[0-9]),\s*pressure:([0-9]+)`)
 
-	if match := re.FindStringSubmatch(string(text)); match == nil || len(match) < 4 {
+	if match := re.FindStringSubmatch(string(text)); match == nil || len(match) < 5 {
 		return fmt.Errorf("invalid PolyphonicPressure event (%v)", text)
 	} else if delta, err := lib.ParseDelta(match[1]); err != nil {
 		return err
 	} else if channel, err := lib.ParseChannel(match[2]); err != nil {
 		return err
-	} else if pressure, err := strconv.ParseUint(match[3], 10, 8); err != nil {
+	} else if note, err := ParseNote(nil, match[3]); err != nil {
+		return err
+	} else if pressure, err := strconv.ParseUint(match[4], 10, 8); err != nil {
 		return err
 	} else if pressure > 127 {
 		return fmt.Errorf("invalid PolyphonicPressure pressure (%v)", pressure)
 	} else {
-		*e = MakePolyphonicPressure(0, uint32(delta), lib.Channel(channel), uint8(pressure), []byte{}...)
+		*e = MakePolyphonicPressure(0, uint32(delta), lib.Channel(channel), note, uint8(pressure), []byte{}...)
 	}
 
 	return nil
@@ -108,12 +180,14 @@ func (e PolyphonicPressure) MarshalJSON() (encoded []byte, err error) {
 		Delta    lib.Delta   `json:"delta"`
 		Status   byte        `json:"status"`
 		Channel  lib.Channel `json:"channel"`
+		Note     Note        `json:"note"`
 		Pressure uint8       `json:"pressure"`
 	}{
 		Tag:      fmt.Sprintf("%v", e.tag),
 		Delta:    e.delta,
 		Status:   byte(e.Status),
 		Channel:  e.Channel,
+		Note:     e.Note,
 		Pressure: e.Pressure,
 	}
 
@@ -125,6 +199,7 @@ func (e *PolyphonicPressure) UnmarshalJSON(bytes []byte) error {
 		Tag      string      `json:"tag"`
 		Delta    lib.Delta   `json:"delta"`
 		Channel  lib.Channel `json:"channel"`
+		Note     Note        `json:"note"`
 		Pressure uint8       `json:"pressure"`
 	}{}
 
@@ -133,7 +208,13 @@ func (e *PolyphonicPressure) UnmarshalJSON(bytes []byte) error {
 	} else if !equal(t.Tag, lib.TagPolyphonicPressure) {
 		return fmt.Errorf("invalid %v event (%v)", e.tag, string(bytes))
 	} else {
-		*e = MakePolyphonicPressure(0, uint32(t.Delta), t.Channel, t.Pressure, []byte{}...)
+		note := Note{
+			Value: t.Note.Value,
+			Name:  FormatNote(nil, t.Note.Value),
+			Alias: FormatNote(nil, t.Note.Value),
+		}
+
+		*e = MakePolyphonicPressure(0, uint32(t.Delta), t.Channel, note, t.Pressure, []byte{}...)
 	}
 
 	return nil
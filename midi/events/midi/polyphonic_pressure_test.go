@@ -12,16 +12,17 @@ func TestParsePolyphonicPressure(t *testing.T) {
 		event: event{
 			tick:  2400,
 			delta: 480,
-			bytes: []byte{0x83, 0x60, 0xa7, 0x64},
+			bytes: []byte{0x83, 0x60, 0xa7, 0x31, 0x64},
 
 			tag:     lib.TagPolyphonicPressure,
 			Status:  0xa7,
 			Channel: 7,
 		},
+		Note:     Note{Value: 49, Name: "C♯3", Alias: "C♯3"},
 		Pressure: 100,
 	}
 
-	e, err := Parse(2400, 0xa7, []byte{0x83, 0x60, 0xa7, 0x64}...)
+	e, err := Parse(2400, 0xa7, []byte{0x83, 0x60, 0xa7, 0x31, 0x64}...)
 	if err != nil {
 		t.Fatalf("Unexpected PolyphonicPressure event parse error: %v", err)
 	} else if e == nil {
@@ -43,16 +44,17 @@ func TestPolyphonicPressureMarshalBinary(t *testing.T) {
 		event: event{
 			tick:  2400,
 			delta: 480,
-			bytes: []byte{0x00, 0xa7, 0x64},
+			bytes: []byte{0x00, 0xa7, 0x31, 0x64},
 			tag:   lib.TagPolyphonicPressure,
 
 			Status:  0xa7,
 			Channel: 7,
 		},
+		Note:     Note{Value: 49, Name: "C♯3", Alias: "C♯3"},
 		Pressure: 100,
 	}
 
-	expected := []byte{0xa7, 0x64}
+	expected := []byte{0xa7, 0x31, 0x64}
 
 	encoded, err := e.MarshalBinary()
 	if err != nil {
@@ -71,12 +73,13 @@ func TestPolyphonicPressureUnmarshalBinary(t *testing.T) {
 			tag:     lib.TagPolyphonicPressure,
 			Status:  0xa7,
 			Channel: 7,
-			bytes:   []byte{0x83, 0x60, 0xa7, 0x64},
+			bytes:   []byte{0x83, 0x60, 0xa7, 0x31, 0x64},
 		},
+		Note:     Note{Value: 49, Name: "C♯3", Alias: "C♯3"},
 		Pressure: 100,
 	}
 
-	bytes := []byte{0x83, 0x60, 0xa7, 0x64}
+	bytes := []byte{0x83, 0x60, 0xa7, 0x31, 0x64}
 
 	e := PolyphonicPressure{}
 
@@ -90,7 +93,7 @@ func TestPolyphonicPressureUnmarshalBinary(t *testing.T) {
 }
 
 func TestPolyphonicPressureUnmarshalText(t *testing.T) {
-	text := "      00 A0 64                              tick:0          delta:480        A7 PolyphonicPressure     channel:7  pressure:100"
+	text := "      00 A7 31 64                           tick:0          delta:480        A7 PolyphonicPressure     channel:7  note:C♯3, pressure:100"
 	expected := PolyphonicPressure{
 		event: event{
 			tick:    0,
@@ -100,6 +103,7 @@ func TestPolyphonicPressureUnmarshalText(t *testing.T) {
 			Channel: 7,
 			bytes:   []byte{},
 		},
+		Note:     Note{Value: 49, Name: "C♯3", Alias: "C♯3"},
 		Pressure: 100,
 	}
 
@@ -120,23 +124,24 @@ func TestPolyphonicPressureMarshalJSON(t *testing.T) {
 		event: event{
 			tick:  2400,
 			delta: 480,
-			bytes: []byte{0x00, 0xa7, 0x64},
+			bytes: []byte{0x00, 0xa7, 0x31, 0x64},
 			tag:   lib.TagPolyphonicPressure,
 
 			Status:  0xa7,
 			Channel: 7,
 		},
+		Note:     Note{Value: 49, Name: "C♯3", Alias: "C♯3"},
 		Pressure: 100,
 	}
 
-	expected := `{"tag":"PolyphonicPressure","delta":480,"status":167,"channel":7,"pressure":100}`
+	expected := `{"tag":"PolyphonicPressure","delta":480,"status":167,"channel":7,"note":{"value":49,"name":"C♯3","alias":"C♯3"},"pressure":100}`
 
 	testMarshalJSON(t, lib.TagPolyphonicPressure, e, expected)
 }
 
 func TestPolyphonicPressureNameUnmarshalJSON(t *testing.T) {
 	tag := lib.TagPolyphonicPressure
-	text := `{"tag":"PolyphonicPressure","delta":480,"status":167,"channel":7,"pressure":100}`
+	text := `{"tag":"PolyphonicPressure","delta":480,"status":167,"channel":7,"note":{"value":49,"name":"C♯3","alias":"C♯3"},"pressure":100}`
 	expected := PolyphonicPressure{
 		event: event{
 			tick:  0,
@@ -147,6 +152,7 @@ func TestPolyphonicPressureNameUnmarshalJSON(t *testing.T) {
 			Status:  0xa7,
 			Channel: 7,
 		},
+		Note:     Note{Value: 49, Name: "C♯3", Alias: "C♯3"},
 		Pressure: 100,
 	}
 
@@ -47,16 +47,12 @@ func (e *SysExContinuationMessage) unmarshal(tick uint64, delta uint32, status l
 		return fmt.Errorf("Invalid SysExContinuationMessage event type (%02x): expected 'F7'", status)
 	}
 
-	d := data
-	if len(data) > 0 {
-		terminator := data[len(d)-1]
-		if terminator == 0xf7 {
-			d = data[:len(d)-1]
-		}
+	if len(data) > 0 && data[len(data)-1] == 0xf7 {
+		*e = MakeSysExContinuationEndMessage(tick, delta, data[:len(data)-1], bytes...)
+	} else {
+		*e = MakeSysExContinuationMessage(tick, delta, data, bytes...)
 	}
 
-	*e = MakeSysExContinuationMessage(tick, delta, d, bytes...)
-
 	return nil
 }
 
@@ -91,16 +87,12 @@ func (e *SysExContinuationMessage) UnmarshalBinary(bytes []byte) error {
 	} else if data, err := vlf(remaining[1:]); err != nil {
 		return err
 	} else {
-		d := data
-		if len(data) > 0 {
-			terminator := data[len(d)-1]
-			if terminator == 0xf7 {
-				d = data[:len(d)-1]
-				// ctx.Casio = false
-			}
+		if len(data) > 0 && data[len(data)-1] == 0xf7 {
+			// ctx.Casio = false
+			*e = MakeSysExContinuationEndMessage(0, uint32(delta), data[:len(data)-1], bytes...)
+		} else {
+			*e = MakeSysExContinuationMessage(0, uint32(delta), data, bytes...)
 		}
-
-		*e = MakeSysExContinuationMessage(0, uint32(delta), d, bytes...)
 	}
 
 	return nil
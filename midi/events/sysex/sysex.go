@@ -2,6 +2,7 @@ package sysex
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/transcriptaze/midiasm/midi/lib"
 )
@@ -39,6 +40,25 @@ func (e event) Tag() string {
 	return fmt.Sprintf("%v", e.tag)
 }
 
+func (e *event) retime(tick uint64, delta lib.Delta) {
+	e.tick = tick
+	e.delta = delta
+}
+
+// Retime returns a copy of the event with the tick and delta replaced.
+func Retime(e any, tick uint64, delta lib.Delta) (any, error) {
+	v := reflect.New(reflect.TypeOf(e))
+	v.Elem().Set(reflect.ValueOf(e))
+
+	if p, ok := v.Interface().(interface{ retime(uint64, lib.Delta) }); !ok {
+		return nil, fmt.Errorf("Invalid event (%v)", e)
+	} else {
+		p.retime(tick, delta)
+	}
+
+	return v.Elem().Interface(), nil
+}
+
 func (e event) MarshalBinary() ([]byte, error) {
 	status := byte(e.Status)
 
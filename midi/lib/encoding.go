@@ -0,0 +1,227 @@
+package lib
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+)
+
+// Encoding is the character encoding of the text in the Text, Copyright, TrackName, Lyric
+// etc. meta events. The MIDI specification doesn't define an encoding and MIDI files in the
+// wild use whatever the authoring software used, typically ASCII, Windows-1252 (or Latin-1),
+// Shift-JIS for Japanese files and, more recently, UTF-8.
+type Encoding int
+
+const (
+	Auto Encoding = iota
+	UTF8
+	Latin1
+	Windows1252
+	ShiftJIS
+	EUCJP
+)
+
+var encodings = map[Encoding]string{
+	Auto:        "auto",
+	UTF8:        "utf-8",
+	Latin1:      "latin-1",
+	Windows1252: "windows-1252",
+	ShiftJIS:    "shift-jis",
+	EUCJP:       "euc-jp",
+}
+
+var codecs = map[Encoding]encoding.Encoding{
+	Latin1:      charmap.ISO8859_1,
+	Windows1252: charmap.Windows1252,
+	ShiftJIS:    japanese.ShiftJIS,
+	EUCJP:       japanese.EUCJP,
+}
+
+func (e Encoding) String() string {
+	if s, ok := encodings[e]; ok {
+		return s
+	}
+
+	return "auto"
+}
+
+func (e *Encoding) Set(s string) error {
+	switch strings.ToLower(strings.ReplaceAll(s, "_", "-")) {
+	case "auto", "":
+		*e = Auto
+
+	case "utf-8", "utf8":
+		*e = UTF8
+
+	case "latin-1", "latin1", "iso-8859-1":
+		*e = Latin1
+
+	case "windows-1252", "cp1252":
+		*e = Windows1252
+
+	case "shift-jis", "sjis", "cp932", "windows-31j":
+		*e = ShiftJIS
+
+	case "euc-jp", "eucjp":
+		*e = EUCJP
+
+	default:
+		return fmt.Errorf("invalid text encoding (%v): expected 'auto', 'utf-8', 'latin-1', 'windows-1252', 'shift-jis' or 'euc-jp'", s)
+	}
+
+	return nil
+}
+
+// Decode returns the text for the encoded bytes, detecting the encoding if the encoding is
+// Auto. Invalid bytes are decoded as the Unicode replacement character.
+func (e Encoding) Decode(b []byte) string {
+	if e == Auto {
+		e = Detect(b)
+	}
+
+	if codec, ok := codecs[e]; ok {
+		if decoded, err := codec.NewDecoder().Bytes(b); err == nil {
+			return string(decoded)
+		}
+	}
+
+	return strings.ToValidUTF8(string(b), string(utf8.RuneError))
+}
+
+// Encode returns the encoded bytes for the text. Auto encodes the text as UTF-8.
+func (e Encoding) Encode(s string) ([]byte, error) {
+	codec, ok := codecs[e]
+	if !ok {
+		return []byte(s), nil
+	}
+
+	encoder := codec.NewEncoder()
+	encoded := make([]byte, 0, len(s))
+
+	for _, r := range s {
+		if b, err := encoder.String(string(r)); err != nil {
+			return nil, fmt.Errorf("invalid %v text (%q): %q cannot be encoded as %v", e, s, r, e)
+		} else {
+			encoded = append(encoded, b...)
+		}
+	}
+
+	return encoded, nil
+}
+
+// Detect returns the most likely encoding for the text: UTF-8 if the text is valid UTF-8 and
+// otherwise whichever of Shift-JIS, EUC-JP and Windows-1252 decodes the text to the most
+// 'plausible' characters i.e. kana, Japanese punctuation and JIS level 1 kanji for Shift-JIS
+// and EUC-JP and accented letters next to other letters for Windows-1252. Defaults to
+// Windows-1252 if the text is not obviously Japanese.
+//
+// The detection is per event so short texts can be ambiguous (e.g. two halfwidth katakana
+// are also two accented capital letters) - set the encoding explicitly if the detected
+// encoding is wrong.
+func Detect(b []byte) Encoding {
+	if utf8.Valid(b) {
+		return UTF8
+	}
+
+	sjis := japaneseStats(japanese.ShiftJIS, b)
+	euc := japaneseStats(japanese.EUCJP, b)
+	latin := latinScore(b)
+
+	switch {
+	case euc.invalid == 0 && euc.score > latin && (sjis.invalid > 0 || euc.score >= sjis.score):
+		return EUCJP
+
+	case sjis.invalid == 0 && sjis.score > latin:
+		return ShiftJIS
+
+	default:
+		return Windows1252
+	}
+}
+
+// stats are the number of invalid byte sequences and the number of commonly used Japanese
+// characters in decoded Shift-JIS or EUC-JP text.
+type stats struct {
+	invalid int
+	score   int
+}
+
+// japaneseStats decodes the text as Shift-JIS or EUC-JP and counts the invalid byte sequences
+// and the 'common' characters i.e. adjacent halfwidth katakana, the characters in JIS X 0208
+// rows 1-8 (punctuation, kana, alphanumerics etc.) and the JIS level 1 kanji (rows 16-47).
+func japaneseStats(codec encoding.Encoding, b []byte) stats {
+	var st stats
+
+	decoded, err := codec.NewDecoder().Bytes(b)
+	if err != nil {
+		return stats{invalid: len(b)}
+	}
+
+	halfwidth := func(r rune) bool {
+		return r >= 0xff61 && r <= 0xff9f
+	}
+
+	jis := japanese.EUCJP.NewEncoder()
+	runes := []rune(string(decoded))
+
+	for i, r := range runes {
+		switch {
+		case r == utf8.RuneError:
+			st.invalid++
+
+		case halfwidth(r):
+			if (i > 0 && halfwidth(runes[i-1])) || (i+1 < len(runes) && halfwidth(runes[i+1])) {
+				st.score++
+			}
+
+		case r >= 0x80:
+			if v, err := jis.String(string(r)); err == nil && len(v) == 2 {
+				row := int(v[0]) - 0xa1
+				if row < 8 || (row >= 15 && row < 47) {
+					st.score++
+				}
+			}
+		}
+	}
+
+	return st
+}
+
+// latinScore returns the number of bytes that decode as Windows-1252 accented letters next to
+// another letter.
+func latinScore(b []byte) int {
+	letter := func(i int) bool {
+		if i < 0 || i >= len(b) {
+			return false
+		}
+
+		c := b[i]
+		if c < 0x80 {
+			return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		}
+
+		switch c {
+		case 0x8a, 0x8c, 0x8e, 0x9a, 0x9c, 0x9e, 0x9f: // Š Œ Ž š œ ž Ÿ
+			return true
+
+		case 0xd7, 0xf7: // × ÷
+			return false
+
+		default:
+			return c >= 0xc0
+		}
+	}
+
+	score := 0
+	for i, c := range b {
+		if c >= 0x80 && letter(i) && (letter(i-1) || letter(i+1)) {
+			score++
+		}
+	}
+
+	return score
+}
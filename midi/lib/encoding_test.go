@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"reflect"
+	"testing"
+)
+
+var encodingTests = []struct {
+	encoding Encoding
+	text     string
+	encoded  []byte
+}{
+	{UTF8, "さくら", []byte{0xe3, 0x81, 0x95, 0xe3, 0x81, 0x8f, 0xe3, 0x82, 0x89}},
+	{Latin1, "Grüße", []byte{0x47, 0x72, 0xfc, 0xdf, 0x65}},
+	{Windows1252, "Café – naïve", []byte{0x43, 0x61, 0x66, 0xe9, 0x20, 0x96, 0x20, 0x6e, 0x61, 0xef, 0x76, 0x65}},
+	{ShiftJIS, "さくら 桜", []byte{0x82, 0xb3, 0x82, 0xad, 0x82, 0xe7, 0x20, 0x8d, 0xf7}},
+	{ShiftJIS, "カラオケ①", []byte{0x83, 0x4a, 0x83, 0x89, 0x83, 0x49, 0x83, 0x50, 0x87, 0x40}},
+	{ShiftJIS, "ｶﾗｵｹ", []byte{0xb6, 0xd7, 0xb5, 0xb9}},
+	{EUCJP, "さくら 桜", []byte{0xa4, 0xb5, 0xa4, 0xaf, 0xa4, 0xe9, 0x20, 0xba, 0xf9}},
+	{EUCJP, "ｶﾗｵｹ", []byte{0x8e, 0xb6, 0x8e, 0xd7, 0x8e, 0xb5, 0x8e, 0xb9}},
+}
+
+func TestEncodingDecode(t *testing.T) {
+	for _, test := range encodingTests {
+		if text := test.encoding.Decode(test.encoded); text != test.text {
+			t.Errorf("Incorrectly decoded %v text - expected:%q, got:%q", test.encoding, test.text, text)
+		}
+	}
+}
+
+func TestEncodingEncode(t *testing.T) {
+	for _, test := range encodingTests {
+		if encoded, err := test.encoding.Encode(test.text); err != nil {
+			t.Errorf("Error encoding %v text %q (%v)", test.encoding, test.text, err)
+		} else if !reflect.DeepEqual(encoded, test.encoded) {
+			t.Errorf("Incorrectly encoded %v text %q - expected:%v, got:%v", test.encoding, test.text, test.encoded, encoded)
+		}
+	}
+}
+
+func TestEncodingEncodeInvalid(t *testing.T) {
+	tests := []struct {
+		encoding Encoding
+		text     string
+	}{
+		{Latin1, "€"},
+		{Windows1252, "さくら"},
+		{ShiftJIS, "Café"},
+		{EUCJP, "①€"},
+	}
+
+	for _, test := range tests {
+		if _, err := test.encoding.Encode(test.text); err == nil {
+			t.Errorf("Expected error encoding %q as %v", test.text, test.encoding)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	for _, test := range encodingTests {
+		expected := test.encoding
+		if expected == Latin1 {
+			expected = Windows1252
+		}
+
+		if encoding := Detect(test.encoded); encoding != expected {
+			t.Errorf("Incorrectly detected encoding for %q - expected:%v, got:%v", test.text, expected, encoding)
+		}
+	}
+
+	tests := []struct {
+		encoded  []byte
+		expected Encoding
+	}{
+		{[]byte("plain ASCII"), UTF8},
+		{[]byte{0xe7, 0x61}, Windows1252},                   // ça
+		{[]byte{0x8a, 0x6b, 0x6f, 0x64, 0x61}, Windows1252}, // Škoda
+		{[]byte{0x93, 0xfa, 0x96, 0x7b}, ShiftJIS},          // 日本
+		{[]byte{0x88, 0xa4}, ShiftJIS},                      // 愛
+	}
+
+	for _, test := range tests {
+		if encoding := Detect(test.encoded); encoding != test.expected {
+			t.Errorf("Incorrectly detected encoding for %v - expected:%v, got:%v", test.encoded, test.expected, encoding)
+		}
+	}
+}
+
+func TestEncodingSet(t *testing.T) {
+	tests := map[string]Encoding{
+		"auto":      Auto,
+		"UTF-8":     UTF8,
+		"latin1":    Latin1,
+		"cp1252":    Windows1252,
+		"Shift_JIS": ShiftJIS,
+		"sjis":      ShiftJIS,
+		"euc-jp":    EUCJP,
+	}
+
+	for s, expected := range tests {
+		var e Encoding
+		if err := e.Set(s); err != nil {
+			t.Errorf("Error parsing encoding %q (%v)", s, err)
+		} else if e != expected {
+			t.Errorf("Incorrectly parsed encoding %q - expected:%v, got:%v", s, expected, e)
+		}
+	}
+
+	var e Encoding
+	if err := e.Set("ebcdic"); err == nil {
+		t.Errorf("Expected error parsing invalid encoding")
+	}
+}
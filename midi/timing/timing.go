@@ -0,0 +1,289 @@
+package timing
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+)
+
+// Map converts between MIDI ticks, wall clock time and bar:beat positions using the
+// Tempo and TimeSignature events in a MIDI file. A file without any Tempo or
+// TimeSignature events defaults to 120 BPM and 4/4.
+type Map struct {
+	PPQN       uint16
+	tempi      []tempo
+	signatures []signature
+}
+
+type tempo struct {
+	tick  uint64
+	tempo uint32
+	at    time.Duration
+}
+
+type signature struct {
+	tick        uint64
+	bar         int
+	numerator   uint8
+	denominator uint8
+	clocks      uint8
+}
+
+// Position is the 1-based bar and beat of a tick, with Ticks the offset of the tick
+// from the start of the beat.
+type Position struct {
+	Bar   int
+	Beat  int
+	Ticks uint64
+}
+
+// Beat is a single beat in the beat grid of a MIDI file.
+type Beat struct {
+	Bar         int
+	Beat        int
+	Tick        uint64
+	At          time.Duration
+	Tempo       uint32
+	Numerator   uint8
+	Denominator uint8
+	Clocks      uint8
+}
+
+const DefaultTempo = 500000
+
+func (p Position) String() string {
+	return fmt.Sprintf("%v:%v", p.Bar, p.Beat)
+}
+
+func NewMap(smf *midi.SMF) (*Map, error) {
+	if smf.MThd == nil {
+		return nil, fmt.Errorf("missing MThd")
+	} else if smf.MThd.SMPTETimeCode || smf.MThd.PPQN == 0 {
+		return nil, fmt.Errorf("SMPTE time division is not supported")
+	}
+
+	m := Map{
+		PPQN: smf.MThd.PPQN,
+	}
+
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			switch v := e.Event.(type) {
+			case metaevent.Tempo:
+				m.tempi = append(m.tempi, tempo{tick: e.Tick(), tempo: v.Tempo})
+
+			case metaevent.TimeSignature:
+				m.signatures = append(m.signatures, signature{
+					tick:        e.Tick(),
+					numerator:   v.Numerator,
+					denominator: v.Denominator,
+					clocks:      v.TicksPerClick,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(m.tempi, func(i, j int) bool {
+		return m.tempi[i].tick < m.tempi[j].tick
+	})
+
+	sort.SliceStable(m.signatures, func(i, j int) bool {
+		return m.signatures[i].tick < m.signatures[j].tick
+	})
+
+	if len(m.tempi) == 0 || m.tempi[0].tick > 0 {
+		m.tempi = append([]tempo{{tick: 0, tempo: DefaultTempo}}, m.tempi...)
+	}
+
+	if len(m.signatures) == 0 || m.signatures[0].tick > 0 {
+		m.signatures = append([]signature{{tick: 0, numerator: 4, denominator: 4, clocks: 24}}, m.signatures...)
+	}
+
+	// ... wall clock time at each tempo change
+	for i := 1; i < len(m.tempi); i++ {
+		p := m.tempi[i-1]
+		m.tempi[i].at = p.at + m.duration(m.tempi[i].tick-p.tick, p.tempo)
+	}
+
+	// ... bar at each time signature change (a time signature change always starts a new bar)
+	m.signatures[0].bar = 1
+	for i := 1; i < len(m.signatures); i++ {
+		p := m.signatures[i-1]
+		length := m.barLength(p)
+		bars := (m.signatures[i].tick - p.tick + length - 1) / length
+
+		m.signatures[i].bar = p.bar + int(bars)
+	}
+
+	return &m, nil
+}
+
+// Time returns the wall clock time of a tick.
+func (m Map) Time(tick uint64) time.Duration {
+	t := m.tempoAt(tick)
+
+	return t.at + m.duration(tick-t.tick, t.tempo)
+}
+
+// Tick returns the tick at a wall clock time, rounded to the nearest tick.
+func (m Map) Tick(at time.Duration) uint64 {
+	if at <= 0 {
+		return 0
+	}
+
+	t := m.tempi[0]
+	for _, v := range m.tempi[1:] {
+		if v.at > at {
+			break
+		}
+		t = v
+	}
+
+	ns := uint64(at - t.at)
+	tempo := uint64(t.tempo) * 1000
+
+	return t.tick + (ns*uint64(m.PPQN)+tempo/2)/tempo
+}
+
+// Tempo returns the tempo (in microseconds per quarter note) in effect at a tick.
+func (m Map) Tempo(tick uint64) uint32 {
+	return m.tempoAt(tick).tempo
+}
+
+// TimeSignature returns the numerator and denominator of the time signature in effect at a tick.
+func (m Map) TimeSignature(tick uint64) (uint8, uint8) {
+	s := m.signatureAt(tick)
+
+	return s.numerator, s.denominator
+}
+
+// Position returns the bar:beat position of a tick.
+func (m Map) Position(tick uint64) Position {
+	s := m.signatureAt(tick)
+	beat := m.beatLength(s)
+	bar := m.barLength(s)
+	offset := tick - s.tick
+
+	return Position{
+		Bar:   s.bar + int(offset/bar),
+		Beat:  1 + int((offset%bar)/beat),
+		Ticks: (offset % bar) % beat,
+	}
+}
+
+// Bar returns the tick at the start of a (1-based) bar.
+func (m Map) Bar(bar int) uint64 {
+	s := m.signatures[0]
+	for _, v := range m.signatures[1:] {
+		if v.bar > bar {
+			break
+		}
+		s = v
+	}
+
+	if bar < s.bar {
+		return s.tick
+	}
+
+	return s.tick + uint64(bar-s.bar)*m.barLength(s)
+}
+
+// Beats returns the beat grid from the start of the file up to (but not including) the
+// end tick.
+func (m Map) Beats(end uint64) []Beat {
+	beats := []Beat{}
+
+	for i, s := range m.signatures {
+		next := end
+		if i+1 < len(m.signatures) && m.signatures[i+1].tick < end {
+			next = m.signatures[i+1].tick
+		}
+
+		length := m.beatLength(s)
+		beat := 0
+		for tick := s.tick; tick < next; tick += length {
+			beats = append(beats, Beat{
+				Bar:         s.bar + beat/int(s.numerator),
+				Beat:        1 + beat%int(s.numerator),
+				Tick:        tick,
+				At:          m.Time(tick),
+				Tempo:       m.Tempo(tick),
+				Numerator:   s.numerator,
+				Denominator: s.denominator,
+				Clocks:      s.clocks,
+			})
+
+			beat++
+		}
+	}
+
+	return beats
+}
+
+// BeatLength returns the number of ticks in a beat at a tick.
+func (m Map) BeatLength(tick uint64) uint64 {
+	return m.beatLength(m.signatureAt(tick))
+}
+
+// ClickLength returns the number of ticks between metronome clicks at a tick, from the
+// TimeSignature 'MIDI clocks per click' (with 24 MIDI clocks per quarter note).
+func (m Map) ClickLength(tick uint64) uint64 {
+	s := m.signatureAt(tick)
+	if s.clocks == 0 {
+		return m.beatLength(s)
+	}
+
+	return max(1, uint64(s.clocks)*uint64(m.PPQN)/24)
+}
+
+// BarLength returns the number of ticks in a bar at a tick.
+func (m Map) BarLength(tick uint64) uint64 {
+	return m.barLength(m.signatureAt(tick))
+}
+
+func (m Map) tempoAt(tick uint64) tempo {
+	t := m.tempi[0]
+	for _, v := range m.tempi[1:] {
+		if v.tick > tick {
+			break
+		}
+		t = v
+	}
+
+	return t
+}
+
+func (m Map) signatureAt(tick uint64) signature {
+	s := m.signatures[0]
+	for _, v := range m.signatures[1:] {
+		if v.tick > tick {
+			break
+		}
+		s = v
+	}
+
+	return s
+}
+
+func (m Map) duration(ticks uint64, tempo uint32) time.Duration {
+	return time.Duration(ticks * uint64(tempo) * 1000 / uint64(m.PPQN))
+}
+
+func (m Map) beatLength(s signature) uint64 {
+	if s.denominator == 0 {
+		return uint64(m.PPQN)
+	}
+
+	return 4 * uint64(m.PPQN) / uint64(s.denominator)
+}
+
+func (m Map) barLength(s signature) uint64 {
+	if s.numerator == 0 {
+		return m.beatLength(s)
+	}
+
+	return uint64(s.numerator) * m.beatLength(s)
+}
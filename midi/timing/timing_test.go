@@ -0,0 +1,166 @@
+package timing
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+)
+
+var smf = midi.SMF{
+	MThd: &midi.MThd{
+		Format:   1,
+		Tracks:   1,
+		PPQN:     480,
+		Division: 480,
+	},
+
+	Tracks: []*midi.MTrk{
+		&midi.MTrk{
+			Events: []*events.Event{
+				&events.Event{Event: metaevent.MakeTempo(0, 0, 500000)},
+				&events.Event{Event: metaevent.MakeTimeSignature(0, 0, 4, 4, 24, 8)},
+				&events.Event{Event: metaevent.MakeTempo(1920, 1920, 250000)},
+				&events.Event{Event: metaevent.MakeTimeSignature(3840, 1920, 3, 4, 24, 8)},
+				&events.Event{Event: metaevent.MakeTimeSignature(5280, 1440, 6, 8, 36, 8)},
+				&events.Event{Event: metaevent.MakeEndOfTrack(5280, 0)},
+			},
+		},
+	},
+}
+
+func TestTime(t *testing.T) {
+	tests := []struct {
+		tick     uint64
+		expected time.Duration
+	}{
+		{0, 0},
+		{480, 500 * time.Millisecond},
+		{1920, 2 * time.Second},
+		{2400, 2250 * time.Millisecond},
+		{3840, 3 * time.Second},
+	}
+
+	m, err := NewMap(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, test := range tests {
+		if at := m.Time(test.tick); at != test.expected {
+			t.Errorf("Incorrect time for tick %v - expected:%v, got:%v", test.tick, test.expected, at)
+		}
+	}
+}
+
+func TestTick(t *testing.T) {
+	tests := []struct {
+		at       time.Duration
+		expected uint64
+	}{
+		{0, 0},
+		{500 * time.Millisecond, 480},
+		{2 * time.Second, 1920},
+		{2250 * time.Millisecond, 2400},
+		{3 * time.Second, 3840},
+		{2750 * time.Millisecond, 3360},
+	}
+
+	m, err := NewMap(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, test := range tests {
+		if tick := m.Tick(test.at); tick != test.expected {
+			t.Errorf("Incorrect tick for time %v - expected:%v, got:%v", test.at, test.expected, tick)
+		}
+	}
+}
+
+func TestPosition(t *testing.T) {
+	tests := []struct {
+		tick     uint64
+		expected Position
+	}{
+		{0, Position{Bar: 1, Beat: 1}},
+		{480, Position{Bar: 1, Beat: 2}},
+		{500, Position{Bar: 1, Beat: 2, Ticks: 20}},
+		{1920, Position{Bar: 2, Beat: 1}},
+		{3840, Position{Bar: 3, Beat: 1}},
+		{4800, Position{Bar: 3, Beat: 3}},
+		{5280, Position{Bar: 4, Beat: 1}},
+		{5520, Position{Bar: 4, Beat: 2}},
+		{6720, Position{Bar: 5, Beat: 1}},
+	}
+
+	m, err := NewMap(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, test := range tests {
+		if p := m.Position(test.tick); !reflect.DeepEqual(p, test.expected) {
+			t.Errorf("Incorrect position for tick %v - expected:%v, got:%v", test.tick, test.expected, p)
+		}
+	}
+
+	for _, test := range tests {
+		if test.expected.Beat == 1 && test.expected.Ticks == 0 {
+			if tick := m.Bar(test.expected.Bar); tick != test.tick {
+				t.Errorf("Incorrect tick for bar %v - expected:%v, got:%v", test.expected.Bar, test.tick, tick)
+			}
+		}
+	}
+}
+
+func TestBeats(t *testing.T) {
+	m, err := NewMap(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	beats := m.Beats(5760)
+
+	if len(beats) != 13 {
+		t.Fatalf("Incorrect number of beats - expected:%v, got:%v", 13, len(beats))
+	}
+
+	expected := []Beat{
+		{Bar: 1, Beat: 1, Tick: 0, At: 0, Tempo: 500000, Numerator: 4, Denominator: 4, Clocks: 24},
+		{Bar: 2, Beat: 1, Tick: 1920, At: 2 * time.Second, Tempo: 250000, Numerator: 4, Denominator: 4, Clocks: 24},
+		{Bar: 3, Beat: 3, Tick: 4800, At: 3500 * time.Millisecond, Tempo: 250000, Numerator: 3, Denominator: 4, Clocks: 24},
+		{Bar: 4, Beat: 2, Tick: 5520, At: 3875 * time.Millisecond, Tempo: 250000, Numerator: 6, Denominator: 8, Clocks: 36},
+	}
+
+	for i, ix := range []int{0, 4, 10, 12} {
+		if !reflect.DeepEqual(beats[ix], expected[i]) {
+			t.Errorf("Incorrect beat %v\n   expected:%+v\n   got:     %+v", ix, expected[i], beats[ix])
+		}
+	}
+}
+
+func TestClickLength(t *testing.T) {
+	tests := []struct {
+		tick     uint64
+		expected uint64
+	}{
+		{0, 480},
+		{3840, 480},
+		{5280, 720},
+	}
+
+	m, err := NewMap(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, test := range tests {
+		if length := m.ClickLength(test.tick); length != test.expected {
+			t.Errorf("Incorrect click length for tick %v - expected:%v, got:%v", test.tick, test.expected, length)
+		}
+	}
+}
@@ -0,0 +1,1077 @@
+package assemble
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// ABCAssembler assembles a Format 1 MIDI file from the first tune in an ABC file. Track 0
+// holds the title, tempo, time signature and key signature events and each voice (V:) is
+// assembled into its own track, with notes, chords, ties, tuplets and broken rhythms. Note
+// velocities are taken from the !dynamics! decorations. Repeats, grace notes and voice
+// overlays are not expanded.
+type ABCAssembler struct {
+	PPQN     uint16
+	Velocity byte
+}
+
+type abcVoice struct {
+	id       string
+	name     string
+	channel  lib.Channel
+	program  int
+	ppqn     uint64
+	meter    abcMeter
+	unit     abcFraction
+	key      map[byte]int
+	bar      map[string]int
+	velocity byte
+	tick     uint64
+	notes    []mxlNoteEvent
+	tied     map[byte]int
+	tuplet   abcTuplet
+	broken   abcFraction
+	last     *abcElement
+}
+
+type abcMeter struct {
+	beats    int
+	beatType int
+}
+
+type abcFraction struct {
+	n uint64
+	d uint64
+}
+
+type abcTuplet struct {
+	p         uint64
+	q         uint64
+	remaining int
+}
+
+type abcElement struct {
+	start    uint64
+	duration uint64
+	notes    []int
+}
+
+type abcTune struct {
+	title     string
+	ppqn      uint64
+	meter     abcMeter
+	unit      abcFraction
+	key       map[byte]int
+	voices    []*abcVoice
+	current   *abcVoice
+	conductor []events.IEvent
+}
+
+var abcField = regexp.MustCompile(`^([A-Za-z+]):(.*)$`)
+var abcLetters = regexp.MustCompile(`^[A-Za-z]+$`)
+var abcAnnotation = regexp.MustCompile(`"[^"]*"`)
+var abcAccidental = regexp.MustCompile(`^(\^\^|\^|__|_|=)([A-Ga-g])$`)
+
+// Key signature offsets (in fifths) for the ABC modes.
+var modes = map[string]int{"maj": 0, "ion": 0, "mix": -1, "dor": -2, "min": -3, "aeo": -3, "phr": -4, "loc": -5, "lyd": 1}
+
+func NewABCAssembler() ABCAssembler {
+	return ABCAssembler{
+		PPQN:     480,
+		Velocity: 80,
+	}
+}
+
+func (a ABCAssembler) Assemble(r io.Reader) ([]byte, error) {
+	smf, err := a.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+// Parse reads the first tune in an ABC file and returns the equivalent Format 1 MIDI file.
+func (a ABCAssembler) Parse(r io.Reader) (*midi.SMF, error) {
+	ppqn := a.PPQN
+	if ppqn == 0 {
+		ppqn = 480
+	}
+
+	tune := abcTune{
+		ppqn:  uint64(ppqn),
+		meter: abcMeter{4, 4},
+		key:   map[byte]int{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	header := false
+	body := false
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		s := strings.TrimRight(scanner.Text(), " \t\r")
+
+		// ... directives and comments
+		if strings.HasPrefix(s, "%%") {
+			if body {
+				if err := a.directive(&tune, s); err != nil {
+					return nil, fmt.Errorf("line %v: %v", line, err)
+				}
+			}
+			continue
+		}
+
+		if ix := strings.Index(s, "%"); ix >= 0 && (ix == 0 || s[ix-1] != '\\') {
+			s = strings.TrimRight(s[:ix], " \t")
+		}
+
+		match := abcField.FindStringSubmatch(s)
+
+		// ... tune header
+		if !header && !body {
+			if match != nil && match[1] == "X" {
+				header = true
+			}
+			continue
+		}
+
+		if match != nil && match[1] == "X" {
+			break
+		}
+
+		if header {
+			if match == nil {
+				if strings.TrimSpace(s) == "" {
+					continue
+				}
+
+				return nil, fmt.Errorf("line %v: missing K: field at end of tune header", line)
+			}
+
+			if err := a.field(&tune, match[1], strings.TrimSpace(match[2]), true); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+
+			if match[1] == "K" {
+				header = false
+				body = true
+			}
+
+			continue
+		}
+
+		// ... tune body
+		if match != nil {
+			if err := a.field(&tune, match[1], strings.TrimSpace(match[2]), false); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+		} else if strings.TrimSpace(s) == "" {
+			break
+		} else if err := a.music(&tune, s); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	} else if !body {
+		return nil, fmt.Errorf("invalid ABC file: no tune with X: and K: fields")
+	}
+
+	if len(tune.voices) == 0 {
+		tune.voice("1")
+	}
+
+	// ... track 0
+	end := uint64(0)
+	for _, v := range tune.voices {
+		end = max(end, v.tick)
+		for _, n := range v.notes {
+			end = max(end, n.end)
+		}
+	}
+
+	mthd := midi.MakeMThd(1, uint16(len(tune.voices)+1), ppqn&0x7fff)
+	smf := midi.SMF{
+		MThd: &mthd,
+	}
+
+	conductor := tune.conductor
+	if tune.title != "" {
+		conductor = append([]events.IEvent{metaevent.MakeTrackName(0, 0, tune.title)}, conductor...)
+	}
+
+	if mtrk, err := mktrack(0, conductor, end); err != nil {
+		return nil, err
+	} else {
+		smf.Tracks = append(smf.Tracks, mtrk)
+	}
+
+	// ... voice tracks
+	for i, v := range tune.voices {
+		list := []events.IEvent{metaevent.MakeTrackName(0, 0, v.name)}
+
+		if v.program >= 0 {
+			list = append(list, midievent.MakeProgramChange(0, 0, v.channel, 0, uint8(v.program)))
+		}
+
+		for _, n := range v.notes {
+			velocity := a.Velocity
+			if n.velocity >= 0 {
+				velocity = byte(n.velocity)
+			}
+
+			list = append(list, midievent.MakeNoteOn(n.start, 0, v.channel, note(uint64(n.note)), max(1, velocity)))
+			list = append(list, midievent.MakeNoteOff(n.end, 0, v.channel, note(uint64(n.note)), 64))
+		}
+
+		if mtrk, err := mktrack(i+1, list, end); err != nil {
+			return nil, err
+		} else {
+			smf.Tracks = append(smf.Tracks, mtrk)
+		}
+	}
+
+	return &smf, nil
+}
+
+// voice returns the voice with the ID, creating it with the tune defaults if necessary.
+func (t *abcTune) voice(id string) *abcVoice {
+	for _, v := range t.voices {
+		if v.id == id {
+			return v
+		}
+	}
+
+	channel := lib.Channel(len(t.voices) % 16)
+	if len(t.voices) >= 9 {
+		channel = lib.Channel((len(t.voices) + 1) % 16)
+	}
+
+	v := abcVoice{
+		id:      id,
+		name:    fmt.Sprintf("Voice %v", id),
+		channel: channel,
+		program: -1,
+		ppqn:    t.ppqn,
+		meter:   t.meter,
+		unit:    t.unit,
+		key:     t.key,
+		bar:     map[string]int{},
+		tied:    map[byte]int{},
+	}
+
+	t.voices = append(t.voices, &v)
+
+	return &v
+}
+
+// active returns the current voice, defaulting to the first voice or creating a voice if the
+// tune has no V: fields.
+func (t *abcTune) active() *abcVoice {
+	if t.current == nil && len(t.voices) > 0 {
+		t.current = t.voices[0]
+	} else if t.current == nil {
+		t.current = t.voice("1")
+	}
+
+	return t.current
+}
+
+// field processes a header field, body field or inline field.
+func (a ABCAssembler) field(tune *abcTune, field string, value string, header bool) error {
+	switch field {
+	case "T":
+		if header && tune.title == "" {
+			tune.title = value
+		}
+
+	case "M":
+		meter, err := abcmeter(value)
+		if err != nil {
+			return err
+		}
+
+		if header {
+			tune.meter = meter
+		} else {
+			tune.active().meter = meter
+		}
+
+		if meter.beats > 0 && (header || tune.active() == tune.voices[0]) {
+			tick := a.tick(tune, header)
+			clocks := uint8(max(1, 96/meter.beatType))
+
+			tune.conductor = append(tune.conductor, metaevent.MakeTimeSignature(tick, 0, uint8(meter.beats), uint8(meter.beatType), clocks, 8))
+		}
+
+	case "L":
+		unit, err := abcfraction(value)
+		if err != nil {
+			return fmt.Errorf("invalid unit note length (%v)", value)
+		}
+
+		if header {
+			tune.unit = unit
+		} else {
+			tune.active().unit = unit
+		}
+
+	case "Q":
+		if header || tune.active() == tune.voices[0] {
+			unit := tune.unit
+			if !header {
+				unit = tune.active().unit
+			}
+
+			if unit.n == 0 {
+				unit = abcunit(tune.meter)
+			}
+
+			if tempo, err := abctempo(value, unit); err != nil {
+				return err
+			} else if tempo > 0 {
+				tune.conductor = append(tune.conductor, metaevent.MakeTempo(a.tick(tune, header), 0, tempo))
+			}
+		}
+
+	case "K":
+		fifths, keytype, key, err := abckey(value)
+		if err != nil {
+			return err
+		}
+
+		if header {
+			tune.key = key
+			if tune.unit.n == 0 {
+				tune.unit = abcunit(tune.meter)
+			}
+
+			for _, v := range tune.voices {
+				v.meter = tune.meter
+				v.unit = tune.unit
+				v.key = tune.key
+			}
+		} else {
+			tune.active().key = key
+		}
+
+		if header || tune.active() == tune.voices[0] {
+			tune.conductor = append(tune.conductor, metaevent.MakeKeySignature(a.tick(tune, header), 0, fifths, keytype))
+		}
+
+	case "V":
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return fmt.Errorf("missing voice ID")
+		}
+
+		v := tune.voice(fields[0])
+		if name := abcproperty(value, "name", "nm"); name != "" {
+			v.name = name
+		}
+
+		if !header {
+			tune.current = v
+		}
+	}
+
+	return nil
+}
+
+// directive processes the %%MIDI program and %%MIDI channel directives for the current voice.
+func (a ABCAssembler) directive(tune *abcTune, s string) error {
+	fields := strings.Fields(strings.TrimPrefix(s, "%%"))
+	if len(fields) < 3 || fields[0] != "MIDI" {
+		return nil
+	}
+
+	v, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return fmt.Errorf("invalid directive (%v)", s)
+	}
+
+	switch fields[1] {
+	case "program":
+		if v < 0 || v > 127 {
+			return fmt.Errorf("invalid program (%v)", v)
+		}
+
+		tune.active().program = v
+
+	case "channel":
+		if v < 1 || v > 16 {
+			return fmt.Errorf("invalid channel (%v)", v)
+		}
+
+		tune.active().channel = lib.Channel(v - 1)
+	}
+
+	return nil
+}
+
+func (a ABCAssembler) tick(tune *abcTune, header bool) uint64 {
+	if header {
+		return 0
+	}
+
+	return tune.active().tick
+}
+
+// music processes a line of music for the current voice.
+func (a ABCAssembler) music(tune *abcTune, s string) error {
+	v := tune.active()
+	runes := []rune(s)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case r == ' ' || r == '\t' || r == '`' || r == '\\' || r == 'y' || r == ')':
+			i++
+
+		case r == '"':
+			if j := index(runes, i+1, '"'); j < 0 {
+				return fmt.Errorf("unterminated annotation")
+			} else {
+				i = j + 1
+			}
+
+		case r == '!' || r == '+':
+			j := index(runes, i+1, r)
+			if j < 0 {
+				return fmt.Errorf("unterminated decoration")
+			}
+
+			if velocity, ok := dynamics[string(runes[i+1:j])]; ok {
+				v.velocity = velocity
+			}
+
+			i = j + 1
+
+		case strings.ContainsRune(".~HLMOPSTuv", r):
+			i++
+
+		case r == '{':
+			if j := index(runes, i+1, '}'); j < 0 {
+				return fmt.Errorf("unterminated grace notes")
+			} else {
+				i = j + 1
+			}
+
+		case r == '(':
+			i++
+			if i < len(runes) && runes[i] >= '2' && runes[i] <= '9' {
+				tuplet, j, err := abctuplet(runes, i, v.meter)
+				if err != nil {
+					return err
+				}
+
+				v.tuplet = tuplet
+				i = j
+			}
+
+		case r == '-':
+			if v.last != nil {
+				for _, ix := range v.last.notes {
+					v.tied[v.notes[ix].note] = ix
+				}
+			}
+			i++
+
+		case r == '>' || r == '<':
+			n := 0
+			for i < len(runes) && runes[i] == r {
+				n++
+				i++
+			}
+
+			long := abcFraction{1<<(n+1) - 1, 1 << n}
+			short := abcFraction{1, 1 << n}
+			if r == '<' {
+				long, short = short, long
+			}
+
+			if v.last != nil {
+				duration := v.last.duration * long.n / long.d
+				for _, ix := range v.last.notes {
+					v.notes[ix].end = v.last.start + duration
+				}
+
+				v.tick = v.last.start + duration
+				v.last.duration = duration
+			}
+
+			v.broken = short
+
+		case r == '[' && i+2 < len(runes) && isletter(runes[i+1]) && runes[i+2] == ':':
+			j := index(runes, i+1, ']')
+			if j < 0 {
+				return fmt.Errorf("unterminated inline field")
+			}
+
+			if err := a.field(tune, string(runes[i+1]), strings.TrimSpace(string(runes[i+3:j])), false); err != nil {
+				return err
+			}
+
+			v = tune.active()
+			i = j + 1
+
+		case r == '|' || r == ':' || r == ']' || (r == '[' && i+1 < len(runes) && (runes[i+1] == '|' || isdigit(runes[i+1]))):
+			for i < len(runes) && (strings.ContainsRune("|:]", runes[i]) || (runes[i] == '[' && i+1 < len(runes) && (runes[i+1] == '|' || isdigit(runes[i+1])))) {
+				i++
+			}
+
+			for i < len(runes) && (isdigit(runes[i]) || runes[i] == ',' || runes[i] == '-') {
+				i++
+			}
+
+			v.bar = map[string]int{}
+
+		case r == '&':
+			return fmt.Errorf("voice overlays (&) are not supported")
+
+		case r == 'z' || r == 'x':
+			length, j := abclength(runes, i+1)
+			a.element(v, nil, length)
+			i = j
+
+		case r == 'Z' || r == 'X':
+			j := i + 1
+			for j < len(runes) && isdigit(runes[j]) {
+				j++
+			}
+
+			bars := uint64(1)
+			if j > i+1 {
+				bars, _ = strconv.ParseUint(string(runes[i+1:j]), 10, 64)
+			}
+
+			meter := v.meter
+			if meter.beats == 0 {
+				meter = abcMeter{4, 4}
+			}
+
+			v.tick += bars * 4 * tune.ppqn * uint64(meter.beats) / uint64(meter.beatType)
+			v.tied = map[byte]int{}
+			v.last = nil
+			i = j
+
+		case r == '[':
+			j := index(runes, i+1, ']')
+			if j < 0 {
+				return fmt.Errorf("unterminated chord")
+			}
+
+			pitches := []byte{}
+			ties := []int{}
+			var first *abcFraction
+			for k := i + 1; k < j; {
+				if runes[k] == '-' && len(pitches) > 0 {
+					ties = append(ties, len(pitches)-1)
+				}
+
+				if runes[k] == ' ' || runes[k] == '-' {
+					k++
+					continue
+				}
+
+				pitch, length, next, err := a.note(v, runes, k)
+				if err != nil {
+					return err
+				}
+
+				pitches = append(pitches, pitch)
+				if first == nil {
+					first = &length
+				}
+				k = next
+			}
+
+			if len(pitches) == 0 {
+				i = j + 1
+				continue
+			}
+
+			outer, next := abclength(runes, j+1)
+			a.element(v, pitches, abcFraction{first.n * outer.n, first.d * outer.d})
+			for _, t := range ties {
+				ix := v.last.notes[t]
+				v.tied[v.notes[ix].note] = ix
+			}
+
+			i = next
+
+		case strings.ContainsRune("^_=ABCDEFGabcdefg", r):
+			pitch, length, j, err := a.note(v, runes, i)
+			if err != nil {
+				return err
+			}
+
+			a.element(v, []byte{pitch}, length)
+			i = j
+
+		default:
+			return fmt.Errorf("unexpected character '%c'", r)
+		}
+	}
+
+	return nil
+}
+
+// note parses a note with optional accidentals, octave marks and length, returning the
+// MIDI note number.
+func (a ABCAssembler) note(v *abcVoice, runes []rune, i int) (byte, abcFraction, int, error) {
+	alter := 0
+	explicit := false
+
+	for ; i < len(runes) && strings.ContainsRune("^_=", runes[i]); i++ {
+		explicit = true
+		switch runes[i] {
+		case '^':
+			alter++
+		case '_':
+			alter--
+		}
+	}
+
+	if i >= len(runes) || !strings.ContainsRune("ABCDEFGabcdefg", runes[i]) {
+		return 0, abcFraction{}, i, fmt.Errorf("invalid note")
+	}
+
+	letter := strings.ToUpper(string(runes[i]))
+	octave := 4
+	if runes[i] >= 'a' {
+		octave = 5
+	}
+
+	for i++; i < len(runes) && (runes[i] == '\'' || runes[i] == ','); i++ {
+		if runes[i] == '\'' {
+			octave++
+		} else {
+			octave--
+		}
+	}
+
+	id := fmt.Sprintf("%v%v", letter, octave)
+	if explicit {
+		v.bar[id] = alter
+	} else if a, ok := v.bar[id]; ok {
+		alter = a
+	} else {
+		alter = v.key[letter[0]]
+	}
+
+	value := 12*(octave+1) + steps[letter] + alter
+	if value < 0 || value > 127 {
+		return 0, abcFraction{}, i, fmt.Errorf("note out of range (%v%v)", letter, octave)
+	}
+
+	length, j := abclength(runes, i)
+
+	return byte(value), length, j, nil
+}
+
+// element adds a note, chord or rest (if pitches is nil) to the voice, applying any tuplet
+// or broken rhythm and continuing tied notes.
+func (a ABCAssembler) element(v *abcVoice, pitches []byte, length abcFraction) {
+	n := v.unit.n * length.n
+	d := v.unit.d * length.d
+
+	if v.tuplet.remaining > 0 {
+		n *= v.tuplet.q
+		d *= v.tuplet.p
+		if v.tuplet.remaining--; v.tuplet.remaining == 0 {
+			v.tuplet = abcTuplet{}
+		}
+	}
+
+	if v.broken.n > 0 {
+		n *= v.broken.n
+		d *= v.broken.d
+		v.broken = abcFraction{}
+	}
+
+	duration := uint64(math.Round(float64(4*v.ppqn*n) / float64(d)))
+	element := abcElement{
+		start:    v.tick,
+		duration: duration,
+	}
+
+	tied := v.tied
+	v.tied = map[byte]int{}
+
+	for _, p := range pitches {
+		if ix, ok := tied[p]; ok {
+			v.notes[ix].end = v.tick + duration
+			element.notes = append(element.notes, ix)
+			continue
+		}
+
+		velocity := -1
+		if v.velocity > 0 {
+			velocity = int(v.velocity)
+		}
+
+		v.notes = append(v.notes, mxlNoteEvent{
+			note:     p,
+			start:    v.tick,
+			end:      v.tick + duration,
+			velocity: velocity,
+		})
+
+		element.notes = append(element.notes, len(v.notes)-1)
+	}
+
+	v.tick += duration
+	v.last = &element
+}
+
+// abcmeter parses an M: field e.g. 6/8, C, C|, (2+3)/8 or none (which has no beats).
+func abcmeter(s string) (abcMeter, error) {
+	switch s {
+	case "", "none":
+		return abcMeter{}, nil
+	case "C":
+		return abcMeter{4, 4}, nil
+	case "C|":
+		return abcMeter{2, 2}, nil
+	}
+
+	numerator, denominator, ok := strings.Cut(s, "/")
+	if !ok {
+		return abcMeter{}, fmt.Errorf("invalid meter (%v)", s)
+	}
+
+	beats := 0
+	for _, b := range strings.Split(strings.Trim(numerator, "() "), "+") {
+		if n, err := strconv.Atoi(strings.TrimSpace(b)); err != nil {
+			return abcMeter{}, fmt.Errorf("invalid meter (%v)", s)
+		} else {
+			beats += n
+		}
+	}
+
+	beatType, err := strconv.Atoi(strings.TrimSpace(denominator))
+	if err != nil || beats < 1 || beats > 255 || beatType < 1 || beatType > 128 || beatType&(beatType-1) != 0 {
+		return abcMeter{}, fmt.Errorf("invalid meter (%v)", s)
+	}
+
+	return abcMeter{beats, beatType}, nil
+}
+
+// abcunit returns the default unit note length for a meter i.e. 1/16 if the meter is less
+// than 3/4 and 1/8 otherwise.
+func abcunit(meter abcMeter) abcFraction {
+	if meter.beats > 0 && 4*meter.beats < 3*meter.beatType {
+		return abcFraction{1, 16}
+	}
+
+	return abcFraction{1, 8}
+}
+
+// abcfraction parses a fraction e.g. 1/8 or 3/8.
+func abcfraction(s string) (abcFraction, error) {
+	numerator, denominator, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		denominator = "1"
+	}
+
+	if n, err := strconv.ParseUint(strings.TrimSpace(numerator), 10, 32); err != nil || n == 0 {
+		return abcFraction{}, fmt.Errorf("invalid fraction (%v)", s)
+	} else if d, err := strconv.ParseUint(strings.TrimSpace(denominator), 10, 32); err != nil || d == 0 {
+		return abcFraction{}, fmt.Errorf("invalid fraction (%v)", s)
+	} else {
+		return abcFraction{n, d}, nil
+	}
+}
+
+// abctempo parses a Q: field e.g. 1/4=120, "Allegro" 3/8=60, C=120 or 120 (in unit note
+// lengths), returning the tempo in microseconds per quarter note or 0 if the field has no
+// tempo.
+func abctempo(s string, unit abcFraction) (uint32, error) {
+	s = abcAnnotation.ReplaceAllString(s, "")
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, nil
+	}
+
+	beat := unit
+	bpm := s
+
+	if left, right, ok := strings.Cut(s, "="); ok {
+		bpm = right
+		beat = abcFraction{0, 1}
+
+		for _, f := range strings.Fields(left) {
+			if strings.HasPrefix(f, "C") {
+				n := uint64(1)
+				if f != "C" {
+					if v, err := strconv.ParseUint(f[1:], 10, 32); err != nil || v == 0 {
+						return 0, fmt.Errorf("invalid tempo (%v)", s)
+					} else {
+						n = v
+					}
+				}
+
+				f = fmt.Sprintf("%v/%v", n*unit.n, unit.d)
+			}
+
+			if v, err := abcfraction(f); err != nil {
+				return 0, fmt.Errorf("invalid tempo (%v)", s)
+			} else {
+				beat = abcFraction{beat.n*v.d + v.n*beat.d, beat.d * v.d}
+			}
+		}
+
+		if beat.n == 0 {
+			return 0, fmt.Errorf("invalid tempo (%v)", s)
+		}
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSpace(bpm), 64)
+	if err != nil || v <= 0 {
+		return 0, fmt.Errorf("invalid tempo (%v)", s)
+	}
+
+	quarter := v * 4 * float64(beat.n) / float64(beat.d)
+	if tempo := math.Round(60000000 / quarter); tempo < 1 || tempo > 0xffffff {
+		return 0, fmt.Errorf("invalid tempo (%v)", s)
+	} else {
+		return uint32(tempo), nil
+	}
+}
+
+// abckey parses a K: field e.g. G, Bb, F#m, D dor, Ador ^c or none, returning the MIDI key
+// signature and the accidentals for each note letter.
+func abckey(s string) (int8, lib.KeyType, map[byte]int, error) {
+	tonics := map[byte]int{'F': -1, 'C': 0, 'G': 1, 'D': 2, 'A': 3, 'E': 4, 'B': 5}
+
+	fifths := 0
+	keytype := lib.Major
+	fields := strings.Fields(s)
+
+	switch {
+	case len(fields) == 0 || fields[0] == "none" || fields[0] == "HP" || strings.HasPrefix(fields[0], "clef="):
+
+	case fields[0] == "Hp":
+		fifths = 2
+		fields = fields[1:]
+
+	default:
+		tonic, ok := tonics[fields[0][0]]
+		if !ok {
+			return 0, keytype, nil, fmt.Errorf("invalid key (%v)", s)
+		}
+
+		fifths = tonic
+		mode := fields[0][1:]
+		fields = fields[1:]
+
+		switch {
+		case strings.HasPrefix(mode, "#"):
+			fifths += 7
+			mode = mode[1:]
+		case strings.HasPrefix(mode, "b"):
+			fifths -= 7
+			mode = mode[1:]
+		}
+
+		if mode == "" && len(fields) > 0 && abcmode(fields[0]) {
+			mode = fields[0]
+			fields = fields[1:]
+		}
+
+		mode = strings.ToLower(mode)
+		switch {
+		case mode == "":
+		case !abcmode(mode):
+			return 0, keytype, nil, fmt.Errorf("invalid mode (%v)", s)
+		case mode == "m" || strings.HasPrefix(mode, "min") || strings.HasPrefix(mode, "aeo"):
+			fifths -= 3
+			keytype = lib.Minor
+		default:
+			fifths += modes[mode[:3]]
+		}
+
+		if fifths < -7 || fifths > 7 {
+			return 0, keytype, nil, fmt.Errorf("invalid key (%v)", s)
+		}
+	}
+
+	key := map[byte]int{}
+	for i := 0; i < fifths; i++ {
+		key["FCGDAEB"[i]] = 1
+	}
+
+	for i := 0; i < -fifths; i++ {
+		key["BEADGCF"[i]] = -1
+	}
+
+	// ... explicit accidentals
+	for _, f := range fields {
+		if match := abcAccidental.FindStringSubmatch(f); match != nil {
+			letter := strings.ToUpper(match[2])[0]
+			key[letter] = map[string]int{"^^": 2, "^": 1, "=": 0, "_": -1, "__": -2}[match[1]]
+		}
+	}
+
+	return int8(fifths), keytype, key, nil
+}
+
+// abcmode returns true if the string is a mode e.g. m, min, Dorian, mix.
+func abcmode(s string) bool {
+	s = strings.ToLower(s)
+	if s == "m" {
+		return true
+	} else if len(s) < 3 {
+		return false
+	}
+
+	_, ok := modes[s[:3]]
+
+	return ok && abcLetters.MatchString(s)
+}
+
+// abcproperty returns the value of a name=value property in a V: field.
+func abcproperty(s string, names ...string) string {
+	for _, name := range names {
+		if match := regexp.MustCompile(name + `=(?:"([^"]*)"|(\S+))`).FindStringSubmatch(s); match != nil {
+			return match[1] + match[2]
+		}
+	}
+
+	return ""
+}
+
+// abctuplet parses a tuplet (p:q:r starting after the '(', returning the tuplet and the
+// index of the next character.
+func abctuplet(runes []rune, i int, meter abcMeter) (abcTuplet, int, error) {
+	values := []uint64{}
+
+	for len(values) < 3 {
+		j := i
+		for j < len(runes) && isdigit(runes[j]) {
+			j++
+		}
+
+		if j == i {
+			values = append(values, 0)
+		} else {
+			v, _ := strconv.ParseUint(string(runes[i:j]), 10, 32)
+			values = append(values, v)
+		}
+
+		if i = j; i < len(runes) && runes[i] == ':' && len(values) < 3 {
+			i++
+		} else {
+			break
+		}
+	}
+
+	for len(values) < 3 {
+		values = append(values, 0)
+	}
+
+	p, q, r := values[0], values[1], values[2]
+	if q == 0 {
+		switch p {
+		case 2, 4, 8:
+			q = 3
+		case 3, 6:
+			q = 2
+		default:
+			if meter.beatType == 8 && meter.beats%3 == 0 && meter.beats > 3 {
+				q = 3
+			} else {
+				q = 2
+			}
+		}
+	}
+
+	if r == 0 {
+		r = p
+	}
+
+	if p < 2 || p > 9 {
+		return abcTuplet{}, i, fmt.Errorf("invalid tuplet (%v)", p)
+	}
+
+	return abcTuplet{p: p, q: q, remaining: int(r)}, i, nil
+}
+
+// abclength parses a note length e.g. 2, 3/2, /, // or /4, returning the length as a
+// multiple of the unit note length and the index of the next character.
+func abclength(runes []rune, i int) (abcFraction, int) {
+	n := uint64(1)
+	d := uint64(1)
+
+	j := i
+	for j < len(runes) && isdigit(runes[j]) {
+		j++
+	}
+
+	if j > i {
+		n, _ = strconv.ParseUint(string(runes[i:j]), 10, 32)
+		n = max(1, n)
+	}
+
+	for i = j; i < len(runes) && runes[i] == '/'; {
+		i++
+
+		j := i
+		for j < len(runes) && isdigit(runes[j]) {
+			j++
+		}
+
+		if j > i {
+			v, _ := strconv.ParseUint(string(runes[i:j]), 10, 32)
+			d *= max(1, v)
+			i = j
+		} else {
+			d *= 2
+		}
+	}
+
+	return abcFraction{n, d}, i
+}
+
+func index(runes []rune, from int, r rune) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func isdigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isletter(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+}
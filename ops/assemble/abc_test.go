@@ -0,0 +1,106 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+)
+
+//go:embed test-files/reference.abc
+var referenceABC []byte
+
+func TestABCReference(t *testing.T) {
+	expected := [][]string{
+		{
+			"0 TrackName Reference",
+			"0 TimeSignature 3/4",
+			"0 Tempo 666667",
+			"0 KeySignature -1 major",
+			"4320 EndOfTrack",
+		},
+		{
+			"0 TrackName Melody",
+			"0 ProgramChange 0 73",
+			"0 NoteOn 0 65 49",
+			"480 NoteOff 0 65",
+			"480 NoteOn 0 70 49",
+			"720 NoteOff 0 70",
+			"720 NoteOn 0 72 49",
+			"960 NoteOff 0 72",
+			"960 NoteOn 0 72 49",
+			"1440 NoteOff 0 72",
+			"1440 NoteOn 0 72 49",
+			"1600 NoteOff 0 72",
+			"1600 NoteOn 0 74 49",
+			"1760 NoteOff 0 74",
+			"1760 NoteOn 0 76 49",
+			"1920 NoteOff 0 76",
+			"1920 NoteOn 0 77 49",
+			"2280 NoteOff 0 77",
+			"2280 NoteOn 0 76 49",
+			"2400 NoteOff 0 76",
+			"2400 NoteOn 0 74 96",
+			"4320 NoteOff 0 74",
+			"4320 EndOfTrack",
+		},
+		{
+			"0 TrackName Bass",
+			"0 ProgramChange 1 32",
+			"0 NoteOn 1 41 80",
+			"0 NoteOn 1 48 80",
+			"1440 NoteOff 1 41",
+			"1440 NoteOff 1 48",
+			"1920 NoteOn 1 51 80",
+			"2880 NoteOff 1 51",
+			"2880 NoteOn 1 50 80",
+			"4320 NoteOff 1 50",
+			"4320 EndOfTrack",
+		},
+	}
+
+	smf, err := NewABCAssembler().Parse(bytes.NewReader(referenceABC))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if smf.MThd.Format != 1 || smf.MThd.PPQN != 480 || len(smf.Tracks) != 3 {
+		t.Fatalf("Incorrect MThd - expected format:1, PPQN:480, tracks:3 got:%v", smf.MThd)
+	}
+
+	for i, track := range smf.Tracks {
+		list := []string{}
+		for _, e := range track.Events {
+			s := fmt.Sprintf("%v %v", e.Tick(), e.Event.Tag())
+
+			switch v := e.Event.(type) {
+			case metaevent.TrackName:
+				s += " " + v.Name
+			case metaevent.Lyric:
+				s += " " + v.Lyric
+			case metaevent.Tempo:
+				s += fmt.Sprintf(" %v", v.Tempo)
+			case metaevent.TimeSignature:
+				s += fmt.Sprintf(" %v/%v", v.Numerator, v.Denominator)
+			case metaevent.KeySignature:
+				s += fmt.Sprintf(" %v %v", v.Accidentals, v.KeyType)
+			case midievent.ProgramChange:
+				s += fmt.Sprintf(" %v %v", v.Channel, v.Program)
+			case midievent.NoteOn:
+				s += fmt.Sprintf(" %v %v %v", v.Channel, v.Note.Value, v.Velocity)
+			case midievent.NoteOff:
+				s += fmt.Sprintf(" %v %v", v.Channel, v.Note.Value)
+			}
+
+			list = append(list, s)
+		}
+
+		if !reflect.DeepEqual(list, expected[i]) {
+			t.Errorf("Incorrectly assembled track %v\n   expected:%q\n   got:     %q", i, expected[i], list)
+		}
+	}
+}
@@ -2,9 +2,14 @@ package assemble
 
 import (
 	"io"
+	"sort"
 
 	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
 	"github.com/transcriptaze/midiasm/midi/events/sysex"
+	"github.com/transcriptaze/midiasm/midi/lib"
 )
 
 type Assembler interface {
@@ -50,3 +55,42 @@ func fixups(mtrk *midi.MTrk) (*midi.MTrk, error) {
 
 	return mtrk, nil
 }
+
+// mktrack builds an MTrk from the list of events, with note offs before note ons at the same
+// tick and the EndOfTrack at the end tick.
+func mktrack(n int, list []events.IEvent, end uint64) (*midi.MTrk, error) {
+	order := func(e events.IEvent) int {
+		switch e.(type) {
+		case midievent.NoteOff:
+			return 2
+		case midievent.NoteOn:
+			return 3
+		case metaevent.Lyric:
+			return 1
+		default:
+			return 0
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Tick() != list[j].Tick() {
+			return list[i].Tick() < list[j].Tick()
+		}
+
+		return order(list[i]) < order(list[j])
+	})
+
+	mtrk, err := midi.NewMTrk()
+	if err != nil {
+		return nil, err
+	}
+
+	mtrk.TrackNumber = lib.TrackNumber(n)
+	mtrk.Events = append(mtrk.Events, events.NewEvent(metaevent.MakeEndOfTrack(end, 0)))
+
+	if err := mtrk.Insert(list...); err != nil {
+		return nil, err
+	}
+
+	return mtrk, nil
+}
@@ -0,0 +1,576 @@
+package assemble
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/events/sysex"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// CSVAssembler assembles a MIDI file from the midicsv record format (Track, Time, Type, ...). The
+// default is to not use running status, so that the CSV export of a MIDI file that doesn't use
+// running status reassembles to the original file.
+type CSVAssembler struct {
+	RunningStatus midifile.RunningStatus
+}
+
+type csvtrack struct {
+	mtrk    *midi.MTrk
+	tick    uint64
+	pending bool
+}
+
+func NewCSVAssembler() CSVAssembler {
+	return CSVAssembler{
+		RunningStatus: midifile.RunningStatusNone,
+	}
+}
+
+func (a CSVAssembler) Assemble(r io.Reader) ([]byte, error) {
+	smf := midi.SMF{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	eof := false
+
+	var track *csvtrack
+	var ntracks uint64
+
+	for scanner.Scan() && !eof {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";") {
+			continue
+		}
+
+		fields, err := a.split(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		} else if len(fields) < 3 {
+			return nil, fmt.Errorf("line %v: invalid record (%v)", line, text)
+		}
+
+		tracknum, err := strconv.ParseUint(fields[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: invalid track (%v)", line, fields[0])
+		}
+
+		tick, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: invalid time (%v)", line, fields[1])
+		}
+
+		record := strings.ToLower(fields[2])
+		args := fields[3:]
+
+		switch {
+		case record == "header":
+			if smf.MThd != nil {
+				return nil, fmt.Errorf("line %v: duplicate header", line)
+			} else if mthd, N, err := a.parseHeader(args); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			} else {
+				smf.MThd = mthd
+				ntracks = N
+			}
+
+		case record == "end_of_file":
+			eof = true
+
+		case smf.MThd == nil:
+			return nil, fmt.Errorf("line %v: missing header", line)
+
+		case record == "start_track":
+			if track != nil {
+				return nil, fmt.Errorf("line %v: missing End_track for track %v", line, len(smf.Tracks))
+			} else if tracknum != uint64(len(smf.Tracks)+1) {
+				return nil, fmt.Errorf("line %v: invalid track number (%v): expected %v", line, tracknum, len(smf.Tracks)+1)
+			} else if mtrk, err := midi.NewMTrk(); err != nil {
+				return nil, err
+			} else {
+				mtrk.TrackNumber = lib.TrackNumber(len(smf.Tracks))
+				track = &csvtrack{mtrk: mtrk}
+			}
+
+		case track == nil || tracknum != uint64(len(smf.Tracks)+1):
+			return nil, fmt.Errorf("line %v: event outside of track (%v)", line, text)
+
+		case tick < track.tick:
+			return nil, fmt.Errorf("line %v: time (%v) is before previous event (%v)", line, tick, track.tick)
+
+		default:
+			delta := uint32(tick - track.tick)
+
+			if e, err := a.parseEvent(track, record, tick, delta, args); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			} else {
+				track.mtrk.Events = append(track.mtrk.Events, events.NewEvent(e))
+				track.tick = tick
+			}
+
+			if record == "end_track" {
+				smf.Tracks = append(smf.Tracks, track.mtrk)
+				smf.MThd.Tracks += 1
+				track = nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if smf.MThd == nil {
+		return nil, fmt.Errorf("missing header")
+	} else if track != nil {
+		return nil, fmt.Errorf("missing End_track for track %v", len(smf.Tracks)+1)
+	} else if ntracks != uint64(len(smf.Tracks)) {
+		return nil, fmt.Errorf("header specifies %v tracks but found %v", ntracks, len(smf.Tracks))
+	}
+
+	// ... assemble into MIDI file
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b, midifile.WithRunningStatus(a.RunningStatus))
+
+	if err := e.Encode(smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+func (a CSVAssembler) parseHeader(args []string) (*midi.MThd, uint64, error) {
+	if len(args) != 3 {
+		return nil, 0, fmt.Errorf("invalid header: expected format, tracks and division")
+	}
+
+	format, err := strconv.ParseUint(args[0], 10, 16)
+	if err != nil || format > 2 {
+		return nil, 0, fmt.Errorf("invalid header format (%v): expected 0, 1 or 2", args[0])
+	}
+
+	ntracks, err := strconv.ParseUint(args[1], 10, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid header tracks (%v)", args[1])
+	}
+
+	division, err := strconv.ParseUint(args[2], 10, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid header division (%v)", args[2])
+	}
+
+	if division&0x8000 == 0x8000 {
+		fps := division & 0xff00 >> 8
+		if fps != 0xe8 && fps != 0xe7 && fps != 0xe3 && fps != 0xe2 {
+			return nil, 0, fmt.Errorf("Invalid MThd division SMPTE timecode type (%02X): expected 24, 25, 29 or 30", fps)
+		}
+	}
+
+	mthd := midi.MakeMThd(uint16(format), 0, uint16(division))
+
+	return &mthd, ntracks, nil
+}
+
+func (a CSVAssembler) parseEvent(track *csvtrack, record string, tick uint64, delta uint32, args []string) (any, error) {
+	switch record {
+	case "end_track":
+		return metaevent.MakeEndOfTrack(tick, lib.Delta(delta)), nil
+
+	case "sequence_number":
+		if v, err := integers(args, 0xffff); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeSequenceNumber(tick, lib.Delta(delta), uint16(v[0])), nil
+		}
+
+	case "text_t", "copyright_t", "title_t", "instrument_name_t", "lyric_t", "marker_t", "cue_point_t":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("invalid %v: expected a single string", record)
+		} else {
+			return text(record, tick, delta, args[0])
+		}
+
+	case "unknown_meta_event":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("invalid %v: expected type and length", record)
+		} else if v, err := integers(args[:1], 0x7f); err != nil {
+			return nil, err
+		} else if data, err := payload(args[1:]); err != nil {
+			return nil, err
+		} else {
+			switch v[0] {
+			case 0x01:
+				return text("text_t", tick, delta, string(data))
+			case 0x02:
+				return text("copyright_t", tick, delta, string(data))
+			case 0x03:
+				return text("title_t", tick, delta, string(data))
+			case 0x04:
+				return text("instrument_name_t", tick, delta, string(data))
+			case 0x05:
+				return text("lyric_t", tick, delta, string(data))
+			case 0x06:
+				return text("marker_t", tick, delta, string(data))
+			case 0x07:
+				return text("cue_point_t", tick, delta, string(data))
+			case 0x08:
+				return metaevent.MakeProgramName(tick, lib.Delta(delta), string(data)), nil
+			case 0x09:
+				return metaevent.MakeDeviceName(tick, lib.Delta(delta), string(data)), nil
+			default:
+				return rawmeta{tick: tick, delta: delta, metatype: uint8(v[0]), data: data}, nil
+			}
+		}
+
+	case "channel_prefix":
+		if v, err := integers(args, 15); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeMIDIChannelPrefix(tick, lib.Delta(delta), uint8(v[0])), nil
+		}
+
+	case "midi_port":
+		if v, err := integers(args, 127); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeMIDIPort(tick, lib.Delta(delta), uint8(v[0])), nil
+		}
+
+	case "tempo":
+		if v, err := integers(args, 0xffffff); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeTempo(tick, lib.Delta(delta), uint32(v[0])), nil
+		}
+
+	case "smpte_offset":
+		return smpte(tick, delta, args)
+
+	case "time_signature":
+		if v, err := integers(args, 255, 7, 255, 255); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeTimeSignature(tick, lib.Delta(delta), uint8(v[0]), uint8(1<<v[1]), uint8(v[2]), uint8(v[3])), nil
+		}
+
+	case "key_signature":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("invalid %v: expected key and major/minor", record)
+		} else if key, err := strconv.ParseInt(args[0], 10, 8); err != nil || key < -7 || key > 7 {
+			return nil, fmt.Errorf("invalid key signature accidentals (%v): expected a value in the interval [-7..7]", args[0])
+		} else {
+			switch strings.ToLower(args[1]) {
+			case "major":
+				return metaevent.MakeKeySignature(tick, lib.Delta(delta), int8(key), lib.Major), nil
+			case "minor":
+				return metaevent.MakeKeySignature(tick, lib.Delta(delta), int8(key), lib.Minor), nil
+			default:
+				return nil, fmt.Errorf("invalid key signature type (%v): expected 'major' or 'minor'", args[1])
+			}
+		}
+
+	case "sequencer_specific":
+		if data, err := payload(args); err != nil {
+			return nil, err
+		} else if len(data) == 0 {
+			return nil, fmt.Errorf("invalid %v: missing manufacturer", record)
+		} else {
+			id := data[0:1]
+			d := data[1:]
+
+			if data[0] == 0x00 && len(data) >= 3 {
+				id = data[0:3]
+				d = data[3:]
+			}
+
+			return metaevent.MakeSequencerSpecificEvent(tick, lib.Delta(delta), lib.LookupManufacturer(id), d), nil
+		}
+
+	case "note_off_c":
+		if v, err := integers(args, 15, 127, 127); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeNoteOff(tick, delta, lib.Channel(v[0]), note(v[1]), uint8(v[2])), nil
+		}
+
+	case "note_on_c":
+		if v, err := integers(args, 15, 127, 127); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeNoteOn(tick, delta, lib.Channel(v[0]), note(v[1]), uint8(v[2])), nil
+		}
+
+	case "poly_aftertouch_c":
+		if v, err := integers(args, 15, 127, 127); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakePolyphonicPressure(tick, delta, lib.Channel(v[0]), note(v[1]), uint8(v[2])), nil
+		}
+
+	case "control_c":
+		if v, err := integers(args, 15, 127, 127); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeController(tick, delta, lib.Channel(v[0]), lib.LookupController(uint8(v[1])), uint8(v[2])), nil
+		}
+
+	case "program_c":
+		if v, err := integers(args, 15, 127); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeProgramChange(tick, delta, lib.Channel(v[0]), 0, uint8(v[1])), nil
+		}
+
+	case "channel_aftertouch_c":
+		if v, err := integers(args, 15, 127); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeChannelPressure(tick, delta, lib.Channel(v[0]), uint8(v[1])), nil
+		}
+
+	case "pitch_bend_c":
+		if v, err := integers(args, 15, 0x3fff); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakePitchBend(tick, delta, lib.Channel(v[0]), uint16(v[1])), nil
+		}
+
+	case "system_exclusive":
+		if data, err := payload(args); err != nil {
+			return nil, err
+		} else if len(data) == 0 {
+			return nil, fmt.Errorf("invalid %v: missing manufacturer", record)
+		} else if data[len(data)-1] == 0xf7 && len(data) > 1 {
+			track.pending = false
+			return sysex.MakeSysExSingleMessage(tick, delta, lib.LookupManufacturer(data[0:1]), data[1:len(data)-1]), nil
+		} else {
+			track.pending = true
+			return sysex.MakeSysExMessage(tick, delta, lib.LookupManufacturer(data[0:1]), data[1:]), nil
+		}
+
+	case "system_exclusive_packet":
+		if data, err := payload(args); err != nil {
+			return nil, err
+		} else if !track.pending {
+			return sysex.MakeSysExEscapeMessage(tick, delta, data), nil
+		} else if len(data) > 0 && data[len(data)-1] == 0xf7 {
+			track.pending = false
+			return sysex.MakeSysExContinuationEndMessage(tick, delta, data[:len(data)-1]), nil
+		} else {
+			return sysex.MakeSysExContinuationMessage(tick, delta, data), nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unrecognised record type (%v)", record)
+	}
+}
+
+// split splits a CSV record into fields, unquoting and unescaping string fields.
+func (a CSVAssembler) split(record string) ([]string, error) {
+	fields := []string{}
+	s := []byte(record)
+
+	for i := 0; i <= len(s); {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+
+		if i < len(s) && s[i] == '"' {
+			var field []byte
+			closed := false
+
+			for i++; i < len(s) && !closed; {
+				switch {
+				case s[i] == '"' && i+1 < len(s) && s[i+1] == '"':
+					field = append(field, '"')
+					i += 2
+
+				case s[i] == '"':
+					closed = true
+					i++
+
+				case s[i] == '\\' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '7':
+					v := 0
+					j := i + 1
+					for ; j < len(s) && j < i+4 && s[j] >= '0' && s[j] <= '7'; j++ {
+						v = v*8 + int(s[j]-'0')
+					}
+					field = append(field, byte(v))
+					i = j
+
+				case s[i] == '\\' && i+1 < len(s):
+					field = append(field, s[i+1])
+					i += 2
+
+				default:
+					field = append(field, s[i])
+					i++
+				}
+			}
+
+			if !closed {
+				return nil, fmt.Errorf("unterminated string (%v)", record)
+			}
+
+			for i < len(s) && s[i] != ',' {
+				i++
+			}
+
+			fields = append(fields, string(field))
+		} else {
+			j := i
+			for j < len(s) && s[j] != ',' {
+				j++
+			}
+
+			fields = append(fields, strings.TrimSpace(string(s[i:j])))
+			i = j
+		}
+
+		i++
+	}
+
+	return fields, nil
+}
+
+func text(record string, tick uint64, delta uint32, s string) (any, error) {
+	switch record {
+	case "text_t":
+		return metaevent.MakeText(tick, lib.Delta(delta), s), nil
+	case "copyright_t":
+		return metaevent.MakeCopyright(tick, lib.Delta(delta), s), nil
+	case "title_t":
+		return metaevent.MakeTrackName(tick, lib.Delta(delta), s), nil
+	case "instrument_name_t":
+		return metaevent.MakeInstrumentName(tick, lib.Delta(delta), s), nil
+	case "lyric_t":
+		return metaevent.MakeLyric(tick, lib.Delta(delta), s), nil
+	case "marker_t":
+		return metaevent.MakeMarker(tick, lib.Delta(delta), s), nil
+	case "cue_point_t":
+		return metaevent.MakeCuePoint(tick, lib.Delta(delta), s), nil
+	default:
+		return nil, fmt.Errorf("unrecognised record type (%v)", record)
+	}
+}
+
+func smpte(tick uint64, delta uint32, args []string) (any, error) {
+	v, err := integers(args, 255, 59, 59, 29, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	rates := []uint8{24, 25, 29, 30}
+	rate := rates[(v[0]>>5)&0x03]
+	hour := uint8(v[0] & 0x1f)
+
+	if hour > 24 {
+		return nil, fmt.Errorf("invalid SMPTE offset hour (%d): expected a value in the interval [0..24]", hour)
+	} else if uint8(v[3]) >= rate {
+		return nil, fmt.Errorf("invalid SMPTE offset frames (%d): expected a value in the interval [0..%d]", v[3], rate-1)
+	}
+
+	return metaevent.MakeSMPTEOffset(tick, lib.Delta(delta), hour, uint8(v[1]), uint8(v[2]), rate, uint8(v[3]), uint8(v[4])), nil
+}
+
+func note(v uint64) midievent.Note {
+	return midievent.Note{
+		Value: byte(v),
+		Name:  midievent.FormatNote(nil, byte(v)),
+		Alias: midievent.FormatNote(nil, byte(v)),
+	}
+}
+
+// integers parses the record arguments as unsigned integers, checking each one against the
+// corresponding maximum value.
+func integers(args []string, limits ...uint64) ([]uint64, error) {
+	if len(args) != len(limits) {
+		return nil, fmt.Errorf("invalid number of fields (%v): expected %v", len(args), len(limits))
+	}
+
+	list := make([]uint64, len(args))
+	for i, arg := range args {
+		if v, err := strconv.ParseUint(arg, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid value (%v)", arg)
+		} else if v > limits[i] {
+			return nil, fmt.Errorf("invalid value (%v): expected a value in the interval [0..%v]", v, limits[i])
+		} else {
+			list[i] = v
+		}
+	}
+
+	return list, nil
+}
+
+// payload parses a variable length field i.e. a length followed by that number of bytes.
+// rawmeta is a meta event assembled from an Unknown_meta_event record with a type that has no
+// midiasm event, encoded as is (like csvmidi).
+type rawmeta struct {
+	tick     uint64
+	delta    uint32
+	metatype uint8
+	data     []byte
+}
+
+func (e rawmeta) Tick() uint64 {
+	return e.tick
+}
+
+func (e rawmeta) Delta() uint32 {
+	return e.delta
+}
+
+func (e rawmeta) Tag() string {
+	return fmt.Sprintf("META %02X", e.metatype)
+}
+
+func (e rawmeta) Bytes() []byte {
+	return []byte{}
+}
+
+func (e rawmeta) MarshalBinary() ([]byte, error) {
+	if data, err := lib.VLF(e.data).MarshalBinary(); err != nil {
+		return nil, err
+	} else {
+		return append([]byte{0xff, e.metatype}, data...), nil
+	}
+}
+
+func payload(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("missing length")
+	}
+
+	N, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid length (%v)", args[0])
+	} else if N != uint64(len(args)-1) {
+		return nil, fmt.Errorf("invalid length (%v): record has %v bytes", N, len(args)-1)
+	}
+
+	limits := make([]uint64, N)
+	for i := range limits {
+		limits[i] = 255
+	}
+
+	if v, err := integers(args[1:], limits...); err != nil {
+		return nil, err
+	} else {
+		data := make([]byte, len(v))
+		for i, b := range v {
+			data[i] = byte(b)
+		}
+
+		return data, nil
+	}
+}
@@ -0,0 +1,110 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+//go:embed test-files/reference.csv
+var referenceCSV []byte
+
+//go:embed test-files/csv.mid
+var smfCSV []byte
+
+func TestCSVReference(t *testing.T) {
+	assembler := NewCSVAssembler()
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(referenceCSV))
+	if err != nil {
+		t.Fatalf("error assembling CSV file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smfCSV) {
+		t.Errorf("incorrectly assembled CSV file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smfCSV), hex.Dump(encoded))
+	}
+}
+
+func TestCSVRunningStatus(t *testing.T) {
+	src := `0, 0, Header, 0, 1, 480
+1, 0, Start_track
+1, 0, Control_c, 0, 7, 100
+1, 0, Control_c, 0, 10, 64
+1, 0, Note_on_c, 0, 60, 72
+1, 480, Note_on_c, 0, 60, 0
+1, 480, End_track
+0, 0, End_of_file
+`
+
+	tests := []struct {
+		runningStatus midifile.RunningStatus
+		expected      []byte
+	}{
+		{midifile.RunningStatusNone, []byte{0x00, 0xb0, 0x07, 0x64, 0x00, 0xb0, 0x0a, 0x40, 0x00, 0x90, 0x3c, 0x48, 0x83, 0x60, 0x90, 0x3c, 0x00}},
+		{midifile.RunningStatusNotes, []byte{0x00, 0xb0, 0x07, 0x64, 0x00, 0xb0, 0x0a, 0x40, 0x00, 0x90, 0x3c, 0x48, 0x83, 0x60, 0x3c, 0x00}},
+		{midifile.RunningStatusAll, []byte{0x00, 0xb0, 0x07, 0x64, 0x00, 0x0a, 0x40, 0x00, 0x90, 0x3c, 0x48, 0x83, 0x60, 0x3c, 0x00}},
+	}
+
+	for _, test := range tests {
+		assembler := CSVAssembler{RunningStatus: test.runningStatus}
+
+		encoded, err := assembler.Assemble(bytes.NewBufferString(src))
+		if err != nil {
+			t.Fatalf("error assembling CSV file (%v)", err)
+		}
+
+		if events := encoded[22 : len(encoded)-4]; !reflect.DeepEqual(events, test.expected) {
+			t.Errorf("incorrectly encoded running status %v\nexpected:%X\ngot:     %X", test.runningStatus, test.expected, events)
+		}
+	}
+}
+
+func TestCSVPolyphonicPressure(t *testing.T) {
+	src := `0, 0, Header, 0, 1, 480
+1, 0, Start_track
+1, 0, Poly_aftertouch_c, 3, 48, 100
+1, 0, End_track
+0, 0, End_of_file
+`
+
+	expected := []byte{0x00, 0xa3, 0x30, 0x64}
+
+	encoded, err := NewCSVAssembler().Assemble(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatalf("error assembling CSV file (%v)", err)
+	}
+
+	if events := encoded[22 : len(encoded)-4]; !reflect.DeepEqual(events, expected) {
+		t.Errorf("incorrectly encoded Poly_aftertouch_c\nexpected:%X\ngot:     %X", expected, events)
+	}
+}
+
+func TestCSVUnknownMetaEvent(t *testing.T) {
+	src := `0, 0, Header, 0, 1, 480
+1, 0, Start_track
+1, 0, Unknown_meta_event, 8, 6, 69, 115, 99, 97, 112, 101
+1, 0, Unknown_meta_event, 96, 3, 1, 2, 3
+1, 0, Unknown_meta_event, 81, 3, 7, 161, 32
+1, 0, End_track
+0, 0, End_of_file
+`
+
+	expected := []byte{
+		0x00, 0xff, 0x08, 0x06, 0x45, 0x73, 0x63, 0x61, 0x70, 0x65,
+		0x00, 0xff, 0x60, 0x03, 0x01, 0x02, 0x03,
+		0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20,
+	}
+
+	encoded, err := NewCSVAssembler().Assemble(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatalf("error assembling CSV file (%v)", err)
+	}
+
+	if events := encoded[22 : len(encoded)-4]; !reflect.DeepEqual(events, expected) {
+		t.Errorf("incorrectly encoded Unknown_meta_event\nexpected:%X\ngot:     %X", expected, events)
+	}
+}
@@ -10,38 +10,90 @@ import (
 	"github.com/transcriptaze/midiasm/midi"
 	"github.com/transcriptaze/midiasm/midi/events"
 	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/ops/schema"
 )
 
 type JSONAssembler struct {
 }
 
 type mthd struct {
-	Tag    *string `json:"tag,omitempty"`
-	Format *uint16 `json:"format,omitempty"`
-	PPQN   *uint16 `json:"PPQN,omitempty"`
+	Tag        *string `json:"tag,omitempty"`
+	Format     *uint16 `json:"format,omitempty"`
+	Division   *uint16 `json:"division,omitempty"`
+	PPQN       *uint16 `json:"ppqn,omitempty"`
+	LegacyPPQN *uint16 `json:"PPQN,omitempty"` // unversioned JSON
 }
 
 type mtrk struct {
-	Tag         *string           `json:"tag,omitempty"`
-	TrackNumber *uint16           `json:"tracknumber,omitempty"`
-	Events      []json.RawMessage `json:"events"`
+	Tag               *string           `json:"tag,omitempty"`
+	TrackNumber       *uint16           `json:"track-number,omitempty"`
+	LegacyTrackNumber *uint16           `json:"tracknumber,omitempty"` // unversioned JSON
+	Events            []json.RawMessage `json:"events"`
 }
 
 func NewJSONAssembler() JSONAssembler {
 	return JSONAssembler{}
 }
 
+// Assemble assembles a MIDI file from either a JSON document or an NDJSON stream (as written by
+// 'export --format ndjson').
 func (a JSONAssembler) Assemble(r io.Reader) ([]byte, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var smf *midi.SMF
+
+	if isNDJSON(src) {
+		smf, err = a.parseNDJSON(src)
+	} else {
+		smf, err = a.parseJSON(src)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// ... assemble into MIDI file
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+func (a JSONAssembler) parseJSON(bytes []byte) (*midi.SMF, error) {
 	src := struct {
-		Header mthd   `json:"header"`
-		Tracks []mtrk `json:"tracks"`
+		Version *int   `json:"version"`
+		Header  mthd   `json:"header"`
+		Tracks  []mtrk `json:"tracks"`
 	}{}
 
-	decoder := json.NewDecoder(r)
-	if err := decoder.Decode(&src); err != nil {
+	if err := json.Unmarshal(bytes, &src); err != nil {
 		return nil, err
 	}
 
+	if err := checkVersion(src.Version); err != nil {
+		return nil, err
+	}
+
+	// ... unversioned JSON uses the 'PPQN' and 'tracknumber' keys
+	if src.Version == nil {
+		if src.Header.PPQN == nil {
+			src.Header.PPQN = src.Header.LegacyPPQN
+		}
+
+		for i := range src.Tracks {
+			if src.Tracks[i].TrackNumber == nil {
+				src.Tracks[i].TrackNumber = src.Tracks[i].LegacyTrackNumber
+			}
+		}
+	}
+
 	smf := midi.SMF{}
 
 	// ... header
@@ -63,15 +115,7 @@ func (a JSONAssembler) Assemble(r io.Reader) ([]byte, error) {
 		}
 	}
 
-	// ... assemble into MIDI file
-	var b bytes.Buffer
-	var e = midifile.NewEncoder(&b)
-
-	if err := e.Encode(smf); err != nil {
-		return nil, err
-	} else {
-		return b.Bytes(), nil
-	}
+	return &smf, nil
 }
 
 func (a JSONAssembler) parseMThd(h mthd) (*midi.MThd, error) {
@@ -88,16 +132,18 @@ func (a JSONAssembler) parseMThd(h mthd) (*midi.MThd, error) {
 		format = *h.Format
 	}
 
-	if h.PPQN == nil {
-		return nil, fmt.Errorf("missing 'metrical-time' field in header")
+	if h.Division == nil && h.PPQN == nil {
+		return nil, fmt.Errorf("missing 'division' field in header")
+	} else if h.Division != nil {
+		division = *h.Division
 	} else {
 		division = *h.PPQN
+	}
 
-		if division&0x8000 == 0x8000 {
-			fps := division & 0xff00 >> 8
-			if fps != 0xe8 && fps != 0xe7 && fps != 0xe3 && fps != 0xe2 {
-				return nil, fmt.Errorf("Invalid MThd division SMPTE timecode type (%v): expected 24, 25, 29 or 30", fps)
-			}
+	if division&0x8000 == 0x8000 {
+		fps := division & 0xff00 >> 8
+		if fps != 0xe8 && fps != 0xe7 && fps != 0xe3 && fps != 0xe2 {
+			return nil, fmt.Errorf("Invalid MThd division SMPTE timecode type (%v): expected 24, 25, 29 or 30", fps)
 		}
 	}
 
@@ -136,6 +182,16 @@ func (a JSONAssembler) parseMTrk(track mtrk) (*midi.MTrk, error) {
 
 }
 
+// checkVersion rejects unsupported format versions. Unversioned JSON predates the schema and
+// is assembled as is.
+func checkVersion(version *int) error {
+	if version != nil && *version != schema.Version {
+		return fmt.Errorf("unsupported JSON format version (%v): expected %v", *version, schema.Version)
+	}
+
+	return nil
+}
+
 func unmarshal[
 	E events.TEvent,
 	P interface {
@@ -26,3 +26,70 @@ func TestJSONReference(t *testing.T) {
 		t.Errorf("incorrectly assembled JSON file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smfJ), hex.Dump(encoded))
 	}
 }
+
+//go:embed test-files/reference-v1.json
+var referenceJv1 []byte
+
+func TestJSONVersion1(t *testing.T) {
+	assembler := JSONAssembler{}
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(referenceJv1))
+	if err != nil {
+		t.Fatalf("error assembling JSON file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smfJ) {
+		t.Errorf("incorrectly assembled JSON file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smfJ), hex.Dump(encoded))
+	}
+}
+
+func TestJSONUnsupportedVersion(t *testing.T) {
+	assembler := JSONAssembler{}
+	src := `{"version":2, "header":{"tag":"MThd","format":1,"division":480}, "tracks":[]}`
+
+	if _, err := assembler.Assemble(bytes.NewBufferString(src)); err == nil {
+		t.Errorf("expected error assembling unsupported JSON format version")
+	}
+}
+
+//go:embed test-files/baseline.json
+var baselineJ []byte
+
+//go:embed test-files/baseline.mid
+var baselineMid []byte
+
+func TestJSONUnversioned(t *testing.T) {
+	assembler := JSONAssembler{}
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(baselineJ))
+	if err != nil {
+		t.Fatalf("error assembling JSON file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, baselineMid) {
+		t.Errorf("incorrectly assembled JSON file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(baselineMid), hex.Dump(encoded))
+	}
+}
+
+func TestJSONLegacyKeys(t *testing.T) {
+	assembler := JSONAssembler{}
+	src := `{"header":{"tag":"MThd","format":1,"PPQN":480}, "tracks":[{"tag":"MTrk","tracknumber":0,"events":[{"event":{"tag":"EndOfTrack","delta":0,"status":255,"type":47}}]}]}`
+	expected := []byte{
+		0x4d, 0x54, 0x68, 0x64, 0x00, 0x00, 0x00, 0x06, 0x00, 0x01, 0x00, 0x01, 0x01, 0xe0,
+		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0x04, 0x00, 0xff, 0x2f, 0x00,
+	}
+
+	encoded, err := assembler.Assemble(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatalf("error assembling JSON file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("incorrectly assembled JSON file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(expected), hex.Dump(encoded))
+	}
+
+	versioned := `{"version":1, "header":{"tag":"MThd","format":1,"PPQN":480}, "tracks":[]}`
+	if _, err := assembler.Assemble(bytes.NewBufferString(versioned)); err == nil {
+		t.Errorf("expected error assembling versioned JSON with the unversioned 'PPQN' key")
+	}
+}
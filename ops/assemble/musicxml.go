@@ -0,0 +1,650 @@
+package assemble
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// MusicXMLAssembler assembles a Format 1 MIDI file from a partwise MusicXML score. Track 0
+// holds the title, tempo, time signature and key signature events and each part is assembled
+// into its own track, with the program change from the part's <midi-instrument>, notes
+// (with tied notes merged and grace notes played just before the principal note), velocities
+// from the dynamics and lyrics as Lyric events. Repeats are not expanded.
+type MusicXMLAssembler struct {
+	PPQN     uint16
+	Velocity byte
+}
+
+type mxlScore struct {
+	XMLName  xml.Name       `xml:"score-partwise"`
+	Title    string         `xml:"work>work-title"`
+	Movement string         `xml:"movement-title"`
+	Parts    []mxlScorePart `xml:"part-list>score-part"`
+	Music    []mxlPart      `xml:"part"`
+}
+
+type mxlScorePart struct {
+	ID             string `xml:"id,attr"`
+	Name           string `xml:"part-name"`
+	MIDIInstrument *struct {
+		Channel int `xml:"midi-channel"`
+		Program int `xml:"midi-program"`
+	} `xml:"midi-instrument"`
+}
+
+type mxlPart struct {
+	ID       string       `xml:"id,attr"`
+	Measures []mxlMeasure `xml:"measure"`
+}
+
+type mxlMeasure struct {
+	Number   string       `xml:"number,attr"`
+	Elements []mxlElement `xml:",any"`
+}
+
+// mxlElement is one of the (ordered) measure elements.
+type mxlElement struct {
+	Attributes *mxlAttributes
+	Note       *mxlNote
+	Direction  *mxlDirection
+	Sound      *mxlSound
+	Backup     *int
+	Forward    *int
+}
+
+type mxlAttributes struct {
+	Divisions int `xml:"divisions"`
+	Key       *struct {
+		Fifths int    `xml:"fifths"`
+		Mode   string `xml:"mode"`
+	} `xml:"key"`
+	Time *struct {
+		Beats    string `xml:"beats"`
+		BeatType int    `xml:"beat-type"`
+	} `xml:"time"`
+	Clefs []struct {
+		Number string `xml:"number,attr"`
+		Sign   string `xml:"sign"`
+	} `xml:"clef"`
+	Transpose []struct {
+		Number       string `xml:"number,attr"`
+		Chromatic    int    `xml:"chromatic"`
+		OctaveChange int    `xml:"octave-change"`
+	} `xml:"transpose"`
+}
+
+type mxlNote struct {
+	Dynamics string `xml:"dynamics,attr"`
+	Grace    *struct {
+		Slash string `xml:"slash,attr"`
+	} `xml:"grace"`
+	Chord *struct{} `xml:"chord"`
+	Pitch *struct {
+		Step   string  `xml:"step"`
+		Alter  float64 `xml:"alter"`
+		Octave int     `xml:"octave"`
+	} `xml:"pitch"`
+	Unpitched *struct {
+		Step   string `xml:"display-step"`
+		Octave int    `xml:"display-octave"`
+	} `xml:"unpitched"`
+	Rest     *struct{} `xml:"rest"`
+	Duration int       `xml:"duration"`
+	Ties     []struct {
+		Type string `xml:"type,attr"`
+	} `xml:"tie"`
+	Voice string `xml:"voice"`
+	Staff string `xml:"staff"`
+	Tied  []struct {
+		Type string `xml:"type,attr"`
+	} `xml:"notations>tied"`
+	Marks  mxlDynamics `xml:"notations>dynamics"`
+	Lyrics []struct {
+		Number   string `xml:"number,attr"`
+		Syllabic string `xml:"syllabic"`
+		Text     string `xml:"text"`
+	} `xml:"lyric"`
+}
+
+type mxlDirection struct {
+	Dynamics  []mxlDynamics `xml:"direction-type>dynamics"`
+	Metronome *struct {
+		BeatUnit  string    `xml:"beat-unit"`
+		Dot       *struct{} `xml:"beat-unit-dot"`
+		PerMinute string    `xml:"per-minute"`
+	} `xml:"direction-type>metronome"`
+	Offset int       `xml:"offset"`
+	Sound  *mxlSound `xml:"sound"`
+}
+
+type mxlSound struct {
+	Tempo    string `xml:"tempo,attr"`
+	Dynamics string `xml:"dynamics,attr"`
+}
+
+// mxlDynamics holds the names of the dynamics marks e.g. <p/>, <mf/>.
+type mxlDynamics struct {
+	Marks []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+// Velocities for the MusicXML dynamics marks.
+var dynamics = map[string]byte{
+	"pppppp": 4,
+	"ppppp":  8,
+	"pppp":   12,
+	"ppp":    16,
+	"pp":     33,
+	"p":      49,
+	"mp":     64,
+	"mf":     80,
+	"f":      96,
+	"ff":     112,
+	"fff":    120,
+	"ffff":   124,
+	"fffff":  126,
+	"ffffff": 127,
+}
+
+var steps = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+
+type mxlTrack struct {
+	name    string
+	channel lib.Channel
+	program int
+	notes   []mxlNoteEvent
+	lyrics  []metaevent.Lyric
+	levels  []mxlLevel
+	end     uint64
+}
+
+type mxlNoteEvent struct {
+	note     byte
+	start    uint64
+	end      uint64
+	velocity int
+}
+
+type mxlLevel struct {
+	tick     uint64
+	velocity byte
+}
+
+func NewMusicXMLAssembler() MusicXMLAssembler {
+	return MusicXMLAssembler{
+		PPQN:     480,
+		Velocity: 80,
+	}
+}
+
+func (e *mxlElement) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	switch start.Name.Local {
+	case "attributes":
+		e.Attributes = &mxlAttributes{}
+		return d.DecodeElement(e.Attributes, &start)
+
+	case "note":
+		e.Note = &mxlNote{}
+		return d.DecodeElement(e.Note, &start)
+
+	case "direction":
+		e.Direction = &mxlDirection{}
+		return d.DecodeElement(e.Direction, &start)
+
+	case "sound":
+		e.Sound = &mxlSound{}
+		return d.DecodeElement(e.Sound, &start)
+
+	case "backup", "forward":
+		v := struct {
+			Duration int `xml:"duration"`
+		}{}
+
+		if err := d.DecodeElement(&v, &start); err != nil {
+			return err
+		} else if start.Name.Local == "backup" {
+			e.Backup = &v.Duration
+		} else {
+			e.Forward = &v.Duration
+		}
+
+		return nil
+
+	default:
+		return d.Skip()
+	}
+}
+
+func (a MusicXMLAssembler) Assemble(r io.Reader) ([]byte, error) {
+	smf, err := a.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+// Parse reads a partwise MusicXML score and returns the equivalent Format 1 MIDI file.
+func (a MusicXMLAssembler) Parse(r io.Reader) (*midi.SMF, error) {
+	var score mxlScore
+
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+
+	if err := decoder.Decode(&score); err != nil {
+		return nil, fmt.Errorf("invalid MusicXML score (%v)", err)
+	} else if score.XMLName.Local != "score-partwise" {
+		return nil, fmt.Errorf("unsupported MusicXML score (%v): expected score-partwise", score.XMLName.Local)
+	}
+
+	ppqn := a.PPQN
+	if ppqn == 0 {
+		ppqn = 480
+	}
+
+	conductor := []events.IEvent{}
+	end := uint64(0)
+	tracks := []*mxlTrack{}
+
+	for i, part := range score.Music {
+		track := mxlTrack{
+			name:    part.ID,
+			channel: lib.Channel(i % 16),
+			program: -1,
+		}
+
+		if i >= 9 {
+			track.channel = lib.Channel((i + 1) % 16)
+		}
+
+		if ix := slices.IndexFunc(score.Parts, func(p mxlScorePart) bool { return p.ID == part.ID }); ix >= 0 {
+			p := score.Parts[ix]
+			if p.Name != "" {
+				track.name = p.Name
+			}
+
+			if p.MIDIInstrument != nil {
+				if p.MIDIInstrument.Channel >= 1 && p.MIDIInstrument.Channel <= 16 {
+					track.channel = lib.Channel(p.MIDIInstrument.Channel - 1)
+				}
+
+				if p.MIDIInstrument.Program >= 1 && p.MIDIInstrument.Program <= 128 {
+					track.program = p.MIDIInstrument.Program - 1
+				}
+			}
+		}
+
+		if list, err := a.part(part, &track, uint64(ppqn), i == 0); err != nil {
+			return nil, fmt.Errorf("part %v: %v", part.ID, err)
+		} else {
+			conductor = append(conductor, list...)
+		}
+
+		end = max(end, track.end)
+		tracks = append(tracks, &track)
+	}
+
+	// ... track 0
+	mthd := midi.MakeMThd(1, uint16(len(tracks)+1), ppqn&0x7fff)
+	smf := midi.SMF{
+		MThd: &mthd,
+	}
+
+	title := score.Title
+	if title == "" {
+		title = score.Movement
+	}
+
+	if title != "" {
+		conductor = append([]events.IEvent{metaevent.MakeTrackName(0, 0, title)}, conductor...)
+	}
+
+	if mtrk, err := mktrack(0, conductor, end); err != nil {
+		return nil, err
+	} else {
+		smf.Tracks = append(smf.Tracks, mtrk)
+	}
+
+	// ... part tracks
+	for i, t := range tracks {
+		list := []events.IEvent{metaevent.MakeTrackName(0, 0, t.name)}
+
+		if t.program >= 0 {
+			list = append(list, midievent.MakeProgramChange(0, 0, t.channel, 0, uint8(t.program)))
+		}
+
+		for _, e := range t.lyrics {
+			list = append(list, e)
+		}
+
+		sort.SliceStable(t.levels, func(i, j int) bool { return t.levels[i].tick < t.levels[j].tick })
+
+		for _, n := range t.notes {
+			velocity := a.Velocity
+			for _, l := range t.levels {
+				if l.tick <= n.start {
+					velocity = l.velocity
+				}
+			}
+
+			if n.velocity >= 0 {
+				velocity = byte(n.velocity)
+			}
+
+			list = append(list, midievent.MakeNoteOn(n.start, 0, t.channel, note(uint64(n.note)), max(1, velocity)))
+			list = append(list, midievent.MakeNoteOff(n.end, 0, t.channel, note(uint64(n.note)), 64))
+		}
+
+		if mtrk, err := mktrack(i+1, list, end); err != nil {
+			return nil, err
+		} else {
+			smf.Tracks = append(smf.Tracks, mtrk)
+		}
+	}
+
+	return &smf, nil
+}
+
+// part converts the measures of a part to notes, lyrics and dynamics, returning the tempo,
+// time signature and key signature events if the part is the first part.
+func (a MusicXMLAssembler) part(part mxlPart, track *mxlTrack, ppqn uint64, first bool) ([]events.IEvent, error) {
+	conductor := []events.IEvent{}
+	divisions := uint64(1)
+	transpose := map[string]int{}
+	tab := map[string]bool{}
+	tick := uint64(0)
+	ties := map[string]int{}
+	graces := map[string][]byte{}
+	tempo := ""
+
+	duration := func(d int) uint64 {
+		return uint64(max(0, d)) * ppqn / divisions
+	}
+
+	for _, measure := range part.Measures {
+		start := tick
+		end := tick
+		chord := struct{ tick, from uint64 }{}
+
+		for _, e := range measure.Elements {
+			switch {
+			case e.Attributes != nil:
+				v := e.Attributes
+				if v.Divisions > 0 {
+					divisions = uint64(v.Divisions)
+				}
+
+				for _, t := range v.Transpose {
+					transpose[t.Number] = t.Chromatic + 12*t.OctaveChange
+				}
+
+				for _, c := range v.Clefs {
+					tab[c.Number] = c.Sign == "TAB"
+				}
+
+				if first && v.Time != nil {
+					numerator := 0
+					for _, b := range strings.Split(v.Time.Beats, "+") {
+						if n, err := strconv.Atoi(strings.TrimSpace(b)); err != nil {
+							return nil, fmt.Errorf("measure %v: invalid time signature (%v)", measure.Number, v.Time.Beats)
+						} else {
+							numerator += n
+						}
+					}
+
+					if numerator < 1 || numerator > 255 || v.Time.BeatType < 1 || v.Time.BeatType > 128 {
+						return nil, fmt.Errorf("measure %v: invalid time signature (%v/%v)", measure.Number, v.Time.Beats, v.Time.BeatType)
+					}
+
+					clocks := uint8(max(1, 96/v.Time.BeatType))
+					conductor = append(conductor, metaevent.MakeTimeSignature(tick, 0, uint8(numerator), uint8(v.Time.BeatType), clocks, 8))
+				}
+
+				if first && v.Key != nil {
+					if v.Key.Fifths < -7 || v.Key.Fifths > 7 {
+						return nil, fmt.Errorf("measure %v: invalid key signature (%v)", measure.Number, v.Key.Fifths)
+					}
+
+					keytype := lib.Major
+					if v.Key.Mode == "minor" {
+						keytype = lib.Minor
+					}
+
+					conductor = append(conductor, metaevent.MakeKeySignature(tick, 0, int8(v.Key.Fifths), keytype))
+				}
+
+			case e.Backup != nil:
+				tick -= min(tick-start, duration(*e.Backup))
+
+			case e.Forward != nil:
+				tick += duration(*e.Forward)
+				end = max(end, tick)
+
+			case e.Sound != nil:
+				if first && e.Sound.Tempo != "" && e.Sound.Tempo != tempo {
+					if t, err := a.tempo(tick, e.Sound.Tempo); err != nil {
+						return nil, fmt.Errorf("measure %v: %v", measure.Number, err)
+					} else {
+						conductor = append(conductor, t)
+						tempo = e.Sound.Tempo
+					}
+				}
+
+				if e.Sound.Dynamics != "" {
+					if v, err := percent(e.Sound.Dynamics); err == nil {
+						track.levels = append(track.levels, mxlLevel{tick, v})
+					}
+				}
+
+			case e.Direction != nil:
+				v := e.Direction
+				at := uint64(max(0, int64(tick)+int64(v.Offset)*int64(ppqn)/int64(divisions)))
+
+				for _, d := range v.Dynamics {
+					for _, m := range d.Marks {
+						if velocity, ok := dynamics[m.XMLName.Local]; ok {
+							track.levels = append(track.levels, mxlLevel{at, velocity})
+						}
+					}
+				}
+
+				bpm := ""
+				if v.Sound != nil && v.Sound.Tempo != "" {
+					bpm = v.Sound.Tempo
+				} else if m := v.Metronome; m != nil {
+					if b, err := strconv.ParseFloat(m.PerMinute, 64); err == nil {
+						unit := map[string]float64{"whole": 4, "half": 2, "quarter": 1, "eighth": 0.5, "16th": 0.25}[m.BeatUnit]
+						if m.Dot != nil {
+							unit *= 1.5
+						}
+
+						if unit > 0 {
+							bpm = strconv.FormatFloat(b*unit, 'f', -1, 64)
+						}
+					}
+				}
+
+				if first && bpm != "" && bpm != tempo {
+					if t, err := a.tempo(at, bpm); err != nil {
+						return nil, fmt.Errorf("measure %v: %v", measure.Number, err)
+					} else {
+						conductor = append(conductor, t)
+						tempo = bpm
+					}
+				}
+
+				if v.Sound != nil && v.Sound.Dynamics != "" {
+					if velocity, err := percent(v.Sound.Dynamics); err == nil {
+						track.levels = append(track.levels, mxlLevel{at, velocity})
+					}
+				}
+
+			case e.Note != nil:
+				n := e.Note
+				d := duration(n.Duration)
+
+				// ... chord notes start with the previous note
+				if n.Chord != nil && n.Grace == nil {
+					tick = chord.tick
+				}
+
+				// ... tablature staves duplicate the notes on the standard notation staff
+				if tab[n.Staff] || (n.Staff == "" && tab["1"]) {
+					continue
+				}
+
+				shift, ok := transpose[n.Staff]
+				if !ok {
+					shift = transpose[""]
+				}
+
+				value := -1
+				switch {
+				case n.Pitch != nil:
+					value = 12*(n.Pitch.Octave+1) + steps[strings.ToUpper(n.Pitch.Step)] + int(math.Round(n.Pitch.Alter)) + shift
+
+				case n.Unpitched != nil:
+					value = 12*(n.Unpitched.Octave+1) + steps[strings.ToUpper(n.Unpitched.Step)] + shift
+				}
+
+				if value > 127 {
+					return nil, fmt.Errorf("measure %v: invalid note (%v)", measure.Number, value)
+				}
+
+				// ... grace notes are played just before the principal note
+				if n.Grace != nil {
+					if value >= 0 && n.Chord == nil {
+						graces[n.Voice] = append(graces[n.Voice], byte(value))
+					}
+					continue
+				}
+
+				if n.Rest != nil || value < 0 {
+					tick += d
+					end = max(end, tick)
+					continue
+				}
+
+				from := tick
+				if g := graces[n.Voice]; len(g) > 0 && n.Chord == nil {
+					length := min(ppqn/8, d/uint64(2*len(g)))
+					for _, v := range g {
+						track.notes = append(track.notes, mxlNoteEvent{note: v, start: from, end: from + length, velocity: -1})
+						from += length
+					}
+
+					delete(graces, n.Voice)
+				} else if n.Chord != nil {
+					from = chord.from
+				}
+
+				level := -1
+				if n.Dynamics != "" {
+					if v, err := percent(n.Dynamics); err != nil {
+						return nil, fmt.Errorf("measure %v: %v", measure.Number, err)
+					} else {
+						level = int(v)
+					}
+				}
+
+				for _, m := range n.Marks.Marks {
+					if v, ok := dynamics[m.XMLName.Local]; ok {
+						track.levels = append(track.levels, mxlLevel{tick, v})
+					}
+				}
+
+				// ... ties
+				stop := false
+				start := false
+				for _, t := range append(n.Ties, n.Tied...) {
+					stop = stop || t.Type == "stop"
+					start = start || t.Type == "start"
+				}
+
+				key := fmt.Sprintf("%v:%v", n.Voice, value)
+				if ix, ok := ties[key]; ok && stop {
+					track.notes[ix].end = tick + d
+					if !start {
+						delete(ties, key)
+					}
+				} else {
+					track.notes = append(track.notes, mxlNoteEvent{note: byte(value), start: from, end: tick + d, velocity: level})
+					if start {
+						ties[key] = len(track.notes) - 1
+					} else {
+						delete(ties, key)
+					}
+				}
+
+				// ... lyrics (first verse only)
+				for _, l := range n.Lyrics {
+					if l.Number == "" || l.Number == "1" {
+						text := l.Text
+						if l.Syllabic == "begin" || l.Syllabic == "middle" {
+							text += "-"
+						}
+
+						track.lyrics = append(track.lyrics, metaevent.MakeLyric(from, 0, text))
+						break
+					}
+				}
+
+				chord.tick = tick
+				chord.from = from
+				tick += d
+				end = max(end, tick)
+			}
+		}
+
+		tick = end
+	}
+
+	track.end = tick
+	for _, n := range track.notes {
+		track.end = max(track.end, n.end)
+	}
+
+	return conductor, nil
+}
+
+func (a MusicXMLAssembler) tempo(tick uint64, bpm string) (events.IEvent, error) {
+	if v, err := strconv.ParseFloat(bpm, 64); err != nil || v <= 0 {
+		return nil, fmt.Errorf("invalid tempo (%v)", bpm)
+	} else if tempo := math.Round(60000000 / v); tempo > 0xffffff {
+		return nil, fmt.Errorf("invalid tempo (%v)", bpm)
+	} else {
+		return metaevent.MakeTempo(tick, 0, uint32(tempo)), nil
+	}
+}
+
+// percent converts a MusicXML dynamics attribute (a percentage of forte, which is velocity 90)
+// to a MIDI velocity.
+func percent(s string) (byte, error) {
+	if v, err := strconv.ParseFloat(s, 64); err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid dynamics (%v)", s)
+	} else {
+		return byte(min(127, math.Round(v*90/100))), nil
+	}
+}
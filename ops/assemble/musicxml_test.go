@@ -0,0 +1,96 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+)
+
+//go:embed test-files/reference.musicxml
+var referenceMusicXML []byte
+
+func TestMusicXMLReference(t *testing.T) {
+	expected := [][]string{
+		{
+			"0 TrackName Reference",
+			"0 TimeSignature 3/4",
+			"0 KeySignature -1 major",
+			"0 Tempo 666667",
+			"2880 EndOfTrack",
+		},
+		{
+			"0 TrackName Voice",
+			"0 ProgramChange 0 52",
+			"0 Lyric Hel-",
+			"0 NoteOn 0 65 49",
+			"480 NoteOff 0 65",
+			"480 NoteOn 0 69 49",
+			"540 Lyric lo",
+			"540 NoteOff 0 69",
+			"540 NoteOn 0 67 49",
+			"960 NoteOff 0 67",
+			"960 NoteOn 0 70 90",
+			"1920 NoteOff 0 70",
+			"1920 NoteOn 0 69 96",
+			"2880 NoteOff 0 69",
+			"2880 EndOfTrack",
+		},
+		{
+			"0 TrackName Guitar",
+			"0 ProgramChange 1 25",
+			"0 NoteOn 1 53 80",
+			"0 NoteOn 1 57 80",
+			"960 NoteOn 1 48 80",
+			"1440 NoteOff 1 53",
+			"1440 NoteOff 1 57",
+			"1440 NoteOff 1 48",
+			"2880 EndOfTrack",
+		},
+	}
+
+	smf, err := NewMusicXMLAssembler().Parse(bytes.NewReader(referenceMusicXML))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if smf.MThd.Format != 1 || smf.MThd.PPQN != 480 || len(smf.Tracks) != 3 {
+		t.Fatalf("Incorrect MThd - expected format:1, PPQN:480, tracks:3 got:%v", smf.MThd)
+	}
+
+	for i, track := range smf.Tracks {
+		list := []string{}
+		for _, e := range track.Events {
+			s := fmt.Sprintf("%v %v", e.Tick(), e.Event.Tag())
+
+			switch v := e.Event.(type) {
+			case metaevent.TrackName:
+				s += " " + v.Name
+			case metaevent.Lyric:
+				s += " " + v.Lyric
+			case metaevent.Tempo:
+				s += fmt.Sprintf(" %v", v.Tempo)
+			case metaevent.TimeSignature:
+				s += fmt.Sprintf(" %v/%v", v.Numerator, v.Denominator)
+			case metaevent.KeySignature:
+				s += fmt.Sprintf(" %v %v", v.Accidentals, v.KeyType)
+			case midievent.ProgramChange:
+				s += fmt.Sprintf(" %v %v", v.Channel, v.Program)
+			case midievent.NoteOn:
+				s += fmt.Sprintf(" %v %v %v", v.Channel, v.Note.Value, v.Velocity)
+			case midievent.NoteOff:
+				s += fmt.Sprintf(" %v %v", v.Channel, v.Note.Value)
+			}
+
+			list = append(list, s)
+		}
+
+		if !reflect.DeepEqual(list, expected[i]) {
+			t.Errorf("Incorrectly assembled track %v\n   expected:%q\n   got:     %q", i, expected[i], list)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package assemble
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+type record struct {
+	Record  string  `json:"record"`
+	Version *int    `json:"version"`
+	Header  *mthd   `json:"header"`
+	Tag     *string `json:"tag"`
+	Track   *int    `json:"track"`
+	Tick    *uint64 `json:"tick"`
+	Delta   *uint32 `json:"delta"`
+}
+
+// isNDJSON returns true if the first JSON object in the source is an NDJSON 'record'.
+func isNDJSON(src []byte) bool {
+	r := struct {
+		Record *string `json:"record"`
+	}{}
+
+	decoder := json.NewDecoder(bytes.NewReader(src))
+	if err := decoder.Decode(&r); err != nil {
+		return false
+	}
+
+	return r.Record != nil
+}
+
+// parseNDJSON assembles an NDJSON stream i.e. a 'header' record followed by 'track' and 'event'
+// records. The events in each track are ordered by tick (with the EndOfTrack last) and the
+// deltas are recalculated, so that lines can be added, removed or reordered with line oriented
+// tools. Events without a tick are positioned using the delta from the previous event.
+func (a JSONAssembler) parseNDJSON(src []byte) (*midi.SMF, error) {
+	type timed struct {
+		tick  uint64
+		event events.IEvent
+	}
+
+	var smf *midi.SMF
+	var tracks [][]timed
+
+	for ix, line := range bytes.Split(src, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		r := record{}
+		if err := json.Unmarshal(line, &r); err != nil {
+			return nil, fmt.Errorf("invalid NDJSON record (line %v): %v", ix+1, err)
+		}
+
+		if smf == nil && r.Record != "header" {
+			return nil, fmt.Errorf("invalid NDJSON record (line %v): expected 'header' record", ix+1)
+		}
+
+		switch r.Record {
+		case "header":
+			if smf != nil {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): duplicate 'header' record", ix+1)
+			} else if err := checkVersion(r.Version); err != nil {
+				return nil, err
+			} else if r.Header == nil {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): missing 'header'", ix+1)
+			} else if mthd, err := a.parseMThd(*r.Header); err != nil {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): %v", ix+1, err)
+			} else {
+				smf = &midi.SMF{MThd: mthd}
+			}
+
+		case "track":
+			if r.Tag != nil && *r.Tag != "MTrk" {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): invalid 'MTrk' tag in track", ix+1)
+			} else if r.Track == nil || *r.Track != len(tracks) {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): expected track %v", ix+1, len(tracks))
+			} else {
+				tracks = append(tracks, []timed{})
+			}
+
+		case "event":
+			if r.Track == nil || *r.Track < 0 || *r.Track >= len(tracks) {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): missing or invalid 'track'", ix+1)
+			}
+
+			e := events.Event{}
+			if err := e.UnmarshalJSON(line); err != nil {
+				return nil, fmt.Errorf("invalid NDJSON record (line %v): %v", ix+1, err)
+			}
+
+			t := *r.Track
+			tick := uint64(0)
+
+			switch {
+			case r.Tick != nil:
+				tick = *r.Tick
+
+			default:
+				if n := len(tracks[t]); n > 0 {
+					tick = tracks[t][n-1].tick
+				}
+
+				if r.Delta != nil {
+					tick += uint64(*r.Delta)
+				} else {
+					tick += uint64(e.Delta())
+				}
+			}
+
+			tracks[t] = append(tracks[t], timed{tick, e.Event})
+
+		default:
+			return nil, fmt.Errorf("invalid NDJSON record (line %v): unknown record type '%v'", ix+1, r.Record)
+		}
+	}
+
+	if smf == nil {
+		return nil, fmt.Errorf("invalid NDJSON: missing 'header' record")
+	}
+
+	for i, list := range tracks {
+		var eot *timed
+
+		eventlist := []timed{}
+		for _, e := range list {
+			if _, ok := e.event.(metaevent.EndOfTrack); ok {
+				eot = &timed{e.tick, e.event}
+			} else {
+				eventlist = append(eventlist, e)
+			}
+		}
+
+		sort.SliceStable(eventlist, func(i, j int) bool {
+			return eventlist[i].tick < eventlist[j].tick
+		})
+
+		if eot != nil {
+			if n := len(eventlist); n > 0 {
+				eot.tick = max(eot.tick, eventlist[n-1].tick)
+			}
+
+			eventlist = append(eventlist, *eot)
+		}
+
+		mtrk, err := midi.NewMTrk()
+		if err != nil {
+			return nil, err
+		}
+
+		mtrk.TrackNumber = lib.TrackNumber(i)
+
+		tick := uint64(0)
+		for _, e := range eventlist {
+			if v, err := events.Retime(e.event, e.tick, uint32(e.tick-tick)); err != nil {
+				return nil, err
+			} else {
+				mtrk.Events = append(mtrk.Events, events.NewEvent(v))
+			}
+
+			tick = e.tick
+		}
+
+		if len(mtrk.Events) > 0 {
+			if _, err := fixups(mtrk); err != nil {
+				return nil, err
+			}
+		}
+
+		smf.Tracks = append(smf.Tracks, mtrk)
+		smf.MThd.Tracks += 1
+	}
+
+	return smf, nil
+}
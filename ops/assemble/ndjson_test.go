@@ -0,0 +1,102 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+)
+
+//go:embed test-files/reference.ndjson
+var referenceNDJSON []byte
+
+func TestNDJSONReference(t *testing.T) {
+	assembler := JSONAssembler{}
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(referenceNDJSON))
+	if err != nil {
+		t.Fatalf("error assembling NDJSON file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smfJ) {
+		t.Errorf("incorrectly assembled NDJSON file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smfJ), hex.Dump(encoded))
+	}
+}
+
+func TestNDJSONEdited(t *testing.T) {
+	src := `{"record":"header","version":1,"header":{"tag":"MThd","format":0,"division":480}}
+{"record":"track","track":0,"tag":"MTrk"}
+{"record":"event","track":0,"tick":480,"event":{"tag":"NoteOff","delta":0,"channel":0,"note":{"value":60},"velocity":64}}
+{"record":"event","track":0,"tick":0,"event":{"tag":"NoteOn","delta":0,"channel":0,"note":{"value":60},"velocity":100}}
+
+{"record":"event","track":0,"tick":0,"event":{"tag":"EndOfTrack","delta":0}}
+{"record":"event","track":0,"delta":240,"event":{"tag":"NoteOn","delta":0,"channel":0,"note":{"value":64},"velocity":100}}
+`
+
+	assembler := JSONAssembler{}
+
+	encoded, err := assembler.Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("error assembling NDJSON (%v)", err)
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("error decoding assembled MIDI file (%v)", err)
+	}
+
+	expected := []struct {
+		tag   string
+		tick  uint64
+		delta uint32
+	}{
+		{"NoteOn", 0, 0},
+		{"NoteOn", 240, 240},
+		{"NoteOff", 480, 240},
+		{"EndOfTrack", 480, 0},
+	}
+
+	if len(smf.Tracks) != 1 {
+		t.Fatalf("incorrect number of tracks - expected:%v, got:%v", 1, len(smf.Tracks))
+	}
+
+	list := smf.Tracks[0].Events
+	if len(list) != len(expected) {
+		t.Fatalf("incorrect number of events - expected:%v, got:%v", len(expected), len(list))
+	}
+
+	for i, e := range expected {
+		if list[i].Event.Tag() != e.tag || list[i].Tick() != e.tick || list[i].Delta() != e.delta {
+			t.Errorf("incorrect event %v - expected:%v@%v (delta %v), got:%v@%v (delta %v)",
+				i, e.tag, e.tick, e.delta, list[i].Event.Tag(), list[i].Tick(), list[i].Delta())
+		}
+	}
+
+	if !events.Is[midievent.NoteOn](*list[1]) {
+		t.Errorf("incorrect event 1 - expected NoteOn, got %v", list[1].Event)
+	}
+}
+
+func TestNDJSONInvalid(t *testing.T) {
+	tests := []string{
+		`{"record":"track","track":0,"tag":"MTrk"}`,
+		`{"record":"header","version":2,"header":{"tag":"MThd","format":0,"division":480}}`,
+		"{\"record\":\"header\",\"version\":1,\"header\":{\"tag\":\"MThd\",\"format\":0,\"division\":480}}\n{\"record\":\"track\",\"track\":1}",
+		"{\"record\":\"header\",\"version\":1,\"header\":{\"tag\":\"MThd\",\"format\":0,\"division\":480}}\n{\"record\":\"event\",\"track\":0,\"event\":{\"tag\":\"EndOfTrack\",\"delta\":0}}",
+		"{\"record\":\"header\",\"version\":1,\"header\":{\"tag\":\"MThd\",\"format\":0,\"division\":480}}\n{\"record\":\"note\"}",
+	}
+
+	assembler := JSONAssembler{}
+
+	for _, src := range tests {
+		if _, err := assembler.Assemble(strings.NewReader(src)); err == nil {
+			t.Errorf("expected error assembling invalid NDJSON\n%v", src)
+		}
+	}
+}
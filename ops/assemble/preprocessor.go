@@ -0,0 +1,442 @@
+package assemble
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// source is a line of assembler source, with the file and line number it came from (and the
+// macro it was expanded from, if any) for error messages.
+type source struct {
+	text  string
+	file  string
+	line  int
+	macro string
+	pad   uint64
+}
+
+type macro struct {
+	name   string
+	params []string
+	body   []source
+}
+
+// preprocessor expands the %%define, %%macro, %%include and %%repeat directives in a text
+// assembler source:
+//
+//	%%define NAME value            defines a constant, referenced as $NAME or ${NAME} ($$NAME is
+//	                               a literal $NAME)
+//	%%macro name(a, b, ...)        defines a macro, with the parameters referenced as $a, $b, ...
+//	...
+//	%%end
+//	%%name(x, y, ...)              expands a macro
+//	%%include "file"               includes a file (relative to the including file)
+//	%%repeat N [length:<ticks>]    repeats a block of lines N times, optionally padding each
+//	...                            repetition to a fixed length by adding to the delta of the
+//	%%end                          event following the repetition
+//
+// A %%name(...) call to an undefined macro is an error and any other line starting with %% is a
+// comment.
+type preprocessor struct {
+	defines  map[string]string
+	macros   map[string]macro
+	includes []string
+}
+
+const MAX_DEPTH = 32
+
+var (
+	reDirective = regexp.MustCompile(`^%%([A-Za-z_][A-Za-z0-9_-]*)\s*(.*)$`)
+	reCall      = regexp.MustCompile(`^%%([A-Za-z_][A-Za-z0-9_-]*)\s*\((.*)\)\s*$`)
+	reMacro     = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_-]*)\s*\((.*)\)$`)
+	reVariable  = regexp.MustCompile(`\$(\$?)(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+	reChunk     = regexp.MustCompile(`^\s*(?:[0-9A-Fa-f]{2}(?:\s+|…\s*))*(MThd|MTrk)\b`)
+	reDelta     = regexp.MustCompile(`delta:([0-9]+)`)
+	reRepeat    = regexp.MustCompile(`^([0-9]+)(?:\s+length:([0-9]+))?$`)
+	reName      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+func newPreprocessor() *preprocessor {
+	return &preprocessor{
+		defines: map[string]string{},
+		macros:  map[string]macro{},
+	}
+}
+
+// lines splits a file into a list of source lines.
+func lines(text string, file string) []source {
+	list := []source{}
+
+	for i, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		list = append(list, source{text: line, file: file, line: i + 1})
+	}
+
+	return list
+}
+
+// preprocess expands the directives in the source and applies the repeat padding to the event
+// deltas.
+func (p *preprocessor) preprocess(src []source) ([]source, error) {
+	expanded, err := p.expand(src, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	list := []source{}
+	pad := uint64(0)
+
+	for _, s := range expanded {
+		switch {
+		case s.pad > 0:
+			pad += s.pad
+
+		case chunkTag(s.text) != "":
+			pad = 0
+			list = append(list, s)
+
+		case pad > 0 && !strings.HasPrefix(s.text, "%%") && reDelta.MatchString(s.text):
+			if text, err := retime(s.text, pad); err != nil {
+				return nil, s.errorf("%v", err)
+			} else {
+				s.text = text
+				pad = 0
+			}
+
+			list = append(list, s)
+
+		default:
+			list = append(list, s)
+		}
+	}
+
+	return list, nil
+}
+
+func (p *preprocessor) expand(src []source, args map[string]string, depth int) ([]source, error) {
+	if depth > MAX_DEPTH {
+		return nil, fmt.Errorf("macro, include or repeat nesting exceeds %v levels", MAX_DEPTH)
+	}
+
+	list := []source{}
+
+	for i := 0; i < len(src); i++ {
+		s := src[i]
+		text := strings.TrimSpace(s.text)
+
+		if !strings.HasPrefix(text, "%%") {
+			if v, err := p.substitute(s.text, args); err != nil {
+				return nil, s.errorf("%v", err)
+			} else {
+				s.text = v
+				list = append(list, s)
+			}
+
+			continue
+		}
+
+		match := reDirective.FindStringSubmatch(text)
+		if match == nil {
+			list = append(list, s) // comment
+			continue
+		}
+
+		directive := match[1]
+		operands := strings.TrimSpace(match[2])
+
+		switch directive {
+		case "define":
+			fields := strings.SplitN(operands, " ", 2)
+			if !reName.MatchString(fields[0]) {
+				return nil, s.errorf("invalid %%%%define (%v): expected %%%%define NAME value", operands)
+			}
+
+			value := ""
+			if len(fields) > 1 {
+				if v, err := p.substitute(strings.TrimSpace(fields[1]), args); err != nil {
+					return nil, s.errorf("%v", err)
+				} else {
+					value = v
+				}
+			}
+
+			p.defines[fields[0]] = value
+
+		case "macro":
+			m := reMacro.FindStringSubmatch(operands)
+			if m == nil {
+				return nil, s.errorf("invalid %%%%macro (%v): expected %%%%macro name(parameters)", operands)
+			}
+
+			body, end, err := block(src, i)
+			if err != nil {
+				return nil, err
+			}
+
+			params := []string{}
+			for _, param := range split(m[2]) {
+				if !reName.MatchString(param) {
+					return nil, s.errorf("invalid macro parameter (%v)", param)
+				}
+
+				params = append(params, param)
+			}
+
+			p.macros[m[1]] = macro{name: m[1], params: params, body: body}
+			i = end
+
+		case "include":
+			included, err := p.include(s, strings.Trim(operands, `"'`), depth)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, included...)
+
+		case "repeat":
+			body, end, err := block(src, i)
+			if err != nil {
+				return nil, err
+			}
+
+			repeated, err := p.repeat(s, operands, body, args, depth)
+			if err != nil {
+				return nil, err
+			}
+
+			list = append(list, repeated...)
+			i = end
+
+		case "end":
+			return nil, s.errorf("%%%%end without %%%%macro or %%%%repeat")
+
+		default:
+			if call := reCall.FindStringSubmatch(text); call != nil {
+				m, ok := p.macros[call[1]]
+				if !ok {
+					return nil, s.errorf("undefined macro '%v'", call[1])
+				}
+
+				expanded, err := p.call(s, m, call[2], args, depth)
+				if err != nil {
+					return nil, err
+				}
+
+				list = append(list, expanded...)
+				continue
+			}
+
+			list = append(list, s) // comment
+		}
+	}
+
+	return list, nil
+}
+
+func (p *preprocessor) call(s source, m macro, operands string, args map[string]string, depth int) ([]source, error) {
+	values := split(operands)
+	if len(values) != len(m.params) {
+		return nil, s.errorf("macro '%v' expects %v arguments, got %v", m.name, len(m.params), len(values))
+	}
+
+	scope := map[string]string{}
+	for k, v := range args {
+		scope[k] = v
+	}
+
+	for i, param := range m.params {
+		if v, err := p.substitute(values[i], args); err != nil {
+			return nil, s.errorf("%v", err)
+		} else {
+			scope[param] = v
+		}
+	}
+
+	body := []source{}
+	for _, b := range m.body {
+		b.macro = fmt.Sprintf("'%v' called from %v", m.name, s.location())
+		body = append(body, b)
+	}
+
+	return p.expand(body, scope, depth+1)
+}
+
+func (p *preprocessor) include(s source, file string, depth int) ([]source, error) {
+	if file == "" {
+		return nil, s.errorf("invalid %%%%include: missing file name")
+	}
+
+	path := file
+	if !filepath.IsAbs(path) && s.file != "" {
+		path = filepath.Join(filepath.Dir(s.file), file)
+	}
+
+	for _, f := range p.includes {
+		if f == filepath.Clean(path) {
+			return nil, s.errorf("recursive %%%%include (%v)", file)
+		}
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, s.errorf("%v", err)
+	}
+
+	p.includes = append(p.includes, filepath.Clean(path))
+	defer func() {
+		p.includes = p.includes[:len(p.includes)-1]
+	}()
+
+	return p.expand(lines(string(bytes), path), nil, depth+1)
+}
+
+func (p *preprocessor) repeat(s source, operands string, body []source, args map[string]string, depth int) ([]source, error) {
+	match := reRepeat.FindStringSubmatch(operands)
+	if match == nil {
+		return nil, s.errorf("invalid %%%%repeat (%v): expected %%%%repeat N [length:<ticks>]", operands)
+	}
+
+	N, err := strconv.Atoi(match[1])
+	if err != nil {
+		return nil, s.errorf("invalid %%%%repeat count (%v)", match[1])
+	}
+
+	list := []source{}
+	for i := 0; i < N; i++ {
+		expanded, err := p.expand(body, args, depth+1)
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, expanded...)
+
+		if match[2] != "" {
+			length, _ := strconv.ParseUint(match[2], 10, 64)
+			ticks := uint64(0)
+
+			for _, e := range expanded {
+				ticks += e.pad
+
+				if !strings.HasPrefix(strings.TrimSpace(e.text), "%%") {
+					if d := reDelta.FindStringSubmatch(e.text); d != nil {
+						v, _ := strconv.ParseUint(d[1], 10, 64)
+						ticks += v
+					}
+				}
+			}
+
+			if ticks > length {
+				return nil, s.errorf("%%%%repeat block is longer (%v ticks) than the repeat length (%v ticks)", ticks, length)
+			} else if ticks < length {
+				list = append(list, source{file: s.file, line: s.line, pad: length - ticks})
+			}
+		}
+	}
+
+	return list, nil
+}
+
+// substitute replaces the $NAME and ${NAME} references with the macro arguments or defines
+// ($$NAME is a literal $NAME). An undefined ${NAME} is an error but an undefined $NAME (or
+// $$NAME) is left as is, so that text events can include a '$'.
+func (p *preprocessor) substitute(text string, args map[string]string) (string, error) {
+	var err error
+
+	v := reVariable.ReplaceAllStringFunc(text, func(s string) string {
+		match := reVariable.FindStringSubmatch(s)
+		escaped := match[1] != ""
+		name := match[2] + match[3]
+		arg, isArg := args[name]
+		define, isDefine := p.defines[name]
+
+		switch {
+		case escaped && (isArg || isDefine):
+			return s[1:]
+
+		case escaped:
+			return s
+
+		case isArg:
+			return arg
+
+		case isDefine:
+			return define
+
+		case match[2] != "" && err == nil:
+			err = fmt.Errorf("undefined variable '%v'", s)
+		}
+
+		return s
+	})
+
+	return v, err
+}
+
+// block returns the lines between a %%macro or %%repeat directive and the matching %%end.
+func block(src []source, start int) ([]source, int, error) {
+	nesting := 0
+
+	for i := start + 1; i < len(src); i++ {
+		text := strings.TrimSpace(src[i].text)
+
+		if match := reDirective.FindStringSubmatch(text); match != nil {
+			switch match[1] {
+			case "macro", "repeat":
+				nesting++
+
+			case "end":
+				if nesting == 0 {
+					return src[start+1 : i], i, nil
+				}
+
+				nesting--
+			}
+		}
+	}
+
+	return nil, 0, src[start].errorf("missing %%%%end")
+}
+
+// split splits a comma separated argument list.
+func split(s string) []string {
+	list := []string{}
+
+	if strings.TrimSpace(s) != "" {
+		for _, v := range strings.Split(s, ",") {
+			list = append(list, strings.TrimSpace(v))
+		}
+	}
+
+	return list
+}
+
+func retime(text string, pad uint64) (string, error) {
+	match := reDelta.FindStringSubmatchIndex(text)
+	delta, err := strconv.ParseUint(text[match[2]:match[3]], 10, 32)
+	if err != nil {
+		return text, err
+	}
+
+	return fmt.Sprintf("%vdelta:%v%v", text[:match[0]], delta+pad, text[match[1]:]), nil
+}
+
+func (s source) location() string {
+	if s.file == "" {
+		return fmt.Sprintf("line %v", s.line)
+	}
+
+	return fmt.Sprintf("%v:%v", s.file, s.line)
+}
+
+func (s source) errorf(format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+
+	if s.macro != "" {
+		return fmt.Errorf("%v: %v (in macro %v)", s.location(), msg, s.macro)
+	}
+
+	return fmt.Errorf("%v: %v", s.location(), msg)
+}
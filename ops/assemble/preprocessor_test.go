@@ -0,0 +1,132 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//go:embed test-files/macros-expanded.txt
+var macrosExpanded []byte
+
+func TestTextMacros(t *testing.T) {
+	src, err := os.ReadFile("test-files/macros.txt")
+	if err != nil {
+		t.Fatalf("error reading test file (%v)", err)
+	}
+
+	expected, err := TextAssembler{}.Assemble(bytes.NewBuffer(macrosExpanded))
+	if err != nil {
+		t.Fatalf("error assembling expanded text file (%v)", err)
+	}
+
+	assembler := TextAssembler{Filename: "test-files/macros.txt"}
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(src))
+	if err != nil {
+		t.Fatalf("error assembling text file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("incorrectly assembled text file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(expected), hex.Dump(encoded))
+	}
+}
+
+func TestTextPreprocessorErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\nMTrk\ndelta:0 NoteOn channel:${CHANNEL} note:C3, velocity:72\n",
+			expected: "song.txt:3: undefined variable '${CHANNEL}'",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\nMTrk\ndelta:0 NoteOn channel:$CHANNEL note:C3, velocity:72\n",
+			expected: "song.txt:3: invalid NoteOn event",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\nMTrk\n%%notdefined(1)\ndelta:0 EndOfTrack\n",
+			expected: "song.txt:3: undefined macro 'notdefined'",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\nMTrk\n%%repeat 2\ndelta:0 EndOfTrack\n",
+			expected: "song.txt:3: missing %%end",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\nMTrk\n%%end\n",
+			expected: "song.txt:3: %%end without %%macro or %%repeat",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\nMTrk\n%%repeat 2 length:100\ndelta:200 EndOfTrack\n%%end\n",
+			expected: "song.txt:3: %%repeat block is longer (200 ticks) than the repeat length (100 ticks)",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\n%%macro m(a)\ndelta:0 NoteOn channel:$a note:X3, velocity:72\n%%end\nMTrk\n%%m(1)\n",
+			expected: "song.txt:3: invalid NoteOn event",
+		},
+		{
+			src:      "MThd format:1, metrical time:480 ppqn\n%%macro m(a)\n%%end\nMTrk\n%%m(1, 2)\n",
+			expected: "song.txt:5: macro 'm' expects 1 arguments, got 2",
+		},
+	}
+
+	for _, test := range tests {
+		assembler := TextAssembler{Filename: "song.txt"}
+
+		if _, err := assembler.Assemble(strings.NewReader(test.src)); err == nil {
+			t.Errorf("expected error assembling %q", test.src)
+		} else if !strings.HasPrefix(err.Error(), test.expected) {
+			t.Errorf("incorrect error\n   expected:%v\n   got:     %v", test.expected, err)
+		}
+	}
+}
+
+func TestTextPreprocessorLiterals(t *testing.T) {
+	src := "MThd format:1, metrical time:480 ppqn\n" +
+		"MTrk\n" +
+		"%%define PRICE 5\n" +
+		"%%repeat 2 length:480\n" +
+		"delta:0 Text $Price in MTrk costs $$PRICE\n" +
+		"%%end\n" +
+		"delta:0 EndOfTrack\n"
+
+	expanded := "MThd format:1, metrical time:480 ppqn\n" +
+		"MTrk\n" +
+		"delta:0 Text $Price in MTrk costs $PRICE\n" +
+		"delta:480 Text $Price in MTrk costs $PRICE\n" +
+		"delta:480 EndOfTrack\n"
+
+	expected, err := TextAssembler{}.Assemble(strings.NewReader(expanded))
+	if err != nil {
+		t.Fatalf("error assembling expanded text file (%v)", err)
+	}
+
+	encoded, err := TextAssembler{}.Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("error assembling text file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("incorrectly assembled text file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(expected), hex.Dump(encoded))
+	}
+}
+
+func TestTextRecursiveInclude(t *testing.T) {
+	src, err := os.ReadFile("test-files/macros-recursive.txt")
+	if err != nil {
+		t.Fatalf("error reading test file (%v)", err)
+	}
+
+	assembler := TextAssembler{Filename: "test-files/macros-recursive.txt"}
+
+	if _, err := assembler.Assemble(bytes.NewBuffer(src)); err == nil {
+		t.Errorf("expected error assembling recursive %%%%include")
+	} else if !strings.Contains(err.Error(), "recursive %%include") {
+		t.Errorf("incorrect error (%v)", err)
+	}
+}
@@ -0,0 +1,688 @@
+package assemble
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// ScoreAssembler assembles a Format 1 MIDI file from a score i.e. a simple text notation in
+// which each voice is written as a sequence of notes, chords and rests with durations:
+//
+//	title Example
+//	tempo 120
+//	time 4/4
+//	key C
+//
+//	voice Melody channel:0 program:0
+//	mf C4/8 E4/8 G4/4 [C4 E4 G4]/2 | repeat 2 { r/4 G4 E4 C4 | }
+//
+// Track 0 holds the title, tempo, time signature and key signature events and each voice is
+// assembled into its own track. Bar checks (|) verify that the preceding notes fill a whole
+// number of bars and the dynamics marks (ppp to fff) set the velocity of the following notes.
+// Filename is used for error messages.
+type ScoreAssembler struct {
+	PPQN     uint16
+	Velocity byte
+	Filename string
+}
+
+type scoreToken struct {
+	text string
+	line int
+	file string
+}
+
+type scoreVoice struct {
+	name     string
+	channel  lib.Channel
+	program  int
+	velocity byte
+	duration uint64
+	tick     uint64
+	notes    []mxlNoteEvent
+	changes  []events.IEvent
+	tied     map[byte]int
+}
+
+type scoreMeter struct {
+	tick uint64
+	bar  uint64
+}
+
+type scoreScore struct {
+	title     string
+	ppqn      uint64
+	meters    []scoreMeter
+	voices    []*scoreVoice
+	current   *scoreVoice
+	conductor []events.IEvent
+}
+
+var scoreNote = regexp.MustCompile(`^([A-Ga-g])(#|##|b|bb|♯|♭)?(-?[0-9])((?:/[0-9]+)?\.*t?)(~?)$`)
+var scoreRest = regexp.MustCompile(`^r((?:/[0-9]+)?\.*t?)$`)
+var scoreChord = regexp.MustCompile(`^\[([^\]]*)\]((?:/[0-9]+)?\.*t?)(~?)$`)
+var scoreDuration = regexp.MustCompile(`^(?:/([0-9]+))?(\.*)(t?)$`)
+var scoreOption = regexp.MustCompile(`^(channel|program|velocity):([0-9]+)$`)
+
+func NewScoreAssembler() ScoreAssembler {
+	return ScoreAssembler{
+		PPQN:     480,
+		Velocity: 80,
+	}
+}
+
+func (a ScoreAssembler) Assemble(r io.Reader) ([]byte, error) {
+	smf, err := a.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+// Parse compiles a score into the equivalent Format 1 MIDI file.
+func (a ScoreAssembler) Parse(r io.Reader) (*midi.SMF, error) {
+	ppqn := a.PPQN
+	if ppqn == 0 {
+		ppqn = 480
+	}
+
+	tokens, err := a.tokenize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	score := scoreScore{
+		ppqn:   uint64(ppqn),
+		meters: []scoreMeter{{0, 4 * uint64(ppqn)}},
+	}
+
+	if err := a.run(&score, tokens, 0); err != nil {
+		return nil, err
+	}
+
+	if len(score.voices) == 0 {
+		return nil, fmt.Errorf("invalid score: no voices")
+	}
+
+	// ... track 0
+	end := uint64(0)
+	for _, v := range score.voices {
+		end = max(end, v.tick)
+		for _, n := range v.notes {
+			end = max(end, n.end)
+		}
+	}
+
+	mthd := midi.MakeMThd(1, uint16(len(score.voices)+1), ppqn&0x7fff)
+	smf := midi.SMF{
+		MThd: &mthd,
+	}
+
+	conductor := score.conductor
+	if score.title != "" {
+		conductor = append([]events.IEvent{metaevent.MakeTrackName(0, 0, score.title)}, conductor...)
+	}
+
+	if mtrk, err := mktrack(0, conductor, end); err != nil {
+		return nil, err
+	} else {
+		smf.Tracks = append(smf.Tracks, mtrk)
+	}
+
+	// ... voice tracks
+	for i, v := range score.voices {
+		list := []events.IEvent{metaevent.MakeTrackName(0, 0, v.name)}
+
+		if v.program >= 0 {
+			list = append(list, midievent.MakeProgramChange(0, 0, v.channel, 0, uint8(v.program)))
+		}
+
+		list = append(list, v.changes...)
+
+		for _, n := range v.notes {
+			list = append(list, midievent.MakeNoteOn(n.start, 0, v.channel, note(uint64(n.note)), max(1, byte(n.velocity))))
+			list = append(list, midievent.MakeNoteOff(n.end, 0, v.channel, note(uint64(n.note)), 64))
+		}
+
+		if mtrk, err := mktrack(i+1, list, end); err != nil {
+			return nil, err
+		} else {
+			smf.Tracks = append(smf.Tracks, mtrk)
+		}
+	}
+
+	return &smf, nil
+}
+
+// tokenize splits a score into tokens, discarding % comments. Quoted strings and chords are
+// single tokens and braces and bar lines are always separate tokens.
+func (a ScoreAssembler) tokenize(r io.Reader) ([]scoreToken, error) {
+	tokens := []scoreToken{}
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		runes := []rune(scanner.Text())
+		token := []rune{}
+
+		flush := func() {
+			if len(token) > 0 {
+				tokens = append(tokens, scoreToken{string(token), line, a.Filename})
+				token = []rune{}
+			}
+		}
+
+		for i := 0; i < len(runes); i++ {
+			switch r := runes[i]; {
+			case r == '%':
+				i = len(runes)
+
+			case r == ' ' || r == '\t' || r == '\r':
+				flush()
+
+			case r == '{' || r == '}' || r == '|':
+				flush()
+				tokens = append(tokens, scoreToken{string(r), line, a.Filename})
+
+			case r == '"':
+				j := index(runes, i+1, '"')
+				if j < 0 {
+					return nil, source{file: a.Filename, line: line}.errorf("unterminated string")
+				}
+
+				token = append(token, runes[i:j+1]...)
+				i = j
+
+			case r == '[':
+				j := index(runes, i+1, ']')
+				if j < 0 {
+					return nil, source{file: a.Filename, line: line}.errorf("unterminated chord")
+				}
+
+				token = append(token, runes[i:j+1]...)
+				i = j
+
+			default:
+				token = append(token, r)
+			}
+		}
+
+		flush()
+		tokens = append(tokens, scoreToken{"\n", line, a.Filename})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// run executes a list of tokens, recursively for repeat blocks.
+func (a ScoreAssembler) run(score *scoreScore, tokens []scoreToken, depth int) error {
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+
+		// ... rest of line arguments
+		args := func() []scoreToken {
+			list := []scoreToken{}
+			for i+1 < len(tokens) && tokens[i+1].text != "\n" {
+				i++
+				list = append(list, tokens[i])
+			}
+
+			return list
+		}
+
+		arg := func() (string, error) {
+			if i+1 >= len(tokens) || tokens[i+1].text == "\n" {
+				return "", t.errorf("missing '%v' value", t.text)
+			}
+
+			i++
+			return tokens[i].text, nil
+		}
+
+		switch t.text {
+		case "\n":
+
+		case "title":
+			list := []string{}
+			for _, v := range args() {
+				list = append(list, strings.Trim(v.text, `"`))
+			}
+
+			score.title = strings.Join(list, " ")
+
+		case "voice":
+			if depth > 0 {
+				return t.errorf("'voice' in repeat block")
+			} else if err := a.voice(score, t, args()); err != nil {
+				return err
+			}
+
+		case "tempo":
+			if v, err := arg(); err != nil {
+				return err
+			} else if bpm, err := strconv.ParseFloat(v, 64); err != nil || bpm <= 0 {
+				return t.errorf("invalid tempo (%v)", v)
+			} else {
+				score.conductor = append(score.conductor, metaevent.MakeTempo(score.tick(), 0, uint32(60000000/bpm)))
+			}
+
+		case "time":
+			if v, err := arg(); err != nil {
+				return err
+			} else if meter, err := abcmeter(v); err != nil || meter.beats == 0 {
+				return t.errorf("invalid time signature (%v)", v)
+			} else {
+				tick := score.tick()
+				clocks := uint8(max(1, 96/meter.beatType))
+				bar := score.ppqn * 4 * uint64(meter.beats) / uint64(meter.beatType)
+
+				score.meters = append(score.meters, scoreMeter{tick, bar})
+				score.conductor = append(score.conductor, metaevent.MakeTimeSignature(tick, 0, uint8(meter.beats), uint8(meter.beatType), clocks, 8))
+			}
+
+		case "key":
+			v, err := arg()
+			if err != nil {
+				return err
+			}
+
+			if i+1 < len(tokens) && abcmode(tokens[i+1].text) {
+				i++
+				v += " " + tokens[i].text
+			}
+
+			if fifths, keytype, _, err := abckey(v); err != nil {
+				return t.errorf("%v", err)
+			} else {
+				score.conductor = append(score.conductor, metaevent.MakeKeySignature(score.tick(), 0, fifths, keytype))
+			}
+
+		case "program", "channel", "velocity":
+			v, err := arg()
+			if err != nil {
+				return err
+			}
+
+			voice, err := score.active(t)
+			if err != nil {
+				return err
+			}
+
+			if err := a.option(score, voice, t.text+":"+v, true); err != nil {
+				return t.errorf("%v", err)
+			}
+
+		case "repeat":
+			v, err := arg()
+			if err != nil {
+				return err
+			}
+
+			N, err := strconv.Atoi(v)
+			if err != nil || N < 1 {
+				return t.errorf("invalid repeat count (%v)", v)
+			}
+
+			start, end, err := braces(tokens, i+1)
+			if err != nil {
+				return t.errorf("%v", err)
+			}
+
+			for j := 0; j < N; j++ {
+				if err := a.run(score, tokens[start:end], depth+1); err != nil {
+					return err
+				}
+			}
+
+			i = end
+
+		case "{", "}":
+			return t.errorf("unexpected '%v'", t.text)
+
+		case "|":
+			if voice, err := score.active(t); err != nil {
+				return err
+			} else if err := score.barcheck(voice); err != nil {
+				return t.errorf("%v", err)
+			}
+
+		default:
+			voice, err := score.active(t)
+			if err != nil {
+				return err
+			}
+
+			if err := a.music(score, voice, t.text); err != nil {
+				return t.errorf("%v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// voice starts (or resumes) a voice with the name and channel, program and velocity options.
+func (a ScoreAssembler) voice(score *scoreScore, t scoreToken, args []scoreToken) error {
+	name := []string{}
+	options := []string{}
+
+	for _, arg := range args {
+		if scoreOption.MatchString(arg.text) {
+			options = append(options, arg.text)
+		} else {
+			name = append(name, strings.Trim(arg.text, `"`))
+		}
+	}
+
+	id := strings.Join(name, " ")
+	if id == "" {
+		id = fmt.Sprintf("Voice %v", len(score.voices)+1)
+	}
+
+	var voice *scoreVoice
+	for _, v := range score.voices {
+		if v.name == id {
+			voice = v
+		}
+	}
+
+	if voice == nil {
+		channel := lib.Channel(len(score.voices) % 16)
+		if len(score.voices) >= 9 {
+			channel = lib.Channel((len(score.voices) + 1) % 16)
+		}
+
+		voice = &scoreVoice{
+			name:     id,
+			channel:  channel,
+			program:  -1,
+			velocity: a.Velocity,
+			duration: score.ppqn,
+			tied:     map[byte]int{},
+		}
+
+		if voice.velocity == 0 {
+			voice.velocity = 80
+		}
+
+		score.voices = append(score.voices, voice)
+	}
+
+	for _, option := range options {
+		if err := a.option(score, voice, option, false); err != nil {
+			return t.errorf("%v", err)
+		}
+	}
+
+	score.current = voice
+
+	return nil
+}
+
+// option sets a voice's channel, program or velocity. A program change after the start of
+// a voice is added to the voice's notes as a ProgramChange event at the current tick.
+func (a ScoreAssembler) option(score *scoreScore, voice *scoreVoice, option string, inline bool) error {
+	match := scoreOption.FindStringSubmatch(option)
+	if match == nil {
+		return fmt.Errorf("invalid option (%v)", option)
+	}
+
+	v, err := strconv.Atoi(match[2])
+	if err != nil {
+		return fmt.Errorf("invalid %v (%v)", match[1], match[2])
+	}
+
+	switch match[1] {
+	case "channel":
+		if v > 15 {
+			return fmt.Errorf("invalid channel (%v): expected a value in the interval [0..15]", v)
+		} else if inline && (len(voice.notes) > 0 || len(voice.changes) > 0) {
+			return fmt.Errorf("channel cannot be changed after the start of a voice")
+		}
+
+		voice.channel = lib.Channel(v)
+
+	case "program":
+		if v > 127 {
+			return fmt.Errorf("invalid program (%v): expected a value in the interval [0..127]", v)
+		} else if inline && voice.tick > 0 {
+			voice.changes = append(voice.changes, midievent.MakeProgramChange(voice.tick, 0, voice.channel, 0, uint8(v)))
+		} else {
+			voice.program = v
+		}
+
+	case "velocity":
+		if v < 1 || v > 127 {
+			return fmt.Errorf("invalid velocity (%v): expected a value in the interval [1..127]", v)
+		}
+
+		voice.velocity = byte(v)
+	}
+
+	return nil
+}
+
+// music processes a note, chord, rest or dynamics mark.
+func (a ScoreAssembler) music(score *scoreScore, voice *scoreVoice, s string) error {
+	if velocity, ok := dynamics[s]; ok {
+		voice.velocity = velocity
+		return nil
+	}
+
+	if match := scoreRest.FindStringSubmatch(s); match != nil {
+		if d, err := score.duration(voice, match[1]); err != nil {
+			return err
+		} else {
+			voice.tick += d
+		}
+
+		return nil
+	}
+
+	if match := scoreNote.FindStringSubmatch(s); match != nil {
+		if pitch, err := pitch(match[1], match[2], match[3]); err != nil {
+			return err
+		} else if d, err := score.duration(voice, match[4]); err != nil {
+			return err
+		} else {
+			voice.play([]byte{pitch}, d, match[5] == "~")
+		}
+
+		return nil
+	}
+
+	if match := scoreChord.FindStringSubmatch(s); match != nil {
+		pitches := []byte{}
+		for _, p := range strings.Fields(match[1]) {
+			if m := scoreNote.FindStringSubmatch(p); m == nil || m[4] != "" || m[5] != "" {
+				return fmt.Errorf("invalid chord note (%v)", p)
+			} else if pitch, err := pitch(m[1], m[2], m[3]); err != nil {
+				return err
+			} else {
+				pitches = append(pitches, pitch)
+			}
+		}
+
+		if len(pitches) == 0 {
+			return fmt.Errorf("empty chord (%v)", s)
+		} else if d, err := score.duration(voice, match[2]); err != nil {
+			return err
+		} else {
+			voice.play(pitches, d, match[3] == "~")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unrecognised note, chord or directive (%v)", s)
+}
+
+// play adds the notes at the voice's current tick, extending any notes tied to them, and
+// advances the voice.
+func (v *scoreVoice) play(pitches []byte, duration uint64, tie bool) {
+	for _, p := range pitches {
+		if ix, ok := v.tied[p]; ok && v.notes[ix].end == v.tick {
+			v.notes[ix].end += duration
+		} else {
+			v.notes = append(v.notes, mxlNoteEvent{note: p, start: v.tick, end: v.tick + duration, velocity: int(v.velocity)})
+			ix = len(v.notes) - 1
+			v.tied[p] = ix
+		}
+
+		if !tie {
+			delete(v.tied, p)
+		}
+	}
+
+	v.tick += duration
+}
+
+// active returns the current voice, creating a default voice if the score does not start with
+// a 'voice' directive.
+func (s *scoreScore) active(t scoreToken) (*scoreVoice, error) {
+	if s.current == nil {
+		return nil, t.errorf("'%v' before first 'voice'", t.text)
+	}
+
+	return s.current, nil
+}
+
+// tick returns the current voice's tick (or 0 before the first voice).
+func (s *scoreScore) tick() uint64 {
+	if s.current != nil {
+		return s.current.tick
+	}
+
+	return 0
+}
+
+// barcheck returns an error if the voice is not at the start of a bar.
+func (s *scoreScore) barcheck(v *scoreVoice) error {
+	meter := s.meters[0]
+	for _, m := range s.meters {
+		if m.tick <= v.tick && m.tick >= meter.tick {
+			meter = m
+		}
+	}
+
+	if offset := (v.tick - meter.tick) % meter.bar; offset != 0 {
+		return fmt.Errorf("bar check failed at tick %v (%v ticks into bar of %v ticks)", v.tick, offset, meter.bar)
+	}
+
+	return nil
+}
+
+// duration returns the duration in ticks for a /N duration with optional dots and triplet
+// suffix (t), defaulting to the voice's previous duration. The duration is saved as the
+// voice's default duration.
+func (s *scoreScore) duration(v *scoreVoice, d string) (uint64, error) {
+	match := scoreDuration.FindStringSubmatch(d)
+	if match == nil {
+		return 0, fmt.Errorf("invalid duration (%v)", d)
+	} else if match[1] == "" && match[2] == "" && match[3] == "" {
+		return v.duration, nil
+	}
+
+	ticks := v.duration
+	if match[1] != "" {
+		N, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil || N == 0 || N&(N-1) != 0 || N > 128 {
+			return 0, fmt.Errorf("invalid duration (%v): expected /1, /2, /4, ... /128", d)
+		} else if (4*s.ppqn)%N != 0 {
+			return 0, fmt.Errorf("invalid duration (%v): not a whole number of ticks at %v PPQN", d, s.ppqn)
+		}
+
+		ticks = 4 * s.ppqn / N
+	}
+
+	for i, dot := 0, ticks/2; i < len(match[2]); i, dot = i+1, dot/2 {
+		ticks += dot
+	}
+
+	if match[3] == "t" {
+		ticks = ticks * 2 / 3
+	}
+
+	if ticks == 0 {
+		return 0, fmt.Errorf("invalid duration (%v): too short", d)
+	}
+
+	v.duration = ticks
+
+	return ticks, nil
+}
+
+// pitch returns the MIDI note number for a note name, accidental and octave (C4 is 60).
+func pitch(letter, accidental, octave string) (byte, error) {
+	o, err := strconv.Atoi(octave)
+	if err != nil {
+		return 0, fmt.Errorf("invalid octave (%v)", octave)
+	}
+
+	alter := map[string]int{"": 0, "#": 1, "♯": 1, "##": 2, "b": -1, "♭": -1, "bb": -2}[accidental]
+	value := 12*(o+1) + steps[strings.ToUpper(letter)] + alter
+
+	if value < 0 || value > 127 {
+		return 0, fmt.Errorf("note out of range (%v%v%v)", letter, accidental, octave)
+	}
+
+	return byte(value), nil
+}
+
+func (t scoreToken) errorf(format string, args ...any) error {
+	return source{file: t.file, line: t.line}.errorf(format, args...)
+}
+
+// braces returns the range of tokens between a '{' (optionally on the next line) and the
+// matching '}'.
+func braces(tokens []scoreToken, i int) (int, int, error) {
+	for i < len(tokens) && tokens[i].text == "\n" {
+		i++
+	}
+
+	if i >= len(tokens) || tokens[i].text != "{" {
+		return 0, 0, fmt.Errorf("missing '{' after 'repeat'")
+	}
+
+	nesting := 0
+	for j := i + 1; j < len(tokens); j++ {
+		switch tokens[j].text {
+		case "{":
+			nesting++
+
+		case "}":
+			if nesting == 0 {
+				return i + 1, j, nil
+			}
+
+			nesting--
+		}
+	}
+
+	return 0, 0, fmt.Errorf("missing '}'")
+}
@@ -0,0 +1,95 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+//go:embed test-files/reference.score
+var referenceScore []byte
+
+//go:embed test-files/score.mid
+var smfScore []byte
+
+func TestScoreReference(t *testing.T) {
+	encoded, err := NewScoreAssembler().Assemble(bytes.NewReader(referenceScore))
+	if err != nil {
+		t.Fatalf("error assembling score (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smfScore) {
+		t.Errorf("incorrectly assembled score\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smfScore), hex.Dump(encoded))
+	}
+}
+
+func TestScoreDurations(t *testing.T) {
+	src := "voice Test\nC4/4 D4 E4/8. F4/16 [G4 B4]/8t r/8t r/8t |\n"
+
+	smf, err := NewScoreAssembler().Parse(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []uint64{0, 0, 480, 480, 960, 960, 1320, 1320, 1440, 1440, 1440, 1600, 1600, 1920}
+
+	ticks := []uint64{}
+	for _, e := range smf.Tracks[1].Events {
+		ticks = append(ticks, e.Tick())
+	}
+
+	if !reflect.DeepEqual(ticks, expected) {
+		t.Errorf("incorrect event ticks\n   expected:%v\n   got:     %v", expected, ticks)
+	}
+}
+
+func TestScoreErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{"voice Test\nC4/4 D4 E4 |\n", "line 2: bar check failed at tick 1440 (1440 ticks into bar of 1920 ticks)"},
+		{"time 3/4\nvoice Test\nC4/2 D4/4 | E4/2 |\n", "line 3: bar check failed at tick 2400 (960 ticks into bar of 1440 ticks)"},
+		{"C4/4\n", "line 1: 'C4/4' before first 'voice'"},
+		{"voice Test\nH4/4\n", "line 2: unrecognised note, chord or directive (H4/4)"},
+		{"voice Test\nC4/3\n", "line 2: invalid duration (/3): expected /1, /2, /4, ... /128"},
+		{"voice Test\nrepeat 2 { C4/4\n", "line 2: missing '}'"},
+		{"voice Test\nrepeat 2 C4/4\n", "line 2: missing '{' after 'repeat'"},
+		{"voice Test\nC4/4 }\n", "line 2: unexpected '}'"},
+		{"voice Test channel:16\n", "line 1: invalid channel (16): expected a value in the interval [0..15]"},
+		{"voice Test\n[C4 X4]/4\n", "line 2: invalid chord note (X4)"},
+		{"tempo 120\n", "invalid score: no voices"},
+	}
+
+	for _, test := range tests {
+		if _, err := NewScoreAssembler().Parse(strings.NewReader(test.src)); err == nil {
+			t.Errorf("expected error parsing %q", test.src)
+		} else if err.Error() != test.expected {
+			t.Errorf("incorrect error\n   expected:%v\n   got:     %v", test.expected, err)
+		}
+	}
+}
+
+func TestScoreErrorFilename(t *testing.T) {
+	assembler := NewScoreAssembler()
+	assembler.Filename = "example.score"
+
+	expected := "example.score:2: unrecognised note, chord or directive (H4/4)"
+
+	if _, err := assembler.Parse(strings.NewReader("voice Test\nH4/4\n")); err == nil {
+		t.Errorf("expected error parsing score")
+	} else if err.Error() != expected {
+		t.Errorf("incorrect error\n   expected:%v\n   got:     %v", expected, err)
+	}
+
+	expected = "example.score:2: unterminated string"
+
+	if _, err := assembler.Parse(strings.NewReader("voice Test\ntitle \"Example\n")); err == nil {
+		t.Errorf("expected error parsing score")
+	} else if err.Error() != expected {
+		t.Errorf("incorrect error\n   expected:%v\n   got:     %v", expected, err)
+	}
+}
@@ -1,7 +1,6 @@
 package assemble
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io"
@@ -15,7 +14,12 @@ import (
 	"github.com/transcriptaze/midiasm/midi/lib"
 )
 
+// TextAssembler assembles a MIDI file from the text disassembly format. The source is run
+// through a preprocessor that expands %%define, %%macro, %%include and %%repeat directives
+// (see preprocessor.go). Filename is used for error messages and as the base directory for
+// relative %%include files.
 type TextAssembler struct {
+	Filename string
 }
 
 func NewTextAssembler() TextAssembler {
@@ -34,10 +38,10 @@ func (a TextAssembler) Assemble(r io.Reader) ([]byte, error) {
 	for _, chunk := range chunks {
 		for _, line := range chunk {
 			switch {
-			case strings.HasPrefix(line, "%%"):
+			case strings.HasPrefix(line.text, "%%"):
 				// comment - ignore
 
-			case strings.Contains(line, "MThd"):
+			case chunkTag(line.text) == "MThd":
 				if mthd, err := a.parseMThd(chunk); err != nil {
 					return nil, err
 				} else {
@@ -46,8 +50,10 @@ func (a TextAssembler) Assemble(r io.Reader) ([]byte, error) {
 
 				break
 
-			case strings.Contains(line, "MTrk"):
-				if mtrk, err := a.parseMTrk(chunk); err != nil {
+			case chunkTag(line.text) == "MTrk":
+				if smf.MThd == nil {
+					return nil, line.errorf("missing MThd")
+				} else if mtrk, err := a.parseMTrk(chunk); err != nil {
 					return nil, err
 				} else {
 					mtrk.TrackNumber = lib.TrackNumber(len(smf.Tracks))
@@ -72,75 +78,68 @@ func (a TextAssembler) Assemble(r io.Reader) ([]byte, error) {
 	}
 }
 
-func (a TextAssembler) read(r io.Reader) ([][]string, error) {
-	scanner := bufio.NewScanner(r)
-	lines := make(chan string)
-	chunks := make(chan []string)
-
-	go a.scan(scanner, lines)
-	go a.chunkify(lines, chunks)
-
-	list := [][]string{}
-	for chunk := range chunks {
-		list = append(list, chunk)
-	}
-
-	if err := scanner.Err(); err != nil {
+func (a TextAssembler) read(r io.Reader) ([][]source, error) {
+	text, err := io.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
 
-	return list, nil
-}
-
-func (a TextAssembler) scan(scanner *bufio.Scanner, lines chan string) {
-	for scanner.Scan() {
-		lines <- scanner.Text()
+	src := lines(string(text), a.Filename)
+	if p, err := newPreprocessor().preprocess(src); err != nil {
+		return nil, err
+	} else {
+		return a.chunkify(p), nil
 	}
-
-	close(lines)
 }
 
-func (a TextAssembler) chunkify(lines chan string, chunks chan []string) {
-	tags := regexp.MustCompile("(MThd)|(MTrk)")
+func (a TextAssembler) chunkify(lines []source) [][]source {
+	chunks := [][]source{}
 
-	clone := func(slice []string) []string {
-		chunk := make([]string, len(slice))
-		copy(chunk, slice)
-		return chunk
-	}
+	var chunk []source
+	for len(lines) > 0 {
+		line := lines[0]
+		lines = lines[1:]
 
-	var chunk []string
-	for line := range lines {
-		if strings.Contains(line, "MThd") {
-			chunk = []string{line}
+		if chunkTag(line.text) == "MThd" {
+			chunk = []source{line}
 			break
 		}
 	}
 
-	for line := range lines {
-		if match := tags.FindStringSubmatch(line); match != nil {
-			chunks <- clone(chunk)
-			chunk = []string{line}
+	for _, line := range lines {
+		if chunkTag(line.text) != "" {
+			chunks = append(chunks, chunk)
+			chunk = []source{line}
 		} else {
 			chunk = append(chunk, line)
 		}
 	}
 
 	if len(chunk) > 0 {
-		chunks <- clone(chunk)
+		chunks = append(chunks, chunk)
 	}
 
-	close(chunks)
+	return chunks
 }
 
-func (a TextAssembler) parseMThd(chunk []string) (*midi.MThd, error) {
-	for _, line := range chunk {
-		if strings.Contains(line, "MThd") {
+// chunkTag returns the chunk tag (MThd or MTrk) if the line is a chunk header i.e. the tag
+// follows the (optional) hex dump, so that text events containing MThd or MTrk are not chunks.
+func chunkTag(line string) string {
+	if match := reChunk.FindStringSubmatch(line); match != nil {
+		return match[1]
+	}
+
+	return ""
+}
+
+func (a TextAssembler) parseMThd(chunk []source) (*midi.MThd, error) {
+	for _, l := range chunk {
+		if line := l.text; chunkTag(line) == "MThd" {
 			var format uint16
 			var division uint16
 
 			if match := regexp.MustCompile(`format:(0|1|2)`).FindStringSubmatch(line); match == nil || len(match) < 2 {
-				return nil, fmt.Errorf("missing or invalid 'format' field in MThd")
+				return nil, l.errorf("missing or invalid 'format' field in MThd")
 			} else if v, err := strconv.ParseUint(match[1], 10, 16); err != nil {
 				return nil, err
 			} else {
@@ -148,7 +147,7 @@ func (a TextAssembler) parseMThd(chunk []string) (*midi.MThd, error) {
 			}
 
 			if match := regexp.MustCompile(`metrical(?:[ -])?time:([0-9]+)\s*ppqn`).FindStringSubmatch(line); match == nil || len(match) < 2 {
-				return nil, fmt.Errorf("missing 'metrical-time' field in MThd")
+				return nil, l.errorf("missing 'metrical-time' field in MThd")
 			} else if v, err := strconv.ParseUint(match[1], 10, 16); err != nil {
 				return nil, err
 			} else {
@@ -157,7 +156,7 @@ func (a TextAssembler) parseMThd(chunk []string) (*midi.MThd, error) {
 				if division&0x8000 == 0x8000 {
 					fps := division & 0xff00 >> 8
 					if fps != 0xe8 && fps != 0xe7 && fps != 0xe3 && fps != 0xe2 {
-						return nil, fmt.Errorf("Invalid MThd division SMPTE timecode type (%02X): expected 24, 25, 29 or 30", fps)
+						return nil, l.errorf("Invalid MThd division SMPTE timecode type (%02X): expected 24, 25, 29 or 30", fps)
 					}
 				}
 			}
@@ -171,31 +170,15 @@ func (a TextAssembler) parseMThd(chunk []string) (*midi.MThd, error) {
 	return nil, fmt.Errorf("invalid MThd")
 }
 
-func (a TextAssembler) parseMTrk(chunk []string) (*midi.MTrk, error) {
-	lines := make(chan string)
-	closed := make(chan bool, 1)
-
-	defer func() {
-		closed <- true
-	}()
-
-	go func() {
-		for _, line := range chunk {
-			select {
-			case lines <- line:
-			case <-closed:
-				break
-			}
-		}
-
-		close(lines)
-	}()
-
+func (a TextAssembler) parseMTrk(chunk []source) (*midi.MTrk, error) {
 	// ... make MTrk
 	var mtrk *midi.MTrk
 
-	for line := range lines {
-		if strings.Contains(line, "MTrk") {
+	for len(chunk) > 0 {
+		line := chunk[0]
+		chunk = chunk[1:]
+
+		if chunkTag(line.text) == "MTrk" {
 			if v, err := midi.NewMTrk(); err != nil {
 				return nil, err
 			} else {
@@ -210,13 +193,13 @@ func (a TextAssembler) parseMTrk(chunk []string) (*midi.MTrk, error) {
 	}
 
 	// ... extract events
-	for line := range lines {
-		if !strings.HasPrefix(line, "%%") && strings.TrimSpace(line) != "" {
-			text := []byte(line)
+	for _, line := range chunk {
+		if !strings.HasPrefix(line.text, "%%") && strings.TrimSpace(line.text) != "" {
+			text := []byte(line.text)
 			e := events.Event{}
 
 			if err := e.UnmarshalText(text); err != nil {
-				return nil, err
+				return nil, line.errorf("%v", err)
 			} else {
 				mtrk.Events = append(mtrk.Events, &e)
 			}
@@ -0,0 +1,563 @@
+package assemble
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/events/sysex"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// TSVAssembler assembles a MIDI file from the 'tsv' command output i.e. an (optional) MThd row followed
+// by one group of Tick, Delta, Tag, Channel, Note, Velocity and Details columns per track. The event
+// times are taken from the Tick column (the Delta column is recalculated). The format and division are
+// taken from the MThd row if it is present, otherwise the format is 0 for a single track and 1 for
+// multiple tracks and the division is PPQN. The default is to use running status for notes.
+type TSVAssembler struct {
+	Delimiter     rune
+	Tabular       bool
+	PPQN          uint16
+	RunningStatus midifile.RunningStatus
+}
+
+// tsvgroup is the number of columns per track in the 'tsv' output.
+const tsvgroup = 7
+
+func NewTSVAssembler() TSVAssembler {
+	return TSVAssembler{
+		Delimiter:     '\t',
+		Tabular:       false,
+		PPQN:          480,
+		RunningStatus: midifile.RunningStatusNotes,
+	}
+}
+
+func (a TSVAssembler) Assemble(r io.Reader) ([]byte, error) {
+	var mthd []string
+	var records [][]string
+	var err error
+
+	if a.Tabular {
+		mthd, records, err = a.readTable(r)
+	} else {
+		mthd, records, err = a.readTSV(r)
+	}
+
+	if err != nil {
+		return nil, err
+	} else if len(records) == 0 {
+		return nil, fmt.Errorf("missing TSV header")
+	}
+
+	// ... build tracks
+	ntracks := (len(records[0]) + tsvgroup - 1) / tsvgroup
+	tracks := make([]*midi.MTrk, ntracks)
+	ticks := make([]uint64, ntracks)
+
+	for i := range tracks {
+		if mtrk, err := midi.NewMTrk(); err != nil {
+			return nil, err
+		} else {
+			mtrk.TrackNumber = lib.TrackNumber(i)
+			tracks[i] = mtrk
+		}
+	}
+
+	for row, record := range records[1:] {
+		for i, mtrk := range tracks {
+			cells := make([]string, tsvgroup)
+			for j := range cells {
+				if col := i*tsvgroup + j; col < len(record) && j == tsvgroup-1 {
+					cells[j] = record[col]
+				} else if col < len(record) {
+					cells[j] = strings.TrimSpace(record[col])
+				}
+			}
+
+			if cells[2] == "" {
+				continue
+			}
+
+			tick, err := strconv.ParseUint(cells[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %v, track %v: invalid tick (%v)", row+1, i, cells[0])
+			} else if tick < ticks[i] {
+				return nil, fmt.Errorf("row %v, track %v: tick (%v) is before previous event (%v)", row+1, i, tick, ticks[i])
+			}
+
+			if e, err := a.parseEvent(tick, uint32(tick-ticks[i]), cells[2:]); err != nil {
+				return nil, fmt.Errorf("row %v, track %v: %v", row+1, i, err)
+			} else {
+				mtrk.Events = append(mtrk.Events, events.NewEvent(e))
+				ticks[i] = tick
+			}
+		}
+	}
+
+	// ... assemble into SMF
+	format := uint16(1)
+	if ntracks == 1 {
+		format = 0
+	}
+
+	division := a.PPQN & 0x7fff
+
+	if mthd != nil {
+		if f, d, err := a.parseMThd(mthd); err != nil {
+			return nil, err
+		} else {
+			format = f
+			division = d
+		}
+	}
+
+	header := midi.MakeMThd(format, uint16(ntracks), division)
+	smf := midi.SMF{
+		MThd: &header,
+	}
+
+	for i, mtrk := range tracks {
+		if N := len(mtrk.Events); N == 0 || mtrk.Events[N-1].Event.Tag() != lib.TagEndOfTrack.String() {
+			mtrk.Events = append(mtrk.Events, events.NewEvent(metaevent.MakeEndOfTrack(ticks[i], 0)))
+		}
+
+		if mtrk, err := fixups(mtrk); err != nil {
+			return nil, err
+		} else {
+			smf.Tracks = append(smf.Tracks, mtrk)
+		}
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b, midifile.WithRunningStatus(a.RunningStatus))
+
+	if err := e.Encode(smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+// readTSV reads the delimited 'tsv' output, returning the MThd row (if any) and the last header row
+// followed by the event rows.
+func (a TSVAssembler) readTSV(r io.Reader) ([]string, [][]string, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = a.Delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var mthd []string
+	var header []string
+	rows := [][]string{}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		if len(record) > 0 && strings.TrimSpace(record[0]) == "MThd" && header == nil {
+			mthd = record
+		} else if len(record) > 0 && strings.TrimSpace(record[0]) == "Tick" {
+			header = record
+		} else if header != nil {
+			rows = append(rows, record)
+		}
+	}
+
+	if header == nil {
+		return mthd, nil, nil
+	}
+
+	return mthd, append([][]string{header}, rows...), nil
+}
+
+// readTable reads the fixed width 'tsv --tabular' output, returning the MThd row (if any) and the
+// header and event rows. The columns are located using the header row.
+func (a TSVAssembler) readTable(r io.Reader) ([]string, [][]string, error) {
+	scanner := bufio.NewScanner(r)
+	mthd := []string(nil)
+	records := [][]string{}
+	columns := []int{}
+
+	for scanner.Scan() {
+		line := []rune(scanner.Text())
+
+		if len(columns) == 0 {
+			if strings.HasPrefix(strings.TrimSpace(string(line)), "MThd") {
+				mthd = strings.Fields(string(line))
+			} else if strings.HasPrefix(strings.TrimSpace(string(line)), "Tick") {
+				for i, c := range line {
+					if !unicode.IsSpace(c) && (i == 0 || unicode.IsSpace(line[i-1])) {
+						columns = append(columns, i)
+					}
+				}
+
+				records = append(records, strings.Fields(string(line)))
+			}
+
+			continue
+		}
+
+		record := make([]string, len(columns))
+		for i, start := range columns {
+			end := len(line)
+			if i+1 < len(columns) {
+				end = min(columns[i+1], len(line))
+			}
+
+			if start < end {
+				record[i] = strings.TrimSpace(string(line[start:end]))
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return mthd, records, nil
+}
+
+// parseMThd returns the format and division from the MThd row i.e. MThd, format:N, tracks:N and
+// division:N.
+func (a TSVAssembler) parseMThd(record []string) (uint16, uint16, error) {
+	fields := map[string]uint64{}
+
+	for _, f := range record[1:] {
+		if k, v, ok := strings.Cut(strings.TrimSpace(f), ":"); ok {
+			if u, err := strconv.ParseUint(v, 10, 16); err != nil {
+				return 0, 0, fmt.Errorf("invalid MThd %v (%v)", k, v)
+			} else {
+				fields[k] = u
+			}
+		}
+	}
+
+	format, ok := fields["format"]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid MThd (%v): missing format", strings.Join(record, " "))
+	} else if format > 2 {
+		return 0, 0, fmt.Errorf("invalid MThd format (%v): expected 0, 1 or 2", format)
+	}
+
+	division, ok := fields["division"]
+	if !ok || division == 0 {
+		return 0, 0, fmt.Errorf("invalid MThd (%v): missing division", strings.Join(record, " "))
+	}
+
+	return uint16(format), uint16(division), nil
+}
+
+// parseEvent creates an event from the Tag, Channel, Note, Velocity and Details cells.
+func (a TSVAssembler) parseEvent(tick uint64, delta uint32, cells []string) (any, error) {
+	tag := cells[0]
+	channel := cells[1]
+	note := cells[2]
+	velocity := cells[3]
+	details := cells[4]
+
+	number := func(s string, bits int) (uint64, error) {
+		if v, err := strconv.ParseUint(s, 10, bits); err != nil {
+			return 0, fmt.Errorf("invalid %v value (%v)", tag, s)
+		} else {
+			return v, nil
+		}
+	}
+
+	ch := func() (lib.Channel, error) {
+		if v, err := strconv.ParseUint(channel, 10, 8); err != nil || v > 15 {
+			return 0, fmt.Errorf("invalid %v channel (%v)", tag, channel)
+		} else {
+			return lib.Channel(v), nil
+		}
+	}
+
+	data7 := func(s string) (uint8, error) {
+		if v, err := strconv.ParseUint(s, 10, 8); err != nil || v > 127 {
+			return 0, fmt.Errorf("invalid %v value (%v)", tag, s)
+		} else {
+			return uint8(v), nil
+		}
+	}
+
+	switch tag {
+	case lib.TagSequenceNumber.String():
+		if v, err := number(details, 16); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeSequenceNumber(tick, lib.Delta(delta), uint16(v)), nil
+		}
+
+	case lib.TagText.String():
+		return metaevent.MakeText(tick, lib.Delta(delta), details), nil
+
+	case lib.TagCopyright.String():
+		return metaevent.MakeCopyright(tick, lib.Delta(delta), details), nil
+
+	case lib.TagTrackName.String():
+		return metaevent.MakeTrackName(tick, lib.Delta(delta), details), nil
+
+	case lib.TagInstrumentName.String():
+		return metaevent.MakeInstrumentName(tick, lib.Delta(delta), details), nil
+
+	case lib.TagLyric.String():
+		return metaevent.MakeLyric(tick, lib.Delta(delta), details), nil
+
+	case lib.TagMarker.String():
+		return metaevent.MakeMarker(tick, lib.Delta(delta), details), nil
+
+	case lib.TagCuePoint.String():
+		return metaevent.MakeCuePoint(tick, lib.Delta(delta), details), nil
+
+	case lib.TagProgramName.String():
+		return metaevent.MakeProgramName(tick, lib.Delta(delta), details), nil
+
+	case lib.TagDeviceName.String():
+		return metaevent.MakeDeviceName(tick, lib.Delta(delta), details), nil
+
+	case lib.TagMIDIChannelPrefix.String():
+		if v, err := number(details, 8); err != nil || v > 15 {
+			return nil, fmt.Errorf("invalid %v channel (%v)", tag, details)
+		} else {
+			return metaevent.MakeMIDIChannelPrefix(tick, lib.Delta(delta), uint8(v)), nil
+		}
+
+	case lib.TagMIDIPort.String():
+		if v, err := number(details, 8); err != nil || v > 127 {
+			return nil, fmt.Errorf("invalid %v port (%v)", tag, details)
+		} else {
+			return metaevent.MakeMIDIPort(tick, lib.Delta(delta), uint8(v)), nil
+		}
+
+	case lib.TagEndOfTrack.String():
+		return metaevent.MakeEndOfTrack(tick, lib.Delta(delta)), nil
+
+	case lib.TagTempo.String():
+		if v, err := number(details, 24); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeTempo(tick, lib.Delta(delta), uint32(v)), nil
+		}
+
+	case lib.TagSMPTEOffset.String():
+		return a.smpte(tick, delta, details)
+
+	case lib.TagTimeSignature.String():
+		re := regexp.MustCompile(`^([0-9]+)/([0-9]+),\s*([0-9]+) ticks/click,\s*([0-9]+) 32nds/quarter$`)
+
+		if match := re.FindStringSubmatch(details); match == nil {
+			return nil, fmt.Errorf("invalid %v (%v)", tag, details)
+		} else {
+			v := make([]uint8, 4)
+			for i := range v {
+				if u, err := number(match[i+1], 8); err != nil {
+					return nil, err
+				} else {
+					v[i] = uint8(u)
+				}
+			}
+
+			if v[1] == 0 || v[1]&(v[1]-1) != 0 {
+				return nil, fmt.Errorf("invalid %v denominator (%v): expected a power of 2", tag, v[1])
+			}
+
+			return metaevent.MakeTimeSignature(tick, lib.Delta(delta), v[0], v[1], v[2], v[3]), nil
+		}
+
+	case lib.TagKeySignature.String():
+		for _, scale := range slices.Concat(lib.MAJOR_SCALES, lib.MINOR_SCALES) {
+			if strings.EqualFold(scale.Name, details) {
+				return metaevent.MakeKeySignature(tick, lib.Delta(delta), scale.Accidentals, scale.Type), nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid %v (%v)", tag, details)
+
+	case lib.TagSequencerSpecificEvent.String():
+		re := regexp.MustCompile(`^(.*?)\s*\[(.*)\]$`)
+
+		if match := re.FindStringSubmatch(details); match == nil {
+			return nil, fmt.Errorf("invalid %v (%v)", tag, details)
+		} else if manufacturer, err := lib.FindManufacturer(match[1]); err != nil {
+			return nil, err
+		} else if data, err := lib.ParseHex(match[2]); err != nil {
+			return nil, err
+		} else {
+			return metaevent.MakeSequencerSpecificEvent(tick, lib.Delta(delta), manufacturer, data), nil
+		}
+
+	case lib.TagNoteOff.String(), lib.TagNoteOn.String():
+		if c, err := ch(); err != nil {
+			return nil, err
+		} else if n, err := data7(note); err != nil {
+			return nil, err
+		} else if v, err := data7(velocity); err != nil {
+			return nil, err
+		} else {
+			note := midievent.Note{
+				Value: n,
+				Name:  midievent.FormatNote(nil, n),
+				Alias: midievent.FormatNote(nil, n),
+			}
+
+			if tag == lib.TagNoteOff.String() {
+				return midievent.MakeNoteOff(tick, delta, c, note, v), nil
+			} else {
+				return midievent.MakeNoteOn(tick, delta, c, note, v), nil
+			}
+		}
+
+	case lib.TagPolyphonicPressure.String():
+		if c, err := ch(); err != nil {
+			return nil, err
+		} else if n, err := data7(note); err != nil {
+			return nil, err
+		} else if v, err := data7(details); err != nil {
+			return nil, err
+		} else {
+			note := midievent.Note{
+				Value: n,
+				Name:  midievent.FormatNote(nil, n),
+				Alias: midievent.FormatNote(nil, n),
+			}
+
+			return midievent.MakePolyphonicPressure(tick, delta, c, note, v), nil
+		}
+
+	case lib.TagController.String():
+		re := regexp.MustCompile(`^([0-9]+)(?::.*)?,\s*([0-9]+)$`)
+
+		if c, err := ch(); err != nil {
+			return nil, err
+		} else if match := re.FindStringSubmatch(details); match == nil {
+			return nil, fmt.Errorf("invalid %v (%v)", tag, details)
+		} else if id, err := data7(match[1]); err != nil {
+			return nil, err
+		} else if v, err := data7(match[2]); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeController(tick, delta, c, lib.LookupController(id), v), nil
+		}
+
+	case lib.TagProgramChange.String():
+		re := regexp.MustCompile(`^([0-9]+),\s*([0-9]+)$`)
+
+		if c, err := ch(); err != nil {
+			return nil, err
+		} else if match := re.FindStringSubmatch(details); match == nil {
+			return nil, fmt.Errorf("invalid %v (%v)", tag, details)
+		} else if bank, err := number(match[1], 16); err != nil {
+			return nil, err
+		} else if program, err := data7(match[2]); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeProgramChange(tick, delta, c, uint16(bank), program), nil
+		}
+
+	case lib.TagChannelPressure.String():
+		if c, err := ch(); err != nil {
+			return nil, err
+		} else if v, err := data7(details); err != nil {
+			return nil, err
+		} else {
+			return midievent.MakeChannelPressure(tick, delta, c, v), nil
+		}
+
+	case lib.TagPitchBend.String():
+		if c, err := ch(); err != nil {
+			return nil, err
+		} else if v, err := number(details, 16); err != nil || v > 0x3fff {
+			return nil, fmt.Errorf("invalid %v value (%v)", tag, details)
+		} else {
+			return midievent.MakePitchBend(tick, delta, c, uint16(v)), nil
+		}
+
+	case lib.TagSysExMessage.String():
+		re := regexp.MustCompile(`^\[([0-9 ]+)\].*?,\s*([0-9A-Fa-f ]*)$`)
+
+		if match := re.FindStringSubmatch(details); match == nil {
+			return nil, fmt.Errorf("invalid %v (%v)", tag, details)
+		} else if data, err := lib.ParseHex(match[2]); err != nil {
+			return nil, err
+		} else {
+			id := []byte{}
+			for _, s := range strings.Fields(match[1]) {
+				if v, err := number(s, 8); err != nil {
+					return nil, err
+				} else {
+					id = append(id, byte(v))
+				}
+			}
+
+			return sysex.MakeSysExMessage(tick, delta, lib.LookupManufacturer(id), data), nil
+		}
+
+	case lib.TagSysExContinuation.String():
+		if data, err := lib.ParseHex(details); err != nil {
+			return nil, err
+		} else {
+			return sysex.MakeSysExContinuationMessage(tick, delta, data), nil
+		}
+
+	case lib.TagSysExEscape.String():
+		if data, err := lib.ParseHex(details); err != nil {
+			return nil, err
+		} else {
+			return sysex.MakeSysExEscapeMessage(tick, delta, data), nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unrecognised event (%v)", tag)
+	}
+}
+
+func (a TSVAssembler) smpte(tick uint64, delta uint32, details string) (any, error) {
+	re := regexp.MustCompile(`^offset\s+([0-9]+):([0-9]+):([0-9]+)\s+frame rate\s+([0-9]+):([0-9]+):([0-9]+)$`)
+
+	match := re.FindStringSubmatch(details)
+	if match == nil {
+		return nil, fmt.Errorf("invalid SMPTEOffset (%v)", details)
+	}
+
+	v := make([]uint8, 6)
+	for i := range v {
+		if u, err := strconv.ParseUint(match[i+1], 10, 8); err != nil {
+			return nil, fmt.Errorf("invalid SMPTEOffset (%v)", details)
+		} else {
+			v[i] = uint8(u)
+		}
+	}
+
+	hour, minute, second, rate, frames, fractions := v[0], v[1], v[2], v[3], v[4], v[5]
+
+	switch {
+	case hour > 24 || minute > 59 || second > 59:
+		return nil, fmt.Errorf("invalid SMPTEOffset time (%v)", details)
+
+	case rate != 24 && rate != 25 && rate != 29 && rate != 30:
+		return nil, fmt.Errorf("invalid SMPTEOffset frame rate (%v): expected 24, 25, 29 or 30", rate)
+
+	case frames >= rate || fractions > 100:
+		return nil, fmt.Errorf("invalid SMPTEOffset frames (%v)", details)
+	}
+
+	return metaevent.MakeSMPTEOffset(tick, lib.Delta(delta), hour, minute, second, rate, frames, fractions), nil
+}
@@ -0,0 +1,93 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+//go:embed test-files/reference.tsv
+var referenceTSV []byte
+
+//go:embed test-files/reference.tab
+var referenceTable []byte
+
+func TestTSVReference(t *testing.T) {
+	assembler := NewTSVAssembler()
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(referenceTSV))
+	if err != nil {
+		t.Fatalf("error assembling TSV file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smf) {
+		t.Errorf("incorrectly assembled TSV file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smf), hex.Dump(encoded))
+	}
+}
+
+func TestTSVTabular(t *testing.T) {
+	assembler := NewTSVAssembler()
+	assembler.Tabular = true
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(referenceTable))
+	if err != nil {
+		t.Fatalf("error assembling tabular TSV file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smf) {
+		t.Errorf("incorrectly assembled tabular TSV file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smf), hex.Dump(encoded))
+	}
+}
+
+func TestTSVDivision(t *testing.T) {
+	src := "MThd\tformat:1\ttracks:1\tdivision:96\n" +
+		"Tick\tDelta\tTag\tChannel\tNote\tVelocity\tDetails\n" +
+		"0\t0\tTempo\t\t\t\t500000\n" +
+		"96\t96\tNoteOn\t1\t60\t72\tC4\n"
+
+	expected := []byte{
+		0x4d, 0x54, 0x68, 0x64, 0x00, 0x00, 0x00, 0x06, 0x00, 0x01, 0x00, 0x01, 0x00, 0x60,
+		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0x0f,
+		0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20,
+		0x60, 0x91, 0x3c, 0x48,
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	encoded, err := NewTSVAssembler().Assemble(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatalf("error assembling TSV file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("incorrectly assembled TSV file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(expected), hex.Dump(encoded))
+	}
+}
+
+func TestTSVDelimiter(t *testing.T) {
+	src := "Tick;Delta;Tag;Channel;Note;Velocity;Details\n" +
+		"0;0;Tempo;;;;500000\n" +
+		"480;480;NoteOn;1;60;72;C4\n" +
+		"960;480;NoteOff;1;60;64;C4\n"
+
+	expected := []byte{
+		0x4d, 0x54, 0x68, 0x64, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x01, 0x00, 0x60,
+		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0x15,
+		0x00, 0xff, 0x51, 0x03, 0x07, 0xa1, 0x20,
+		0x83, 0x60, 0x91, 0x3c, 0x48,
+		0x83, 0x60, 0x81, 0x3c, 0x40,
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	assembler := TSVAssembler{Delimiter: ';', PPQN: 96}
+
+	encoded, err := assembler.Assemble(bytes.NewBufferString(src))
+	if err != nil {
+		t.Fatalf("error assembling TSV file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, expected) {
+		t.Errorf("incorrectly assembled TSV file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(expected), hex.Dump(encoded))
+	}
+}
@@ -0,0 +1,247 @@
+package assemble
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// YAMLAssembler assembles a MIDI file from a YAML document with the same document model as
+// the JSON format (version, header and tracks). An item in a track's events list may also be
+// a list of events (typically an alias to an anchored list), which is expanded in place so
+// that repeated blocks of events only need to be written once.
+type YAMLAssembler struct {
+}
+
+func NewYAMLAssembler() YAMLAssembler {
+	return YAMLAssembler{}
+}
+
+func (a YAMLAssembler) Assemble(r io.Reader) ([]byte, error) {
+	var root yaml.Node
+
+	if err := yaml.NewDecoder(r).Decode(&root); err == io.EOF {
+		return nil, fmt.Errorf("invalid YAML: empty document")
+	} else if err != nil {
+		return nil, err
+	}
+
+	document := resolve(&root)
+	if document.Kind == yaml.DocumentNode && len(document.Content) > 0 {
+		document = resolve(document.Content[0])
+	}
+
+	if document.Kind != yaml.MappingNode {
+		return nil, yamlError(document, "expected a mapping with 'version', 'header' and 'tracks'")
+	}
+
+	var header, tracks *yaml.Node
+	var version *int
+
+	for i := 0; i+1 < len(document.Content); i += 2 {
+		key := document.Content[i]
+		value := resolve(document.Content[i+1])
+
+		switch key.Value {
+		case "version":
+			v := 0
+			if err := value.Decode(&v); err != nil {
+				return nil, yamlError(value, "invalid 'version' (%v)", value.Value)
+			}
+			version = &v
+
+		case "header":
+			header = value
+
+		case "tracks":
+			tracks = value
+		}
+	}
+
+	if err := checkVersion(version); err != nil {
+		return nil, yamlError(document, "%v", err)
+	}
+
+	smf := midi.SMF{}
+
+	// ... header
+	if header == nil {
+		return nil, yamlError(document, "missing 'header'")
+	} else if h, err := a.parseMThd(header); err != nil {
+		return nil, err
+	} else {
+		smf.MThd = h
+	}
+
+	// ... tracks
+	if tracks != nil {
+		if tracks.Kind != yaml.SequenceNode {
+			return nil, yamlError(tracks, "expected a list of tracks")
+		}
+
+		for _, t := range tracks.Content {
+			if mtrk, err := a.parseMTrk(resolve(t)); err != nil {
+				return nil, err
+			} else {
+				mtrk.TrackNumber = lib.TrackNumber(len(smf.Tracks))
+
+				smf.Tracks = append(smf.Tracks, mtrk)
+				smf.MThd.Tracks += 1
+			}
+		}
+	}
+
+	// ... assemble into MIDI file
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+func (a YAMLAssembler) parseMThd(node *yaml.Node) (*midi.MThd, error) {
+	h := mthd{}
+
+	if node.Kind != yaml.MappingNode {
+		return nil, yamlError(node, "expected a 'header' mapping")
+	} else if err := decode(node, &h); err != nil {
+		return nil, yamlError(node, "invalid 'header' (%v)", err)
+	} else if mthd, err := NewJSONAssembler().parseMThd(h); err != nil {
+		return nil, yamlError(node, "%v", err)
+	} else {
+		return mthd, nil
+	}
+}
+
+func (a YAMLAssembler) parseMTrk(node *yaml.Node) (*midi.MTrk, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, yamlError(node, "expected a track mapping")
+	}
+
+	var tag *yaml.Node
+	var list *yaml.Node
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case "tag":
+			tag = resolve(node.Content[i+1])
+
+		case "events":
+			list = resolve(node.Content[i+1])
+		}
+	}
+
+	if tag == nil || tag.Value != "MTrk" {
+		return nil, yamlError(node, "missing or invalid 'MTrk' tag in track")
+	}
+
+	mtrk, err := midi.NewMTrk()
+	if err != nil {
+		return nil, err
+	} else if mtrk == nil {
+		return nil, fmt.Errorf("error creating 'MTrk' for tracks")
+	}
+
+	if list != nil {
+		if list.Kind != yaml.SequenceNode {
+			return nil, yamlError(list, "expected a list of events")
+		}
+
+		for _, item := range flatten(list) {
+			if e, err := a.parseEvent(item); err != nil {
+				return nil, err
+			} else {
+				mtrk.Events = append(mtrk.Events, e)
+			}
+		}
+	}
+
+	if len(mtrk.Events) == 0 {
+		return mtrk, nil
+	}
+
+	return fixups(mtrk)
+}
+
+// parseEvent unmarshals an event as either {event: {tag: ..., ...}} (as in the JSON format) or
+// just {tag: ..., ...}.
+func (a YAMLAssembler) parseEvent(node *yaml.Node) (*events.Event, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, yamlError(node, "expected an event mapping")
+	}
+
+	event := node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "event" {
+			event = resolve(node.Content[i+1])
+		}
+	}
+
+	var v any
+	if err := event.Decode(&v); err != nil {
+		return nil, yamlError(event, "%v", err)
+	}
+
+	bytes, err := json.Marshal(map[string]any{"event": v})
+	if err != nil {
+		return nil, yamlError(event, "invalid event (%v)", err)
+	}
+
+	e := events.Event{}
+	if err := e.UnmarshalJSON(bytes); err != nil {
+		return nil, yamlError(event, "%v", err)
+	}
+
+	return &e, nil
+}
+
+// flatten expands nested lists of events (e.g. aliases to anchored blocks of events).
+func flatten(node *yaml.Node) []*yaml.Node {
+	list := []*yaml.Node{}
+
+	for _, item := range node.Content {
+		if v := resolve(item); v.Kind == yaml.SequenceNode {
+			list = append(list, flatten(v)...)
+		} else {
+			list = append(list, v)
+		}
+	}
+
+	return list
+}
+
+func resolve(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	return node
+}
+
+// decode decodes a YAML node into a struct using the struct's JSON tags.
+func decode(node *yaml.Node, v any) error {
+	var u any
+
+	if err := node.Decode(&u); err != nil {
+		return err
+	} else if bytes, err := json.Marshal(u); err != nil {
+		return err
+	} else {
+		return json.Unmarshal(bytes, v)
+	}
+}
+
+func yamlError(node *yaml.Node, format string, args ...any) error {
+	return fmt.Errorf("invalid YAML (line %v): %v", node.Line, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,118 @@
+package assemble
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+//go:embed test-files/reference.yaml
+var referenceYAML []byte
+
+func TestYAMLReference(t *testing.T) {
+	assembler := YAMLAssembler{}
+
+	encoded, err := assembler.Assemble(bytes.NewBuffer(referenceYAML))
+	if err != nil {
+		t.Fatalf("error assembling YAML file (%v)", err)
+	}
+
+	if !reflect.DeepEqual(encoded, smfJ) {
+		t.Errorf("incorrectly assembled YAML file\nexpected:\n%+v\ngot:\n%+v", hex.Dump(smfJ), hex.Dump(encoded))
+	}
+}
+
+func TestYAMLAnchors(t *testing.T) {
+	src := `# riff played twice
+version: 1
+header: {tag: MThd, format: 0, division: 480}
+riff: &riff
+  - {tag: NoteOn, delta: 0, channel: 0, note: {value: 60}, velocity: 100}
+  - {tag: NoteOff, delta: 480, channel: 0, note: {value: 60}, velocity: 64}
+tracks:
+  - tag: MTrk
+    events:
+      - event: {tag: TrackName, delta: 0, name: Riff}
+      - *riff
+      - *riff   # again
+      - event: {tag: EndOfTrack, delta: 0}
+`
+
+	expected := []struct {
+		tag  string
+		tick uint64
+	}{
+		{"TrackName", 0},
+		{"NoteOn", 0},
+		{"NoteOff", 480},
+		{"NoteOn", 480},
+		{"NoteOff", 960},
+		{"EndOfTrack", 960},
+	}
+
+	assembler := YAMLAssembler{}
+
+	encoded, err := assembler.Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("error assembling YAML (%v)", err)
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("error decoding assembled MIDI file (%v)", err)
+	}
+
+	list := smf.Tracks[0].Events
+	if len(list) != len(expected) {
+		t.Fatalf("incorrect number of events - expected:%v, got:%v", len(expected), len(list))
+	}
+
+	for i, e := range expected {
+		if list[i].Event.Tag() != e.tag || list[i].Tick() != e.tick {
+			t.Errorf("incorrect event %v - expected:%v@%v, got:%v@%v", i, e.tag, e.tick, list[i].Event.Tag(), list[i].Tick())
+		}
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected string
+	}{
+		{
+			"version: 2\nheader: {tag: MThd, format: 0, division: 480}\ntracks: []\n",
+			"invalid YAML (line 1): unsupported JSON format version (2): expected 1",
+		},
+		{
+			"version: 1\ntracks: []\n",
+			"invalid YAML (line 1): missing 'header'",
+		},
+		{
+			"version: 1\nheader: {tag: MThd, format: 0, division: 480}\ntracks:\n  - tag: MTrk\n    events:\n      - {tag: NoteOn, delta: 0, channel: 16, note: {value: 60}, velocity: 1}\n",
+			"invalid YAML (line 6): Invalid channel (16)",
+		},
+		{
+			"version: 1\nheader: {tag: MThd, format: 0, division: 480}\ntracks:\n  - tag: MTrk\n    events:\n      - {tag: Noise, delta: 0}\n",
+			"invalid YAML (line 6): Unrecognised tag (Noise)",
+		},
+		{
+			"version: 1\nheader: {tag: MThd, format: 0, division: 480}\ntracks:\n  - tag: MTrack\n",
+			"invalid YAML (line 4): missing or invalid 'MTrk' tag in track",
+		},
+	}
+
+	assembler := YAMLAssembler{}
+
+	for _, test := range tests {
+		if _, err := assembler.Assemble(strings.NewReader(test.src)); err == nil {
+			t.Errorf("expected error assembling invalid YAML\n%v", test.src)
+		} else if err.Error() != test.expected {
+			t.Errorf("incorrect error\n   expected:%v\n   got:     %v", test.expected, err)
+		}
+	}
+}
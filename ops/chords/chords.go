@@ -0,0 +1,449 @@
+package chords
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/log"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/midi/timing"
+	"github.com/transcriptaze/midiasm/ops/key"
+	"github.com/transcriptaze/midiasm/ops/notes"
+)
+
+const LOG_TAG = "chords"
+
+type Resolution int
+
+const (
+	Beat Resolution = iota
+	Bar
+)
+
+// Chords groups the notes of a MIDI file into chords per beat or per bar and names them,
+// spelling the chords according to the key in effect (from the KeySignature events or,
+// if there are none, the estimated key of the piece).
+type Chords struct {
+	Resolution Resolution
+}
+
+type Chord struct {
+	Bar       int
+	Beat      int
+	Tick      uint64
+	Name      string
+	Root      string
+	Quality   string
+	Bass      string
+	Inversion int
+	Notes     []string
+}
+
+type quality struct {
+	name      string
+	intervals []int
+}
+
+// Chord qualities in order of preference for chords that match equally well.
+var qualities = []quality{
+	{"", []int{0, 4, 7}},
+	{"m", []int{0, 3, 7}},
+	{"7", []int{0, 4, 7, 10}},
+	{"maj7", []int{0, 4, 7, 11}},
+	{"m7", []int{0, 3, 7, 10}},
+	{"dim", []int{0, 3, 6}},
+	{"aug", []int{0, 4, 8}},
+	{"sus4", []int{0, 5, 7}},
+	{"sus2", []int{0, 2, 7}},
+	{"6", []int{0, 4, 7, 9}},
+	{"m6", []int{0, 3, 7, 9}},
+	{"m7♭5", []int{0, 3, 6, 10}},
+	{"dim7", []int{0, 3, 6, 9}},
+	{"m(maj7)", []int{0, 3, 7, 11}},
+	{"7sus4", []int{0, 5, 7, 10}},
+	{"add9", []int{0, 4, 7, 2}},
+	{"9", []int{0, 4, 7, 10, 2}},
+	{"maj9", []int{0, 4, 7, 11, 2}},
+	{"m9", []int{0, 3, 7, 10, 2}},
+	{"11", []int{0, 4, 7, 10, 2, 5}},
+	{"13", []int{0, 4, 7, 10, 2, 9}},
+	{"5", []int{0, 7}},
+}
+
+const percussion = lib.Channel(9)
+
+func ParseResolution(s string) (Resolution, error) {
+	switch strings.ToLower(s) {
+	case "beat":
+		return Beat, nil
+
+	case "bar":
+		return Bar, nil
+
+	default:
+		return Beat, fmt.Errorf("invalid chord resolution (%v): expected 'beat' or 'bar'", s)
+	}
+}
+
+func (c Chords) Analyse(smf *midi.SMF) ([]Chord, error) {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := notes.Extract(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := keyMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	end := uint64(0)
+	for _, n := range list {
+		if n.EndTick > end {
+			end = n.EndTick
+		}
+	}
+
+	// ... segments
+	type segment struct {
+		bar   int
+		beat  int
+		start uint64
+		end   uint64
+	}
+
+	segments := []segment{}
+	for _, b := range tempoMap.Beats(end) {
+		if c.Resolution == Bar && b.Beat != 1 && len(segments) > 0 {
+			continue
+		}
+
+		if N := len(segments); N > 0 {
+			segments[N-1].end = b.Tick
+		}
+
+		segments = append(segments, segment{bar: b.Bar, beat: b.Beat, start: b.Tick, end: end})
+	}
+
+	// ... name the chord for each segment
+	chords := []Chord{}
+	for _, s := range segments {
+		weights := [12]uint64{}
+		bass := -1
+
+		for _, n := range list {
+			if n.Channel != percussion && n.StartTick < s.end && n.EndTick > s.start {
+				start := max(n.StartTick, s.start)
+				end := min(n.EndTick, s.end)
+
+				weights[n.Note%12] += end - start
+			}
+		}
+
+		threshold := (s.end - s.start) / 8
+		present := map[int]bool{}
+		for pc, w := range weights {
+			if w > 0 && w >= threshold {
+				present[pc] = true
+			}
+		}
+
+		for _, n := range list {
+			if n.Channel != percussion && n.StartTick < s.end && n.EndTick > s.start && present[int(n.Note%12)] {
+				if bass < 0 || int(n.Note) < bass {
+					bass = int(n.Note)
+				}
+			}
+		}
+
+		if bass < 0 {
+			continue
+		}
+
+		if chord, ok := name(present, bass%12, keys.at(s.start)); ok {
+			chord.Bar = s.bar
+			chord.Beat = s.beat
+			chord.Tick = s.start
+
+			if N := len(chords); N == 0 || chords[N-1].Name != chord.Name {
+				debugf("%v:%v  %v", chord.Bar, chord.Beat, chord.Name)
+				chords = append(chords, chord)
+			}
+		}
+	}
+
+	return chords, nil
+}
+
+// Insert adds the chord names to track 0 as Text or Marker events and returns the encoded
+// MIDI file.
+func (c Chords) Insert(smf *midi.SMF, chords []Chord, tag lib.Tag) ([]byte, error) {
+	if len(smf.Tracks) == 0 {
+		return nil, fmt.Errorf("missing track 0")
+	}
+
+	list := []events.IEvent{}
+	for _, chord := range chords {
+		switch tag {
+		case lib.TagMarker:
+			list = append(list, metaevent.MakeMarker(chord.Tick, 0, chord.Name))
+
+		case lib.TagText:
+			list = append(list, metaevent.MakeText(chord.Tick, 0, chord.Name))
+
+		default:
+			return nil, fmt.Errorf("invalid chord event (%v): expected Text or Marker", tag)
+		}
+	}
+
+	if err := smf.Tracks[0].Insert(list...); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+func Print(chords []Chord, w io.Writer) error {
+	for _, c := range chords {
+		position := fmt.Sprintf("%v:%v", c.Bar, c.Beat)
+
+		if _, err := fmt.Fprintf(w, "%-8v  %-12v  %v\n", position, c.Name, strings.Join(c.Notes, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Export(chords []Chord, w io.Writer) error {
+	type chord struct {
+		Bar       int      `json:"bar"`
+		Beat      int      `json:"beat"`
+		Tick      uint64   `json:"tick"`
+		Chord     string   `json:"chord"`
+		Root      string   `json:"root"`
+		Quality   string   `json:"quality"`
+		Bass      string   `json:"bass"`
+		Inversion int      `json:"inversion"`
+		Notes     []string `json:"notes"`
+	}
+
+	object := struct {
+		Chords []chord `json:"chords"`
+	}{
+		Chords: []chord{},
+	}
+
+	for _, c := range chords {
+		object.Chords = append(object.Chords, chord{
+			Bar:       c.Bar,
+			Beat:      c.Beat,
+			Tick:      c.Tick,
+			Chord:     c.Name,
+			Root:      c.Root,
+			Quality:   c.Quality,
+			Bass:      c.Bass,
+			Inversion: c.Inversion,
+			Notes:     c.Notes,
+		})
+	}
+
+	if bytes, err := json.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(bytes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// name identifies the chord formed by a set of pitch classes, preferring the chord with
+// the fewest missing and extra notes and then the chord with the bass note as the root.
+// The perfect fifth is treated as optional for chords with four or more notes.
+func name(present map[int]bool, bass int, scale lib.Scale) (Chord, bool) {
+	type candidate struct {
+		root    int
+		quality quality
+		extra   int
+		order   int
+	}
+
+	candidates := []candidate{}
+	for root := range present {
+		for order, q := range qualities {
+			missing := 0
+			matched := map[int]bool{}
+
+			for _, interval := range q.intervals {
+				pc := (root + interval) % 12
+				if present[pc] {
+					matched[pc] = true
+				} else if interval != 7 || len(q.intervals) < 4 {
+					missing++
+				}
+			}
+
+			if missing == 0 {
+				candidates = append(candidates, candidate{
+					root:    root,
+					quality: q,
+					extra:   len(present) - len(matched),
+					order:   order,
+				})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return Chord{}, false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		p := candidates[i]
+		q := candidates[j]
+
+		switch {
+		case p.extra != q.extra:
+			return p.extra < q.extra
+
+		case (p.root == bass) != (q.root == bass):
+			return p.root == bass
+
+		case p.order != q.order:
+			return p.order < q.order
+
+		default:
+			return p.root < q.root
+		}
+	})
+
+	best := candidates[0]
+	root := spell(best.root, scale)
+	chord := Chord{
+		Name:    root + best.quality.name,
+		Root:    root,
+		Quality: best.quality.name,
+		Bass:    spell(bass, scale),
+	}
+
+	for i, interval := range best.quality.intervals {
+		pc := (best.root + interval) % 12
+		if present[pc] {
+			chord.Notes = append(chord.Notes, spell(pc, scale))
+		}
+
+		if pc == bass && pc != best.root {
+			chord.Inversion = i
+		}
+	}
+
+	if bass != best.root {
+		chord.Name = fmt.Sprintf("%v/%v", chord.Name, chord.Bass)
+	}
+
+	return chord, true
+}
+
+// spell returns the note name of a pitch class, using the note from the scale if it is a
+// scale note and otherwise sharps or flats depending on the key signature.
+func spell(pc int, scale lib.Scale) string {
+	for _, n := range scale.Notes {
+		if n.Ord == pc {
+			return n.Name
+		}
+	}
+
+	sharps := []lib.Note{lib.C, lib.C_SHARP, lib.D, lib.D_SHARP, lib.E, lib.F, lib.F_SHARP, lib.G, lib.G_SHARP, lib.A, lib.A_SHARP, lib.B}
+	flats := []lib.Note{lib.C, lib.D_FLAT, lib.D, lib.E_FLAT, lib.E, lib.F, lib.G_FLAT, lib.G, lib.A_FLAT, lib.A, lib.B_FLAT, lib.B}
+
+	if scale.Accidentals < 0 {
+		return flats[pc].Name
+	}
+
+	return sharps[pc].Name
+}
+
+type keysig struct {
+	tick  uint64
+	scale lib.Scale
+}
+
+type keys []keysig
+
+func (k keys) at(tick uint64) lib.Scale {
+	scale := k[0].scale
+	for _, v := range k[1:] {
+		if v.tick > tick {
+			break
+		}
+		scale = v.scale
+	}
+
+	return scale
+}
+
+// keyMap builds the list of keys from the KeySignature events in the MIDI file, falling back
+// on the estimated key if the file does not have any KeySignature events.
+func keyMap(smf *midi.SMF) (keys, error) {
+	list := keys{}
+
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			if v, ok := e.Event.(metaevent.KeySignature); ok {
+				var scale lib.Scale
+				var ok bool
+
+				if v.KeyType == lib.Minor {
+					scale, ok = lib.MinorScale(v.Accidentals)
+				} else {
+					scale, ok = lib.MajorScale(v.Accidentals)
+				}
+
+				if ok {
+					list = append(list, keysig{e.Tick(), scale})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].tick < list[j].tick
+	})
+
+	if len(list) == 0 {
+		scale := lib.C_MAJOR
+
+		if estimates, err := (key.Key{}).Detect(smf); err != nil {
+			return nil, err
+		} else if len(estimates) > 0 {
+			scale = estimates[0].Scale
+		}
+
+		list = append(list, keysig{0, scale})
+	}
+
+	return list, nil
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
@@ -0,0 +1,247 @@
+package chords
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+func TestName(t *testing.T) {
+	tests := []struct {
+		notes     []int
+		bass      int
+		scale     lib.Scale
+		expected  string
+		inversion int
+		spelling  []string
+	}{
+		{[]int{0, 4, 7}, 0, lib.C_MAJOR, "C", 0, []string{"C", "E", "G"}},
+		{[]int{0, 4, 7}, 4, lib.C_MAJOR, "C/E", 1, []string{"C", "E", "G"}},
+		{[]int{9, 0, 4}, 9, lib.C_MAJOR, "Am", 0, []string{"A", "C", "E"}},
+		{[]int{7, 11, 2, 5}, 7, lib.C_MAJOR, "G7", 0, []string{"G", "B", "D", "F"}},
+		{[]int{7, 11, 5}, 7, lib.C_MAJOR, "G7", 0, []string{"G", "B", "F"}},
+		{[]int{0, 4, 7, 11}, 0, lib.C_MAJOR, "Cmaj7", 0, []string{"C", "E", "G", "B"}},
+		{[]int{11, 2, 5}, 11, lib.C_MAJOR, "Bdim", 0, []string{"B", "D", "F"}},
+		{[]int{10, 2, 5}, 10, lib.F_MAJOR, "B♭", 0, []string{"B♭", "D", "F"}},
+		{[]int{3, 7, 10, 1}, 3, lib.A_FLAT_MAJOR, "E♭7", 0, []string{"E♭", "G", "B♭", "D♭"}},
+		{[]int{6, 10, 1}, 1, lib.B_MAJOR, "F♯/C♯", 2, []string{"F♯", "A♯", "C♯"}},
+		{[]int{0, 4, 7, 10, 2}, 0, lib.F_MAJOR, "C9", 0, []string{"C", "E", "G", "B♭", "D"}},
+	}
+
+	for _, test := range tests {
+		present := map[int]bool{}
+		for _, pc := range test.notes {
+			present[pc] = true
+		}
+
+		if chord, ok := name(present, test.bass, test.scale); !ok {
+			t.Errorf("Failed to name chord %v", test.notes)
+		} else {
+			if chord.Name != test.expected {
+				t.Errorf("Incorrectly named chord %v - expected:%v, got:%v", test.notes, test.expected, chord.Name)
+			}
+
+			if chord.Inversion != test.inversion {
+				t.Errorf("Incorrect inversion for chord %v - expected:%v, got:%v", test.expected, test.inversion, chord.Inversion)
+			}
+
+			if !reflect.DeepEqual(chord.Notes, test.spelling) {
+				t.Errorf("Incorrectly spelled chord %v - expected:%v, got:%v", test.expected, test.spelling, chord.Notes)
+			}
+		}
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	tests := []struct {
+		resolution Resolution
+		expected   []string
+	}{
+		{Beat, []string{"1:1 0 C [C E G]", "1:3 960 Am [A C E]", "2:1 1920 G♭ [G♭ B♭ D♭]", "3:1 3840 F♯ [F♯ A♯ C♯]"}},
+		{Bar, []string{"1:1 0 Am7 [A C E G]", "2:1 1920 G♭ [G♭ B♭ D♭]", "3:1 3840 F♯ [F♯ A♯ C♯]"}},
+	}
+
+	for _, test := range tests {
+		smf := reference()
+
+		chords, err := Chords{Resolution: test.resolution}.Analyse(&smf)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		list := []string{}
+		for _, c := range chords {
+			list = append(list, fmt.Sprintf("%v:%v %v %v %v", c.Bar, c.Beat, c.Tick, c.Name, c.Notes))
+		}
+
+		if !reflect.DeepEqual(list, test.expected) {
+			t.Errorf("Incorrect chords for resolution %v\n   expected:%q\n   got:     %q", test.resolution, test.expected, list)
+		}
+	}
+}
+
+func TestInsert(t *testing.T) {
+	chords := []Chord{
+		{Tick: 0, Name: "C"},
+		{Tick: 960, Name: "Am"},
+		{Tick: 1920, Name: "G♭"},
+	}
+
+	tests := []struct {
+		tag      lib.Tag
+		expected []string
+	}{
+		{lib.TagText, []string{"0 Text C", "960 Text Am", "1920 Text G♭"}},
+		{lib.TagMarker, []string{"0 Marker C", "960 Marker Am", "1920 Marker G♭"}},
+	}
+
+	for _, test := range tests {
+		smf := reference()
+
+		encoded, err := Chords{}.Insert(&smf, chords, test.tag)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		decoded, err := midifile.NewDecoder().Decode(bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("error decoding MIDI file (%v)", err)
+		}
+
+		list := []string{}
+		for _, e := range decoded.Tracks[0].Events {
+			switch v := e.Event.(type) {
+			case metaevent.Text:
+				list = append(list, fmt.Sprintf("%v %v %v", e.Tick(), e.Event.Tag(), v.Text))
+
+			case metaevent.Marker:
+				list = append(list, fmt.Sprintf("%v %v %v", e.Tick(), e.Event.Tag(), v.Marker))
+			}
+		}
+
+		if !reflect.DeepEqual(list, test.expected) {
+			t.Errorf("Incorrect %v events\n   expected:%q\n   got:     %q", test.tag, test.expected, list)
+		}
+
+		if N := len(decoded.Tracks[1].Events); N != len(smf.Tracks[1].Events) {
+			t.Errorf("Incorrect number of track 1 events - expected:%v, got:%v", len(smf.Tracks[1].Events), N)
+		}
+	}
+
+	smf := reference()
+	if _, err := (Chords{}).Insert(&smf, chords, lib.TagLyric); err == nil {
+		t.Errorf("Expected error inserting chords as %v events", lib.TagLyric)
+	}
+}
+
+func TestExport(t *testing.T) {
+	chords := []Chord{
+		{Bar: 1, Beat: 1, Tick: 0, Name: "C/E", Root: "C", Quality: "", Bass: "E", Inversion: 1, Notes: []string{"C", "E", "G"}},
+		{Bar: 2, Beat: 3, Tick: 2880, Name: "B♭7", Root: "B♭", Quality: "7", Bass: "B♭", Inversion: 0, Notes: []string{"B♭", "D", "F", "A♭"}},
+	}
+
+	expected := `{
+  "chords": [
+    {
+      "bar": 1,
+      "beat": 1,
+      "tick": 0,
+      "chord": "C/E",
+      "root": "C",
+      "quality": "",
+      "bass": "E",
+      "inversion": 1,
+      "notes": [
+        "C",
+        "E",
+        "G"
+      ]
+    },
+    {
+      "bar": 2,
+      "beat": 3,
+      "tick": 2880,
+      "chord": "B♭7",
+      "root": "B♭",
+      "quality": "7",
+      "bass": "B♭",
+      "inversion": 0,
+      "notes": [
+        "B♭",
+        "D",
+        "F",
+        "A♭"
+      ]
+    }
+  ]
+}`
+
+	var b bytes.Buffer
+	if err := Export(chords, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrectly exported chords\n   expected:%v\n   got:     %v", expected, b.String())
+	}
+}
+
+// reference returns a 3 bar MIDI file in 4/4 with C, Am, G♭ and F♯ chords, changing key
+// from C major to D♭ major at bar 2 and to B major at bar 3.
+func reference() midi.SMF {
+	on := func(tick uint64, delta uint32, value byte) *events.Event {
+		return &events.Event{Event: midievent.MakeNoteOn(tick, delta, 0, midievent.Note{Value: value}, 64)}
+	}
+
+	off := func(tick uint64, delta uint32, value byte) *events.Event {
+		return &events.Event{Event: midievent.MakeNoteOff(tick, delta, 0, midievent.Note{Value: value}, 64)}
+	}
+
+	track := midi.MTrk{
+		Tag:         "MTrk",
+		TrackNumber: 1,
+		Events: []*events.Event{
+			on(0, 0, 48), // C3
+			on(0, 0, 52), // E3
+			on(0, 0, 55), // G3
+			off(960, 960, 55),
+			on(960, 0, 45), // A2
+			off(1920, 960, 48),
+			off(1920, 0, 52),
+			off(1920, 0, 45),
+			on(1920, 0, 54), // G♭3/F♯3
+			on(1920, 0, 58), // B♭3/A♯3
+			on(1920, 0, 61), // D♭4/C♯4
+			off(5760, 3840, 54),
+			off(5760, 0, 58),
+			off(5760, 0, 61),
+			&events.Event{Event: metaevent.MakeEndOfTrack(5760, 0)},
+		},
+	}
+
+	mthd := midi.MakeMThd(1, 2, 480)
+
+	return midi.SMF{
+		MThd: &mthd,
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Tag: "MTrk",
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTempo(0, 0, 500000)},
+					&events.Event{Event: metaevent.MakeTimeSignature(0, 0, 4, 4, 24, 8)},
+					&events.Event{Event: metaevent.MakeKeySignature(0, 0, 0, lib.Major)},
+					&events.Event{Event: metaevent.MakeKeySignature(1920, 1920, -5, lib.Major)},
+					&events.Event{Event: metaevent.MakeKeySignature(3840, 1920, 5, lib.Major)},
+					&events.Event{Event: metaevent.MakeEndOfTrack(5760, 1920)},
+				},
+			},
+			&track,
+		},
+	}
+}
@@ -1,183 +1,221 @@
 package click
 
 import (
+	"bytes"
 	"fmt"
 	"io"
-	"math"
-	"sort"
+	"strings"
+	"time"
 
+	"github.com/transcriptaze/midiasm/encoding/midi"
 	"github.com/transcriptaze/midiasm/log"
 	"github.com/transcriptaze/midiasm/midi"
 	"github.com/transcriptaze/midiasm/midi/events"
 	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/midi/timing"
 )
 
 const LOG_TAG = "click"
 
+// ClickTrack computes the metronome clicks for a MIDI file from the Tempo and TimeSignature
+// events and generates a General MIDI percussion click track. The downbeat of each bar is
+// played with the Accent note and velocity and the other clicks with Note and Velocity.
 type ClickTrack struct {
-	Writer io.Writer
+	Accent         byte
+	Note           byte
+	AccentVelocity byte
+	Velocity       byte
+	CountIn        int
 }
 
-type Cluck struct {
-	Bar           uint
-	Tempo         uint
-	TimeSignature string
+// Click is a single metronome click. The clicks in a bar are determined by the TimeSignature
+// 'MIDI clocks per click' e.g. a 6/8 bar with 36 clocks per click has two clicks.
+type Click struct {
+	Bar         int
+	Beat        int
+	Tick        uint64
+	At          time.Duration
+	Tempo       uint32
+	Numerator   uint8
+	Denominator uint8
+	Clocks      uint8
+	Accent      bool
 }
 
-type Span struct {
-	Start         uint
-	End           uint
-	Tempo         uint
-	TimeSignature string
-}
+// GM percussion channel.
+const channel lib.Channel = 9
 
-type signature struct {
-	numerator   uint8
-	denominator uint8
+func NewClickTrack() (*ClickTrack, error) {
+	return &ClickTrack{
+		Accent:         76,
+		Note:           77,
+		AccentVelocity: 127,
+		Velocity:       100,
+	}, nil
 }
 
-func (x *ClickTrack) Execute(smf *midi.SMF) error {
-	clucks := map[uint]Cluck{}
-	ppqn := uint64(smf.MThd.Division)
-	tempoMap := make([]*events.Event, 0)
+// Clicks returns the metronome clicks from the start of the MIDI file up to the end of the
+// last track.
+func (c ClickTrack) Clicks(smf *midi.SMF) ([]Click, error) {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	end := uint64(0)
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			end = max(end, e.Tick())
+		}
+	}
 
-	for _, e := range smf.Tracks[0].Events {
-		if _, ok := e.Event.(*metaevent.Tempo); ok {
-			tempoMap = append(tempoMap, e)
+	clicks := []Click{}
+
+	for bar := 1; tempoMap.Bar(bar) < end; bar++ {
+		start := tempoMap.Bar(bar)
+		next := min(end, tempoMap.Bar(bar+1))
+		length := tempoMap.ClickLength(start)
+		numerator, denominator := tempoMap.TimeSignature(start)
+		clocks := uint8(length * 24 / uint64(tempoMap.PPQN))
+
+		for beat, tick := 1, start; tick < next; beat, tick = beat+1, tick+length {
+			clicks = append(clicks, Click{
+				Bar:         bar,
+				Beat:        beat,
+				Tick:        tick,
+				At:          tempoMap.Time(tick),
+				Tempo:       tempoMap.Tempo(tick),
+				Numerator:   numerator,
+				Denominator: denominator,
+				Clocks:      clocks,
+				Accent:      beat == 1,
+			})
 		}
+
+		debugf("bar %-4v  clicks:%v  time-signature:%v/%v", bar, (next-start+length-1)/length, numerator, denominator)
+	}
+
+	return clicks, nil
+}
+
+// Insert adds a click track (preceded by CountIn bars of clicks) to the MIDI file and returns
+// the encoded MIDI file. For a count-in the notes and any events after the start of the file
+// are delayed by the count-in bars.
+func (c ClickTrack) Insert(smf *midi.SMF) ([]byte, error) {
+	if c.CountIn < 0 {
+		return nil, fmt.Errorf("invalid count-in (%v): expected a value greater than or equal to 0", c.CountIn)
 	}
 
-	for _, track := range smf.Tracks[1:] {
-		eventlist := make(map[uint64][]*events.Event, 0)
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, e := range tempoMap {
-			tick := e.Tick()
-			list := eventlist[tick]
-			eventlist[tick] = append(list, e)
+	if offset := uint64(c.CountIn) * tempoMap.BarLength(0); offset > 0 {
+		if err := shift(smf, offset); err != nil {
+			return nil, err
 		}
+	}
+
+	clicks, err := c.Clicks(smf)
+	if err != nil {
+		return nil, err
+	}
 
+	end := uint64(0)
+	for _, track := range smf.Tracks {
 		for _, e := range track.Events {
-			if _, ok := e.Event.(*metaevent.TimeSignature); ok {
-				tick := e.Tick()
-				list := eventlist[tick]
-				eventlist[tick] = append(list, e)
-			}
+			end = max(end, e.Tick())
+		}
+	}
 
-			if _, ok := e.Event.(*metaevent.EndOfTrack); ok {
-				tick := e.Tick()
-				list := eventlist[tick]
-				eventlist[tick] = append(list, e)
-			}
+	list := []events.IEvent{metaevent.MakeTrackName(0, 0, "Click")}
+	for i, click := range clicks {
+		note, velocity := c.Note, c.Velocity
+		if click.Accent {
+			note, velocity = c.Accent, c.AccentVelocity
 		}
 
-		var ticks []uint64
-		for tick, _ := range eventlist {
-			ticks = append(ticks, tick)
+		length := uint64(click.Clocks) * uint64(smf.MThd.PPQN) / 24
+		if i+1 < len(clicks) {
+			length = clicks[i+1].Tick - click.Tick
 		}
 
-		sort.SliceStable(ticks, func(i, j int) bool {
-			return ticks[i] < ticks[j]
-		})
+		n := midievent.Note{Value: note}
+		list = append(list, midievent.MakeNoteOn(click.Tick, 0, channel, n, velocity))
+		list = append(list, midievent.MakeNoteOff(click.Tick+max(1, length/4), 0, channel, n, 64))
+	}
 
-		var tempo uint = 120
-		var beat float64 = 0.0
-		var bar uint = 1
-		var last float64 = 0.0
-		var timeSignature *metaevent.TimeSignature
+	track, err := midi.NewMTrk()
+	if err != nil {
+		return nil, err
+	}
 
-		for _, tick := range ticks {
-			beat = float64(tick) / float64(ppqn)
-			if timeSignature != nil {
-				bar += uint((beat - last) / float64(timeSignature.Numerator))
-			}
-			last = beat
-
-			list := eventlist[tick]
-			for _, e := range list {
-				event := e.Event
-
-				// ... tempo changes
-				if v, ok := event.(*metaevent.Tempo); ok {
-					tempo = uint(math.Round(60.0 * 1000000.0 / float64(v.Tempo)))
-
-					debugf("%-14v  %-5v bar:%-3d", "TEMPO", v, bar)
-
-					cluck := clucks[bar]
-					cluck.Bar = bar
-					cluck.Tempo = tempo
-					clucks[bar] = cluck
-				}
-
-				// ... time signature changes
-				if v, ok := event.(*metaevent.TimeSignature); ok {
-					debugf("%-14v  %-5v bar:%-3d", "TIME SIGNATURE", v, bar)
-
-					cluck := clucks[bar]
-					cluck.Bar = bar
-					cluck.Tempo = tempo
-					cluck.TimeSignature = fmt.Sprintf("%v", v)
-					clucks[bar] = cluck
-
-					timeSignature = v
-				}
-
-				if _, ok := event.(*metaevent.EndOfTrack); ok {
-					debugf("%-14v  %-5v bar:%-3d", "END OF TRACK", "", bar)
-
-					cluck := clucks[bar]
-					cluck.Bar = bar - 1 // because end of track as after the last bar ????
-					cluck.Tempo = tempo
-					cluck.TimeSignature = fmt.Sprintf("%v", timeSignature)
-					clucks[bar] = cluck
-				}
-			}
-		}
+	track.TrackNumber = lib.TrackNumber(len(smf.Tracks))
+	track.Events = append(track.Events, events.NewEvent(metaevent.MakeEndOfTrack(end, 0)))
 
-		// Only process Track 1 for now
-		break
+	if err := track.Insert(list...); err != nil {
+		return nil, err
 	}
 
-	list := []Cluck{}
-	for _, v := range clucks {
-		list = append(list, v)
+	smf.Tracks = append(smf.Tracks, track)
+	smf.MThd.Tracks = uint16(len(smf.Tracks))
+	if smf.MThd.Format == 0 {
+		smf.MThd.Format = 1
 	}
 
-	sort.SliceStable(list, func(i, j int) bool {
-		return list[i].Bar < list[j].Bar
-	})
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
 
-	fmt.Println()
-	for _, v := range list {
-		fmt.Fprintf(x.Writer, "bar %-4v  tempo:%-3v  time-signature %v\n", v.Bar, v.Tempo, v.TimeSignature)
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
 	}
+}
 
-	spans := []Span{}
-	if len(list) > 0 {
-		span := Span{
-			Start:         list[0].Bar,
-			End:           list[0].Bar,
-			Tempo:         list[0].Tempo,
-			TimeSignature: list[0].TimeSignature,
-		}
-
-		for _, v := range list[1:] {
-			span.End = v.Bar - 1
-			spans = append(spans, span)
+// shift delays the events in a MIDI file by offset ticks, leaving the setup events (i.e. the
+// events other than notes) at the start of the file in place.
+func shift(smf *midi.SMF, offset uint64) error {
+	for _, track := range smf.Tracks {
+		tick := uint64(0)
+		for i, e := range track.Events {
+			t := e.Tick()
+			if t > 0 || events.Is[midievent.NoteOn](*e) || events.Is[midievent.NoteOff](*e) {
+				t += offset
+			}
 
-			span = Span{
-				Start:         v.Bar,
-				End:           v.Bar,
-				Tempo:         v.Tempo,
-				TimeSignature: v.TimeSignature,
+			if v, err := events.Retime(e.Event, t, uint32(t-tick)); err != nil {
+				return err
+			} else {
+				track.Events[i] = events.NewEvent(v)
 			}
+
+			tick = t
 		}
 	}
 
-	fmt.Println()
-	for _, v := range spans {
-		fmt.Fprintf(x.Writer, "bars %-7v  tempo:%-3v  time-signature %v\n", fmt.Sprintf("%v:%v", v.Start, v.End), v.Tempo, v.TimeSignature)
+	return nil
+}
+
+func Print(clicks []Click, w io.Writer) error {
+	for _, c := range clicks {
+		accent := ""
+		if c.Accent {
+			accent = "*"
+		}
+
+		bpm := 60.0 * 1000000.0 / float64(c.Tempo)
+		signature := fmt.Sprintf("%v/%v", c.Numerator, c.Denominator)
+
+		line := fmt.Sprintf("bar %-4v beat %-2v  tick:%-7v  time:%-9.3f  tempo:%-6.2f  time-signature:%-5v %v", c.Bar, c.Beat, c.Tick, c.At.Seconds(), bpm, signature, accent)
+
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
 	}
-	fmt.Println()
 
 	return nil
 }
@@ -185,7 +223,3 @@ func (x *ClickTrack) Execute(smf *midi.SMF) error {
 func debugf(format string, args ...any) {
 	log.Debugf(LOG_TAG, format, args...)
 }
-
-func warnf(format string, args ...any) {
-	log.Warnf(LOG_TAG, format, args...)
-}
@@ -0,0 +1,113 @@
+package click
+
+import (
+	"bytes"
+	_ "embed"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+)
+
+// reference is one bar of 2/4 at 120 BPM followed by a bar of 6/8 (with dotted quarter note
+// clicks) at 60 BPM.
+//
+//go:embed test-files/reference.mid
+var reference []byte
+
+func TestClicks(t *testing.T) {
+	op, _ := NewClickTrack()
+
+	expected := []Click{
+		{Bar: 1, Beat: 1, Tick: 0, At: 0, Tempo: 500000, Numerator: 2, Denominator: 4, Clocks: 24, Accent: true},
+		{Bar: 1, Beat: 2, Tick: 480, At: 500 * time.Millisecond, Tempo: 500000, Numerator: 2, Denominator: 4, Clocks: 24},
+		{Bar: 2, Beat: 1, Tick: 960, At: 1 * time.Second, Tempo: 1000000, Numerator: 6, Denominator: 8, Clocks: 36, Accent: true},
+		{Bar: 2, Beat: 2, Tick: 1680, At: 2500 * time.Millisecond, Tempo: 1000000, Numerator: 6, Denominator: 8, Clocks: 36},
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	clicks, err := op.Clicks(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !reflect.DeepEqual(clicks, expected) {
+		t.Errorf("Incorrect clicks\n   expected:%+v\n   got:     %+v", expected, clicks)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	op, _ := NewClickTrack()
+	op.CountIn = 1
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	encoded, err := op.Insert(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	decoded, err := midifile.NewDecoder().Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(decoded.Tracks) != 3 || decoded.MThd.Tracks != 3 {
+		t.Fatalf("Incorrect number of tracks - expected:%v, got:%v", 3, len(decoded.Tracks))
+	}
+
+	// ... count-in delays the notes by one 2/4 bar but not the setup events
+	for _, e := range decoded.Tracks[1].Events {
+		switch v := e.Event.(type) {
+		case midievent.ProgramChange:
+			if e.Tick() != 0 {
+				t.Errorf("Incorrect ProgramChange tick - expected:%v, got:%v", 0, e.Tick())
+			}
+		case midievent.NoteOn:
+			if e.Tick() != 960 {
+				t.Errorf("Incorrect NoteOn tick - expected:%v, got:%v", 960, e.Tick())
+			}
+		case metaevent.EndOfTrack:
+			if e.Tick() != 3360 {
+				t.Errorf("Incorrect EndOfTrack tick - expected:%v, got:%v (%v)", 3360, e.Tick(), v)
+			}
+		}
+	}
+
+	type note struct {
+		tick     uint64
+		note     byte
+		velocity byte
+	}
+
+	expected := []note{
+		{0, 76, 127}, {480, 77, 100},
+		{960, 76, 127}, {1440, 77, 100},
+		{1920, 76, 127}, {2640, 77, 100},
+	}
+
+	notes := []note{}
+	for _, e := range decoded.Tracks[2].Events {
+		if v, ok := e.Event.(midievent.NoteOn); ok {
+			if v.Channel != 9 {
+				t.Errorf("Incorrect click track channel - expected:%v, got:%v", 9, v.Channel)
+			}
+
+			notes = append(notes, note{e.Tick(), v.Note.Value, v.Velocity})
+		}
+	}
+
+	if !reflect.DeepEqual(notes, expected) {
+		t.Errorf("Incorrect click track\n   expected:%v\n   got:     %v", expected, notes)
+	}
+}
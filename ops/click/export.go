@@ -0,0 +1,143 @@
+package click
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	Text Format = iota
+	Audacity
+	Reaper
+	JSON
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "text":
+		return Text, nil
+
+	case "audacity":
+		return Audacity, nil
+
+	case "reaper":
+		return Reaper, nil
+
+	case "json":
+		return JSON, nil
+
+	default:
+		return Text, fmt.Errorf("invalid format (%v): expected 'text', 'audacity', 'reaper' or 'json'", s)
+	}
+}
+
+// Extension returns the default file extension for the format.
+func (f Format) Extension() string {
+	switch f {
+	case Audacity:
+		return ".txt"
+	case Reaper:
+		return ".csv"
+	case JSON:
+		return ".json"
+	default:
+		return ".click"
+	}
+}
+
+// Labels writes the clicks as an Audacity label track, with a point label for each beat
+// e.g. '2.1' for the first beat of bar 2.
+func Labels(clicks []Click, w io.Writer) error {
+	for _, c := range clicks {
+		t := c.At.Seconds()
+		if _, err := fmt.Fprintf(w, "%.6f\t%.6f\t%v.%v\n", t, t, c.Bar, c.Beat); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Markers writes the clicks as a Reaper marker list CSV file, with a marker for each beat.
+func Markers(clicks []Click, w io.Writer) error {
+	records := [][]string{
+		{"#", "Name", "Start", "End", "Length"},
+	}
+
+	for i, c := range clicks {
+		records = append(records, []string{
+			fmt.Sprintf("M%v", i+1),
+			fmt.Sprintf("%v.%v", c.Bar, c.Beat),
+			reaperTime(c.At),
+			"",
+			"",
+		})
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// Export writes the clicks as a JSON beat grid.
+func Export(clicks []Click, w io.Writer) error {
+	type beat struct {
+		Bar           int     `json:"bar"`
+		Beat          int     `json:"beat"`
+		Tick          uint64  `json:"tick"`
+		Seconds       float64 `json:"seconds"`
+		Tempo         float64 `json:"tempo"`
+		TimeSignature string  `json:"time-signature"`
+		Accent        bool    `json:"accent"`
+	}
+
+	object := struct {
+		Beats []beat `json:"beats"`
+	}{
+		Beats: []beat{},
+	}
+
+	for _, c := range clicks {
+		object.Beats = append(object.Beats, beat{
+			Bar:           c.Bar,
+			Beat:          c.Beat,
+			Tick:          c.Tick,
+			Seconds:       math.Round(c.At.Seconds()*1000000) / 1000000,
+			Tempo:         math.Round(60*1000000*1000/float64(c.Tempo)) / 1000,
+			TimeSignature: fmt.Sprintf("%v/%v", c.Numerator, c.Denominator),
+			Accent:        c.Accent,
+		})
+	}
+
+	if bytes, err := json.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(bytes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reaperTime formats a time in the Reaper minutes:seconds format e.g. 1:05.250.
+func reaperTime(t time.Duration) string {
+	ms := t.Milliseconds()
+	h := ms / 3600000
+	m := (ms / 60000) % 60
+	s := float64(ms%60000) / 1000
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%06.3f", h, m, s)
+	}
+
+	return fmt.Sprintf("%d:%06.3f", m, s)
+}
@@ -0,0 +1,113 @@
+package click
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+func TestLabels(t *testing.T) {
+	var b bytes.Buffer
+
+	op, _ := NewClickTrack()
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	clicks, err := op.Clicks(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "0.000000\t0.000000\t1.1\n" +
+		"0.500000\t0.500000\t1.2\n" +
+		"1.000000\t1.000000\t2.1\n" +
+		"2.500000\t2.500000\t2.2\n"
+
+	if err := Labels(clicks, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect Audacity labels\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestMarkers(t *testing.T) {
+	var b bytes.Buffer
+
+	op, _ := NewClickTrack()
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	clicks, err := op.Clicks(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "#,Name,Start,End,Length\n" +
+		"M1,1.1,0:00.000,,\n" +
+		"M2,1.2,0:00.500,,\n" +
+		"M3,2.1,0:01.000,,\n" +
+		"M4,2.2,0:02.500,,\n"
+
+	if err := Markers(clicks, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect Reaper markers\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	var b bytes.Buffer
+
+	op, _ := NewClickTrack()
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	clicks, err := op.Clicks(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if err := Export(clicks, &b); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := `    {
+      "bar": 2,
+      "beat": 2,
+      "tick": 1680,
+      "seconds": 2.5,
+      "tempo": 60,
+      "time-signature": "6/8",
+      "accent": false
+    }`
+
+	if !strings.Contains(b.String(), expected) {
+		t.Errorf("Incorrect JSON beat grid\n   expected:%v\n   got:     %v", expected, b.String())
+	}
+}
+
+func TestReaperTime(t *testing.T) {
+	tests := []struct {
+		t        time.Duration
+		expected string
+	}{
+		{0, "0:00.000"},
+		{65250 * time.Millisecond, "1:05.250"},
+		{3723500 * time.Millisecond, "1:02:03.500"},
+	}
+
+	for _, test := range tests {
+		if s := reaperTime(test.t); s != test.expected {
+			t.Errorf("Incorrect Reaper time for %v - expected:%v, got:%v", test.t, test.expected, s)
+		}
+	}
+}
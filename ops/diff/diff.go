@@ -0,0 +1,643 @@
+package diff
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/log"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/ops/disassemble"
+	"github.com/transcriptaze/midiasm/ops/notes"
+)
+
+const LOG_TAG = "diff"
+
+type Align int
+
+const (
+	ByIndex Align = iota
+	ByName
+)
+
+// Diff compares two MIDI files semantically, aligning the tracks by index or by name and the
+// events in each track by time and content.
+type Diff struct {
+	Align          Align
+	IgnoreDeltas   bool
+	IgnoreTicks    bool
+	IgnoreVelocity bool
+	Notes          bool
+}
+
+type Result struct {
+	Header []Field
+	Tracks []Track
+}
+
+type Field struct {
+	Field string
+	A     any
+	B     any
+}
+
+// Track is the difference between a pair of aligned tracks. A track that is only in one of the
+// files has a nil track number for the other file.
+type Track struct {
+	A       *lib.TrackNumber
+	B       *lib.TrackNumber
+	Name    string
+	Added   []Item
+	Removed []Item
+	Changed []Change
+}
+
+// Item is either an event or a note.
+type Item struct {
+	Event events.IEvent
+	Note  *notes.Note
+}
+
+type Change struct {
+	From Item
+	To   Item
+}
+
+func ParseAlign(s string) (Align, error) {
+	switch strings.ToLower(s) {
+	case "index":
+		return ByIndex, nil
+
+	case "name":
+		return ByName, nil
+
+	default:
+		return ByIndex, fmt.Errorf("invalid track alignment (%v): expected 'index' or 'name'", s)
+	}
+}
+
+func (r Result) Equal() bool {
+	if len(r.Header) > 0 {
+		return false
+	}
+
+	for _, t := range r.Tracks {
+		if !t.Equal() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t Track) Equal() bool {
+	return t.A != nil && t.B != nil && len(t.Added) == 0 && len(t.Removed) == 0 && len(t.Changed) == 0
+}
+
+func (d Diff) Compare(a, b *midi.SMF) (Result, error) {
+	result := Result{
+		Header: []Field{},
+		Tracks: []Track{},
+	}
+
+	// ... header
+	if a.MThd.Format != b.MThd.Format {
+		result.Header = append(result.Header, Field{"format", a.MThd.Format, b.MThd.Format})
+	}
+
+	if a.MThd.Division != b.MThd.Division {
+		result.Header = append(result.Header, Field{"division", a.MThd.Division, b.MThd.Division})
+	}
+
+	// ... tracks
+	var p, q map[lib.TrackNumber][]notes.Note
+	if d.Notes {
+		var err error
+		if p, err = extract(a); err != nil {
+			return result, err
+		} else if q, err = extract(b); err != nil {
+			return result, err
+		}
+	}
+
+	for _, pair := range d.align(a, b) {
+		track := Track{
+			Name:    pair.name(),
+			Added:   []Item{},
+			Removed: []Item{},
+			Changed: []Change{},
+		}
+
+		var u, v []*events.Event
+		if pair.a != nil {
+			track.A = &pair.a.TrackNumber
+			u = pair.a.Events
+		}
+
+		if pair.b != nil {
+			track.B = &pair.b.TrackNumber
+			v = pair.b.Events
+		}
+
+		if d.Notes {
+			var x, y []notes.Note
+			if track.A != nil {
+				x = p[*track.A]
+			}
+
+			if track.B != nil {
+				y = q[*track.B]
+			}
+
+			d.compareNotes(&track, x, y)
+		} else if err := d.compareEvents(&track, u, v); err != nil {
+			return result, err
+		}
+
+		debugf("track %v: %v added, %v removed, %v changed", track.Name, len(track.Added), len(track.Removed), len(track.Changed))
+
+		result.Tracks = append(result.Tracks, track)
+	}
+
+	return result, nil
+}
+
+type pair struct {
+	a *midi.MTrk
+	b *midi.MTrk
+}
+
+func (p pair) name() string {
+	if p.a != nil {
+		return trackname(p.a)
+	} else if p.b != nil {
+		return trackname(p.b)
+	}
+
+	return ""
+}
+
+// align matches the tracks in the two files either by index or by the first TrackName event
+// in each track (in order of appearance for tracks with the same name).
+func (d Diff) align(a, b *midi.SMF) []pair {
+	pairs := []pair{}
+
+	switch d.Align {
+	case ByName:
+		used := make([]bool, len(b.Tracks))
+		for _, t := range a.Tracks {
+			p := pair{a: t}
+			for j, u := range b.Tracks {
+				if !used[j] && trackname(u) == trackname(t) {
+					p.b = u
+					used[j] = true
+					break
+				}
+			}
+
+			pairs = append(pairs, p)
+		}
+
+		for j, u := range b.Tracks {
+			if !used[j] {
+				pairs = append(pairs, pair{b: u})
+			}
+		}
+
+	default:
+		for i := 0; i < max(len(a.Tracks), len(b.Tracks)); i++ {
+			p := pair{}
+			if i < len(a.Tracks) {
+				p.a = a.Tracks[i]
+			}
+
+			if i < len(b.Tracks) {
+				p.b = b.Tracks[i]
+			}
+
+			pairs = append(pairs, p)
+		}
+	}
+
+	return pairs
+}
+
+func (d Diff) compareEvents(track *Track, u, v []*events.Event) error {
+	x, err := d.keys(u)
+	if err != nil {
+		return err
+	}
+
+	y, err := d.keys(v)
+	if err != nil {
+		return err
+	}
+
+	for _, h := range hunks(x, y) {
+		removed := h.removed
+		added := []entry{}
+
+		for _, e := range h.added {
+			if i := d.match(removed, e); i < 0 {
+				added = append(added, e)
+			} else {
+				track.Changed = append(track.Changed, Change{From: Item{Event: removed[i].event}, To: Item{Event: e.event}})
+				removed = append(removed[:i:i], removed[i+1:]...)
+			}
+		}
+
+		for _, e := range removed {
+			track.Removed = append(track.Removed, Item{Event: e.event})
+		}
+
+		for _, e := range added {
+			track.Added = append(track.Added, Item{Event: e.event})
+		}
+	}
+
+	return nil
+}
+
+// match returns the index of a removed event that was changed into the added event, i.e. an
+// event for the same 'thing' (e.g. the same note on the same channel) at the same tick.
+func (d Diff) match(removed []entry, e entry) int {
+	for i, r := range removed {
+		if r.identity == e.identity && (d.IgnoreTicks || r.event.Tick() == e.event.Tick()) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+type entry struct {
+	key      string
+	identity string
+	event    events.IEvent
+}
+
+// keys builds the comparison key for each event from the encoded event with the delta removed
+// plus the tick and/or delta (unless ignored). Events at the same tick are sorted by key so
+// that reordering of simultaneous events is not reported as a difference.
+func (d Diff) keys(list []*events.Event) ([]entry, error) {
+	entries := []entry{}
+
+	for _, e := range list {
+		event := e.Event
+		if d.IgnoreVelocity {
+			switch v := event.(type) {
+			case midievent.NoteOn:
+				v.Velocity = 0
+				event = v
+
+			case midievent.NoteOff:
+				v.Velocity = 0
+				event = v
+			}
+		}
+
+		content, err := encode(event)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%X", content)
+		if !d.IgnoreDeltas {
+			key = fmt.Sprintf("%v:%v", e.Delta(), key)
+		}
+
+		if !d.IgnoreTicks {
+			key = fmt.Sprintf("%v:%v", e.Tick(), key)
+		}
+
+		entries = append(entries, entry{
+			key:      key,
+			identity: identity(e.Event),
+			event:    e.Event,
+		})
+	}
+
+	if !d.IgnoreTicks {
+		sort.SliceStable(entries, func(i, j int) bool {
+			p := entries[i]
+			q := entries[j]
+
+			if p.event.Tick() != q.event.Tick() {
+				return p.event.Tick() < q.event.Tick()
+			}
+
+			return p.key < q.key
+		})
+	}
+
+	return entries, nil
+}
+
+// compareNotes matches the notes by channel, note and start tick and reports notes with a
+// different duration (or velocity) as changed.
+func (d Diff) compareNotes(track *Track, x, y []notes.Note) {
+	key := func(n notes.Note) string {
+		if d.IgnoreTicks {
+			return fmt.Sprintf("%v:%v", n.Channel, n.Note)
+		}
+
+		return fmt.Sprintf("%v:%v:%v", n.Channel, n.Note, n.StartTick)
+	}
+
+	equal := func(p, q notes.Note) bool {
+		return p.EndTick-p.StartTick == q.EndTick-q.StartTick && (d.IgnoreVelocity || p.Velocity == q.Velocity)
+	}
+
+	removed := map[string][]notes.Note{}
+	for _, n := range x {
+		k := key(n)
+		removed[k] = append(removed[k], n)
+	}
+
+	for _, n := range y {
+		k := key(n)
+		list := removed[k]
+
+		if len(list) == 0 {
+			note := n
+			track.Added = append(track.Added, Item{Note: &note})
+			continue
+		}
+
+		matched := 0
+		for i, m := range list {
+			if equal(m, n) {
+				matched = i
+				break
+			}
+		}
+
+		from := list[matched]
+		to := n
+		removed[k] = append(list[:matched:matched], list[matched+1:]...)
+
+		if !equal(from, to) {
+			track.Changed = append(track.Changed, Change{From: Item{Note: &from}, To: Item{Note: &to}})
+		}
+	}
+
+	for _, n := range x {
+		k := key(n)
+		if list := removed[k]; len(list) > 0 {
+			note := list[0]
+			removed[k] = list[1:]
+			track.Removed = append(track.Removed, Item{Note: &note})
+		}
+	}
+
+	sort.SliceStable(track.Added, func(i, j int) bool { return track.Added[i].Note.StartTick < track.Added[j].Note.StartTick })
+	sort.SliceStable(track.Removed, func(i, j int) bool { return track.Removed[i].Note.StartTick < track.Removed[j].Note.StartTick })
+	sort.SliceStable(track.Changed, func(i, j int) bool { return track.Changed[i].To.Note.StartTick < track.Changed[j].To.Note.StartTick })
+}
+
+func Print(result Result, w io.Writer) error {
+	disassembler, err := disassemble.NewDisassemble()
+	if err != nil {
+		return err
+	}
+
+	describe := func(item Item) string {
+		if item.Note != nil {
+			n := item.Note
+			return fmt.Sprintf("tick:%-9v  channel:%-2v  note:%-4v  velocity:%-3v  duration:%v", n.StartTick, n.Channel, n.FormattedNote, n.Velocity, n.EndTick-n.StartTick)
+		}
+
+		var b bytes.Buffer
+		if err := disassembler.Print(item.Event, "events", &b); err != nil {
+			return fmt.Sprintf("%v", item.Event)
+		}
+
+		return fmt.Sprintf("tick:%-9v  delta:%-9v  %v", item.Event.Tick(), item.Event.Delta(), strings.TrimSpace(b.String()))
+	}
+
+	var b bytes.Buffer
+
+	for _, f := range result.Header {
+		fmt.Fprintf(&b, "MThd  %v: %v → %v\n", f.Field, f.A, f.B)
+	}
+
+	for _, t := range result.Tracks {
+		if t.Equal() {
+			continue
+		}
+
+		switch {
+		case t.A == nil:
+			fmt.Fprintf(&b, "+ track %v %q\n", *t.B, t.Name)
+
+		case t.B == nil:
+			fmt.Fprintf(&b, "- track %v %q\n", *t.A, t.Name)
+
+		case *t.A == *t.B:
+			fmt.Fprintf(&b, "track %v %q\n", *t.A, t.Name)
+
+		default:
+			fmt.Fprintf(&b, "track %v → %v %q\n", *t.A, *t.B, t.Name)
+		}
+
+		for _, item := range t.Removed {
+			fmt.Fprintf(&b, "  - %v\n", describe(item))
+		}
+
+		for _, item := range t.Added {
+			fmt.Fprintf(&b, "  + %v\n", describe(item))
+		}
+
+		for _, c := range t.Changed {
+			fmt.Fprintf(&b, "  ~ %v\n", describe(c.From))
+			fmt.Fprintf(&b, "    %v\n", describe(c.To))
+		}
+	}
+
+	_, err = w.Write(b.Bytes())
+
+	return err
+}
+
+func Export(result Result, w io.Writer) error {
+	type note struct {
+		Channel   lib.Channel `json:"channel"`
+		Note      byte        `json:"note"`
+		Name      string      `json:"name"`
+		Velocity  byte        `json:"velocity"`
+		StartTick uint64      `json:"start-tick"`
+		EndTick   uint64      `json:"end-tick"`
+	}
+
+	type item struct {
+		Tick  *uint64       `json:"tick,omitempty"`
+		Event events.IEvent `json:"event,omitempty"`
+		Note  *note         `json:"note,omitempty"`
+	}
+
+	type change struct {
+		From item `json:"from"`
+		To   item `json:"to"`
+	}
+
+	type track struct {
+		A       *lib.TrackNumber `json:"a"`
+		B       *lib.TrackNumber `json:"b"`
+		Name    string           `json:"name"`
+		Added   []item           `json:"added"`
+		Removed []item           `json:"removed"`
+		Changed []change         `json:"changed"`
+	}
+
+	type field struct {
+		Field string `json:"field"`
+		A     any    `json:"a"`
+		B     any    `json:"b"`
+	}
+
+	f := func(i Item) item {
+		if i.Note != nil {
+			return item{
+				Note: &note{
+					Channel:   i.Note.Channel,
+					Note:      i.Note.Note,
+					Name:      i.Note.FormattedNote,
+					Velocity:  i.Note.Velocity,
+					StartTick: i.Note.StartTick,
+					EndTick:   i.Note.EndTick,
+				},
+			}
+		}
+
+		tick := i.Event.Tick()
+
+		return item{
+			Tick:  &tick,
+			Event: i.Event,
+		}
+	}
+
+	object := struct {
+		Equal  bool    `json:"equal"`
+		Header []field `json:"header"`
+		Tracks []track `json:"tracks"`
+	}{
+		Equal:  result.Equal(),
+		Header: []field{},
+		Tracks: []track{},
+	}
+
+	for _, h := range result.Header {
+		object.Header = append(object.Header, field{h.Field, h.A, h.B})
+	}
+
+	for _, t := range result.Tracks {
+		u := track{
+			A:       t.A,
+			B:       t.B,
+			Name:    t.Name,
+			Added:   []item{},
+			Removed: []item{},
+			Changed: []change{},
+		}
+
+		for _, i := range t.Added {
+			u.Added = append(u.Added, f(i))
+		}
+
+		for _, i := range t.Removed {
+			u.Removed = append(u.Removed, f(i))
+		}
+
+		for _, c := range t.Changed {
+			u.Changed = append(u.Changed, change{From: f(c.From), To: f(c.To)})
+		}
+
+		object.Tracks = append(object.Tracks, u)
+	}
+
+	if bytes, err := json.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(bytes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func extract(smf *midi.SMF) (map[lib.TrackNumber][]notes.Note, error) {
+	m := map[lib.TrackNumber][]notes.Note{}
+
+	if list, err := notes.Extract(smf); err != nil {
+		return nil, err
+	} else {
+		for _, n := range list {
+			m[n.Track] = append(m[n.Track], n)
+		}
+	}
+
+	return m, nil
+}
+
+// encode returns the encoded event without the delta.
+func encode(e events.IEvent) ([]byte, error) {
+	if v, err := events.Retime(e, 0, 0); err != nil {
+		return nil, err
+	} else if m, ok := v.(encoding.BinaryMarshaler); !ok {
+		return nil, fmt.Errorf("Invalid event (%v)", e)
+	} else {
+		return m.MarshalBinary()
+	}
+}
+
+// identity returns a string that identifies 'what' an event applies to, so that e.g. a NoteOn
+// with a different velocity is reported as a change rather than as a removed and added event.
+func identity(e events.IEvent) string {
+	switch v := e.(type) {
+	case midievent.NoteOn:
+		return fmt.Sprintf("%v:%v:%v", v.Tag(), v.Channel, v.Note.Value)
+
+	case midievent.NoteOff:
+		return fmt.Sprintf("%v:%v:%v", v.Tag(), v.Channel, v.Note.Value)
+
+	case midievent.Controller:
+		return fmt.Sprintf("%v:%v:%v", v.Tag(), v.Channel, v.Controller.ID)
+
+	case midievent.PolyphonicPressure:
+		return fmt.Sprintf("%v:%v:%v", v.Tag(), v.Channel, v.Note.Value)
+
+	case midievent.ProgramChange:
+		return fmt.Sprintf("%v:%v", v.Tag(), v.Channel)
+
+	case midievent.ChannelPressure:
+		return fmt.Sprintf("%v:%v", v.Tag(), v.Channel)
+
+	case midievent.PitchBend:
+		return fmt.Sprintf("%v:%v", v.Tag(), v.Channel)
+
+	default:
+		return e.Tag()
+	}
+}
+
+func trackname(track *midi.MTrk) string {
+	for _, e := range track.Events {
+		if v, ok := e.Event.(metaevent.TrackName); ok {
+			return v.Name
+		}
+	}
+
+	return ""
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
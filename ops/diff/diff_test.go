@@ -0,0 +1,149 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+func TestCompare(t *testing.T) {
+	track := func(list ...events.IEvent) *midi.MTrk {
+		mtrk := midi.MTrk{}
+		for _, e := range list {
+			mtrk.Events = append(mtrk.Events, &events.Event{Event: e})
+		}
+
+		return &mtrk
+	}
+
+	smf := func(tracks ...*midi.MTrk) *midi.SMF {
+		for i, t := range tracks {
+			t.TrackNumber = lib.TrackNumber(i)
+		}
+
+		return &midi.SMF{
+			MThd:   &midi.MThd{Format: 1, Tracks: uint16(len(tracks)), PPQN: 480, Division: 480},
+			Tracks: tracks,
+		}
+	}
+
+	C := midievent.Note{Value: 48}
+	E := midievent.Note{Value: 52}
+
+	track0 := func() *midi.MTrk {
+		return track(
+			metaevent.MakeTrackName(0, 0, "Example"),
+			metaevent.MakeTempo(0, 0, 500000),
+			metaevent.MakeEndOfTrack(0, 0))
+	}
+
+	a := smf(track0(), track(
+		metaevent.MakeTrackName(0, 0, "Piano"),
+		midievent.MakeNoteOn(0, 0, 0, C, 64),
+		midievent.MakeNoteOff(480, 480, 0, C, 64),
+		metaevent.MakeEndOfTrack(480, 0)))
+
+	b := smf(track0(), track(
+		metaevent.MakeTrackName(0, 0, "Piano"),
+		midievent.MakeNoteOn(0, 0, 0, C, 100),
+		midievent.MakeNoteOn(0, 0, 0, E, 64),
+		midievent.MakeNoteOff(480, 480, 0, C, 64),
+		midievent.MakeNoteOff(480, 0, 0, E, 64),
+		metaevent.MakeEndOfTrack(480, 0)))
+
+	tests := []struct {
+		diff    Diff
+		added   int
+		removed int
+		changed int
+	}{
+		{Diff{}, 2, 0, 1},
+		{Diff{IgnoreDeltas: true}, 2, 0, 1},
+		{Diff{IgnoreDeltas: true, IgnoreVelocity: true}, 2, 0, 0},
+		{Diff{Notes: true}, 1, 0, 1},
+		{Diff{Notes: true, IgnoreVelocity: true}, 1, 0, 0},
+	}
+
+	for _, test := range tests {
+		result, err := test.diff.Compare(a, b)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		if result.Equal() {
+			t.Errorf("%+v: incorrectly reported files as equal", test.diff)
+		}
+
+		if len(result.Tracks) != 2 {
+			t.Fatalf("%+v: incorrect number of tracks - expected:%v, got:%v", test.diff, 2, len(result.Tracks))
+		}
+
+		if !result.Tracks[0].Equal() {
+			t.Errorf("%+v: incorrectly reported track 0 as different (%+v)", test.diff, result.Tracks[0])
+		}
+
+		track := result.Tracks[1]
+		if len(track.Added) != test.added || len(track.Removed) != test.removed || len(track.Changed) != test.changed {
+			t.Errorf("%+v: incorrect differences - expected:%v/%v/%v, got:%v/%v/%v",
+				test.diff,
+				test.added, test.removed, test.changed,
+				len(track.Added), len(track.Removed), len(track.Changed))
+		}
+	}
+
+	if result, err := (Diff{}).Compare(a, a); err != nil {
+		t.Fatalf("%v", err)
+	} else if !result.Equal() {
+		t.Errorf("incorrectly reported identical files as different (%+v)", result)
+	}
+}
+
+func TestAlignByName(t *testing.T) {
+	track := func(name string) *midi.MTrk {
+		return &midi.MTrk{
+			Events: []*events.Event{
+				&events.Event{Event: metaevent.MakeTrackName(0, 0, name)},
+				&events.Event{Event: metaevent.MakeEndOfTrack(0, 0)},
+			},
+		}
+	}
+
+	a := midi.SMF{MThd: &midi.MThd{}, Tracks: []*midi.MTrk{track("Conductor"), track("Piano"), track("Bass")}}
+	b := midi.SMF{MThd: &midi.MThd{}, Tracks: []*midi.MTrk{track("Conductor"), track("Bass"), track("Drums")}}
+
+	pairs := Diff{Align: ByName}.align(&a, &b)
+
+	expected := []struct {
+		a string
+		b string
+	}{
+		{"Conductor", "Conductor"},
+		{"Piano", ""},
+		{"Bass", "Bass"},
+		{"", "Drums"},
+	}
+
+	if len(pairs) != len(expected) {
+		t.Fatalf("Incorrect number of track pairs - expected:%v, got:%v", len(expected), len(pairs))
+	}
+
+	for i, p := range pairs {
+		u := ""
+		v := ""
+		if p.a != nil {
+			u = trackname(p.a)
+		}
+
+		if p.b != nil {
+			v = trackname(p.b)
+		}
+
+		if u != expected[i].a || v != expected[i].b {
+			t.Errorf("Incorrect track alignment %v - expected:%v/%v, got:%v/%v", i, expected[i].a, expected[i].b, u, v)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package diff
+
+type hunk struct {
+	removed []entry
+	added   []entry
+}
+
+// hunks aligns two lists of events using the Myers shortest edit script and returns the runs
+// of removed and added events between the common events.
+func hunks(x, y []entry) []hunk {
+	// ... skip common prefix and suffix
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix].key == y[prefix].key {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix].key == y[len(y)-1-suffix].key {
+		suffix++
+	}
+
+	a := x[prefix : len(x)-suffix]
+	b := y[prefix : len(y)-suffix]
+
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+
+	// ... edit script
+	type op struct {
+		removed bool
+		entry   entry
+		common  bool
+	}
+
+	ops := []op{}
+	for _, s := range script(a, b) {
+		switch {
+		case s.i >= 0 && s.j >= 0:
+			ops = append(ops, op{common: true})
+
+		case s.i >= 0:
+			ops = append(ops, op{removed: true, entry: a[s.i]})
+
+		default:
+			ops = append(ops, op{entry: b[s.j]})
+		}
+	}
+
+	list := []hunk{}
+	current := hunk{}
+
+	for _, o := range ops {
+		switch {
+		case o.common:
+			if len(current.removed) > 0 || len(current.added) > 0 {
+				list = append(list, current)
+				current = hunk{}
+			}
+
+		case o.removed:
+			current.removed = append(current.removed, o.entry)
+
+		default:
+			current.added = append(current.added, o.entry)
+		}
+	}
+
+	if len(current.removed) > 0 || len(current.added) > 0 {
+		list = append(list, current)
+	}
+
+	return list
+}
+
+type step struct {
+	i int
+	j int
+}
+
+// script returns the edit script as a list of steps, where a step with both indices set is a
+// common entry, a step with only i set is a removal from a and a step with only j set is an
+// addition from b.
+func script(a, b []entry) []step {
+	N := len(a)
+	M := len(b)
+	MAX := N + M
+	offset := MAX + 1
+
+	v := make([]int, 2*MAX+2)
+	trace := [][]int{}
+
+	for d := 0; d <= MAX; d++ {
+		snapshot := make([]int, 2*d+1)
+		copy(snapshot, v[offset-d:offset+d+1])
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+
+			y := x - k
+			for x < N && y < M && a[x].key == b[y].key {
+				x++
+				y++
+			}
+
+			v[offset+k] = x
+
+			if x >= N && y >= M {
+				return backtrack(trace, N, M, d)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrack(trace [][]int, N, M, D int) []step {
+	steps := []step{}
+	x := N
+	y := M
+
+	for d := D; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prev int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prev = k + 1
+		} else {
+			prev = k - 1
+		}
+
+		px := v[d+prev]
+		py := px - prev
+
+		for x > px && y > py {
+			x--
+			y--
+			steps = append(steps, step{x, y})
+		}
+
+		if x == px {
+			y--
+			steps = append(steps, step{-1, y})
+		} else {
+			x--
+			steps = append(steps, step{x, -1})
+		}
+	}
+
+	for x > 0 && y > 0 {
+		x--
+		y--
+		steps = append(steps, step{x, y})
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+
+	return steps
+}
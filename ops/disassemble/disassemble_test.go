@@ -30,7 +30,7 @@ func TestDisassembleSMF(t *testing.T) {
 		0x00, 0xff, 0x54, 0x05, 0x2d, 0x2d, 0x3b, 0x07, 0x27,
 		0x00, 0xff, 0x2f, 0x00,
 
-		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0xf1,
+		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0xf2,
 		0x00, 0xff, 0x00, 0x02, 0x00, 0x17,
 		0x00, 0xff, 0x01, 0x0d, 0x54, 0x68, 0x69, 0x73, 0x20, 0x61, 0x6e, 0x64, 0x20, 0x54, 0x68, 0x61, 0x74,
 		0x00, 0xff, 0x02, 0x04, 0x54, 0x68, 0x65, 0x6d,
@@ -49,7 +49,7 @@ func TestDisassembleSMF(t *testing.T) {
 		0x00, 0xb0, 0x20, 0x21,
 		0x00, 0xc0, 0x19,
 		0x00, 0xb0, 0x65, 0x00,
-		0x00, 0xa0, 0x64,
+		0x00, 0xa0, 0x30, 0x64,
 		0x00, 0xd0, 0x07,
 		0x00, 0x90, 0x30, 0x48,
 		0x00, 0x92, 0x31, 0x48,
@@ -96,7 +96,7 @@ func TestDisassembleWithLoadedTemplate(t *testing.T) {
 		0x00, 0xff, 0x58, 0x04, 0x04, 0x02, 0x18, 0x08,
 		0x00, 0xff, 0x54, 0x05, 0x2d, 0x2d, 0x3b, 0x07, 0x27,
 		0x00, 0xff, 0x2f, 0x00,
-		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0xea,
+		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0xeb,
 		0x00, 0xff, 0x00, 0x02, 0x00, 0x17,
 		0x00, 0xff, 0x01, 0x0d, 0x54, 0x68, 0x69, 0x73, 0x20, 0x61, 0x6e, 0x64, 0x20, 0x54, 0x68, 0x61, 0x74,
 		0x00, 0xff, 0x02, 0x04, 0x54, 0x68, 0x65, 0x6d,
@@ -115,7 +115,7 @@ func TestDisassembleWithLoadedTemplate(t *testing.T) {
 		0x00, 0xb0, 0x20, 0x21,
 		0x00, 0xc0, 0x19,
 		0x00, 0xb0, 0x65, 0x00,
-		0x00, 0xa0, 0x64,
+		0x00, 0xa0, 0x30, 0x64,
 		0x00, 0xd0, 0x07,
 		0x00, 0x90, 0x30, 0x48,
 		0x81, 0x70, 0xe0, 0x00, 0x08,
@@ -0,0 +1,309 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/ops/notation"
+)
+
+// ABC exports the notes of a MIDI file as an ABC tune, with a voice (V:) for each voice of
+// each track and channel. Note durations are quantised to the Quantise note value and, if
+// Triplets is set, to triplets.
+type ABC struct {
+	Quantise int
+	Triplets bool
+}
+
+type abcvoice struct {
+	part  notation.Part
+	voice int
+	name  string
+}
+
+func NewABC() (*ABC, error) {
+	return &ABC{
+		Quantise: 16,
+		Triplets: true,
+	}, nil
+}
+
+func (x *ABC) Export(smf *midi.SMF, w io.Writer) error {
+	score, err := notation.Notation{Quantise: x.Quantise, Triplets: x.Triplets}.Transcribe(smf)
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+
+	// ... header
+	fmt.Fprintln(b, "X:1")
+	if score.Title != "" {
+		fmt.Fprintf(b, "T:%v\n", score.Title)
+	}
+
+	if m := score.Measures[0]; m.Time != nil {
+		fmt.Fprintf(b, "M:%v/%v\n", m.Time.Beats, m.Time.BeatType)
+	}
+
+	// ... unit note length is an eighth note unless the divisions per quarter note are odd
+	unit := score.Divisions / 2
+	if score.Divisions%2 == 0 {
+		fmt.Fprintln(b, "L:1/8")
+	} else {
+		unit = score.Divisions
+		fmt.Fprintln(b, "L:1/4")
+	}
+
+	if m := score.Measures[0]; len(m.Tempo) > 0 && m.Tempo[0].Offset == 0 {
+		fmt.Fprintf(b, "Q:1/4=%v\n", math.Round(m.Tempo[0].BPM))
+	}
+
+	// ... voices
+	voices := []abcvoice{}
+	for _, p := range score.Parts {
+		numbers := map[int]bool{}
+		for _, bar := range p.Measures {
+			for _, v := range bar.Voices {
+				numbers[v.Number] = true
+			}
+		}
+
+		for n := 1; numbers[n]; n++ {
+			name := p.Name
+			if n > 1 {
+				name = fmt.Sprintf("%v (voice %v)", p.Name, n)
+			}
+
+			voices = append(voices, abcvoice{part: p, voice: n, name: name})
+		}
+	}
+
+	key := abckey(score.Measures[0].Key)
+	if len(voices) == 1 && voices[0].part.Clef == notation.Bass {
+		fmt.Fprintf(b, "K:%v clef=bass\n", key)
+	} else {
+		fmt.Fprintf(b, "K:%v\n", key)
+	}
+
+	for i, v := range voices {
+		if len(voices) > 1 {
+			clef := ""
+			if v.part.Clef == notation.Bass {
+				clef = " clef=bass"
+			}
+
+			fmt.Fprintf(b, "V:%v name=\"%v\"%v\n", i+1, strings.ReplaceAll(v.name, `"`, `'`), clef)
+		}
+
+		x.voice(b, score, v, unit, i == 0)
+	}
+
+	return b.Flush()
+}
+
+func (x *ABC) voice(b *bufio.Writer, score *notation.Score, v abcvoice, unit int, first bool) {
+	var scale lib.Scale
+
+	for i, m := range score.Measures {
+		// ... inline fields
+		if m.Key != nil {
+			scale = m.Key.Scale
+			if i > 0 {
+				fmt.Fprintf(b, "[K:%v] ", abckey(m.Key))
+			}
+		}
+
+		if m.Time != nil && i > 0 {
+			fmt.Fprintf(b, "[M:%v/%v] ", m.Time.Beats, m.Time.BeatType)
+		}
+
+		if first {
+			for _, t := range m.Tempo {
+				if i > 0 || t.Offset > 0 {
+					fmt.Fprintf(b, "[Q:1/4=%v] ", math.Round(t.BPM))
+				}
+			}
+		}
+
+		// ... notes
+		beats, beatType := score.Measures[0].Time.Beats, score.Measures[0].Time.BeatType
+		for _, n := range score.Measures[:i+1] {
+			if n.Time != nil {
+				beats, beatType = n.Time.Beats, n.Time.BeatType
+			}
+		}
+
+		beat := 4 * score.Divisions / beatType
+		if beatType == 8 && beats%3 == 0 {
+			beat *= 3
+		}
+
+		accidentals := map[string]int{}
+
+		var elements []notation.Element
+		for _, voice := range v.part.Measures[i].Voices {
+			if voice.Number == v.voice {
+				elements = voice.Elements
+			}
+		}
+
+		if len(elements) == 0 {
+			fmt.Fprintf(b, "x%v", abclength(m.Length, unit))
+		}
+
+		offset := 0
+		for j, e := range elements {
+			if j > 0 && offset%beat == 0 {
+				b.WriteString(" ")
+			}
+
+			if e.Tuplet == 3 && (j == 0 || elements[j-1].Tuplet != 3) {
+				n := 0
+				for _, f := range elements[j:] {
+					if f.Tuplet != 3 {
+						break
+					}
+					n++
+				}
+
+				if n == 3 {
+					b.WriteString("(3")
+				} else {
+					fmt.Fprintf(b, "(3:2:%v", n)
+				}
+			}
+
+			duration := e.Duration
+			if e.Tuplet == 3 {
+				duration = e.Duration * 3 / 2
+			}
+
+			switch {
+			case e.Rest:
+				fmt.Fprintf(b, "z%v", abclength(duration, unit))
+
+			case len(e.Pitches) == 1:
+				fmt.Fprintf(b, "%v%v", abcpitch(e.Pitches[0], scale, accidentals), abclength(duration, unit))
+
+			default:
+				b.WriteString("[")
+				for _, p := range e.Pitches {
+					b.WriteString(abcpitch(p, scale, accidentals))
+				}
+				fmt.Fprintf(b, "]%v", abclength(duration, unit))
+			}
+
+			if e.TieStart {
+				b.WriteString("-")
+			}
+
+			offset += e.Duration
+		}
+
+		switch {
+		case i == len(score.Measures)-1:
+			b.WriteString(" |]\n")
+
+		case (i+1)%4 == 0:
+			b.WriteString(" |\n")
+
+		default:
+			b.WriteString(" | ")
+		}
+	}
+}
+
+// abckey formats a key signature as an ABC key e.g. Bb, F#m.
+func abckey(key *notation.KeySignature) string {
+	if key == nil {
+		return "C"
+	}
+
+	root := strings.Fields(key.Scale.Name)[0]
+	root = strings.ReplaceAll(root, "♯", "#")
+	root = strings.ReplaceAll(root, "♭", "b")
+
+	if key.Mode == lib.Minor {
+		return root + "m"
+	}
+
+	return root
+}
+
+// abcpitch formats a pitch as an ABC note, with an accidental if the pitch differs from
+// the key signature or an earlier accidental in the bar.
+func abcpitch(p notation.Pitch, scale lib.Scale, accidentals map[string]int) string {
+	var s strings.Builder
+
+	alter := 0
+	for _, n := range scale.Notes {
+		if n.Name[0:1] == p.Step {
+			alter = strings.Count(n.Name, "♯") - strings.Count(n.Name, "♭")
+		}
+	}
+
+	id := fmt.Sprintf("%v%v", p.Step, p.Octave)
+	if v, ok := accidentals[id]; ok {
+		alter = v
+	}
+
+	if alter != p.Alter {
+		switch p.Alter {
+		case 2:
+			s.WriteString("^^")
+		case 1:
+			s.WriteString("^")
+		case 0:
+			s.WriteString("=")
+		case -1:
+			s.WriteString("_")
+		case -2:
+			s.WriteString("__")
+		}
+
+		accidentals[id] = p.Alter
+	}
+
+	if p.Octave >= 5 {
+		s.WriteString(strings.ToLower(p.Step))
+		s.WriteString(strings.Repeat("'", p.Octave-5))
+	} else {
+		s.WriteString(p.Step)
+		s.WriteString(strings.Repeat(",", max(0, 4-p.Octave)))
+	}
+
+	return s.String()
+}
+
+// abclength formats a duration as a multiple of the unit note length e.g. 2, 3/2, /2.
+func abclength(duration, unit int) string {
+	g := gcd(duration, unit)
+	n, d := duration/g, unit/g
+
+	switch {
+	case n == d:
+		return ""
+	case d == 1:
+		return fmt.Sprintf("%v", n)
+	case n == 1 && d == 2:
+		return "/"
+	case n == 1:
+		return fmt.Sprintf("/%v", d)
+	default:
+		return fmt.Sprintf("%v/%v", n, d)
+	}
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return max(1, a)
+}
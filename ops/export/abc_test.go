@@ -0,0 +1,30 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+//go:embed test-files/reference.abc
+var referenceABC []byte
+
+func TestABCExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewABC()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting ABC (%v)", err)
+	}
+
+	if b.String() != string(referenceABC) {
+		t.Errorf("incorrectly exported ABC\nexpected:\n%v\ngot:\n%v", string(referenceABC), b.String())
+	}
+}
@@ -0,0 +1,241 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/events/sysex"
+)
+
+// CSV exports a MIDI file in the midicsv record format (Track, Time, Type, ...) so that the
+// output can be used interchangeably with the midicsv/csvmidi tools.
+type CSV struct {
+}
+
+func NewCSV() (*CSV, error) {
+	return &CSV{}, nil
+}
+
+func (x *CSV) Export(smf *midi.SMF, w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, "0, 0, Header, %v, %v, %v\n", smf.MThd.Format, len(smf.Tracks), smf.MThd.Division)
+
+	for i, track := range smf.Tracks {
+		n := i + 1
+
+		fmt.Fprintf(b, "%v, 0, Start_track\n", n)
+
+		for _, e := range track.Events {
+			if record, err := x.record(e); err != nil {
+				return fmt.Errorf("track %v, tick %v: %v", n, e.Tick(), err)
+			} else {
+				fmt.Fprintf(b, "%v, %v, %v\n", n, e.Tick(), record)
+			}
+		}
+	}
+
+	fmt.Fprintf(b, "0, 0, End_of_file\n")
+
+	return b.Flush()
+}
+
+func (x *CSV) record(e *events.Event) (string, error) {
+	switch v := e.Event.(type) {
+	case metaevent.SequenceNumber:
+		return fmt.Sprintf("Sequence_number, %v", v.SequenceNumber), nil
+
+	case metaevent.Text:
+		return fmt.Sprintf("Text_t, %v", quote(v.Text)), nil
+
+	case metaevent.Copyright:
+		return fmt.Sprintf("Copyright_t, %v", quote(v.Copyright)), nil
+
+	case metaevent.TrackName:
+		return fmt.Sprintf("Title_t, %v", quote(v.Name)), nil
+
+	case metaevent.InstrumentName:
+		return fmt.Sprintf("Instrument_name_t, %v", quote(v.Name)), nil
+
+	case metaevent.Lyric:
+		return fmt.Sprintf("Lyric_t, %v", quote(v.Lyric)), nil
+
+	case metaevent.Marker:
+		return fmt.Sprintf("Marker_t, %v", quote(v.Marker)), nil
+
+	case metaevent.CuePoint:
+		return fmt.Sprintf("Cue_point_t, %v", quote(v.CuePoint)), nil
+
+	case metaevent.ProgramName:
+		return unknown(v)
+
+	case metaevent.DeviceName:
+		return unknown(v)
+
+	case metaevent.MIDIChannelPrefix:
+		return fmt.Sprintf("Channel_prefix, %v", v.Channel), nil
+
+	case metaevent.MIDIPort:
+		return fmt.Sprintf("MIDI_port, %v", v.Port), nil
+
+	case metaevent.EndOfTrack:
+		return "End_track", nil
+
+	case metaevent.Tempo:
+		return fmt.Sprintf("Tempo, %v", v.Tempo), nil
+
+	case metaevent.SMPTEOffset:
+		if bytes, err := v.MarshalBinary(); err != nil {
+			return "", err
+		} else {
+			return fmt.Sprintf("SMPTE_offset, %v, %v, %v, %v, %v", bytes[3], v.Minute, v.Second, v.Frames, v.FractionalFrames), nil
+		}
+
+	case metaevent.TimeSignature:
+		denominator := 0
+		for d := uint8(1); d < v.Denominator; d *= 2 {
+			denominator++
+		}
+
+		return fmt.Sprintf("Time_signature, %v, %v, %v, %v", v.Numerator, denominator, v.TicksPerClick, v.ThirtySecondsPerQuarter), nil
+
+	case metaevent.KeySignature:
+		return fmt.Sprintf("Key_signature, %v, \"%v\"", v.Accidentals, v.KeyType), nil
+
+	case metaevent.SequencerSpecificEvent:
+		return data("Sequencer_specific", v)
+
+	case midievent.NoteOff:
+		return fmt.Sprintf("Note_off_c, %v, %v, %v", v.Channel, v.Note.Value, v.Velocity), nil
+
+	case midievent.NoteOn:
+		return fmt.Sprintf("Note_on_c, %v, %v, %v", v.Channel, v.Note.Value, v.Velocity), nil
+
+	case midievent.PolyphonicPressure:
+		return fmt.Sprintf("Poly_aftertouch_c, %v, %v, %v", v.Channel, v.Note.Value, v.Pressure), nil
+
+	case midievent.Controller:
+		return fmt.Sprintf("Control_c, %v, %v, %v", v.Channel, v.Controller.ID, v.Value), nil
+
+	case midievent.ProgramChange:
+		return fmt.Sprintf("Program_c, %v, %v", v.Channel, v.Program), nil
+
+	case midievent.ChannelPressure:
+		return fmt.Sprintf("Channel_aftertouch_c, %v, %v", v.Channel, v.Pressure), nil
+
+	case midievent.PitchBend:
+		return fmt.Sprintf("Pitch_bend_c, %v, %v", v.Channel, v.Bend), nil
+
+	case sysex.SysExMessage:
+		return data("System_exclusive", v)
+
+	case sysex.SysExContinuationMessage:
+		return data("System_exclusive_packet", v)
+
+	case sysex.SysExEscapeMessage:
+		return data("System_exclusive_packet", v)
+
+	default:
+		return "", fmt.Errorf("unsupported event (%v)", e.Event.Tag())
+	}
+}
+
+// unknown formats a meta event that has no midicsv record type as an Unknown_meta_event.
+func unknown(e events.IEvent) (string, error) {
+	if encoded, err := marshal(e); err != nil {
+		return "", err
+	} else if d, err := payload(encoded[2:]); err != nil {
+		return "", err
+	} else {
+		return fmt.Sprintf("Unknown_meta_event, %v%v", encoded[1], varlen(d)), nil
+	}
+}
+
+// data formats a variable length event (sysex or sequencer specific) as the record type followed
+// by the length and data bytes.
+func data(record string, e events.IEvent) (string, error) {
+	encoded, err := marshal(e)
+	if err != nil {
+		return "", err
+	}
+
+	offset := 1
+	if encoded[0] == 0xff {
+		offset = 2
+	}
+
+	if d, err := payload(encoded[offset:]); err != nil {
+		return "", err
+	} else {
+		return fmt.Sprintf("%v%v", record, varlen(d)), nil
+	}
+}
+
+func marshal(e events.IEvent) ([]byte, error) {
+	if m, ok := e.(interface{ MarshalBinary() ([]byte, error) }); !ok {
+		return nil, fmt.Errorf("%v is not a BinaryMarshaler", e.Tag())
+	} else {
+		return m.MarshalBinary()
+	}
+}
+
+// payload strips the VLQ length from a variable length field.
+func payload(bytes []byte) ([]byte, error) {
+	N := 0
+	for i, b := range bytes {
+		N = (N << 7) | int(b&0x7f)
+		if b&0x80 == 0 {
+			if remaining := bytes[i+1:]; len(remaining) != N {
+				return nil, fmt.Errorf("invalid variable length field (%v)", bytes)
+			} else {
+				return remaining, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("invalid variable length field (%v)", bytes)
+}
+
+// varlen formats the length and bytes of a variable length field as ', N, b1, b2, ...'.
+func varlen(bytes []byte) string {
+	var s strings.Builder
+
+	fmt.Fprintf(&s, ", %v", len(bytes))
+	for _, b := range bytes {
+		fmt.Fprintf(&s, ", %v", b)
+	}
+
+	return s.String()
+}
+
+// quote formats a string in the midicsv style i.e. quoted with embedded quotes doubled,
+// backslashes escaped and control and non-ASCII characters as octal escapes.
+func quote(text string) string {
+	var s strings.Builder
+
+	s.WriteByte('"')
+	for _, c := range []byte(text) {
+		switch {
+		case c < ' ' || (c > '~' && c <= 160):
+			fmt.Fprintf(&s, "\\%03o", c)
+
+		case c == '"':
+			s.WriteString(`""`)
+
+		case c == '\\':
+			s.WriteString(`\\`)
+
+		default:
+			s.WriteByte(c)
+		}
+	}
+	s.WriteByte('"')
+
+	return s.String()
+}
@@ -0,0 +1,65 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+//go:embed test-files/reference.mid
+var referenceMIDI []byte
+
+//go:embed test-files/reference.csv
+var referenceCSV []byte
+
+func TestCSVExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewCSV()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting CSV (%v)", err)
+	}
+
+	if b.String() != string(referenceCSV) {
+		t.Errorf("incorrectly exported CSV\nexpected:\n%v\ngot:\n%v", string(referenceCSV), b.String())
+	}
+}
+
+func TestCSVExportPolyphonicPressure(t *testing.T) {
+	var b bytes.Buffer
+
+	midi := []byte{
+		0x4d, 0x54, 0x68, 0x64, 0x00, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x01, 0x01, 0xe0,
+		0x4d, 0x54, 0x72, 0x6b, 0x00, 0x00, 0x00, 0x08,
+		0x00, 0xa3, 0x30, 0x64,
+		0x00, 0xff, 0x2f, 0x00,
+	}
+
+	expected := `0, 0, Header, 0, 1, 480
+1, 0, Start_track
+1, 0, Poly_aftertouch_c, 3, 48, 100
+1, 0, End_track
+0, 0, End_of_file
+`
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(midi))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewCSV()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting CSV (%v)", err)
+	}
+
+	if b.String() != expected {
+		t.Errorf("incorrectly exported CSV\nexpected:\n%v\ngot:\n%v", expected, b.String())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 
 	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/ops/schema"
 )
 
 type Export struct {
@@ -14,8 +15,17 @@ func NewExport() (*Export, error) {
 	return &Export{}, nil
 }
 
+// Export writes the MIDI file as a JSON document that conforms to the midiasm JSON schema.
 func (x *Export) Export(smf *midi.SMF, w io.Writer) error {
-	if bytes, err := json.MarshalIndent(smf, "", "  "); err != nil {
+	document := struct {
+		Version int `json:"version"`
+		*midi.SMF
+	}{
+		Version: schema.Version,
+		SMF:     smf,
+	}
+
+	if bytes, err := json.MarshalIndent(document, "", "  "); err != nil {
 		return err
 	} else if _, err := w.Write(bytes); err != nil {
 		return err
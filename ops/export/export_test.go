@@ -1 +1,32 @@
 package export
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/ops/schema"
+)
+
+func TestJSONExportSchema(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewExport()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting JSON (%v)", err)
+	}
+
+	violations, err := schema.Validate(&b)
+	if err != nil {
+		t.Fatalf("error validating exported JSON (%v)", err)
+	}
+
+	for _, v := range violations {
+		t.Errorf("exported JSON does not conform to schema: %v", v)
+	}
+}
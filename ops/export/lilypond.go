@@ -0,0 +1,245 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/context"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/ops/notation"
+)
+
+// LilyPond exports the notes of a MIDI file as a LilyPond score, with a staff for each track
+// and channel. Note durations are quantised to the Quantise note value and, if Triplets is
+// set, to triplets.
+type LilyPond struct {
+	Quantise int
+	Triplets bool
+}
+
+func NewLilyPond() (*LilyPond, error) {
+	return &LilyPond{
+		Quantise: 16,
+		Triplets: true,
+	}, nil
+}
+
+func (x *LilyPond) Export(smf *midi.SMF, w io.Writer) error {
+	score, err := notation.Notation{Quantise: x.Quantise, Triplets: x.Triplets}.Transcribe(smf)
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintln(b, `\version "2.24.0"`)
+	fmt.Fprintln(b)
+
+	if score.Title != "" {
+		fmt.Fprintln(b, `\header {`)
+		fmt.Fprintf(b, "  title = %v\n", lilystring(score.Title))
+		fmt.Fprintln(b, `}`)
+		fmt.Fprintln(b)
+	}
+
+	fmt.Fprintln(b, `\score {`)
+	fmt.Fprintln(b, `  <<`)
+
+	for i, p := range score.Parts {
+		x.staff(b, score, p, i == 0)
+	}
+
+	fmt.Fprintln(b, `  >>`)
+	fmt.Fprintln(b, `  \layout { }`)
+	fmt.Fprintln(b, `}`)
+
+	return b.Flush()
+}
+
+func (x *LilyPond) staff(b *bufio.Writer, score *notation.Score, p notation.Part, first bool) {
+	numbers := map[int]bool{}
+	for _, bar := range p.Measures {
+		for _, v := range bar.Voices {
+			numbers[v.Number] = true
+		}
+	}
+
+	voices := 0
+	for numbers[voices+1] {
+		voices++
+	}
+
+	fmt.Fprintf(b, "    \\new Staff \\with { instrumentName = %v } <<\n", lilystring(p.Name))
+
+	for n := 1; n <= voices; n++ {
+		fmt.Fprintln(b, `      \new Voice {`)
+
+		switch {
+		case voices == 1:
+		case n == 1:
+			fmt.Fprintln(b, `        \voiceOne`)
+		case n == 2:
+			fmt.Fprintln(b, `        \voiceTwo`)
+		case n == 3:
+			fmt.Fprintln(b, `        \voiceThree`)
+		default:
+			fmt.Fprintln(b, `        \voiceFour`)
+		}
+
+		if n == 1 {
+			fmt.Fprintf(b, "        \\clef %v\n", map[notation.Clef]string{notation.Treble: "treble", notation.Bass: "bass", notation.Percussion: "percussion"}[p.Clef])
+		}
+
+		x.voice(b, score, p, n, first && n == 1)
+
+		fmt.Fprintln(b, `      }`)
+	}
+
+	fmt.Fprintln(b, `    >>`)
+}
+
+func (x *LilyPond) voice(b *bufio.Writer, score *notation.Score, p notation.Part, n int, tempo bool) {
+	var beats, beatType = 4, 4
+
+	for i, m := range score.Measures {
+		var line strings.Builder
+
+		if m.Key != nil && n == 1 {
+			fmt.Fprintf(&line, "\\key %v \\%v ", lilyname(m.Key.Scale.Notes[0].Name), m.Key.Mode)
+		}
+
+		if m.Time != nil {
+			beats, beatType = m.Time.Beats, m.Time.BeatType
+			if n == 1 {
+				fmt.Fprintf(&line, "\\time %v/%v ", beats, beatType)
+			}
+		}
+
+		var elements []notation.Element
+		for _, v := range p.Measures[i].Voices {
+			if v.Number == n {
+				elements = v.Elements
+			}
+		}
+
+		if len(elements) == 0 {
+			fmt.Fprintf(&line, "s1*%v/%v ", beats, beatType)
+		}
+
+		tempi := []notation.Tempo{}
+		if tempo {
+			tempi = m.Tempo
+		}
+
+		offset := 0
+		for j, e := range elements {
+			for _, t := range tempi {
+				if t.Offset == offset {
+					fmt.Fprintf(&line, "\\tempo 4 = %v ", math.Round(t.BPM))
+				}
+			}
+
+			if e.Tuplet == 3 && (j == 0 || elements[j-1].Tuplet != 3) {
+				line.WriteString(`\tuplet 3/2 { `)
+			}
+
+			line.WriteString(lilyelement(e, beats, beatType))
+
+			if e.Tuplet == 3 && (j == len(elements)-1 || elements[j+1].Tuplet != 3) {
+				line.WriteString(" }")
+			}
+
+			line.WriteString(" ")
+			offset += e.Duration
+		}
+
+		if i == len(score.Measures)-1 {
+			line.WriteString(`\bar "|."`)
+		} else {
+			line.WriteString("|")
+		}
+
+		fmt.Fprintf(b, "        %v\n", line.String())
+	}
+}
+
+// lilyelement formats a note, chord or rest e.g. bes'4, <c' e'>2.~ or R1*3/4.
+func lilyelement(e notation.Element, beats, beatType int) string {
+	duration := fmt.Sprintf("%v%v", e.Value, strings.Repeat(".", e.Dots))
+
+	switch {
+	case e.Rest && e.Value == 0:
+		return fmt.Sprintf("R1*%v/%v", beats, beatType)
+
+	case e.Rest:
+		return "r" + duration
+
+	case len(e.Pitches) == 1:
+		duration = lilypitch(e.Pitches[0]) + duration
+
+	default:
+		pitches := []string{}
+		for _, p := range e.Pitches {
+			pitches = append(pitches, lilypitch(p))
+		}
+
+		duration = "<" + strings.Join(pitches, " ") + ">" + duration
+	}
+
+	if e.TieStart {
+		duration += "~"
+	}
+
+	return duration
+}
+
+// lilypitch formats a pitch as a LilyPond absolute pitch e.g. c' for the note named C4 (which
+// is middle C unless the --C4 convention is in use).
+func lilypitch(p notation.Pitch) string {
+	name := lilystep(p.Step, p.Alter)
+	octave := p.Octave
+	if context.MiddleC == lib.C4 {
+		octave--
+	}
+
+	if octave >= 3 {
+		return name + strings.Repeat("'", octave-3)
+	}
+
+	return name + strings.Repeat(",", 3-octave)
+}
+
+// lilyname converts a note name e.g. B♭ or F♯ to a LilyPond note name.
+func lilyname(name string) string {
+	return lilystep(name[0:1], strings.Count(name, "♯")-strings.Count(name, "♭"))
+}
+
+// lilystep returns the (Dutch) LilyPond name for a note e.g. cis, bes, as.
+func lilystep(step string, alter int) string {
+	name := strings.ToLower(step)
+
+	switch {
+	case alter > 0:
+		name += strings.Repeat("is", alter)
+
+	case alter < 0 && (step == "E" || step == "A"):
+		name += "s" + strings.Repeat("es", -alter-1)
+
+	case alter < 0:
+		name += strings.Repeat("es", -alter)
+	}
+
+	return name
+}
+
+// lilystring quotes a string for LilyPond.
+func lilystring(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+
+	return `"` + s + `"`
+}
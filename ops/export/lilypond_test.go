@@ -0,0 +1,59 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi/context"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/ops/notation"
+)
+
+//go:embed test-files/reference.ly
+var referenceLilyPond []byte
+
+func TestLilyPondExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewLilyPond()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting LilyPond (%v)", err)
+	}
+
+	if b.String() != string(referenceLilyPond) {
+		t.Errorf("incorrectly exported LilyPond\nexpected:\n%v\ngot:\n%v", string(referenceLilyPond), b.String())
+	}
+}
+
+func TestLilyPondPitch(t *testing.T) {
+	tests := []struct {
+		pitch    notation.Pitch
+		middleC  lib.MiddleC
+		expected string
+	}{
+		{notation.Pitch{Note: 60, Step: "C", Alter: 0, Octave: 4}, lib.C3, "c'"},
+		{notation.Pitch{Note: 60, Step: "C", Alter: 0, Octave: 4}, lib.C4, "c"},
+		{notation.Pitch{Note: 70, Step: "B", Alter: -1, Octave: 4}, lib.C3, "bes'"},
+		{notation.Pitch{Note: 68, Step: "A", Alter: -1, Octave: 4}, lib.C3, "as'"},
+		{notation.Pitch{Note: 62, Step: "E", Alter: -2, Octave: 4}, lib.C3, "eses'"},
+		{notation.Pitch{Note: 42, Step: "F", Alter: 1, Octave: 2}, lib.C3, "fis,"},
+		{notation.Pitch{Note: 84, Step: "B", Alter: 1, Octave: 5}, lib.C4, "bis'"},
+	}
+
+	defer context.SetMiddleC(context.MiddleC)
+
+	for _, test := range tests {
+		context.SetMiddleC(test.middleC)
+
+		if s := lilypitch(test.pitch); s != test.expected {
+			t.Errorf("Incorrect LilyPond pitch for %+v (%v) - expected:%v, got:%v", test.pitch, test.middleC, test.expected, s)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/ops/notation"
+)
+
+// MusicXML exports the notes of a MIDI file as a MusicXML partwise score, with a part for
+// each track and channel. Note durations are quantised to the Quantise note value.
+type MusicXML struct {
+	Quantise int
+}
+
+type xmlScore struct {
+	XMLName        xml.Name          `xml:"score-partwise"`
+	Version        string            `xml:"version,attr"`
+	Work           *xmlWork          `xml:"work,omitempty"`
+	Identification xmlIdentification `xml:"identification"`
+	PartList       []xmlScorePart    `xml:"part-list>score-part"`
+	Parts          []xmlPart         `xml:"part"`
+}
+
+type xmlWork struct {
+	Title string `xml:"work-title"`
+}
+
+type xmlIdentification struct {
+	Software string `xml:"encoding>software"`
+}
+
+type xmlScorePart struct {
+	ID             string             `xml:"id,attr"`
+	Name           string             `xml:"part-name"`
+	MIDIInstrument *xmlMIDIInstrument `xml:"midi-instrument,omitempty"`
+}
+
+type xmlMIDIInstrument struct {
+	ID      string `xml:"id,attr"`
+	Channel int    `xml:"midi-channel"`
+	Program int    `xml:"midi-program,omitempty"`
+}
+
+type xmlPart struct {
+	ID       string       `xml:"id,attr"`
+	Measures []xmlMeasure `xml:"measure"`
+}
+
+type xmlMeasure struct {
+	Number   int `xml:"number,attr"`
+	Contents []any
+}
+
+type xmlAttributes struct {
+	XMLName   xml.Name `xml:"attributes"`
+	Divisions int      `xml:"divisions,omitempty"`
+	Key       *xmlKey  `xml:"key,omitempty"`
+	Time      *xmlTime `xml:"time,omitempty"`
+	Clef      *xmlClef `xml:"clef,omitempty"`
+}
+
+type xmlKey struct {
+	Fifths int    `xml:"fifths"`
+	Mode   string `xml:"mode"`
+}
+
+type xmlTime struct {
+	Beats    int `xml:"beats"`
+	BeatType int `xml:"beat-type"`
+}
+
+type xmlClef struct {
+	Sign string `xml:"sign"`
+	Line int    `xml:"line,omitempty"`
+}
+
+type xmlDirection struct {
+	XMLName   xml.Name `xml:"direction"`
+	Placement string   `xml:"placement,attr"`
+	BeatUnit  string   `xml:"direction-type>metronome>beat-unit"`
+	PerMinute string   `xml:"direction-type>metronome>per-minute"`
+	Offset    int      `xml:"offset,omitempty"`
+	Sound     struct {
+		Tempo string `xml:"tempo,attr"`
+	} `xml:"sound"`
+}
+
+type xmlBackup struct {
+	XMLName  xml.Name `xml:"backup"`
+	Duration int      `xml:"duration"`
+}
+
+type xmlNote struct {
+	XMLName   xml.Name      `xml:"note"`
+	Dynamics  string        `xml:"dynamics,attr,omitempty"`
+	Chord     *struct{}     `xml:"chord,omitempty"`
+	Pitch     *xmlPitch     `xml:"pitch,omitempty"`
+	Rest      *xmlRest      `xml:"rest,omitempty"`
+	Duration  int           `xml:"duration"`
+	Ties      []xmlTie      `xml:"tie"`
+	Voice     int           `xml:"voice"`
+	Type      string        `xml:"type,omitempty"`
+	Dots      []struct{}    `xml:"dot"`
+	Notations *xmlNotations `xml:"notations,omitempty"`
+}
+
+type xmlPitch struct {
+	Step   string `xml:"step"`
+	Alter  int    `xml:"alter,omitempty"`
+	Octave int    `xml:"octave"`
+}
+
+type xmlRest struct {
+	Measure string `xml:"measure,attr,omitempty"`
+}
+
+type xmlTie struct {
+	Type string `xml:"type,attr"`
+}
+
+type xmlNotations struct {
+	Tied []xmlTie `xml:"tied"`
+}
+
+var noteTypes = map[int]string{
+	1:  "whole",
+	2:  "half",
+	4:  "quarter",
+	8:  "eighth",
+	16: "16th",
+	32: "32nd",
+	64: "64th",
+}
+
+func NewMusicXML() (*MusicXML, error) {
+	return &MusicXML{
+		Quantise: 16,
+	}, nil
+}
+
+func (x *MusicXML) Export(smf *midi.SMF, w io.Writer) error {
+	score, err := notation.Notation{Quantise: x.Quantise}.Transcribe(smf)
+	if err != nil {
+		return err
+	}
+
+	object := xmlScore{
+		Version: "3.1",
+		Identification: xmlIdentification{
+			Software: "midiasm",
+		},
+	}
+
+	if score.Title != "" {
+		object.Work = &xmlWork{Title: score.Title}
+	}
+
+	for _, p := range score.Parts {
+		part := xmlScorePart{
+			ID:   p.ID,
+			Name: p.Name,
+			MIDIInstrument: &xmlMIDIInstrument{
+				ID:      p.ID + "-I1",
+				Channel: int(p.Channel) + 1,
+			},
+		}
+
+		if p.Program >= 0 {
+			part.MIDIInstrument.Program = p.Program + 1
+		}
+
+		object.PartList = append(object.PartList, part)
+		object.Parts = append(object.Parts, x.part(score, p))
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	} else if _, err := io.WriteString(w, `<!DOCTYPE score-partwise PUBLIC "-//Recordare//DTD MusicXML 3.1 Partwise//EN" "http://www.musicxml.org/dtds/partwise.dtd">`+"\n"); err != nil {
+		return err
+	}
+
+	if bytes, err := xml.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(append(bytes, '\n')); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (x *MusicXML) part(score *notation.Score, p notation.Part) xmlPart {
+	part := xmlPart{
+		ID: p.ID,
+	}
+
+	for i, m := range score.Measures {
+		measure := xmlMeasure{
+			Number: m.Number,
+		}
+
+		// ... attributes
+		attributes := xmlAttributes{}
+		if i == 0 {
+			attributes.Divisions = score.Divisions
+			attributes.Clef = clef(p.Clef)
+		}
+
+		if m.Key != nil {
+			attributes.Key = &xmlKey{Fifths: m.Key.Fifths, Mode: fmt.Sprintf("%v", m.Key.Mode)}
+		}
+
+		if m.Time != nil {
+			attributes.Time = &xmlTime{Beats: m.Time.Beats, BeatType: m.Time.BeatType}
+		}
+
+		if attributes.Divisions > 0 || attributes.Key != nil || attributes.Time != nil {
+			measure.Contents = append(measure.Contents, attributes)
+		}
+
+		// ... tempo
+		for _, t := range m.Tempo {
+			direction := xmlDirection{
+				Placement: "above",
+				BeatUnit:  "quarter",
+				PerMinute: strconv.FormatFloat(math.Round(t.BPM), 'f', -1, 64),
+				Offset:    t.Offset,
+			}
+
+			direction.Sound.Tempo = strconv.FormatFloat(t.BPM, 'f', -1, 64)
+			measure.Contents = append(measure.Contents, direction)
+		}
+
+		// ... voices
+		for j, v := range p.Measures[i].Voices {
+			if j > 0 {
+				measure.Contents = append(measure.Contents, xmlBackup{Duration: m.Length})
+			}
+
+			for _, e := range v.Elements {
+				measure.Contents = append(measure.Contents, notes(e, v.Number)...)
+			}
+		}
+
+		part.Measures = append(part.Measures, measure)
+	}
+
+	return part
+}
+
+func notes(e notation.Element, voice int) []any {
+	if e.Rest {
+		note := xmlNote{
+			Rest:     &xmlRest{},
+			Duration: e.Duration,
+			Voice:    voice,
+			Type:     noteTypes[e.Value],
+			Dots:     make([]struct{}, e.Dots),
+		}
+
+		if e.Value == 0 {
+			note.Rest.Measure = "yes"
+		}
+
+		return []any{note}
+	}
+
+	list := []any{}
+	for i, p := range e.Pitches {
+		note := xmlNote{
+			Dynamics: fmt.Sprintf("%.2f", float64(e.Velocity)*100/90),
+			Pitch: &xmlPitch{
+				Step:   p.Step,
+				Alter:  p.Alter,
+				Octave: p.Octave,
+			},
+			Duration: e.Duration,
+			Voice:    voice,
+			Type:     noteTypes[e.Value],
+			Dots:     make([]struct{}, e.Dots),
+		}
+
+		if i > 0 {
+			note.Chord = &struct{}{}
+		}
+
+		if e.TieStop || e.TieStart {
+			note.Notations = &xmlNotations{}
+		}
+
+		if e.TieStop {
+			note.Ties = append(note.Ties, xmlTie{"stop"})
+			note.Notations.Tied = append(note.Notations.Tied, xmlTie{"stop"})
+		}
+
+		if e.TieStart {
+			note.Ties = append(note.Ties, xmlTie{"start"})
+			note.Notations.Tied = append(note.Notations.Tied, xmlTie{"start"})
+		}
+
+		list = append(list, note)
+	}
+
+	return list
+}
+
+func clef(c notation.Clef) *xmlClef {
+	switch c {
+	case notation.Bass:
+		return &xmlClef{Sign: "F", Line: 4}
+
+	case notation.Percussion:
+		return &xmlClef{Sign: "percussion"}
+
+	default:
+		return &xmlClef{Sign: "G", Line: 2}
+	}
+}
@@ -0,0 +1,30 @@
+package export
+
+import (
+	"bytes"
+	_ "embed"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+//go:embed test-files/reference.musicxml
+var referenceMusicXML []byte
+
+func TestMusicXMLExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewMusicXML()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting MusicXML (%v)", err)
+	}
+
+	if b.String() != string(referenceMusicXML) {
+		t.Errorf("incorrectly exported MusicXML\nexpected:\n%v\ngot:\n%v", string(referenceMusicXML), b.String())
+	}
+}
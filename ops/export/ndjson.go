@@ -0,0 +1,80 @@
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/timing"
+	"github.com/transcriptaze/midiasm/ops/schema"
+)
+
+// NDJSON exports a MIDI file as newline delimited JSON i.e. a 'header' record, followed by a
+// 'track' record and an 'event' record for each event in each track, one JSON object per line.
+type NDJSON struct {
+}
+
+type header struct {
+	Record  string     `json:"record"`
+	Version int        `json:"version"`
+	Header  *midi.MThd `json:"header"`
+}
+
+type track struct {
+	Record string `json:"record"`
+	Track  int    `json:"track"`
+	Tag    string `json:"tag"`
+}
+
+type event struct {
+	Record  string        `json:"record"`
+	Track   int           `json:"track"`
+	Tick    uint64        `json:"tick"`
+	Delta   uint32        `json:"delta"`
+	Seconds float64       `json:"seconds"`
+	Event   events.IEvent `json:"event"`
+}
+
+func NewNDJSON() (*NDJSON, error) {
+	return &NDJSON{}, nil
+}
+
+func (x *NDJSON) Export(smf *midi.SMF, w io.Writer) error {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return err
+	}
+
+	b := bufio.NewWriter(w)
+	encoder := json.NewEncoder(b)
+
+	if err := encoder.Encode(header{Record: "header", Version: schema.Version, Header: smf.MThd}); err != nil {
+		return err
+	}
+
+	for i, mtrk := range smf.Tracks {
+		if err := encoder.Encode(track{Record: "track", Track: i, Tag: mtrk.Tag}); err != nil {
+			return err
+		}
+
+		for _, e := range mtrk.Events {
+			record := event{
+				Record:  "event",
+				Track:   i,
+				Tick:    e.Tick(),
+				Delta:   e.Delta(),
+				Seconds: math.Round(tempoMap.Time(e.Tick()).Seconds()*1000000) / 1000000,
+				Event:   e.Event,
+			}
+
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return b.Flush()
+}
@@ -0,0 +1,63 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+func TestNDJSONExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewNDJSON()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting NDJSON (%v)", err)
+	}
+
+	expected := 1 + len(smf.Tracks)
+	for _, track := range smf.Tracks {
+		expected += len(track.Events)
+	}
+
+	records := []map[string]any{}
+	scanner := bufio.NewScanner(&b)
+	for scanner.Scan() {
+		record := map[string]any{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("invalid NDJSON record %v (%v)", len(records)+1, err)
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) != expected {
+		t.Fatalf("incorrect number of NDJSON records - expected:%v, got:%v", expected, len(records))
+	}
+
+	if records[0]["record"] != "header" || records[0]["version"] != 1.0 {
+		t.Errorf("incorrect NDJSON header record - got:%v", records[0])
+	}
+
+	if records[1]["record"] != "track" || records[1]["track"] != 0.0 {
+		t.Errorf("incorrect NDJSON track record - got:%v", records[1])
+	}
+
+	last := records[len(records)-1]
+	if last["record"] != "event" || last["track"] != float64(len(smf.Tracks)-1) {
+		t.Errorf("incorrect NDJSON event record - got:%v", last)
+	}
+
+	for _, k := range []string{"tick", "delta", "seconds", "event"} {
+		if _, ok := last[k]; !ok {
+			t.Errorf("NDJSON event record missing '%v' - got:%v", k, last)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/ops/schema"
+)
+
+// YAML exports a MIDI file as a YAML document with the same document model as the JSON export,
+// with each event on a single line (in YAML flow style) and the track name as a comment.
+type YAML struct {
+}
+
+func NewYAML() (*YAML, error) {
+	return &YAML{}, nil
+}
+
+func (x *YAML) Export(smf *midi.SMF, w io.Writer) error {
+	header, err := toNode(smf.MThd)
+	if err != nil {
+		return err
+	}
+
+	tracks := &yaml.Node{Kind: yaml.SequenceNode}
+
+	for i, mtrk := range smf.Tracks {
+		list := &yaml.Node{Kind: yaml.SequenceNode}
+
+		for _, e := range mtrk.Events {
+			if v, err := toNode(e); err != nil {
+				return err
+			} else {
+				v.Content[1].Style = yaml.FlowStyle
+				list.Content = append(list.Content, v)
+			}
+		}
+
+		track := mapping(
+			"tag", scalar(mtrk.Tag),
+			"track-number", scalar(fmt.Sprintf("%d", uint(mtrk.TrackNumber))),
+			"events", list)
+
+		track.HeadComment = fmt.Sprintf("track %v", i)
+		if name := trackName(mtrk); name != "" {
+			track.HeadComment = fmt.Sprintf("track %v: %v", i, name)
+		}
+
+		tracks.Content = append(tracks.Content, track)
+	}
+
+	document := mapping(
+		"version", scalar(fmt.Sprintf("%v", schema.Version)),
+		"header", header,
+		"tracks", tracks)
+
+	document.HeadComment = fmt.Sprintf("midiasm YAML (format version %v)", schema.Version)
+
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(document); err != nil {
+		return err
+	} else if err := encoder.Close(); err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+
+	return err
+}
+
+// toNode converts a value to a YAML node via its JSON encoding, preserving the field order.
+func toNode(v any) (*yaml.Node, error) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(bytes)))
+	decoder.UseNumber()
+
+	return parse(decoder)
+}
+
+func parse(decoder *json.Decoder) (*yaml.Node, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			node := &yaml.Node{Kind: yaml.MappingNode}
+			for decoder.More() {
+				if key, err := decoder.Token(); err != nil {
+					return nil, err
+				} else if value, err := parse(decoder); err != nil {
+					return nil, err
+				} else {
+					node.Content = append(node.Content, scalar(fmt.Sprintf("%v", key)), value)
+				}
+			}
+
+			_, err := decoder.Token()
+
+			return node, err
+
+		case '[':
+			node := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+			for decoder.More() {
+				if value, err := parse(decoder); err != nil {
+					return nil, err
+				} else {
+					node.Content = append(node.Content, value)
+				}
+			}
+
+			_, err := decoder.Token()
+
+			return node, err
+		}
+
+	case string:
+		node := &yaml.Node{}
+		node.SetString(t)
+
+		return node, nil
+
+	case json.Number:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: t.String()}, nil
+
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: fmt.Sprintf("%v", t)}, nil
+
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected JSON token (%v)", token)
+}
+
+func mapping(kv ...any) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+
+	for i := 0; i+1 < len(kv); i += 2 {
+		node.Content = append(node.Content, scalar(fmt.Sprintf("%v", kv[i])), kv[i+1].(*yaml.Node))
+	}
+
+	return node
+}
+
+func scalar(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Value: s}
+}
+
+func trackName(mtrk *midi.MTrk) string {
+	for _, e := range mtrk.Events {
+		if v, ok := e.Event.(metaevent.TrackName); ok {
+			return v.Name
+		}
+	}
+
+	return ""
+}
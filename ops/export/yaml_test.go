@@ -0,0 +1,60 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+func TestYAMLExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(referenceMIDI))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	op, _ := NewYAML()
+	if err := op.Export(smf, &b); err != nil {
+		t.Fatalf("error exporting YAML (%v)", err)
+	}
+
+	document := struct {
+		Version int `yaml:"version"`
+		Header  struct {
+			Tag      string `yaml:"tag"`
+			Format   int    `yaml:"format"`
+			Division int    `yaml:"division"`
+		} `yaml:"header"`
+		Tracks []struct {
+			Tag    string           `yaml:"tag"`
+			Events []map[string]any `yaml:"events"`
+		} `yaml:"tracks"`
+	}{}
+
+	if err := yaml.Unmarshal(b.Bytes(), &document); err != nil {
+		t.Fatalf("error unmarshalling exported YAML (%v)", err)
+	}
+
+	if document.Version != 1 || document.Header.Tag != "MThd" || document.Header.Division != 480 {
+		t.Errorf("incorrectly exported YAML header\n%v", b.String())
+	}
+
+	if len(document.Tracks) != len(smf.Tracks) {
+		t.Fatalf("incorrect number of tracks - expected:%v, got:%v", len(smf.Tracks), len(document.Tracks))
+	}
+
+	for i, track := range document.Tracks {
+		if len(track.Events) != len(smf.Tracks[i].Events) {
+			t.Errorf("track %v: incorrect number of events - expected:%v, got:%v", i, len(smf.Tracks[i].Events), len(track.Events))
+		}
+	}
+
+	if !strings.Contains(b.String(), "      - event: {tag: EndOfTrack, delta: 0, status: 255, type: 47}\n") {
+		t.Errorf("incorrectly exported YAML event\n%v", b.String())
+	}
+}
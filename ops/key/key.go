@@ -0,0 +1,300 @@
+package key
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/log"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/midi/timing"
+	"github.com/transcriptaze/midiasm/ops/notes"
+)
+
+const LOG_TAG = "key"
+
+// Key estimates the key of a MIDI file by correlating the pitch class distribution of the
+// notes against the Krumhansl-Kessler major and minor key profiles. The piece is analysed
+// in windows of Window bars (or as a whole if Window is 0) and consecutive windows in the
+// same key are merged.
+type Key struct {
+	Window uint
+}
+
+type Estimate struct {
+	StartBar   int
+	EndBar     int
+	StartTick  uint64
+	EndTick    uint64
+	Scale      lib.Scale
+	Confidence float64
+}
+
+// Ref. https://rnhart.net/articles/key-finding
+var profiles = map[lib.KeyType][12]float64{
+	lib.Major: {6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88},
+	lib.Minor: {6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17},
+}
+
+const percussion = lib.Channel(9)
+
+func (k Key) Detect(smf *midi.SMF) ([]Estimate, error) {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := notes.Extract(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	// ... analysis windows
+	end := uint64(0)
+	for _, n := range list {
+		if n.EndTick > end {
+			end = n.EndTick
+		}
+	}
+
+	if end == 0 {
+		return []Estimate{}, nil
+	}
+
+	type window struct {
+		bar   int
+		start uint64
+		end   uint64
+	}
+
+	windows := []window{}
+	if k.Window == 0 {
+		windows = append(windows, window{bar: 1, start: 0, end: end})
+	} else {
+		for bar := 1; tempoMap.Bar(bar) < end; bar += int(k.Window) {
+			windows = append(windows, window{
+				bar:   bar,
+				start: tempoMap.Bar(bar),
+				end:   tempoMap.Bar(bar + int(k.Window)),
+			})
+		}
+	}
+
+	// ... estimate key for each window
+	estimates := []Estimate{}
+	for _, w := range windows {
+		histogram := [12]float64{}
+		for _, n := range list {
+			if n.Channel != percussion && n.StartTick < w.end && n.EndTick > w.start {
+				start := max(n.StartTick, w.start)
+				end := min(n.EndTick, w.end)
+
+				histogram[n.Note%12] += float64(end - start)
+			}
+		}
+
+		if scale, r, ok := estimate(histogram); ok {
+			debugf("bar %-4v  %-9v  %.3f", w.bar, scale.Name, r)
+
+			estimates = append(estimates, Estimate{
+				StartBar:   w.bar,
+				EndBar:     tempoMap.Position(min(w.end, end) - 1).Bar,
+				StartTick:  w.start,
+				EndTick:    min(w.end, end),
+				Scale:      scale,
+				Confidence: r,
+			})
+		}
+	}
+
+	// ... merge consecutive windows in the same key
+	merged := []Estimate{}
+	count := 0
+	for _, e := range estimates {
+		if N := len(merged); N > 0 && merged[N-1].Scale.Name == e.Scale.Name {
+			p := &merged[N-1]
+			p.EndBar = e.EndBar
+			p.EndTick = e.EndTick
+			p.Confidence = (p.Confidence*float64(count) + e.Confidence) / float64(count+1)
+			count++
+		} else {
+			merged = append(merged, e)
+			count = 1
+		}
+	}
+
+	return merged, nil
+}
+
+// Insert replaces the KeySignature events in all the tracks with the estimated keys in track 0
+// and returns the encoded MIDI file.
+func (k Key) Insert(smf *midi.SMF, estimates []Estimate) ([]byte, error) {
+	if len(smf.Tracks) == 0 {
+		return nil, fmt.Errorf("missing track 0")
+	}
+
+	for _, track := range smf.Tracks {
+		eventlist := []*events.Event{}
+		for _, e := range track.Events {
+			if events.Is[metaevent.KeySignature](*e) {
+				warnf("replacing existing KeySignature event in track %v at tick %v", uint(track.TrackNumber), e.Tick())
+			} else {
+				eventlist = append(eventlist, e)
+			}
+		}
+
+		if len(eventlist) != len(track.Events) {
+			track.Events = eventlist
+			if err := track.Insert(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	list := []events.IEvent{}
+	for _, e := range estimates {
+		list = append(list, metaevent.MakeKeySignature(e.StartTick, 0, e.Scale.Accidentals, e.Scale.Type))
+	}
+
+	if err := smf.Tracks[0].Insert(list...); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+func Print(estimates []Estimate, w io.Writer) error {
+	for _, e := range estimates {
+		bars := fmt.Sprintf("%v:%v", e.StartBar, e.EndBar)
+
+		if _, err := fmt.Fprintf(w, "bars %-9v  %-9v  confidence:%.2f\n", bars, e.Scale.Name, e.Confidence); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func Export(estimates []Estimate, w io.Writer) error {
+	type key struct {
+		StartBar    int         `json:"start-bar"`
+		EndBar      int         `json:"end-bar"`
+		StartTick   uint64      `json:"start-tick"`
+		EndTick     uint64      `json:"end-tick"`
+		Key         string      `json:"key"`
+		Accidentals int8        `json:"accidentals"`
+		KeyType     lib.KeyType `json:"key-type"`
+		Confidence  float64     `json:"confidence"`
+	}
+
+	object := struct {
+		Keys []key `json:"keys"`
+	}{
+		Keys: []key{},
+	}
+
+	for _, e := range estimates {
+		object.Keys = append(object.Keys, key{
+			StartBar:    e.StartBar,
+			EndBar:      e.EndBar,
+			StartTick:   e.StartTick,
+			EndTick:     e.EndTick,
+			Key:         e.Scale.Name,
+			Accidentals: e.Scale.Accidentals,
+			KeyType:     e.Scale.Type,
+			Confidence:  math.Round(e.Confidence*1000) / 1000,
+		})
+	}
+
+	if bytes, err := json.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(bytes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// estimate returns the scale with the highest correlation to the pitch class histogram,
+// preferring the scale with fewer accidentals for enharmonically equivalent keys.
+func estimate(histogram [12]float64) (lib.Scale, float64, bool) {
+	var best lib.Scale
+	var r = math.Inf(-1)
+	var ok = false
+
+	for _, scales := range [][]lib.Scale{lib.MAJOR_SCALES, lib.MINOR_SCALES} {
+		for _, scale := range scales {
+			profile := profiles[scale.Type]
+			tonic := scale.Notes[0].Ord
+			rotated := [12]float64{}
+
+			for pc := 0; pc < 12; pc++ {
+				rotated[pc] = profile[(pc-tonic+12)%12]
+			}
+
+			if v, valid := correlate(histogram, rotated); !valid {
+				continue
+			} else if !ok || v > r+1e-9 || (math.Abs(v-r) <= 1e-9 && abs(scale.Accidentals) < abs(best.Accidentals)) {
+				best = scale
+				r = v
+				ok = true
+			}
+		}
+	}
+
+	return best, r, ok
+}
+
+// correlate returns the Pearson correlation coefficient of two pitch class distributions.
+func correlate(x, y [12]float64) (float64, bool) {
+	mx := 0.0
+	my := 0.0
+	for i := 0; i < 12; i++ {
+		mx += x[i] / 12
+		my += y[i] / 12
+	}
+
+	sxy := 0.0
+	sxx := 0.0
+	syy := 0.0
+	for i := 0; i < 12; i++ {
+		sxy += (x[i] - mx) * (y[i] - my)
+		sxx += (x[i] - mx) * (x[i] - mx)
+		syy += (y[i] - my) * (y[i] - my)
+	}
+
+	if sxx == 0 || syy == 0 {
+		return 0, false
+	}
+
+	return sxy / math.Sqrt(sxx*syy), true
+}
+
+func abs(v int8) int8 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
+
+func warnf(format string, args ...any) {
+	log.Warnf(LOG_TAG, format, args...)
+}
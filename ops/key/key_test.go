@@ -0,0 +1,153 @@
+package key
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		notes    []int
+		expected string
+	}{
+		{[]int{0, 2, 4, 5, 7, 9, 11, 0, 4, 7}, "C major"},
+		{[]int{7, 9, 11, 0, 2, 4, 6, 7, 11, 2}, "G major"},
+		{[]int{9, 11, 0, 2, 4, 5, 8, 9, 0, 4}, "A minor"},
+		{[]int{10, 0, 2, 3, 5, 7, 9, 10, 2, 5}, "B♭ major"},
+		{[]int{1, 3, 5, 6, 8, 10, 0, 1, 5, 8}, "D♭ major"},
+	}
+
+	for _, test := range tests {
+		histogram := [12]float64{}
+		for _, n := range test.notes {
+			histogram[n] += 1
+		}
+
+		if scale, _, ok := estimate(histogram); !ok {
+			t.Errorf("Failed to estimate key for %v", test.notes)
+		} else if scale.Name != test.expected {
+			t.Errorf("Incorrectly estimated key for %v - expected:%v, got:%v", test.notes, test.expected, scale.Name)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	note := func(tick uint64, value byte) []*events.Event {
+		n := midievent.Note{Value: value}
+		return []*events.Event{
+			&events.Event{Event: midievent.MakeNoteOn(tick, 0, 0, n, 64)},
+			&events.Event{Event: midievent.MakeNoteOff(tick+480, 480, 0, n, 64)},
+		}
+	}
+
+	track := midi.MTrk{}
+	for i, v := range []byte{60, 62, 64, 65, 67, 69, 71, 72, 67, 69, 71, 72, 74, 76, 78, 79} {
+		track.Events = append(track.Events, note(uint64(i*480), v)...)
+	}
+	track.Events = append(track.Events, &events.Event{Event: metaevent.MakeEndOfTrack(7680, 0)})
+
+	smf := midi.SMF{
+		MThd: &midi.MThd{Format: 1, Tracks: 2, PPQN: 480, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTempo(0, 0, 500000)},
+					&events.Event{Event: metaevent.MakeTimeSignature(0, 0, 4, 4, 24, 8)},
+				},
+			},
+			&track,
+		},
+	}
+
+	estimates, err := Key{Window: 2}.Detect(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(estimates) != 2 {
+		t.Fatalf("Incorrect number of keys - expected:%v, got:%v (%+v)", 2, len(estimates), estimates)
+	}
+
+	if estimates[0].Scale.Name != "C major" || estimates[0].StartBar != 1 || estimates[0].EndBar != 2 {
+		t.Errorf("Incorrect key - expected:%v, got:%+v", "C major (bars 1:2)", estimates[0])
+	}
+
+	if estimates[1].Scale.Name != "G major" || estimates[1].StartBar != 3 || estimates[1].EndBar != 4 || estimates[1].StartTick != 3840 {
+		t.Errorf("Incorrect key - expected:%v, got:%+v", "G major (bars 3:4)", estimates[1])
+	}
+
+	if estimates[1].Scale.Type != lib.Major || estimates[1].Scale.Accidentals != 1 {
+		t.Errorf("Incorrect key signature - expected:%v, got:%+v", "1 sharp", estimates[1].Scale)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	n := midievent.Note{Value: 60}
+
+	mthd := midi.MakeMThd(1, 2, 480)
+	smf := midi.SMF{
+		MThd: &mthd,
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Tag: "MTrk",
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeKeySignature(0, 0, 2, lib.Major)},
+					&events.Event{Event: metaevent.MakeEndOfTrack(960, 960)},
+				},
+			},
+			&midi.MTrk{
+				Tag:         "MTrk",
+				TrackNumber: 1,
+				Events: []*events.Event{
+					&events.Event{Event: midievent.MakeNoteOn(0, 0, 0, n, 64)},
+					&events.Event{Event: metaevent.MakeKeySignature(240, 240, -3, lib.Minor)},
+					&events.Event{Event: midievent.MakeNoteOff(480, 240, 0, n, 64)},
+					&events.Event{Event: metaevent.MakeEndOfTrack(960, 480)},
+				},
+			},
+		},
+	}
+
+	estimates := []Estimate{
+		{StartTick: 0, Scale: lib.G_MAJOR},
+	}
+
+	encoded, err := Key{}.Insert(&smf, estimates)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	decoded, err := midifile.NewDecoder().Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	expected := [][]string{
+		{"0 KeySignature 1", "960 EndOfTrack"},
+		{"0 NoteOn", "480 NoteOff", "960 EndOfTrack"},
+	}
+
+	for i, track := range decoded.Tracks {
+		list := []string{}
+		for _, e := range track.Events {
+			if v, ok := e.Event.(metaevent.KeySignature); ok {
+				list = append(list, fmt.Sprintf("%v %v %v", e.Tick(), e.Event.Tag(), v.Accidentals))
+			} else {
+				list = append(list, fmt.Sprintf("%v %v", e.Tick(), e.Event.Tag()))
+			}
+		}
+
+		if !reflect.DeepEqual(list, expected[i]) {
+			t.Errorf("Incorrect track %v events\n   expected:%q\n   got:     %q", i, expected[i], list)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package lyrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/transcriptaze/midiasm/log"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/timing"
+)
+
+const LOG_TAG = "lyrics"
+
+type Format int
+
+const (
+	LRC Format = iota
+	SRT
+	WebVTT
+)
+
+// Lyrics extracts the lyrics from a MIDI (or .kar karaoke) file. The syllables are taken from
+// the Lyric events or, if the file does not have any Lyric events, from the Text events in
+// the .kar 'words' track (i.e. the track with the most Text events).
+//
+// The .kar conventions are supported i.e. Text events starting with '@' are header fields
+// (@T title, @L language, @I information) and a syllable starting with '/' starts a new line
+// while a syllable starting with '\' starts a new paragraph. A Lyric ending in a carriage
+// return or line feed ends the line.
+type Lyrics struct {
+	Enhanced bool
+}
+
+type Song struct {
+	Title    []string
+	Language string
+	Info     []string
+	Lines    []Line
+}
+
+type Line struct {
+	Start     time.Duration
+	End       time.Duration
+	Tick      uint64
+	Paragraph bool
+	Syllables []Syllable
+}
+
+type Syllable struct {
+	Text string
+	At   time.Duration
+	Tick uint64
+}
+
+// Time for which a line is displayed after the last syllable, if the next line starts later.
+const linger = 3 * time.Second
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "lrc":
+		return LRC, nil
+
+	case "srt":
+		return SRT, nil
+
+	case "vtt", "webvtt":
+		return WebVTT, nil
+
+	default:
+		return LRC, fmt.Errorf("invalid lyrics format (%v): expected 'lrc', 'srt' or 'vtt'", s)
+	}
+}
+
+// Extension returns the default file extension for the format.
+func (f Format) Extension() string {
+	switch f {
+	case SRT:
+		return ".srt"
+	case WebVTT:
+		return ".vtt"
+	default:
+		return ".lrc"
+	}
+}
+
+func (l Lyrics) Extract(smf *midi.SMF) (*Song, error) {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	type syllable struct {
+		tick uint64
+		text string
+	}
+
+	song := Song{}
+	lyrics := []syllable{}
+	texts := map[int][]syllable{}
+
+	for i, track := range smf.Tracks {
+		for _, e := range track.Events {
+			switch v := e.Event.(type) {
+			case metaevent.Lyric:
+				lyrics = append(lyrics, syllable{e.Tick(), v.Lyric})
+
+			case metaevent.Text:
+				switch {
+				case strings.HasPrefix(v.Text, "@T"):
+					song.Title = append(song.Title, strings.TrimSpace(v.Text[2:]))
+
+				case strings.HasPrefix(v.Text, "@L"):
+					song.Language = strings.TrimSpace(v.Text[2:])
+
+				case strings.HasPrefix(v.Text, "@I"):
+					song.Info = append(song.Info, strings.TrimSpace(v.Text[2:]))
+
+				case strings.HasPrefix(v.Text, "@"):
+					debugf("ignoring .kar header %q", v.Text)
+
+				default:
+					texts[i] = append(texts[i], syllable{e.Tick(), v.Text})
+				}
+			}
+		}
+	}
+
+	if len(lyrics) == 0 {
+		words := -1
+		for i, list := range texts {
+			if words < 0 || len(list) > len(texts[words]) || (len(list) == len(texts[words]) && i < words) {
+				words = i
+			}
+		}
+
+		if words >= 0 {
+			debugf("using Text events in track %v", words)
+			lyrics = texts[words]
+		}
+	}
+
+	sort.SliceStable(lyrics, func(i, j int) bool {
+		return lyrics[i].tick < lyrics[j].tick
+	})
+
+	// ... join syllables into lines
+	var line *Line
+	paragraph := false
+
+	for _, s := range lyrics {
+		text := s.text
+
+		if strings.HasPrefix(text, "\\") {
+			line, paragraph = nil, true
+			text = text[1:]
+		} else if strings.HasPrefix(text, "/") {
+			line = nil
+			text = text[1:]
+		}
+
+		eol := strings.HasSuffix(text, "\r") || strings.HasSuffix(text, "\n")
+		text = strings.TrimRight(text, "\r\n")
+
+		if text != "" {
+			if line == nil {
+				song.Lines = append(song.Lines, Line{
+					Start:     tempoMap.Time(s.tick),
+					Tick:      s.tick,
+					Paragraph: paragraph && len(song.Lines) > 0,
+				})
+
+				line = &song.Lines[len(song.Lines)-1]
+				paragraph = false
+			}
+
+			line.Syllables = append(line.Syllables, Syllable{
+				Text: text,
+				At:   tempoMap.Time(s.tick),
+				Tick: s.tick,
+			})
+		}
+
+		if eol {
+			line = nil
+		}
+	}
+
+	for i := range song.Lines {
+		line := &song.Lines[i]
+		last := line.Syllables[len(line.Syllables)-1]
+
+		line.End = last.At + linger
+		if i+1 < len(song.Lines) && song.Lines[i+1].Start < line.End {
+			line.End = song.Lines[i+1].Start
+		}
+	}
+
+	return &song, nil
+}
+
+// Text returns the line text i.e. the joined syllables.
+func (l Line) Text() string {
+	var b strings.Builder
+
+	for _, s := range l.Syllables {
+		b.WriteString(s.Text)
+	}
+
+	return strings.TrimSpace(b.String())
+}
+
+func (l Lyrics) Write(song *Song, format Format, w io.Writer) error {
+	switch format {
+	case SRT:
+		return l.srt(song, w)
+
+	case WebVTT:
+		return l.vtt(song, w)
+
+	default:
+		return l.lrc(song, w)
+	}
+}
+
+// lrc writes the lyrics as an LRC file, with the enhanced LRC <mm:ss.xx> word times if
+// Enhanced is set. A paragraph break is written as an empty line at the end of the previous
+// line.
+func (l Lyrics) lrc(song *Song, w io.Writer) error {
+	var b strings.Builder
+
+	if len(song.Title) > 0 {
+		fmt.Fprintf(&b, "[ti:%v]\n", song.Title[0])
+	}
+
+	if len(song.Title) > 1 {
+		fmt.Fprintf(&b, "[ar:%v]\n", song.Title[1])
+	}
+
+	for _, info := range song.Info {
+		fmt.Fprintf(&b, "[#:%v]\n", info)
+	}
+
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+
+	for i, line := range song.Lines {
+		if line.Paragraph && i > 0 {
+			fmt.Fprintf(&b, "[%v]\n", lrctime(song.Lines[i-1].End))
+		}
+
+		fmt.Fprintf(&b, "[%v]", lrctime(line.Start))
+
+		if l.Enhanced {
+			for _, s := range line.Syllables {
+				fmt.Fprintf(&b, "<%v>%v", lrctime(s.At), s.Text)
+			}
+			b.WriteString("\n")
+		} else {
+			fmt.Fprintf(&b, "%v\n", line.Text())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func (l Lyrics) srt(song *Song, w io.Writer) error {
+	var b strings.Builder
+
+	for i, line := range song.Lines {
+		fmt.Fprintf(&b, "%v\n", i+1)
+		fmt.Fprintf(&b, "%v --> %v\n", timestamp(line.Start, ","), timestamp(line.End, ","))
+		fmt.Fprintf(&b, "%v\n\n", line.Text())
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// vtt writes the lyrics as a WebVTT file, with the WebVTT <hh:mm:ss.ttt> cue timestamps for
+// each syllable if Enhanced is set.
+func (l Lyrics) vtt(song *Song, w io.Writer) error {
+	var b strings.Builder
+
+	b.WriteString("WEBVTT\n")
+	if len(song.Title) > 0 {
+		fmt.Fprintf(&b, "\nNOTE %v\n", strings.Join(song.Title, " - "))
+	}
+
+	for _, line := range song.Lines {
+		fmt.Fprintf(&b, "\n%v --> %v\n", timestamp(line.Start, "."), timestamp(line.End, "."))
+
+		if l.Enhanced {
+			for i, s := range line.Syllables {
+				if i > 0 {
+					fmt.Fprintf(&b, "<%v>", timestamp(s.At, "."))
+				}
+				b.WriteString(s.Text)
+			}
+			b.WriteString("\n")
+		} else {
+			fmt.Fprintf(&b, "%v\n", line.Text())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// lrctime formats a time as an LRC mm:ss.xx timestamp.
+func lrctime(t time.Duration) string {
+	cs := (t.Milliseconds() + 5) / 10
+
+	return fmt.Sprintf("%02d:%02d.%02d", cs/6000, (cs/100)%60, cs%100)
+}
+
+// timestamp formats a time as an SRT (hh:mm:ss,ttt) or WebVTT (hh:mm:ss.ttt) timestamp.
+func timestamp(t time.Duration, separator string) string {
+	ms := t.Milliseconds()
+
+	return fmt.Sprintf("%02d:%02d:%02d%v%03d", ms/3600000, (ms/60000)%60, (ms/1000)%60, separator, ms%1000)
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
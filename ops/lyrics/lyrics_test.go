@@ -0,0 +1,211 @@
+package lyrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+)
+
+// kar is a .kar karaoke file at 120 BPM (480 ticks per second) with the words in the Text
+// events of track 2.
+func kar() *midi.SMF {
+	text := func(tick uint64, s string) *events.Event {
+		return &events.Event{Event: metaevent.MakeText(tick, 0, s)}
+	}
+
+	return &midi.SMF{
+		MThd: &midi.MThd{Format: 1, Tracks: 3, PPQN: 480, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTempo(0, 0, 500000)},
+					text(0, "Converted by somebody"),
+					&events.Event{Event: metaevent.MakeEndOfTrack(9600, 0)},
+				},
+			},
+			&midi.MTrk{
+				TrackNumber: 1,
+				Events: []*events.Event{
+					text(0, "@KMIDI KARAOKE FILE"),
+					text(0, "@LENGL"),
+					text(0, "@TTwinkle Twinkle"),
+					text(0, "@TTraditional"),
+					&events.Event{Event: metaevent.MakeEndOfTrack(9600, 0)},
+				},
+			},
+			&midi.MTrk{
+				TrackNumber: 2,
+				Events: []*events.Event{
+					text(960, "\\Twin"),
+					text(1200, "kle "),
+					text(1440, "twin"),
+					text(1680, "kle"),
+					text(1920, "/Lit"),
+					text(2160, "tle "),
+					text(2400, "star"),
+					text(4800, "\\How "),
+					text(5280, "I "),
+					text(5760, "won"),
+					text(6000, "der"),
+					&events.Event{Event: metaevent.MakeEndOfTrack(9600, 0)},
+				},
+			},
+		},
+	}
+}
+
+func TestExtract(t *testing.T) {
+	song, err := Lyrics{}.Extract(kar())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(song.Title) != 2 || song.Title[0] != "Twinkle Twinkle" || song.Title[1] != "Traditional" || song.Language != "ENGL" {
+		t.Errorf("Incorrect .kar header - got title:%q language:%q", song.Title, song.Language)
+	}
+
+	expected := []struct {
+		text      string
+		start     time.Duration
+		end       time.Duration
+		paragraph bool
+	}{
+		{"Twinkle twinkle", 1 * time.Second, 2 * time.Second, false},
+		{"Little star", 2 * time.Second, 5 * time.Second, false},
+		{"How I wonder", 5 * time.Second, 9250 * time.Millisecond, true},
+	}
+
+	if len(song.Lines) != len(expected) {
+		t.Fatalf("Incorrect number of lines - expected:%v, got:%v", len(expected), len(song.Lines))
+	}
+
+	for i, line := range song.Lines {
+		if line.Text() != expected[i].text {
+			t.Errorf("Incorrect line %v text - expected:%q, got:%q", i+1, expected[i].text, line.Text())
+		}
+
+		if line.Start != expected[i].start || line.End != expected[i].end {
+			t.Errorf("Incorrect line %v times - expected:%v-%v, got:%v-%v", i+1, expected[i].start, expected[i].end, line.Start, line.End)
+		}
+
+		if line.Paragraph != expected[i].paragraph {
+			t.Errorf("Incorrect line %v paragraph - expected:%v, got:%v", i+1, expected[i].paragraph, line.Paragraph)
+		}
+	}
+}
+
+func TestLyricEvents(t *testing.T) {
+	lyric := func(tick uint64, s string) *events.Event {
+		return &events.Event{Event: metaevent.MakeLyric(tick, 0, s)}
+	}
+
+	smf := midi.SMF{
+		MThd: &midi.MThd{Format: 0, Tracks: 1, PPQN: 480, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeText(0, 0, "not a lyric")},
+					lyric(0, "Hel"),
+					lyric(480, "lo\r"),
+					lyric(960, "world\n"),
+					&events.Event{Event: metaevent.MakeEndOfTrack(1920, 0)},
+				},
+			},
+		},
+	}
+
+	song, err := Lyrics{}.Extract(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(song.Lines) != 2 || song.Lines[0].Text() != "Hello" || song.Lines[1].Text() != "world" {
+		t.Errorf("Incorrect lyrics %+v", song.Lines)
+	}
+}
+
+func TestLRC(t *testing.T) {
+	var b bytes.Buffer
+
+	song, err := Lyrics{}.Extract(kar())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "[ti:Twinkle Twinkle]\n" +
+		"[ar:Traditional]\n" +
+		"\n" +
+		"[00:01.00]Twinkle twinkle\n" +
+		"[00:02.00]Little star\n" +
+		"[00:05.00]\n" +
+		"[00:05.00]How I wonder\n"
+
+	if err := (Lyrics{}).Write(song, LRC, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect LRC\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestEnhancedLRC(t *testing.T) {
+	var b bytes.Buffer
+
+	op := Lyrics{Enhanced: true}
+	song, err := op.Extract(kar())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "[00:02.00]<00:02.00>Lit<00:02.25>tle <00:02.50>star\n"
+
+	if err := op.Write(song, LRC, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if !bytes.Contains(b.Bytes(), []byte(expected)) {
+		t.Errorf("Incorrect enhanced LRC\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestSRT(t *testing.T) {
+	var b bytes.Buffer
+
+	song, err := Lyrics{}.Extract(kar())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "1\n00:00:01,000 --> 00:00:02,000\nTwinkle twinkle\n\n" +
+		"2\n00:00:02,000 --> 00:00:05,000\nLittle star\n\n" +
+		"3\n00:00:05,000 --> 00:00:09,250\nHow I wonder\n\n"
+
+	if err := (Lyrics{}).Write(song, SRT, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect SRT\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestWebVTT(t *testing.T) {
+	var b bytes.Buffer
+
+	op := Lyrics{Enhanced: true}
+	song, err := op.Extract(kar())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "WEBVTT\n\n" +
+		"NOTE Twinkle Twinkle - Traditional\n\n" +
+		"00:00:01.000 --> 00:00:02.000\nTwin<00:00:01.250>kle <00:00:01.500>twin<00:00:01.750>kle\n\n" +
+		"00:00:02.000 --> 00:00:05.000\nLit<00:00:02.250>tle <00:00:02.500>star\n\n" +
+		"00:00:05.000 --> 00:00:09.250\nHow <00:00:05.500>I <00:00:06.000>won<00:00:06.250>der\n"
+
+	if err := op.Write(song, WebVTT, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect WebVTT\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
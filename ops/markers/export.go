@@ -0,0 +1,202 @@
+package markers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	Text Format = iota
+	Audacity
+	Reaper
+	CUE
+	JSON
+)
+
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "", "text":
+		return Text, nil
+
+	case "audacity":
+		return Audacity, nil
+
+	case "reaper":
+		return Reaper, nil
+
+	case "cue":
+		return CUE, nil
+
+	case "json":
+		return JSON, nil
+
+	default:
+		return Text, fmt.Errorf("invalid format (%v): expected 'text', 'audacity', 'reaper', 'cue' or 'json'", s)
+	}
+}
+
+// FormatOf returns the format of a markers file from the file extension.
+func FormatOf(file string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".txt":
+		return Audacity, nil
+
+	case ".csv":
+		return Reaper, nil
+
+	case ".cue":
+		return CUE, nil
+
+	case ".json":
+		return JSON, nil
+
+	default:
+		return Text, fmt.Errorf("unknown markers file format (%v): expected a .txt, .csv, .cue or .json file", file)
+	}
+}
+
+// Extension returns the default file extension for the format.
+func (f Format) Extension() string {
+	switch f {
+	case Audacity:
+		return ".txt"
+	case Reaper:
+		return ".csv"
+	case CUE:
+		return ".cue"
+	case JSON:
+		return ".json"
+	default:
+		return ".markers"
+	}
+}
+
+// Labels writes the markers as an Audacity label track, with a region label for each Marker
+// and a point label for each CuePoint.
+func Labels(markers []Marker, w io.Writer) error {
+	for _, m := range markers {
+		if _, err := fmt.Fprintf(w, "%.6f\t%.6f\t%v\n", m.At.Seconds(), m.End.Seconds(), m.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Regions writes the markers as a Reaper region/marker list CSV file, with a region (R1, R2,
+// ...) for each Marker and a marker (M1, M2, ...) for each CuePoint.
+func Regions(markers []Marker, w io.Writer) error {
+	records := [][]string{
+		{"#", "Name", "Start", "End", "Length"},
+	}
+
+	regions := 0
+	cues := 0
+
+	for _, m := range markers {
+		if m.Type == CuePointType {
+			cues++
+			records = append(records, []string{fmt.Sprintf("M%v", cues), m.Name, reaperTime(m.At), "", ""})
+		} else {
+			regions++
+			records = append(records, []string{fmt.Sprintf("R%v", regions), m.Name, reaperTime(m.At), reaperTime(m.End), reaperTime(m.End - m.At)})
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err := writer.WriteAll(records); err != nil {
+		return err
+	}
+
+	return writer.Error()
+}
+
+// Cue writes the markers as a CUE sheet for an audio file rendered from the MIDI file, with
+// a track for each marker.
+func Cue(markers []Marker, file string, w io.Writer) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "FILE %q WAVE\n", file)
+
+	for i, m := range markers {
+		fmt.Fprintf(&b, "  TRACK %02d AUDIO\n", i+1)
+		fmt.Fprintf(&b, "    TITLE %q\n", m.Name)
+		fmt.Fprintf(&b, "    INDEX 01 %v\n", cueTime(m.At))
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+// Export writes the markers as JSON.
+func Export(markers []Marker, w io.Writer) error {
+	type marker struct {
+		Type       string  `json:"type"`
+		Name       string  `json:"name"`
+		Track      int     `json:"track"`
+		Bar        int     `json:"bar"`
+		Beat       int     `json:"beat"`
+		Tick       uint64  `json:"tick"`
+		EndTick    uint64  `json:"end-tick"`
+		Seconds    float64 `json:"seconds"`
+		EndSeconds float64 `json:"end-seconds"`
+	}
+
+	object := struct {
+		Markers []marker `json:"markers"`
+	}{
+		Markers: []marker{},
+	}
+
+	for _, m := range markers {
+		object.Markers = append(object.Markers, marker{
+			Type:       fmt.Sprintf("%v", m.Type),
+			Name:       m.Name,
+			Track:      m.Track,
+			Bar:        m.Bar,
+			Beat:       m.Beat,
+			Tick:       m.Tick,
+			EndTick:    m.EndTick,
+			Seconds:    math.Round(m.At.Seconds()*1000000) / 1000000,
+			EndSeconds: math.Round(m.End.Seconds()*1000000) / 1000000,
+		})
+	}
+
+	if bytes, err := json.MarshalIndent(object, "", "  "); err != nil {
+		return err
+	} else if _, err := w.Write(bytes); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// reaperTime formats a time in the Reaper minutes:seconds format e.g. 1:05.250.
+func reaperTime(t time.Duration) string {
+	ms := t.Milliseconds()
+	h := ms / 3600000
+	m := (ms / 60000) % 60
+	s := float64(ms%60000) / 1000
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%06.3f", h, m, s)
+	}
+
+	return fmt.Sprintf("%d:%06.3f", m, s)
+}
+
+// cueTime formats a time as a CUE sheet mm:ss:ff index, with 75 frames per second.
+func cueTime(t time.Duration) string {
+	frames := (t.Microseconds()*75 + 500000) / 1000000
+
+	return fmt.Sprintf("%02d:%02d:%02d", frames/(60*75), (frames/75)%60, frames%75)
+}
@@ -0,0 +1,195 @@
+package markers
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+func TestLabels(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	markers, err := Markers{}.Extract(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "0.000000\t4.000000\tIntro\n" +
+		"4.000000\t8.000000\tVerse\n" +
+		"5.000000\t5.000000\tHit\n" +
+		"8.000000\t12.000000\tChorus\n"
+
+	if err := Labels(markers, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect Audacity labels\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestRegions(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	markers, err := Markers{}.Extract(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "#,Name,Start,End,Length\n" +
+		"R1,Intro,0:00.000,0:04.000,0:04.000\n" +
+		"R2,Verse,0:04.000,0:08.000,0:04.000\n" +
+		"M1,Hit,0:05.000,,\n" +
+		"R3,Chorus,0:08.000,0:12.000,0:04.000\n"
+
+	if err := Regions(markers, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect Reaper regions\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestCue(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	markers, err := Markers{}.Extract(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := "FILE \"song.wav\" WAVE\n" +
+		"  TRACK 01 AUDIO\n    TITLE \"Intro\"\n    INDEX 01 00:00:00\n" +
+		"  TRACK 02 AUDIO\n    TITLE \"Verse\"\n    INDEX 01 00:04:00\n" +
+		"  TRACK 03 AUDIO\n    TITLE \"Hit\"\n    INDEX 01 00:05:00\n" +
+		"  TRACK 04 AUDIO\n    TITLE \"Chorus\"\n    INDEX 01 00:08:00\n"
+
+	if err := Cue(markers, "song.wav", &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if b.String() != expected {
+		t.Errorf("Incorrect CUE sheet\n   expected:%q\n   got:     %q", expected, b.String())
+	}
+}
+
+func TestExport(t *testing.T) {
+	var b bytes.Buffer
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	markers, err := Markers{}.Extract(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := `{
+  "markers": [
+    {
+      "type": "marker",
+      "name": "Intro",
+      "track": 0,
+      "bar": 1,
+      "beat": 1,
+      "tick": 0,
+      "end-tick": 3840,
+      "seconds": 0,
+      "end-seconds": 4
+    },`
+
+	if err := Export(markers, &b); err != nil {
+		t.Fatalf("%v", err)
+	} else if !strings.HasPrefix(b.String(), expected) {
+		t.Errorf("Incorrect JSON\n   expected:%v\n   got:     %v", expected, b.String())
+	}
+}
+
+// TestImport checks that the exported formats import back to the same marker positions.
+func TestImport(t *testing.T) {
+	exports := map[Format]func([]Marker, *bytes.Buffer) error{
+		Audacity: func(m []Marker, b *bytes.Buffer) error { return Labels(m, b) },
+		Reaper:   func(m []Marker, b *bytes.Buffer) error { return Regions(m, b) },
+		CUE:      func(m []Marker, b *bytes.Buffer) error { return Cue(m, "song.wav", b) },
+		JSON:     func(m []Marker, b *bytes.Buffer) error { return Export(m, b) },
+	}
+
+	type marker struct {
+		name string
+		tick uint64
+		bar  int
+		beat int
+	}
+
+	expected := []marker{
+		{"Intro", 0, 1, 1},
+		{"Verse", 3840, 3, 1},
+		{"Hit", 4800, 3, 3},
+		{"Chorus", 7680, 5, 1},
+	}
+
+	for format, export := range exports {
+		var b bytes.Buffer
+
+		smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+		if err != nil {
+			t.Fatalf("error decoding MIDI file (%v)", err)
+		}
+
+		markers, err := Markers{}.Extract(smf)
+		if err != nil {
+			t.Fatalf("%v", err)
+		} else if err := export(markers, &b); err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		imported, err := Markers{}.Import(smf, format, &b)
+		if err != nil {
+			t.Fatalf("%v import error (%v)", format.Extension(), err)
+		}
+
+		got := []marker{}
+		for _, m := range imported {
+			got = append(got, marker{m.Name, m.Tick, m.Bar, m.Beat})
+		}
+
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("Incorrect %v import\n   expected:%v\n   got:     %v", format.Extension(), expected, got)
+		}
+	}
+}
+
+func TestImportReaperBeats(t *testing.T) {
+	csv := "#,Name,Start,End,Length\n" +
+		"R1,Intro,1.1.00,3.1.00,2.0.00\n" +
+		"R2,Verse,3.2.50,5.1.00,2.0.00\n"
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	markers, err := Markers{}.Import(smf, Reaper, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if len(markers) != 2 || markers[0].Tick != 0 || markers[1].Tick != 4560 {
+		t.Errorf("Incorrect Reaper bar.beat import %+v", markers)
+	}
+}
@@ -0,0 +1,283 @@
+package markers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/timing"
+)
+
+// Import reads the markers from an Audacity label track, Reaper region/marker list, CUE sheet
+// or JSON file, converting the marker times to ticks with the tempo map of the MIDI file.
+func (m Markers) Import(smf *midi.SMF, format Format, r io.Reader) ([]Marker, error) {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	var markers []Marker
+
+	switch format {
+	case Audacity:
+		markers, err = labels(r, tempoMap)
+
+	case Reaper:
+		markers, err = regions(r, tempoMap)
+
+	case CUE:
+		markers, err = cuesheet(r, tempoMap)
+
+	case JSON:
+		markers, err = unmarshal(r, tempoMap)
+
+	default:
+		return nil, fmt.Errorf("unsupported markers import format: expected 'audacity', 'reaper', 'cue' or 'json'")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].Tick < markers[j].Tick
+	})
+
+	for i := range markers {
+		marker := &markers[i]
+		position := tempoMap.Position(marker.Tick)
+
+		marker.Bar = position.Bar
+		marker.Beat = position.Beat
+		marker.At = tempoMap.Time(marker.Tick)
+		marker.EndTick = marker.Tick
+		marker.End = marker.At
+
+		debugf("imported %-24q bar %v:%v  tick:%v", marker.Name, marker.Bar, marker.Beat, marker.Tick)
+	}
+
+	return markers, nil
+}
+
+// labels parses an Audacity label track i.e. tab separated start, end and label lines. The
+// spectral selection lines (starting with '\') are ignored.
+func labels(r io.Reader, tempoMap *timing.Map) ([]Marker, error) {
+	markers := []Marker{}
+	scanner := bufio.NewScanner(r)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "\\") {
+			continue
+		}
+
+		fields := strings.SplitN(text, "\t", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid Audacity label (line %v): expected <start>\\t<end>\\t<label>", line)
+		}
+
+		start, err := strconv.ParseFloat(strings.TrimSpace(fields[0]), 64)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid Audacity label start time (line %v): %q", line, fields[0])
+		}
+
+		name := ""
+		if len(fields) > 2 {
+			name = fields[2]
+		}
+
+		markers = append(markers, Marker{
+			Type: MarkerType,
+			Name: name,
+			Tick: tempoMap.Tick(seconds(start)),
+		})
+	}
+
+	return markers, scanner.Err()
+}
+
+// regions parses a Reaper region/marker list CSV file, with the start position either as
+// a time (e.g. 1:05.250) or as bar.beat.hundredths (e.g. 5.1.00).
+func regions(r io.Reader, tempoMap *timing.Map) ([]Marker, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	} else if len(records) == 0 {
+		return nil, fmt.Errorf("invalid Reaper region/marker list: missing header")
+	}
+
+	name, start := -1, -1
+	for i, field := range records[0] {
+		switch strings.ToLower(strings.TrimSpace(field)) {
+		case "name":
+			name = i
+		case "start":
+			start = i
+		}
+	}
+
+	if name < 0 || start < 0 {
+		return nil, fmt.Errorf("invalid Reaper region/marker list: missing 'Name' or 'Start' column")
+	}
+
+	markers := []Marker{}
+	for i, record := range records[1:] {
+		if len(record) <= max(name, start) {
+			return nil, fmt.Errorf("invalid Reaper region/marker (line %v): missing 'Name' or 'Start'", i+2)
+		}
+
+		tick, err := position(record[start], tempoMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Reaper region/marker (line %v): %v", i+2, err)
+		}
+
+		markers = append(markers, Marker{
+			Type: MarkerType,
+			Name: record[name],
+			Tick: tick,
+		})
+	}
+
+	return markers, nil
+}
+
+// cuesheet parses the TRACK TITLE and INDEX 01 entries in a CUE sheet.
+func cuesheet(r io.Reader, tempoMap *timing.Map) ([]Marker, error) {
+	markers := []Marker{}
+	scanner := bufio.NewScanner(r)
+
+	var marker *Marker
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "TRACK":
+			markers = append(markers, Marker{Type: MarkerType, Tick: math.MaxUint64})
+			marker = &markers[len(markers)-1]
+
+		case "TITLE":
+			if marker != nil {
+				title := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), fields[0]))
+				marker.Name = strings.Trim(title, `"`)
+			}
+
+		case "INDEX":
+			if marker != nil && len(fields) > 2 && fields[1] == "01" {
+				var mm, ss, ff uint64
+				if _, err := fmt.Sscanf(fields[2], "%d:%d:%d", &mm, &ss, &ff); err != nil || ss > 59 || ff > 74 {
+					return nil, fmt.Errorf("invalid CUE sheet index (line %v): %q", line, fields[2])
+				}
+
+				frames := (mm*60+ss)*75 + ff
+				marker.Tick = tempoMap.Tick(time.Duration(frames) * time.Second / 75)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, m := range markers {
+		if m.Tick == math.MaxUint64 {
+			return nil, fmt.Errorf("invalid CUE sheet: missing INDEX 01 for track %v", i+1)
+		}
+	}
+
+	return markers, nil
+}
+
+// unmarshal parses the markers in a JSON file exported by the 'markers' command, using the
+// tick if present and otherwise the time in seconds.
+func unmarshal(r io.Reader, tempoMap *timing.Map) ([]Marker, error) {
+	object := struct {
+		Markers []struct {
+			Name    string   `json:"name"`
+			Tick    *uint64  `json:"tick"`
+			Seconds *float64 `json:"seconds"`
+		} `json:"markers"`
+	}{}
+
+	if err := json.NewDecoder(r).Decode(&object); err != nil {
+		return nil, err
+	}
+
+	markers := []Marker{}
+	for i, m := range object.Markers {
+		marker := Marker{
+			Type: MarkerType,
+			Name: m.Name,
+		}
+
+		switch {
+		case m.Tick != nil:
+			marker.Tick = *m.Tick
+
+		case m.Seconds != nil && *m.Seconds >= 0:
+			marker.Tick = tempoMap.Tick(seconds(*m.Seconds))
+
+		default:
+			return nil, fmt.Errorf("invalid marker %v (%q): missing 'tick' or 'seconds'", i+1, m.Name)
+		}
+
+		markers = append(markers, marker)
+	}
+
+	return markers, nil
+}
+
+// position parses a Reaper position as h:mm:ss.fff, m:ss.fff, bar.beat.hundredths or seconds.
+func position(s string, tempoMap *timing.Map) (uint64, error) {
+	s = strings.TrimSpace(s)
+
+	if strings.Contains(s, ":") {
+		t := 0.0
+		for _, field := range strings.Split(s, ":") {
+			if v, err := strconv.ParseFloat(field, 64); err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid time %q", s)
+			} else {
+				t = 60*t + v
+			}
+		}
+
+		return tempoMap.Tick(seconds(t)), nil
+	}
+
+	if fields := strings.Split(s, "."); len(fields) == 3 {
+		bar, err1 := strconv.Atoi(fields[0])
+		beat, err2 := strconv.Atoi(fields[1])
+		hundredths, err3 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil || err3 != nil || bar < 1 || beat < 1 || hundredths < 0 {
+			return 0, fmt.Errorf("invalid bar.beat position %q", s)
+		}
+
+		tick := tempoMap.Bar(bar)
+		length := tempoMap.BeatLength(tick)
+
+		return tick + uint64(beat-1)*length + uint64(hundredths)*length/100, nil
+	}
+
+	if v, err := strconv.ParseFloat(s, 64); err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid position %q", s)
+	} else {
+		return tempoMap.Tick(seconds(v)), nil
+	}
+}
+
+func seconds(t float64) time.Duration {
+	return time.Duration(math.Round(t * float64(time.Second)))
+}
@@ -0,0 +1,170 @@
+package markers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/log"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/timing"
+)
+
+const LOG_TAG = "markers"
+
+type Type int
+
+const (
+	MarkerType Type = iota
+	CuePointType
+)
+
+// Markers extracts the song structure (e.g. Intro, Verse, Chorus) from the Marker and
+// CuePoint events in a MIDI file and inserts Marker events into track 0.
+//
+// A Marker is a region that extends up to the next Marker (or the end of the file) while a
+// CuePoint is a single point in time.
+type Markers struct {
+}
+
+type Marker struct {
+	Type    Type
+	Name    string
+	Track   int
+	Bar     int
+	Beat    int
+	Tick    uint64
+	EndTick uint64
+	At      time.Duration
+	End     time.Duration
+}
+
+func (t Type) String() string {
+	switch t {
+	case CuePointType:
+		return "cue"
+	default:
+		return "marker"
+	}
+}
+
+// Extract returns the Marker and CuePoint events in a MIDI file, ordered by tick.
+func (m Markers) Extract(smf *midi.SMF) ([]Marker, error) {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	end := uint64(0)
+	markers := []Marker{}
+
+	for i, track := range smf.Tracks {
+		for _, e := range track.Events {
+			end = max(end, e.Tick())
+
+			switch v := e.Event.(type) {
+			case metaevent.Marker:
+				markers = append(markers, Marker{Type: MarkerType, Name: v.Marker, Track: i, Tick: e.Tick()})
+
+			case metaevent.CuePoint:
+				markers = append(markers, Marker{Type: CuePointType, Name: v.CuePoint, Track: i, Tick: e.Tick()})
+			}
+		}
+	}
+
+	sort.SliceStable(markers, func(i, j int) bool {
+		return markers[i].Tick < markers[j].Tick
+	})
+
+	for i := range markers {
+		marker := &markers[i]
+		position := tempoMap.Position(marker.Tick)
+
+		marker.Bar = position.Bar
+		marker.Beat = position.Beat
+		marker.At = tempoMap.Time(marker.Tick)
+		marker.EndTick = marker.Tick
+
+		if marker.Type == MarkerType {
+			marker.EndTick = max(marker.Tick, end)
+			for _, next := range markers[i+1:] {
+				if next.Type == MarkerType && next.Tick > marker.Tick {
+					marker.EndTick = next.Tick
+					break
+				}
+			}
+		}
+
+		marker.End = tempoMap.Time(marker.EndTick)
+
+		debugf("%-6v %-24q bar %v:%v  tick:%v-%v", marker.Type, marker.Name, marker.Bar, marker.Beat, marker.Tick, marker.EndTick)
+	}
+
+	return markers, nil
+}
+
+// Insert replaces the Marker events in track 0 with the markers and returns the encoded
+// MIDI file.
+func (m Markers) Insert(smf *midi.SMF, markers []Marker) ([]byte, error) {
+	if len(smf.Tracks) == 0 {
+		return nil, fmt.Errorf("missing track 0")
+	}
+
+	track := smf.Tracks[0]
+	eventlist := []*events.Event{}
+	for _, e := range track.Events {
+		if events.Is[metaevent.Marker](*e) {
+			warnf("replacing existing Marker event at tick %v", e.Tick())
+		} else {
+			eventlist = append(eventlist, e)
+		}
+	}
+
+	track.Events = eventlist
+
+	list := []events.IEvent{}
+	for _, marker := range markers {
+		list = append(list, metaevent.MakeMarker(marker.Tick, 0, marker.Name))
+	}
+
+	if err := track.Insert(list...); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	var e = midifile.NewEncoder(&b)
+
+	if err := e.Encode(*smf); err != nil {
+		return nil, err
+	} else {
+		return b.Bytes(), nil
+	}
+}
+
+func Print(markers []Marker, w io.Writer) error {
+	for _, m := range markers {
+		position := fmt.Sprintf("%v:%v", m.Bar, m.Beat)
+
+		line := fmt.Sprintf("bar %-7v  tick:%-7v  time:%-9.3f  %-6v  %v", position, m.Tick, m.At.Seconds(), m.Type, m.Name)
+
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
+
+func warnf(format string, args ...any) {
+	log.Warnf(LOG_TAG, format, args...)
+}
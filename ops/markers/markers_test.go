@@ -0,0 +1,86 @@
+package markers
+
+import (
+	"bytes"
+	_ "embed"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+)
+
+// reference is six bars of 4/4 at 120 BPM (2 seconds per bar) with Intro, Verse and Chorus
+// markers and a cue point in track 1.
+//
+//go:embed test-files/reference.mid
+var reference []byte
+
+func TestExtract(t *testing.T) {
+	expected := []Marker{
+		{Type: MarkerType, Name: "Intro", Track: 0, Bar: 1, Beat: 1, Tick: 0, EndTick: 3840, At: 0, End: 4 * time.Second},
+		{Type: MarkerType, Name: "Verse", Track: 0, Bar: 3, Beat: 1, Tick: 3840, EndTick: 7680, At: 4 * time.Second, End: 8 * time.Second},
+		{Type: CuePointType, Name: "Hit", Track: 1, Bar: 3, Beat: 3, Tick: 4800, EndTick: 4800, At: 5 * time.Second, End: 5 * time.Second},
+		{Type: MarkerType, Name: "Chorus", Track: 0, Bar: 5, Beat: 1, Tick: 7680, EndTick: 11520, At: 8 * time.Second, End: 12 * time.Second},
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	markers, err := Markers{}.Extract(smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !reflect.DeepEqual(markers, expected) {
+		t.Errorf("Incorrect markers\n   expected:%+v\n   got:     %+v", expected, markers)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	markers := []Marker{
+		{Name: "A", Tick: 0},
+		{Name: "B", Tick: 1920},
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	encoded, err := Markers{}.Insert(smf, markers)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	decoded, err := midifile.NewDecoder().Decode(bytes.NewReader(encoded))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	type marker struct {
+		tick  uint64
+		delta uint32
+		name  string
+	}
+
+	expected := []marker{{0, 0, "A"}, {1920, 1920, "B"}}
+	got := []marker{}
+
+	for _, e := range decoded.Tracks[0].Events {
+		if v, ok := e.Event.(metaevent.Marker); ok {
+			got = append(got, marker{e.Tick(), uint32(e.Delta()), v.Marker})
+		}
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Incorrect Marker events\n   expected:%v\n   got:     %v", expected, got)
+	}
+
+	if n := len(decoded.Tracks[1].Events); n != 2 {
+		t.Errorf("Incorrect number of track 1 events - expected:%v, got:%v", 2, n)
+	}
+}
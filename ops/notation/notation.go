@@ -0,0 +1,701 @@
+package notation
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/transcriptaze/midiasm/log"
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/midi/timing"
+	"github.com/transcriptaze/midiasm/ops/notes"
+)
+
+const LOG_TAG = "notation"
+
+// Notation transcribes the notes of a MIDI file into a score, quantising the note start and
+// end times to the Quantise note value (e.g. 16 for sixteenth notes) and splitting the note
+// durations into notated values tied across beats and barlines. If Triplets is set, note
+// times are quantised to the nearer of the Quantise grid and the equivalent triplet grid.
+type Notation struct {
+	Quantise int
+	Triplets bool
+}
+
+// Score is the notated version of a MIDI file. Positions and durations are in divisions
+// of a quarter note.
+type Score struct {
+	Title     string
+	Divisions int
+	Measures  []Measure
+	Parts     []Part
+}
+
+// Measure holds the attributes of a bar that are common to all the parts. Time and Key
+// are only set for the first measure and for measures where they change.
+type Measure struct {
+	Number int
+	Tick   uint64
+	Start  int
+	Length int
+	Time   *TimeSignature
+	Key    *KeySignature
+	Tempo  []Tempo
+}
+
+type TimeSignature struct {
+	Beats    int
+	BeatType int
+}
+
+type KeySignature struct {
+	Fifths int
+	Mode   lib.KeyType
+	Scale  lib.Scale
+}
+
+// Tempo is a tempo change at an offset (in divisions) from the start of a measure.
+type Tempo struct {
+	Offset int
+	BPM    float64
+}
+
+// Part is the notated music for a single track and channel.
+type Part struct {
+	ID       string
+	Name     string
+	Track    lib.TrackNumber
+	Channel  lib.Channel
+	Program  int
+	Clef     Clef
+	Measures []Bar
+}
+
+// Bar is the music for a part in a single measure, as a list of voices.
+type Bar struct {
+	Voices []Voice
+}
+
+type Voice struct {
+	Number   int
+	Elements []Element
+}
+
+// Element is a note, chord or rest. Value is the notated value (1 for a whole note, 2 for
+// a half note, 4 for a quarter note, etc) and is 0 for a whole measure rest. Tuplet is 3
+// for a note notated as part of a triplet (i.e. with a duration of 2/3 of its value).
+type Element struct {
+	Rest     bool
+	Pitches  []Pitch
+	Velocity byte
+	Duration int
+	Value    int
+	Dots     int
+	Tuplet   int
+	TieStart bool
+	TieStop  bool
+}
+
+// Pitch is a note spelt according to the key signature, with Octave the scientific pitch
+// octave (i.e. middle C is C4).
+type Pitch struct {
+	Note   byte
+	Step   string
+	Alter  int
+	Octave int
+}
+
+type Clef int
+
+const (
+	Treble Clef = iota
+	Bass
+	Percussion
+)
+
+const percussion = lib.Channel(9)
+
+// Notated values in order of preference, as a fraction of a whole note (with dotted values
+// expressed in 64ths).
+var values = []struct {
+	value int
+	dots  int
+	size  int
+}{
+	{1, 1, 96},
+	{1, 0, 64},
+	{2, 1, 48},
+	{2, 0, 32},
+	{4, 1, 24},
+	{4, 0, 16},
+	{8, 1, 12},
+	{8, 0, 8},
+	{16, 1, 6},
+	{16, 0, 4},
+	{32, 1, 3},
+	{32, 0, 2},
+	{64, 0, 1},
+}
+
+func (c Clef) String() string {
+	return []string{"treble", "bass", "percussion"}[c]
+}
+
+// Transcribe builds the score for a MIDI file.
+func (n Notation) Transcribe(smf *midi.SMF) (*Score, error) {
+	quantise := n.Quantise
+	if quantise == 0 {
+		quantise = 16
+	}
+
+	switch quantise {
+	case 1, 2, 4, 8, 16, 32, 64:
+	default:
+		return nil, fmt.Errorf("invalid quantisation (%v): expected 1, 2, 4, 8, 16, 32 or 64", n.Quantise)
+	}
+
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := notes.Extract(smf)
+	if err != nil {
+		return nil, err
+	}
+
+	end := uint64(0)
+	for _, note := range list {
+		end = max(end, note.EndTick)
+	}
+
+	// ... divisions per quarter note (at least a quantisation unit and the smallest beat)
+	divisions := max(1, quantise/4)
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			if v, ok := e.Event.(metaevent.TimeSignature); ok && v.Denominator > 4 {
+				divisions = max(divisions, min(16, int(v.Denominator)/4))
+			}
+		}
+	}
+
+	if n.Triplets {
+		divisions *= 3
+	}
+
+	score := Score{
+		Title:     title(smf),
+		Divisions: divisions,
+	}
+
+	score.Measures = measures(smf, tempoMap, end, divisions)
+
+	t := transcriber{
+		ppqn:      uint64(tempoMap.PPQN),
+		divisions: divisions,
+		grid:      max(1, 4*divisions/quantise),
+		triplets:  n.Triplets,
+		measures:  score.Measures,
+	}
+
+	for _, p := range parts(smf, list) {
+		score.Parts = append(score.Parts, t.transcribe(p))
+	}
+
+	for i := range score.Parts {
+		score.Parts[i].ID = fmt.Sprintf("P%v", i+1)
+	}
+
+	return &score, nil
+}
+
+// Spell returns the pitch of a MIDI note, using the note from the scale if it is a
+// scale note and otherwise sharps or flats depending on the key signature.
+func Spell(note byte, scale lib.Scale) Pitch {
+	pc := int(note % 12)
+	name := ""
+
+	for _, n := range scale.Notes {
+		if n.Ord == pc {
+			name = n.Name
+		}
+	}
+
+	if name == "" {
+		sharps := []lib.Note{lib.C, lib.C_SHARP, lib.D, lib.D_SHARP, lib.E, lib.F, lib.F_SHARP, lib.G, lib.G_SHARP, lib.A, lib.A_SHARP, lib.B}
+		flats := []lib.Note{lib.C, lib.D_FLAT, lib.D, lib.E_FLAT, lib.E, lib.F, lib.G_FLAT, lib.G, lib.A_FLAT, lib.A, lib.B_FLAT, lib.B}
+
+		if scale.Accidentals < 0 {
+			name = flats[pc].Name
+		} else {
+			name = sharps[pc].Name
+		}
+	}
+
+	alter := 0
+	for _, r := range name[1:] {
+		switch r {
+		case '♯':
+			alter++
+		case '♭':
+			alter--
+		}
+	}
+
+	return Pitch{
+		Note:   note,
+		Step:   name[0:1],
+		Alter:  alter,
+		Octave: (int(note)-alter)/12 - 1,
+	}
+}
+
+func title(smf *midi.SMF) string {
+	if len(smf.Tracks) > 0 {
+		for _, e := range smf.Tracks[0].Events {
+			if v, ok := e.Event.(metaevent.TrackName); ok {
+				return v.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// measures builds the list of measures up to the end tick from the time signatures, key
+// signatures and tempo changes in the MIDI file.
+func measures(smf *midi.SMF, tempoMap *timing.Map, end uint64, divisions int) []Measure {
+	type keysig struct {
+		tick uint64
+		key  KeySignature
+	}
+
+	type tempo struct {
+		tick uint64
+		bpm  float64
+	}
+
+	keys := []keysig{}
+	tempi := []tempo{}
+
+	for _, track := range smf.Tracks {
+		for _, e := range track.Events {
+			switch v := e.Event.(type) {
+			case metaevent.KeySignature:
+				scale, ok := lib.MajorScale(v.Accidentals)
+				if v.KeyType == lib.Minor {
+					scale, ok = lib.MinorScale(v.Accidentals)
+				}
+
+				if ok {
+					keys = append(keys, keysig{e.Tick(), KeySignature{Fifths: int(v.Accidentals), Mode: v.KeyType, Scale: scale}})
+				}
+
+			case metaevent.Tempo:
+				if v.Tempo > 0 {
+					bpm := math.Round(6000000000/float64(v.Tempo)) / 100
+					tempi = append(tempi, tempo{e.Tick(), bpm})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].tick < keys[j].tick })
+	sort.SliceStable(tempi, func(i, j int) bool { return tempi[i].tick < tempi[j].tick })
+
+	list := []Measure{}
+	start := 0
+	key := KeySignature{Fifths: 0, Mode: lib.Major, Scale: lib.C_MAJOR}
+	time := TimeSignature{}
+
+	for bar := 1; bar == 1 || tempoMap.Bar(bar) < end; bar++ {
+		tick := tempoMap.Bar(bar)
+		next := tick + tempoMap.BarLength(tick)
+		numerator, denominator := tempoMap.TimeSignature(tick)
+
+		m := Measure{
+			Number: bar,
+			Tick:   tick,
+			Start:  start,
+			Length: int(numerator) * 4 * divisions / max(1, int(denominator)),
+		}
+
+		if ts := (TimeSignature{int(numerator), int(denominator)}); bar == 1 || ts != time {
+			time = ts
+			m.Time = &ts
+		}
+
+		changed := bar == 1
+		for _, k := range keys {
+			if k.tick < next && (k.tick >= tick || bar == 1) && k.key.Scale.Name != key.Scale.Name {
+				key = k.key
+				changed = true
+			}
+		}
+
+		if changed {
+			k := key
+			m.Key = &k
+		}
+
+		for _, t := range tempi {
+			if t.tick >= tick && t.tick < next {
+				offset := int((t.tick - tick) * uint64(divisions) / uint64(tempoMap.PPQN))
+
+				m.Tempo = append(m.Tempo, Tempo{Offset: offset, BPM: t.bpm})
+			}
+		}
+
+		list = append(list, m)
+		start += m.Length
+	}
+
+	return list
+}
+
+type part struct {
+	name    string
+	track   lib.TrackNumber
+	channel lib.Channel
+	program int
+	notes   []notes.Note
+}
+
+// parts groups the notes by track and channel, naming each part from the TrackName (or
+// InstrumentName) in the track.
+func parts(smf *midi.SMF, list []notes.Note) []part {
+	parts := []part{}
+
+	for _, note := range list {
+		ix := slices.IndexFunc(parts, func(p part) bool { return p.track == note.Track && p.channel == note.Channel })
+		if ix < 0 {
+			parts = append(parts, part{track: note.Track, channel: note.Channel, program: -1})
+			ix = len(parts) - 1
+		}
+
+		parts[ix].notes = append(parts[ix].notes, note)
+	}
+
+	sort.SliceStable(parts, func(i, j int) bool {
+		if parts[i].track != parts[j].track {
+			return parts[i].track < parts[j].track
+		}
+
+		return parts[i].channel < parts[j].channel
+	})
+
+	for i, p := range parts {
+		name := ""
+		instrument := ""
+		channels := 0
+
+		for _, q := range parts {
+			if q.track == p.track {
+				channels++
+			}
+		}
+
+		for _, track := range smf.Tracks {
+			if track.TrackNumber != p.track {
+				continue
+			}
+
+			for _, e := range track.Events {
+				switch v := e.Event.(type) {
+				case metaevent.TrackName:
+					if name == "" {
+						name = v.Name
+					}
+
+				case metaevent.InstrumentName:
+					if instrument == "" {
+						instrument = v.Name
+					}
+
+				case midievent.ProgramChange:
+					if v.Channel == p.channel && parts[i].program < 0 {
+						parts[i].program = int(v.Program)
+					}
+				}
+			}
+		}
+
+		if smf.MThd.Format == 0 || name == "" {
+			name = instrument
+		}
+
+		if name == "" {
+			name = fmt.Sprintf("Track %v", uint(p.track))
+		}
+
+		if channels > 1 {
+			name = fmt.Sprintf("%v (channel %v)", name, p.channel)
+		}
+
+		parts[i].name = name
+	}
+
+	return parts
+}
+
+type transcriber struct {
+	ppqn      uint64
+	divisions int
+	grid      int
+	triplets  bool
+	measures  []Measure
+}
+
+type chord struct {
+	start    int
+	end      int
+	notes    []byte
+	velocity byte
+}
+
+func (t transcriber) transcribe(p part) Part {
+	part := Part{
+		Name:     p.name,
+		Track:    p.track,
+		Channel:  p.channel,
+		Program:  p.program,
+		Clef:     Treble,
+		Measures: make([]Bar, len(t.measures)),
+	}
+
+	// ... clef
+	sum := 0
+	for _, note := range p.notes {
+		sum += int(note.Note)
+	}
+
+	if p.channel == percussion {
+		part.Clef = Percussion
+	} else if len(p.notes) > 0 && sum/len(p.notes) < 60 {
+		part.Clef = Bass
+	}
+
+	// ... group notes with the same (quantised) start and end into chords
+	chords := []chord{}
+	for _, note := range p.notes {
+		start := t.position(note.StartTick)
+		end := t.position(note.EndTick)
+		if end <= start {
+			end = start + t.grid
+		}
+
+		ix := slices.IndexFunc(chords, func(c chord) bool { return c.start == start && c.end == end })
+		if ix < 0 {
+			chords = append(chords, chord{start: start, end: end, velocity: note.Velocity})
+			ix = len(chords) - 1
+		}
+
+		if !slices.Contains(chords[ix].notes, note.Note) {
+			chords[ix].notes = append(chords[ix].notes, note.Note)
+		}
+	}
+
+	for i := range chords {
+		slices.Sort(chords[i].notes)
+	}
+
+	sort.SliceStable(chords, func(i, j int) bool {
+		if chords[i].start != chords[j].start {
+			return chords[i].start < chords[j].start
+		}
+
+		return slices.Max(chords[i].notes) > slices.Max(chords[j].notes)
+	})
+
+	// ... assign overlapping chords to voices
+	voices := [][]chord{}
+	for _, c := range chords {
+		ix := slices.IndexFunc(voices, func(v []chord) bool { return v[len(v)-1].end <= c.start })
+		if ix < 0 {
+			voices = append(voices, []chord{})
+			ix = len(voices) - 1
+		}
+
+		voices[ix] = append(voices[ix], c)
+	}
+
+	if len(voices) == 0 {
+		voices = append(voices, []chord{})
+	}
+
+	// ... notate each voice
+	total := 0
+	if N := len(t.measures); N > 0 {
+		total = t.measures[N-1].Start + t.measures[N-1].Length
+	}
+
+	for v, list := range voices {
+		bars := make([][]Element, len(t.measures))
+		cursor := 0
+
+		for _, c := range list {
+			t.notate(bars, cursor, c.start, nil, 0)
+			t.notate(bars, c.start, c.end, c.notes, c.velocity)
+			cursor = c.end
+		}
+
+		t.notate(bars, cursor, total, nil, 0)
+
+		for i, elements := range bars {
+			rests := !slices.ContainsFunc(elements, func(e Element) bool { return !e.Rest })
+
+			switch {
+			case rests && v == 0:
+				elements = []Element{{Rest: true, Duration: t.measures[i].Length}}
+
+			case rests:
+				continue
+			}
+
+			for j := range elements {
+				for k := range elements[j].Pitches {
+					elements[j].Pitches[k] = Spell(elements[j].Pitches[k].Note, t.key(i).Scale)
+				}
+			}
+
+			part.Measures[i].Voices = append(part.Measures[i].Voices, Voice{Number: v + 1, Elements: elements})
+		}
+	}
+
+	debugf("part %v: %v chords, %v voices", part.Name, len(chords), len(voices))
+
+	return part
+}
+
+// position converts a tick to the quantised position (in divisions) from the start of
+// the score.
+func (t transcriber) position(tick uint64) int {
+	ix := 0
+	for i, m := range t.measures {
+		if m.Tick <= tick {
+			ix = i
+		}
+	}
+
+	m := t.measures[ix]
+	round := func(v, grid uint64) uint64 {
+		return (v + grid*t.ppqn/2) / (grid * t.ppqn) * grid
+	}
+
+	exact := (tick - min(tick, m.Tick)) * uint64(t.divisions)
+	offset := round(exact, uint64(t.grid))
+
+	if triplet := uint64(t.grid * 2 / 3); t.triplets && triplet > 0 {
+		v := round(exact, triplet)
+		if diff(v*t.ppqn, exact) < diff(offset*t.ppqn, exact) {
+			offset = v
+		}
+	}
+
+	if ix < len(t.measures)-1 {
+		offset = min(offset, uint64(m.Length))
+	}
+
+	return m.Start + int(offset)
+}
+
+// notate adds the notated values for a note, chord or rest from start to end, split at
+// barlines and tied across them.
+func (t transcriber) notate(bars [][]Element, start, end int, notes []byte, velocity byte) {
+	first := true
+
+	for i, m := range t.measures {
+		from := max(start, m.Start)
+		to := min(end, m.Start+m.Length)
+		if from >= to {
+			continue
+		}
+
+		offset := from - m.Start
+		remaining := to - from
+		for remaining > 0 {
+			value, dots, tuplet, duration := t.split(offset, remaining)
+
+			e := Element{
+				Rest:     len(notes) == 0,
+				Velocity: velocity,
+				Duration: duration,
+				Value:    value,
+				Dots:     dots,
+				Tuplet:   tuplet,
+			}
+
+			if len(notes) > 0 {
+				for _, n := range notes {
+					e.Pitches = append(e.Pitches, Pitch{Note: n})
+				}
+
+				e.TieStop = !first
+				if remaining > duration || end > to {
+					e.TieStart = true
+				}
+			}
+
+			bars[i] = append(bars[i], e)
+			offset += duration
+			remaining -= duration
+			first = false
+		}
+	}
+}
+
+// split returns the largest notated value that fits the remaining duration and starts on
+// a multiple of half its length (or a third of its length for dotted values) i.e. values
+// are not syncopated across beats. Positions that are not on the quantisation grid are
+// notated as (undotted) triplet values.
+func (t transcriber) split(offset, remaining int) (int, int, int, int) {
+	if offset%t.grid == 0 && remaining%t.grid == 0 {
+		for _, v := range values {
+			duration := v.size * t.divisions / 16
+			base := max(1, duration/2)
+			if v.dots > 0 {
+				base = max(1, duration/3)
+			}
+
+			if v.size*t.divisions%16 == 0 && duration > 0 && duration <= remaining && offset%base == 0 {
+				return v.value, v.dots, 0, duration
+			}
+		}
+	}
+
+	for _, v := range values {
+		duration := v.size * t.divisions * 2 / 48
+		if v.dots == 0 && v.size*t.divisions*2%48 == 0 && duration > 0 && duration <= remaining && offset%duration == 0 {
+			return v.value, 0, 3, duration
+		}
+	}
+
+	return 64, 0, 0, 1
+}
+
+func diff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+func (t transcriber) key(measure int) KeySignature {
+	key := KeySignature{Fifths: 0, Mode: lib.Major, Scale: lib.C_MAJOR}
+	for _, m := range t.measures[:measure+1] {
+		if m.Key != nil {
+			key = *m.Key
+		}
+	}
+
+	return key
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
@@ -0,0 +1,202 @@
+package notation
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+func TestSpell(t *testing.T) {
+	tests := []struct {
+		note     byte
+		scale    lib.Scale
+		expected Pitch
+	}{
+		{60, lib.C_MAJOR, Pitch{60, "C", 0, 4}},
+		{61, lib.C_MAJOR, Pitch{61, "C", 1, 4}},
+		{61, lib.F_MAJOR, Pitch{61, "D", -1, 4}},
+		{70, lib.F_MAJOR, Pitch{70, "B", -1, 4}},
+		{48, lib.A_MINOR, Pitch{48, "C", 0, 3}},
+		{60, lib.C_SHARP_MAJOR, Pitch{60, "B", 1, 3}},
+		{59, lib.C_FLAT_MAJOR, Pitch{59, "C", -1, 4}},
+	}
+
+	for _, test := range tests {
+		if pitch := Spell(test.note, test.scale); !reflect.DeepEqual(pitch, test.expected) {
+			t.Errorf("Incorrectly spelt note %v in %v - expected:%+v, got:%+v", test.note, test.scale.Name, test.expected, pitch)
+		}
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	note := func(start, end uint64, value byte) []*events.Event {
+		n := midievent.Note{Value: value}
+		return []*events.Event{
+			&events.Event{Event: midievent.MakeNoteOn(start, 0, 0, n, 64)},
+			&events.Event{Event: midievent.MakeNoteOff(end, 0, 0, n, 64)},
+		}
+	}
+
+	track := midi.MTrk{TrackNumber: 1}
+	track.Events = append(track.Events, &events.Event{Event: metaevent.MakeTrackName(0, 0, "Piano")})
+	track.Events = append(track.Events, note(0, 480, 65)...)
+	track.Events = append(track.Events, note(0, 1920, 48)...)
+	track.Events = append(track.Events, note(480, 1440, 70)...)
+	track.Events = append(track.Events, note(1440, 2400, 60)...)
+	track.Events = append(track.Events, note(1440, 2400, 64)...)
+	track.Events = append(track.Events, note(2410, 2650, 61)...)
+	track.Events = append(track.Events, &events.Event{Event: metaevent.MakeEndOfTrack(2650, 0)})
+
+	smf := midi.SMF{
+		MThd: &midi.MThd{Format: 1, Tracks: 2, PPQN: 480, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTrackName(0, 0, "Example")},
+					&events.Event{Event: metaevent.MakeTempo(0, 0, 500000)},
+					&events.Event{Event: metaevent.MakeTimeSignature(0, 0, 4, 4, 24, 8)},
+					&events.Event{Event: metaevent.MakeKeySignature(0, 0, -1, lib.Major)},
+				},
+			},
+			&track,
+		},
+	}
+
+	score, err := Notation{Quantise: 16}.Transcribe(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if score.Title != "Example" {
+		t.Errorf("Incorrect title - expected:%v, got:%v", "Example", score.Title)
+	}
+
+	if score.Divisions != 4 {
+		t.Errorf("Incorrect divisions - expected:%v, got:%v", 4, score.Divisions)
+	}
+
+	if len(score.Measures) != 2 {
+		t.Fatalf("Incorrect number of measures - expected:%v, got:%v", 2, len(score.Measures))
+	}
+
+	if m := score.Measures[0]; m.Time == nil || *m.Time != (TimeSignature{4, 4}) {
+		t.Errorf("Incorrect time signature - expected:%v, got:%v", "4/4", m.Time)
+	} else if m.Key == nil || m.Key.Fifths != -1 || m.Key.Mode != lib.Major {
+		t.Errorf("Incorrect key signature - expected:%v, got:%+v", "F major", m.Key)
+	} else if len(m.Tempo) != 1 || m.Tempo[0].BPM != 120 {
+		t.Errorf("Incorrect tempo - expected:%v, got:%v", 120, m.Tempo)
+	}
+
+	if len(score.Parts) != 1 {
+		t.Fatalf("Incorrect number of parts - expected:%v, got:%v", 1, len(score.Parts))
+	}
+
+	part := score.Parts[0]
+	if part.ID != "P1" || part.Name != "Piano" || part.Clef != Treble {
+		t.Errorf("Incorrect part - expected:%v, got:%v %v %v", "P1 Piano treble", part.ID, part.Name, part.Clef)
+	}
+
+	expected := [][][]string{
+		{
+			{"F4/4", "B♭4/2", "C4+E4/4~"},
+			{"C3/1"},
+		},
+		{
+			{"~C4+E4/4", "D♭4/8", "rest/4.", "rest/4"},
+		},
+	}
+
+	for i, bar := range part.Measures {
+		voices := [][]string{}
+		for _, v := range bar.Voices {
+			elements := []string{}
+			for _, e := range v.Elements {
+				elements = append(elements, format(e))
+			}
+
+			voices = append(voices, elements)
+		}
+
+		if !reflect.DeepEqual(voices, expected[i]) {
+			t.Errorf("Incorrect measure %v\n   expected:%v\n   got:     %v", i+1, expected[i], voices)
+		}
+	}
+}
+
+func format(e Element) string {
+	var s strings.Builder
+
+	if e.TieStop {
+		s.WriteString("~")
+	}
+
+	if e.Rest {
+		s.WriteString("rest")
+	} else {
+		pitches := []string{}
+		for _, p := range e.Pitches {
+			pitches = append(pitches, fmt.Sprintf("%v%v%v", p.Step, map[int]string{-1: "♭", 0: "", 1: "♯"}[p.Alter], p.Octave))
+		}
+
+		s.WriteString(strings.Join(pitches, "+"))
+	}
+
+	fmt.Fprintf(&s, "/%v%v", e.Value, strings.Repeat(".", e.Dots))
+
+	if e.TieStart {
+		s.WriteString("~")
+	}
+
+	return s.String()
+}
+
+func TestTranscribeTriplets(t *testing.T) {
+	note := func(start, end uint64, value byte) []*events.Event {
+		n := midievent.Note{Value: value}
+		return []*events.Event{
+			&events.Event{Event: midievent.MakeNoteOn(start, 0, 0, n, 64)},
+			&events.Event{Event: midievent.MakeNoteOff(end, 0, 0, n, 64)},
+		}
+	}
+
+	track := midi.MTrk{TrackNumber: 1}
+	track.Events = append(track.Events, note(0, 160, 60)...)
+	track.Events = append(track.Events, note(160, 320, 62)...)
+	track.Events = append(track.Events, note(322, 478, 64)...)
+	track.Events = append(track.Events, note(480, 960, 65)...)
+	track.Events = append(track.Events, &events.Event{Event: metaevent.MakeEndOfTrack(960, 0)})
+
+	smf := midi.SMF{
+		MThd: &midi.MThd{Format: 1, Tracks: 2, PPQN: 480, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTimeSignature(0, 0, 2, 4, 24, 8)},
+				},
+			},
+			&track,
+		},
+	}
+
+	score, err := Notation{Quantise: 16, Triplets: true}.Transcribe(&smf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	elements := []string{}
+	for _, e := range score.Parts[0].Measures[0].Voices[0].Elements {
+		elements = append(elements, fmt.Sprintf("%v:%v", format(e), e.Tuplet))
+	}
+
+	expected := []string{"C4/8:3", "D4/8:3", "E4/8:3", "F4/4:0"}
+	if !reflect.DeepEqual(elements, expected) {
+		t.Errorf("Incorrectly notated triplets\n   expected:%v\n   got:     %v", expected, elements)
+	}
+}
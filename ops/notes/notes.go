@@ -21,10 +21,12 @@ const LOG_TAG = "notes"
 type Notes struct {
 	Transpose int
 	JSON      bool
+	Where     func(Note) bool
 	Writer    io.Writer
 }
 
 type Note struct {
+	Track         lib.TrackNumber
 	Channel       lib.Channel
 	Note          byte
 	FormattedNote string
@@ -48,6 +50,17 @@ func (x *Notes) Execute(smf *midi.SMF) error {
 	if notes, err := extract(smf, x.Transpose); err != nil {
 		return err
 	} else {
+		if x.Where != nil {
+			filtered := []Note{}
+			for _, n := range notes {
+				if x.Where(n) {
+					filtered = append(filtered, n)
+				}
+			}
+
+			notes = filtered
+		}
+
 		if x.JSON {
 			export(notes, x.Writer)
 		} else {
@@ -58,19 +71,34 @@ func (x *Notes) Execute(smf *midi.SMF) error {
 	return nil
 }
 
+// Extract returns the notes in all the tracks of a MIDI file, built from the NoteOn and
+// NoteOff events.
+func Extract(smf *midi.SMF) ([]Note, error) {
+	return extract(smf, 0)
+}
+
 func extract(smf *midi.SMF, transposition int) ([]Note, error) {
 	notes := make([]Note, 0)
 
 	// ... build tempo map
 	tempoMap := buildTempoMap(*smf)
 
-	// ... extract track notes
-	for _, track := range smf.Tracks[1:] {
+	// ... extract track notes (FORMAT 0 files have only the one track)
+	tracks := smf.Tracks[1:]
+	if smf.MThd.Format == 0 {
+		tracks = smf.Tracks
+	}
+
+	for _, track := range tracks {
 		if events, err := buildTrackEvents(*track, tempoMap, smf.MThd.PPQN); err != nil {
 			return nil, err
 		} else if list, err := buildNoteList(events, transposition); err != nil {
 			return nil, err
 		} else {
+			for i := range list {
+				list[i].Track = track.TrackNumber
+			}
+
 			notes = append(notes, list...)
 		}
 	}
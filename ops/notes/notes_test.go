@@ -3,6 +3,7 @@ package notes
 import (
 	"bytes"
 	_ "embed"
+	"fmt"
 	"reflect"
 	"testing"
 	"time"
@@ -188,6 +189,53 @@ func TestExtractNotes(t *testing.T) {
 
 }
 
+// Format 0 files have a single track with both the tempo events and the notes.
+func TestExtractNotesFormat0(t *testing.T) {
+	mthd := *smf.MThd
+	mthd.Format = 0
+	mthd.Tracks = 1
+
+	track := midi.MTrk{}
+	track.Events = append(track.Events, smf.Tracks[0].Events...)
+	track.Events = append(track.Events, smf.Tracks[1].Events...)
+
+	format0 := midi.SMF{
+		MThd:   &mthd,
+		Tracks: []*midi.MTrk{&track},
+	}
+
+	notes, err := extract(&format0, 0)
+	if err != nil {
+		t.Fatalf("Error extracting notes from SMF (%v)", err)
+	}
+
+	expected := []string{"C3 0 480", "D3 480 960", "E3 960 1440", "F3 1440 1920", "G3 1920 2400"}
+	list := []string{}
+	for _, n := range notes {
+		list = append(list, fmt.Sprintf("%v %v %v", n.FormattedNote, n.StartTick, n.EndTick))
+	}
+
+	if !reflect.DeepEqual(list, expected) {
+		t.Errorf("Incorrectly extracted format 0 notes\n   expected:%v\n   got:     %v", expected, list)
+	}
+}
+
+func TestExtractNotesTrack(t *testing.T) {
+	format1 := smf
+	format1.Tracks = []*midi.MTrk{smf.Tracks[0], &midi.MTrk{TrackNumber: 3, Events: smf.Tracks[1].Events}}
+
+	notes, err := extract(&format1, 0)
+	if err != nil {
+		t.Fatalf("Error extracting notes from SMF (%v)", err)
+	}
+
+	for _, n := range notes {
+		if n.Track != 3 {
+			t.Errorf("Incorrect track for note %v - expected:%v, got:%v", n.FormattedNote, 3, n.Track)
+		}
+	}
+}
+
 //go:embed test-files/notes.mid
 var testfile []byte
 
@@ -0,0 +1,468 @@
+package pianoroll
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/midi/timing"
+	"github.com/transcriptaze/midiasm/ops/notes"
+)
+
+type Axis int
+
+const (
+	Ticks Axis = iota
+	Seconds
+)
+
+type Colour int
+
+const (
+	ByChannel Colour = iota
+	ByTrack
+)
+
+// PianoRoll renders the notes of a MIDI file as an SVG piano roll, with time on the x-axis
+// (with the bar lines from the time signatures), pitch on the y-axis (with a keyboard legend)
+// and the notes coloured by channel or track and shaded by velocity. Controller and pitch
+// bend values are rendered as lanes below the piano roll.
+type PianoRoll struct {
+	Axis   Axis
+	Colour Colour
+	From   int
+	To     int
+	Tracks []lib.TrackNumber
+	Lanes  []Lane
+}
+
+// Lane is a controller or (if PitchBend is set) pitch bend lane.
+type Lane struct {
+	PitchBend  bool
+	Controller byte
+}
+
+type point struct {
+	tick  uint64
+	track lib.TrackNumber
+	value float64
+}
+
+type layout struct {
+	start  uint64
+	end    uint64
+	low    byte
+	high   byte
+	x      func(uint64) float64
+	width  float64
+	height float64
+}
+
+const (
+	keyHeight    = 8
+	legendWidth  = 48
+	headerHeight = 20
+	rulerHeight  = 16
+	laneHeight   = 64
+	laneGap      = 8
+	margin       = 16
+	pxPerQuarter = 48
+	pxPerSecond  = 96
+)
+
+// Colours for the 16 channels (or tracks).
+var palette = []string{
+	"#1f77b4", "#ff7f0e", "#2ca02c", "#d62728", "#9467bd", "#8c564b", "#e377c2", "#7f7f7f",
+	"#bcbd22", "#17becf", "#393b79", "#637939", "#8c6d31", "#843c39", "#7b4173", "#3182bd",
+}
+
+func ParseAxis(s string) (Axis, error) {
+	switch strings.ToLower(s) {
+	case "ticks":
+		return Ticks, nil
+
+	case "seconds":
+		return Seconds, nil
+
+	default:
+		return Ticks, fmt.Errorf("invalid time axis (%v): expected 'ticks' or 'seconds'", s)
+	}
+}
+
+func ParseColour(s string) (Colour, error) {
+	switch strings.ToLower(s) {
+	case "channel":
+		return ByChannel, nil
+
+	case "track":
+		return ByTrack, nil
+
+	default:
+		return ByChannel, fmt.Errorf("invalid colour (%v): expected 'channel' or 'track'", s)
+	}
+}
+
+// ParseBars parses a (1-based, inclusive) bar range e.g. 5, 5-8 or 5-. A zero To is the
+// end of the file.
+func ParseBars(s string) (int, int, error) {
+	if s == "" {
+		return 0, 0, nil
+	}
+
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		to = from
+	}
+
+	f, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || f < 1 {
+		return 0, 0, fmt.Errorf("invalid bar range (%v): expected e.g. 5, 5-8 or 5-", s)
+	}
+
+	if strings.TrimSpace(to) == "" {
+		return f, 0, nil
+	}
+
+	t, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil || t < f {
+		return 0, 0, fmt.Errorf("invalid bar range (%v): expected e.g. 5, 5-8 or 5-", s)
+	}
+
+	return f, t, nil
+}
+
+// ParseTracks parses a comma separated list of track numbers e.g. 1,3.
+func ParseTracks(s string) ([]lib.TrackNumber, error) {
+	tracks := []lib.TrackNumber{}
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		} else if n, err := strconv.ParseUint(v, 10, 16); err != nil {
+			return nil, fmt.Errorf("invalid track (%v)", v)
+		} else {
+			tracks = append(tracks, lib.TrackNumber(n))
+		}
+	}
+
+	return tracks, nil
+}
+
+// ParseLanes parses a comma separated list of controller numbers and 'pitch-bend' e.g. 7,11,pitch-bend.
+func ParseLanes(s string) ([]Lane, error) {
+	lanes := []Lane{}
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(strings.ToLower(v)); v == "" {
+			continue
+		} else if v == "pitch-bend" || v == "pitchbend" {
+			lanes = append(lanes, Lane{PitchBend: true})
+		} else if n, err := strconv.ParseUint(v, 10, 8); err != nil || n > 127 {
+			return nil, fmt.Errorf("invalid lane (%v): expected a controller number or 'pitch-bend'", v)
+		} else {
+			lanes = append(lanes, Lane{Controller: byte(n)})
+		}
+	}
+
+	return lanes, nil
+}
+
+func (p PianoRoll) Render(smf *midi.SMF, w io.Writer) error {
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return err
+	}
+
+	list, err := notes.Extract(smf)
+	if err != nil {
+		return err
+	}
+
+	// ... range
+	end := uint64(0)
+	for _, track := range smf.Tracks {
+		if len(track.Events) > 0 {
+			end = max(end, track.Events[len(track.Events)-1].Tick())
+		}
+	}
+
+	for _, n := range list {
+		end = max(end, n.EndTick)
+	}
+
+	l := layout{
+		end: tempoMap.Bar(tempoMap.Position(end).Bar + 1),
+	}
+
+	if tempoMap.Position(end).Beat == 1 && tempoMap.Position(end).Ticks == 0 {
+		l.end = end
+	}
+
+	if p.From > 0 {
+		l.start = tempoMap.Bar(p.From)
+	}
+
+	if p.To > 0 {
+		l.end = min(l.end, tempoMap.Bar(p.To+1))
+	}
+
+	if l.start >= l.end {
+		return fmt.Errorf("invalid bar range (%v-%v): the file has %v bars", p.From, p.To, tempoMap.Position(end).Bar)
+	}
+
+	// ... notes
+	selected := []notes.Note{}
+	for _, n := range list {
+		if p.selected(n.Track) && n.StartTick < l.end && n.EndTick > l.start {
+			selected = append(selected, n)
+		}
+	}
+
+	l.low, l.high = 60, 71
+	if len(selected) > 0 {
+		l.low, l.high = 127, 0
+		for _, n := range selected {
+			l.low = min(l.low, n.Note)
+			l.high = max(l.high, n.Note)
+		}
+	}
+
+	l.low -= l.low % 12
+	l.high = min(127, l.high-l.high%12+11)
+
+	// ... time axis
+	switch p.Axis {
+	case Seconds:
+		t0 := tempoMap.Time(l.start)
+		l.x = func(tick uint64) float64 {
+			return legendWidth + (tempoMap.Time(tick)-t0).Seconds()*pxPerSecond
+		}
+
+	default:
+		ppqn := float64(tempoMap.PPQN)
+		l.x = func(tick uint64) float64 {
+			return legendWidth + float64(tick-l.start)*pxPerQuarter/ppqn
+		}
+	}
+
+	rows := int(l.high) - int(l.low) + 1
+	l.width = l.x(l.end) + margin
+	l.height = headerHeight + float64(rows*keyHeight) + float64(len(p.Lanes)*(laneHeight+laneGap)) + margin
+	if p.Axis == Seconds {
+		l.height += rulerHeight
+	}
+
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="sans-serif" font-size="8">`+"\n", l.width, l.height, l.width, l.height)
+	fmt.Fprintf(b, `  <rect width="%.0f" height="%.0f" fill="white"/>`+"\n", l.width, l.height)
+
+	p.keyboard(b, l)
+	p.grid(b, l, tempoMap)
+	p.notes(b, l, selected)
+
+	y := headerHeight + float64(rows*keyHeight)
+	if p.Axis == Seconds {
+		p.ruler(b, l, tempoMap, y)
+		y += rulerHeight
+	}
+
+	for _, lane := range p.Lanes {
+		p.lane(b, l, smf, lane, y+laneGap)
+		y += laneHeight + laneGap
+	}
+
+	fmt.Fprintln(b, `</svg>`)
+
+	return b.Flush()
+}
+
+func (p PianoRoll) selected(track lib.TrackNumber) bool {
+	return len(p.Tracks) == 0 || slices.Contains(p.Tracks, track)
+}
+
+// keyboard renders the keyboard legend and shades the rows of the black keys.
+func (p PianoRoll) keyboard(b *bufio.Writer, l layout) {
+	fmt.Fprintln(b, `  <g class="keyboard">`)
+
+	for n := int(l.high); n >= int(l.low); n-- {
+		y := headerHeight + (int(l.high)-n)*keyHeight
+
+		switch n % 12 {
+		case 1, 3, 6, 8, 10:
+			fmt.Fprintf(b, `    <rect x="%v" y="%v" width="%.0f" height="%v" fill="#f0f0f0"/>`+"\n", legendWidth, y, l.x(l.end)-legendWidth, keyHeight)
+			fmt.Fprintf(b, `    <rect x="0" y="%v" width="%v" height="%v" fill="#333333"/>`+"\n", y, legendWidth*3/5, keyHeight)
+
+		default:
+			fmt.Fprintf(b, `    <rect x="0" y="%v" width="%v" height="%v" fill="white" stroke="#cccccc" stroke-width="0.5"/>`+"\n", y, legendWidth, keyHeight)
+		}
+
+		if n%12 == 0 {
+			fmt.Fprintf(b, `    <text x="%v" y="%v" text-anchor="end" dominant-baseline="middle">%v</text>`+"\n", legendWidth-2, y+keyHeight/2, midievent.FormatNote(nil, byte(n)))
+			fmt.Fprintf(b, `    <line x1="%v" y1="%v" x2="%.0f" y2="%v" stroke="#cccccc" stroke-width="0.5"/>`+"\n", legendWidth, y+keyHeight, l.x(l.end), y+keyHeight)
+		}
+	}
+
+	fmt.Fprintln(b, `  </g>`)
+}
+
+// grid renders the beat and bar lines, with the bar numbers above the piano roll.
+func (p PianoRoll) grid(b *bufio.Writer, l layout, tempoMap *timing.Map) {
+	bottom := headerHeight + (int(l.high)-int(l.low)+1)*keyHeight
+
+	fmt.Fprintln(b, `  <g class="grid">`)
+
+	for _, beat := range tempoMap.Beats(l.end) {
+		if beat.Tick < l.start {
+			continue
+		}
+
+		x := l.x(beat.Tick)
+		if beat.Beat == 1 {
+			fmt.Fprintf(b, `    <line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#999999" stroke-width="1"/>`+"\n", x, headerHeight-4, x, bottom)
+			fmt.Fprintf(b, `    <text x="%.1f" y="%v">%v</text>`+"\n", x+2, headerHeight-6, beat.Bar)
+		} else {
+			fmt.Fprintf(b, `    <line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#e0e0e0" stroke-width="0.5"/>`+"\n", x, headerHeight, x, bottom)
+		}
+	}
+
+	fmt.Fprintf(b, `    <line x1="%.1f" y1="%v" x2="%.1f" y2="%v" stroke="#999999" stroke-width="1"/>`+"\n", l.x(l.end), headerHeight-4, l.x(l.end), bottom)
+	fmt.Fprintln(b, `  </g>`)
+}
+
+// notes renders the notes, coloured by channel or track and shaded by velocity.
+func (p PianoRoll) notes(b *bufio.Writer, l layout, list []notes.Note) {
+	fmt.Fprintln(b, `  <g class="notes">`)
+
+	for _, n := range list {
+		x1 := l.x(max(n.StartTick, l.start))
+		x2 := l.x(min(n.EndTick, l.end))
+		y := headerHeight + (int(l.high)-int(n.Note))*keyHeight
+		opacity := 0.25 + 0.75*float64(n.Velocity)/127
+
+		fmt.Fprintf(b, `    <rect x="%.1f" y="%v" width="%.1f" height="%v" fill="%v" fill-opacity="%.2f" stroke="%v" stroke-width="0.5">`, x1, y, max(1, x2-x1), keyHeight, p.colour(n.Channel, n.Track), opacity, p.colour(n.Channel, n.Track))
+		fmt.Fprintf(b, `<title>%v track:%d channel:%d velocity:%v</title></rect>`+"\n", n.FormattedNote, n.Track, n.Channel, n.Velocity)
+	}
+
+	fmt.Fprintln(b, `  </g>`)
+}
+
+// ruler renders the time (in seconds) below the piano roll.
+func (p PianoRoll) ruler(b *bufio.Writer, l layout, tempoMap *timing.Map, y float64) {
+	t0 := tempoMap.Time(l.start).Seconds()
+	t1 := tempoMap.Time(l.end).Seconds()
+
+	fmt.Fprintln(b, `  <g class="ruler">`)
+
+	for s := math.Ceil(t0); s <= t1; s++ {
+		x := legendWidth + (s-t0)*pxPerSecond
+		fmt.Fprintf(b, `    <line x1="%.1f" y1="%.0f" x2="%.1f" y2="%.0f" stroke="#999999" stroke-width="0.5"/>`+"\n", x, y, x, y+4)
+		fmt.Fprintf(b, `    <text x="%.1f" y="%.0f">%vs</text>`+"\n", x+2, y+12, s)
+	}
+
+	fmt.Fprintln(b, `  </g>`)
+}
+
+// lane renders the controller or pitch bend values as a step graph for each channel.
+func (p PianoRoll) lane(b *bufio.Writer, l layout, smf *midi.SMF, lane Lane, y float64) {
+	name := fmt.Sprintf("CC %v", lane.Controller)
+	if lane.PitchBend {
+		name = "Pitch bend"
+	} else if c := lib.LookupController(lane.Controller); c.Name != "" {
+		name = c.Name
+	}
+
+	fmt.Fprintln(b, `  <g class="lane">`)
+	fmt.Fprintf(b, `    <rect x="%v" y="%.0f" width="%.0f" height="%v" fill="#fafafa" stroke="#cccccc" stroke-width="0.5"/>`+"\n", legendWidth, y, l.x(l.end)-legendWidth, laneHeight)
+	fmt.Fprintf(b, `    <text x="%v" y="%.0f">%v</text>`+"\n", legendWidth+2, y+10, name)
+
+	if lane.PitchBend {
+		fmt.Fprintf(b, `    <line x1="%v" y1="%.0f" x2="%.0f" y2="%.0f" stroke="#cccccc" stroke-width="0.5" stroke-dasharray="2,2"/>`+"\n", legendWidth, y+laneHeight/2, l.x(l.end), y+laneHeight/2)
+	}
+
+	// ... values per channel
+	channels := map[lib.Channel][]point{}
+	for _, track := range smf.Tracks {
+		if !p.selected(track.TrackNumber) {
+			continue
+		}
+
+		for _, e := range track.Events {
+			switch v := e.Event.(type) {
+			case midievent.Controller:
+				if !lane.PitchBend && v.Controller.ID == lane.Controller {
+					channels[v.Channel] = append(channels[v.Channel], point{e.Tick(), track.TrackNumber, float64(v.Value) / 127})
+				}
+
+			case midievent.PitchBend:
+				if lane.PitchBend {
+					channels[v.Channel] = append(channels[v.Channel], point{e.Tick(), track.TrackNumber, float64(v.Bend) / 16383})
+				}
+			}
+		}
+	}
+
+	keys := []lib.Channel{}
+	for c := range channels {
+		keys = append(keys, c)
+	}
+
+	slices.Sort(keys)
+
+	for _, c := range keys {
+		list := channels[c]
+		sort.SliceStable(list, func(i, j int) bool { return list[i].tick < list[j].tick })
+
+		points := []string{}
+		value := -1.0
+		for _, v := range list {
+			if v.tick <= l.start {
+				value = v.value
+				continue
+			} else if v.tick >= l.end {
+				break
+			}
+
+			if value < 0 {
+				value = v.value
+				points = append(points, fmt.Sprintf("%.1f,%.1f", l.x(v.tick), y+laneHeight*(1-value)))
+			} else {
+				if len(points) == 0 {
+					points = append(points, fmt.Sprintf("%.1f,%.1f", l.x(l.start), y+laneHeight*(1-value)))
+				}
+
+				points = append(points, fmt.Sprintf("%.1f,%.1f", l.x(v.tick), y+laneHeight*(1-value)))
+				value = v.value
+				points = append(points, fmt.Sprintf("%.1f,%.1f", l.x(v.tick), y+laneHeight*(1-value)))
+			}
+		}
+
+		if value < 0 {
+			continue
+		} else if len(points) == 0 {
+			points = append(points, fmt.Sprintf("%.1f,%.1f", l.x(l.start), y+laneHeight*(1-value)))
+		}
+
+		points = append(points, fmt.Sprintf("%.1f,%.1f", l.x(l.end), y+laneHeight*(1-value)))
+
+		fmt.Fprintf(b, `    <polyline points="%v" fill="none" stroke="%v" stroke-width="1"/>`+"\n", strings.Join(points, " "), p.colour(c, list[0].track))
+	}
+
+	fmt.Fprintln(b, `  </g>`)
+}
+
+func (p PianoRoll) colour(channel lib.Channel, track lib.TrackNumber) string {
+	if p.Colour == ByTrack {
+		return palette[int(track)%len(palette)]
+	}
+
+	return palette[int(channel)%len(palette)]
+}
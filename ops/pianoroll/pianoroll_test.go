@@ -0,0 +1,140 @@
+package pianoroll
+
+import (
+	"bytes"
+	_ "embed"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+//go:embed test-files/pianoroll.mid
+var pianoroll []byte
+
+func TestRender(t *testing.T) {
+	var b bytes.Buffer
+
+	op := PianoRoll{
+		Lanes: []Lane{{Controller: 7}, {PitchBend: true}},
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(pianoroll))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	if err := op.Render(smf, &b); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	svg := b.String()
+
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" width="448" height="372"`) {
+		t.Errorf("Incorrect SVG dimensions\n%v", svg[:strings.Index(svg, "\n")])
+	}
+
+	expected := []string{
+		`<text x="46" y="208" text-anchor="end" dominant-baseline="middle">C3</text>`,
+		`<text x="50.0" y="14">1</text>`,
+		`<text x="242.0" y="14">3</text>`,
+		`<rect x="48.0" y="108" width="96.0" height="8" fill="#1f77b4" fill-opacity="1.00" stroke="#1f77b4" stroke-width="0.5">`,
+		`<rect x="240.0" y="76" width="96.0" height="8" fill="#1f77b4" fill-opacity="0.63" stroke="#1f77b4" stroke-width="0.5">`,
+		`<rect x="288.0" y="204" width="48.0" height="8" fill="#ff7f0e" fill-opacity="0.72" stroke="#ff7f0e" stroke-width="0.5">`,
+		`<polyline points="48.0,233.6 144.0,233.6 144.0,251.7 432.0,251.7" fill="none" stroke="#1f77b4" stroke-width="1"/>`,
+		`<polyline points="48.0,324.0 240.0,324.0 240.0,308.0 432.0,308.0" fill="none" stroke="#ff7f0e" stroke-width="1"/>`,
+	}
+
+	for _, s := range expected {
+		if !strings.Contains(svg, s) {
+			t.Errorf("Missing SVG element %v", s)
+		}
+	}
+
+	if n := strings.Count(svg, "<title>"); n != 3 {
+		t.Errorf("Incorrect number of notes - expected:%v, got:%v", 3, n)
+	}
+}
+
+func TestRenderSelection(t *testing.T) {
+	var b bytes.Buffer
+
+	op := PianoRoll{
+		Axis:   Seconds,
+		Colour: ByTrack,
+		From:   3,
+		To:     3,
+		Tracks: []lib.TrackNumber{1},
+	}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(pianoroll))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	if err := op.Render(smf, &b); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	svg := b.String()
+
+	expected := []string{
+		`<text x="50.0" y="14">3</text>`,
+		`<rect x="48.0" y="76" width="96.0" height="8" fill="#ff7f0e" fill-opacity="0.63" stroke="#ff7f0e" stroke-width="0.5">`,
+		`<text x="50.0" y="128">2s</text>`,
+		`<title>E4 track:1 channel:0 velocity:64</title>`,
+	}
+
+	for _, s := range expected {
+		if !strings.Contains(svg, s) {
+			t.Errorf("Missing SVG element %v", s)
+		}
+	}
+
+	if n := strings.Count(svg, "<title>"); n != 1 {
+		t.Errorf("Incorrect number of notes - expected:%v, got:%v", 1, n)
+	}
+}
+
+func TestParseLanes(t *testing.T) {
+	lanes, err := ParseLanes("7, 64,pitch-bend")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	expected := []Lane{{Controller: 7}, {Controller: 64}, {PitchBend: true}}
+	if !reflect.DeepEqual(lanes, expected) {
+		t.Errorf("Incorrect lanes - expected:%v, got:%v", expected, lanes)
+	}
+
+	if _, err := ParseLanes("128"); err == nil {
+		t.Errorf("Expected error for invalid controller")
+	}
+}
+
+func TestParseBars(t *testing.T) {
+	tests := []struct {
+		bars string
+		from int
+		to   int
+	}{
+		{"", 0, 0},
+		{"5", 5, 5},
+		{"5-8", 5, 8},
+		{"5-", 5, 0},
+	}
+
+	for _, test := range tests {
+		if from, to, err := ParseBars(test.bars); err != nil {
+			t.Errorf("Error parsing bar range %q (%v)", test.bars, err)
+		} else if from != test.from || to != test.to {
+			t.Errorf("Incorrect bar range %q - expected:%v-%v, got:%v-%v", test.bars, test.from, test.to, from, to)
+		}
+	}
+
+	if _, _, err := ParseBars("8-5"); err == nil {
+		t.Errorf("Expected error for invalid bar range")
+	}
+}
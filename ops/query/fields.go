@@ -0,0 +1,229 @@
+package query
+
+import (
+	"strings"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/events/sysex"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/ops/notes"
+)
+
+// Event exposes the fields of a MIDI event (and the track it is in) to a query:
+//
+//	track, tick, delta, tag, channel, note, velocity, controller, value, bank,
+//	program, pressure, bend, tempo, key, numerator, denominator, text, manufacturer
+//
+// 'text' is the text of any text-like meta event (Text, Copyright, TrackName, Lyric, Marker, etc).
+type Event struct {
+	Track lib.TrackNumber
+	Event events.IEvent
+}
+
+// Note exposes the fields of a note to a query:
+//
+//	track, channel, note, velocity, tick, start, end, duration
+type Note notes.Note
+
+func (e Event) Field(name string) (Value, bool) {
+	switch name {
+	case "track":
+		return Number(uint(e.Track)), true
+
+	case "tick":
+		return Number(e.Event.Tick()), true
+
+	case "delta":
+		return Number(e.Event.Delta()), true
+
+	case "tag":
+		return Text(e.Event.Tag()), true
+	}
+
+	switch v := e.Event.(type) {
+	case midievent.NoteOn:
+		return channel(name, v.Channel, map[string]Value{
+			"note":     note(v.Note),
+			"velocity": Number(v.Velocity),
+		})
+
+	case midievent.NoteOff:
+		return channel(name, v.Channel, map[string]Value{
+			"note":     note(v.Note),
+			"velocity": Number(v.Velocity),
+		})
+
+	case midievent.PolyphonicPressure:
+		return channel(name, v.Channel, map[string]Value{
+			"note":     note(v.Note),
+			"pressure": Number(v.Pressure),
+		})
+
+	case midievent.Controller:
+		return channel(name, v.Channel, map[string]Value{
+			"controller": Value{Text: v.Controller.Name, Number: float64(v.Controller.ID), Numeric: true},
+			"value":      Number(v.Value),
+		})
+
+	case midievent.ProgramChange:
+		return channel(name, v.Channel, map[string]Value{
+			"bank":    Number(v.Bank),
+			"program": Number(v.Program),
+		})
+
+	case midievent.ChannelPressure:
+		return channel(name, v.Channel, map[string]Value{
+			"pressure": Number(v.Pressure),
+		})
+
+	case midievent.PitchBend:
+		return channel(name, v.Channel, map[string]Value{
+			"bend": Number(v.Bend),
+		})
+
+	case metaevent.Text:
+		return field(name, "text", Text(v.Text))
+
+	case metaevent.Copyright:
+		return field(name, "text", Text(v.Copyright))
+
+	case metaevent.TrackName:
+		return field(name, "text", Text(v.Name))
+
+	case metaevent.InstrumentName:
+		return field(name, "text", Text(v.Name))
+
+	case metaevent.Lyric:
+		return field(name, "text", Text(v.Lyric))
+
+	case metaevent.Marker:
+		return field(name, "text", Text(v.Marker))
+
+	case metaevent.CuePoint:
+		return field(name, "text", Text(v.CuePoint))
+
+	case metaevent.ProgramName:
+		return field(name, "text", Text(v.Name))
+
+	case metaevent.DeviceName:
+		return field(name, "text", Text(v.Name))
+
+	case metaevent.SequenceNumber:
+		return field(name, "value", Number(v.SequenceNumber))
+
+	case metaevent.MIDIPort:
+		return field(name, "value", Number(v.Port))
+
+	case metaevent.MIDIChannelPrefix:
+		return field(name, "channel", Number(uint8(v.Channel)))
+
+	case metaevent.Tempo:
+		return field(name, "tempo", Number(v.Tempo))
+
+	case metaevent.KeySignature:
+		return field(name, "key", Text(v.Key))
+
+	case metaevent.TimeSignature:
+		switch name {
+		case "numerator":
+			return Number(v.Numerator), true
+		case "denominator":
+			return Number(v.Denominator), true
+		}
+
+	case metaevent.SequencerSpecificEvent:
+		return field(name, "manufacturer", Text(v.Manufacturer.Name))
+
+	case sysex.SysExMessage:
+		return field(name, "manufacturer", Text(v.Manufacturer.Name))
+	}
+
+	return Value{}, false
+}
+
+func (n Note) Field(name string) (Value, bool) {
+	switch name {
+	case "track":
+		return Number(uint(n.Track)), true
+
+	case "channel":
+		return Number(uint8(n.Channel)), true
+
+	case "note":
+		return Value{Text: n.FormattedNote, Number: float64(n.Note), Numeric: true}, true
+
+	case "velocity":
+		return Number(n.Velocity), true
+
+	case "tick", "start":
+		return Number(n.StartTick), true
+
+	case "end":
+		return Number(n.EndTick), true
+
+	case "duration":
+		return Number(n.EndTick - n.StartTick), true
+	}
+
+	return Value{}, false
+}
+
+// Filter returns a copy of the MIDI file with only the events that match the query. The
+// EndOfTrack events are always included and the event deltas are recalculated from the ticks
+// so that the filtered file can be reassembled.
+func Filter(smf *midi.SMF, q *Query) (*midi.SMF, error) {
+	filtered := midi.SMF{
+		MThd:   smf.MThd,
+		Tracks: []*midi.MTrk{},
+	}
+
+	for _, track := range smf.Tracks {
+		mtrk := *track
+		mtrk.Events = []*events.Event{}
+		tick := uint64(0)
+
+		for _, e := range track.Events {
+			if events.Is[metaevent.EndOfTrack](*e) || q.Match(Event{Track: track.TrackNumber, Event: e.Event}) {
+				if v, err := events.Retime(e.Event, e.Tick(), uint32(e.Tick()-tick)); err != nil {
+					return nil, err
+				} else {
+					mtrk.Events = append(mtrk.Events, events.NewEvent(v))
+					tick = e.Tick()
+				}
+			}
+		}
+
+		filtered.Tracks = append(filtered.Tracks, &mtrk)
+	}
+
+	return &filtered, nil
+}
+
+func channel(name string, c lib.Channel, fields map[string]Value) (Value, bool) {
+	if name == "channel" {
+		return Number(uint8(c)), true
+	}
+
+	v, ok := fields[name]
+
+	return v, ok
+}
+
+func field(name string, key string, v Value) (Value, bool) {
+	if name == key {
+		return v, true
+	}
+
+	return Value{}, false
+}
+
+func note(n midievent.Note) Value {
+	return Value{
+		Text:    strings.TrimSpace(n.Name),
+		Number:  float64(n.Value),
+		Numeric: true,
+	}
+}
@@ -0,0 +1,417 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/transcriptaze/midiasm/midi/context"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// Query is a compiled filter expression, e.g.
+//
+//	track=2 and tag=Controller and controller=64 and tick>=1920
+//
+// Expressions are comparisons combined with 'and', 'or', 'not' and parentheses. The comparison
+// operators are =, !=, <, <=, >, >= and ~ (regular expression match). Values are numbers, bare
+// words or quoted strings. Numeric comparisons are used when both the field and the value are
+// numeric, otherwise the field and value are compared as case-insensitive strings. Note names
+// (e.g. C4, A#3 or B♭3) are converted to note numbers (using the --C4 convention) when compared
+// with the 'note' field. The ordering operators (<, <=, > and >=) require a numeric value and
+// are false for non-numeric fields. Comparisons against a field that doesn't apply to an item
+// (e.g. 'channel' for a meta event) are false.
+type Query struct {
+	expression string
+	root       node
+}
+
+// Fields is implemented by the items that can be matched against a query.
+type Fields interface {
+	Field(name string) (Value, bool)
+}
+
+// Value is a field value with an optional numeric representation.
+type Value struct {
+	Text    string
+	Number  float64
+	Numeric bool
+}
+
+type node interface {
+	eval(Fields) bool
+}
+
+type and struct {
+	left  node
+	right node
+}
+
+type or struct {
+	left  node
+	right node
+}
+
+type not struct {
+	node node
+}
+
+var noteName = regexp.MustCompile(`^([A-Ga-g])(#|♯|b|♭)?(-?[0-9]+)$`)
+
+var steps = map[string]int{"C": 0, "D": 2, "E": 4, "F": 5, "G": 7, "A": 9, "B": 11}
+
+type comparison struct {
+	field string
+	op    string
+	value Value
+	re    *regexp.Regexp
+}
+
+func Text(s string) Value {
+	return Value{Text: s}
+}
+
+func Number[T int | int8 | uint | uint8 | uint16 | uint32 | uint64 | float64](v T) Value {
+	return Value{
+		Text:    fmt.Sprintf("%v", v),
+		Number:  float64(v),
+		Numeric: true,
+	}
+}
+
+func Parse(expression string) (*Query, error) {
+	tokens, err := tokenize(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	p := parser{tokens: tokens}
+
+	root, err := p.expression()
+	if err != nil {
+		return nil, err
+	} else if !p.done() {
+		return nil, fmt.Errorf("invalid query (%v): unexpected '%v'", expression, p.peek().text)
+	}
+
+	return &Query{
+		expression: expression,
+		root:       root,
+	}, nil
+}
+
+func (q Query) String() string {
+	return q.expression
+}
+
+func (q Query) Match(item Fields) bool {
+	return q.root.eval(item)
+}
+
+func (n and) eval(item Fields) bool {
+	return n.left.eval(item) && n.right.eval(item)
+}
+
+func (n or) eval(item Fields) bool {
+	return n.left.eval(item) || n.right.eval(item)
+}
+
+func (n not) eval(item Fields) bool {
+	return !n.node.eval(item)
+}
+
+func (c comparison) eval(item Fields) bool {
+	v, ok := item.Field(c.field)
+	if !ok {
+		return false
+	}
+
+	if c.op == "~" {
+		return c.re.MatchString(v.Text)
+	}
+
+	var cmp int
+	if ordering(c.op) && !v.Numeric {
+		return false
+	} else if v.Numeric && c.value.Numeric {
+		switch {
+		case v.Number < c.value.Number:
+			cmp = -1
+		case v.Number > c.value.Number:
+			cmp = +1
+		}
+	} else {
+		cmp = strings.Compare(strings.ToLower(v.Text), strings.ToLower(c.value.Text))
+	}
+
+	switch c.op {
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+
+	return false
+}
+
+// ... parser
+
+type kind int
+
+const (
+	eof kind = iota
+	word
+	str
+	operator
+	lparen
+	rparen
+)
+
+type token struct {
+	kind kind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	index  int
+}
+
+func (p *parser) done() bool {
+	return p.index >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{}
+	}
+
+	return p.tokens[p.index]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.index++
+
+	return t
+}
+
+func (p *parser) keyword(k string) bool {
+	if t := p.peek(); t.kind == word && strings.EqualFold(t.text, k) {
+		p.index++
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) expression() (node, error) {
+	left, err := p.conjunction()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("or") {
+		if right, err := p.conjunction(); err != nil {
+			return nil, err
+		} else {
+			left = or{left, right}
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) conjunction() (node, error) {
+	left, err := p.negation()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.keyword("and") {
+		if right, err := p.negation(); err != nil {
+			return nil, err
+		} else {
+			left = and{left, right}
+		}
+	}
+
+	return left, nil
+}
+
+func (p *parser) negation() (node, error) {
+	if p.keyword("not") {
+		if n, err := p.negation(); err != nil {
+			return nil, err
+		} else {
+			return not{n}, nil
+		}
+	}
+
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	if p.done() {
+		return nil, fmt.Errorf("invalid query: unexpected end of expression")
+	}
+
+	if p.peek().kind == lparen {
+		p.next()
+
+		n, err := p.expression()
+		if err != nil {
+			return nil, err
+		} else if p.next().kind != rparen {
+			return nil, fmt.Errorf("invalid query: missing ')'")
+		}
+
+		return n, nil
+	}
+
+	field := p.next()
+	if field.kind != word {
+		return nil, fmt.Errorf("invalid query: expected field name, got '%v'", field.text)
+	}
+
+	op := p.next()
+	if op.kind != operator {
+		return nil, fmt.Errorf("invalid query: expected comparison operator after '%v'", field.text)
+	}
+
+	v := p.next()
+	if v.kind != word && v.kind != str {
+		return nil, fmt.Errorf("invalid query: expected value after '%v %v'", field.text, op.text)
+	}
+
+	c := comparison{
+		field: strings.ToLower(field.text),
+		op:    op.text,
+		value: Text(v.text),
+	}
+
+	if v.kind == word {
+		if f, err := strconv.ParseFloat(v.text, 64); err == nil {
+			c.value = Value{Text: v.text, Number: f, Numeric: true}
+		}
+	}
+
+	if c.field == "note" && !c.value.Numeric {
+		if n, ok := notenumber(v.text); ok {
+			c.value = Value{Text: v.text, Number: n, Numeric: true}
+		}
+	}
+
+	if ordering(c.op) && !c.value.Numeric {
+		return nil, fmt.Errorf("invalid query: '%v %v %v' requires a numeric value", field.text, op.text, v.text)
+	}
+
+	if c.op == "~" {
+		if re, err := regexp.Compile("(?i)" + v.text); err != nil {
+			return nil, fmt.Errorf("invalid query: %v", err)
+		} else {
+			c.re = re
+		}
+	}
+
+	return c, nil
+}
+
+func ordering(op string) bool {
+	return op == "<" || op == "<=" || op == ">" || op == ">="
+}
+
+// notenumber returns the MIDI note number for a note name e.g. C4, A#3 or B♭3, using the
+// same octave numbering as the formatted note names (i.e. the --C4 convention).
+func notenumber(s string) (float64, bool) {
+	match := noteName.FindStringSubmatch(s)
+	if match == nil {
+		return 0, false
+	}
+
+	octave, err := strconv.Atoi(match[3])
+	if err != nil {
+		return 0, false
+	}
+
+	if context.MiddleC == lib.C4 {
+		octave++
+	}
+
+	n := 12*(octave+1) + steps[strings.ToUpper(match[1])] + map[string]int{"": 0, "#": 1, "♯": 1, "b": -1, "♭": -1}[match[2]]
+	if n < 0 || n > 127 {
+		return 0, false
+	}
+
+	return float64(n), true
+}
+
+func tokenize(expression string) ([]token, error) {
+	tokens := []token{}
+	runes := []rune(expression)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case r == '(':
+			tokens = append(tokens, token{lparen, "("})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{rparen, ")"})
+			i++
+
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(runes) && runes[j] != r {
+				j++
+			}
+
+			if j >= len(runes) {
+				return nil, fmt.Errorf("invalid query (%v): unterminated string", expression)
+			}
+
+			tokens = append(tokens, token{str, string(runes[i+1 : j])})
+			i = j + 1
+
+		case strings.ContainsRune("=!<>~", r):
+			j := i + 1
+			if j < len(runes) && runes[j] == '=' {
+				j++
+			}
+
+			op := string(runes[i:j])
+			if op == "!" || op == "~=" {
+				return nil, fmt.Errorf("invalid query (%v): invalid operator '%v'", expression, op)
+			}
+
+			tokens = append(tokens, token{operator, op})
+			i = j
+
+		default:
+			j := i
+			for j < len(runes) && !unicode.IsSpace(runes[j]) && !strings.ContainsRune("()=!<>~\"'", runes[j]) {
+				j++
+			}
+
+			tokens = append(tokens, token{word, string(runes[i:j])})
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
@@ -0,0 +1,228 @@
+package query
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+func TestQuery(t *testing.T) {
+	C4 := midievent.Note{Value: 60, Name: "C4"}
+	sustain := lib.LookupController(64)
+
+	list := []Event{
+		{1, metaevent.MakeTrackName(0, 0, "Piano")},
+		{2, midievent.MakeController(1920, 0, 1, sustain, 127)},
+		{2, midievent.MakeController(960, 0, 1, sustain, 0)},
+		{2, midievent.MakeNoteOn(1920, 0, 1, C4, 64)},
+		{3, metaevent.MakeMarker(2400, 0, "Here Be Dragons")},
+	}
+
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"track=2 and tag=Controller and controller=64 and tick>=1920", []int{1}},
+		{"tag=controller and controller~'sustain'", []int{1, 2}},
+		{"channel=1 and not tag=NoteOn", []int{1, 2}},
+		{"note=C4 or text~dragons", []int{3, 4}},
+		{"(track=1 or track=3) and tick<1000", []int{0}},
+		{"velocity>=64", []int{3}},
+		{"channel!=1", []int{}},
+	}
+
+	for _, test := range tests {
+		q, err := Parse(test.query)
+		if err != nil {
+			t.Fatalf("Error parsing query '%v' (%v)", test.query, err)
+		}
+
+		matched := []int{}
+		for i, e := range list {
+			if q.Match(e) {
+				matched = append(matched, i)
+			}
+		}
+
+		if len(matched) != len(test.expected) {
+			t.Errorf("Incorrect matches for '%v' - expected:%v, got:%v", test.query, test.expected, matched)
+			continue
+		}
+
+		for i := range matched {
+			if matched[i] != test.expected[i] {
+				t.Errorf("Incorrect matches for '%v' - expected:%v, got:%v", test.query, test.expected, matched)
+				break
+			}
+		}
+	}
+}
+
+func TestQueryNoteNames(t *testing.T) {
+	list := []Note{
+		{Note: 33, FormattedNote: "A1"},
+		{Note: 50, FormattedNote: "D3"},
+		{Note: 58, FormattedNote: "A♯3"},
+		{Note: 59, FormattedNote: "B3"},
+		{Note: 60, FormattedNote: "C4"},
+		{Note: 62, FormattedNote: "D4"},
+		{Note: 124, FormattedNote: "E9"},
+	}
+
+	tests := []struct {
+		query    string
+		expected []int
+	}{
+		{"note>=C4", []int{4, 5, 6}},
+		{"note<A#3", []int{0, 1}},
+		{"note<=B♭3", []int{0, 1, 2}},
+		{"note=Bb3", []int{2}},
+		{"note>D3 and note<D4", []int{2, 3, 4}},
+		{"note>=60", []int{4, 5, 6}},
+	}
+
+	for _, test := range tests {
+		q, err := Parse(test.query)
+		if err != nil {
+			t.Fatalf("Error parsing query '%v' (%v)", test.query, err)
+		}
+
+		matched := []int{}
+		for i, n := range list {
+			if q.Match(n) {
+				matched = append(matched, i)
+			}
+		}
+
+		if !reflect.DeepEqual(matched, test.expected) {
+			t.Errorf("Incorrect matches for '%v' - expected:%v, got:%v", test.query, test.expected, matched)
+		}
+	}
+}
+
+func TestQueryOrderingNonNumeric(t *testing.T) {
+	list := []Event{
+		{1, metaevent.MakeTrackName(0, 0, "Piano")},
+		{1, metaevent.MakeSequenceNumber(0, 0, 7)},
+	}
+
+	q, err := Parse("text>=1")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, e := range list {
+		if q.Match(e) {
+			t.Errorf("Unexpected match for '%v' (%v)", q, e.Event.Tag())
+		}
+	}
+}
+
+func TestInvalidQuery(t *testing.T) {
+	tests := []string{
+		"",
+		"tag",
+		"tag=",
+		"tag=NoteOn and",
+		"(tag=NoteOn",
+		"tag=NoteOn)",
+		"text='unterminated",
+		"tag ! NoteOn",
+		"text~'('",
+		"text>=Piano",
+		"controller<sustain",
+		"note>=H4",
+	}
+
+	for _, expression := range tests {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Expected error parsing query '%v'", expression)
+		}
+	}
+}
+
+func TestQueryPitchBend(t *testing.T) {
+	e, err := midievent.Parse(240, 0xe0, []byte{0x81, 0x70, 0xe0, 0x00, 0x08}...)
+	if err != nil {
+		t.Fatalf("Error parsing PitchBend event (%v)", err)
+	}
+
+	bend := e.(midievent.PitchBend)
+
+	tests := map[string]bool{
+		"bend=1024": true,
+		"bend>1000": true,
+		"bend<1000": false,
+	}
+
+	for query, expected := range tests {
+		if q, err := Parse(query); err != nil {
+			t.Fatalf("Error parsing query '%v' (%v)", query, err)
+		} else if matched := q.Match(Event{1, bend}); matched != expected {
+			t.Errorf("Incorrect match for '%v' - expected:%v, got:%v", query, expected, matched)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	C4 := midievent.Note{Value: 60, Name: "C4"}
+	volume := lib.LookupController(7)
+
+	smf := midi.SMF{
+		MThd: &midi.MThd{Format: 1, Tracks: 2, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTrackName(0, 0, "Example")},
+					&events.Event{Event: metaevent.MakeEndOfTrack(0, 0)},
+				},
+			},
+			&midi.MTrk{
+				TrackNumber: 1,
+				Events: []*events.Event{
+					&events.Event{Event: midievent.MakeNoteOn(0, 0, 0, C4, 64)},
+					&events.Event{Event: midievent.MakeController(480, 480, 0, volume, 100)},
+					&events.Event{Event: midievent.MakeNoteOn(960, 480, 0, C4, 0)},
+					&events.Event{Event: metaevent.MakeEndOfTrack(1920, 960)},
+				},
+			},
+		},
+	}
+
+	type event struct {
+		tag   string
+		tick  uint64
+		delta uint32
+	}
+
+	expected := [][]event{
+		{{"EndOfTrack", 0, 0}},
+		{{"NoteOn", 0, 0}, {"NoteOn", 960, 960}, {"EndOfTrack", 1920, 960}},
+	}
+
+	q, err := Parse("tag=NoteOn")
+	if err != nil {
+		t.Fatalf("Error parsing query (%v)", err)
+	}
+
+	filtered, err := Filter(&smf, q)
+	if err != nil {
+		t.Fatalf("Error filtering MIDI file (%v)", err)
+	}
+
+	for i, track := range filtered.Tracks {
+		list := []event{}
+		for _, e := range track.Events {
+			list = append(list, event{e.Event.Tag(), e.Tick(), e.Delta()})
+		}
+
+		if !reflect.DeepEqual(list, expected[i]) {
+			t.Errorf("Incorrectly filtered track %v\n   expected:%v\n   got:     %v", i, expected[i], list)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+	"github.com/transcriptaze/midiasm/midi/timing"
+)
+
+// Render synthesises the notes of a MIDI file to a stereo WAV file with simple built-in
+// oscillators and envelopes for each General MIDI instrument family (and for the percussion
+// channel). The rendering follows the tempo changes and note velocities and the channel
+// volume, expression, pan, sustain pedal and pitch bend (including the pitch bend range RPN).
+type Render struct {
+	SampleRate int
+	BitDepth   int
+}
+
+// synth creates the voice for a note.
+type synth interface {
+	voice(ch *channel, note byte, velocity byte, rate int) voice
+}
+
+// voice is a sounding note. render adds the next len(left) samples of the voice to the
+// left and right buffers.
+type voice interface {
+	render(left, right []float32, ch *channel, rate int)
+	release()
+	done() bool
+}
+
+type channel struct {
+	number     lib.Channel
+	bank       uint16
+	program    byte
+	volume     float64
+	expression float64
+	pan        float64
+	sustain    bool
+	bend       float64
+	bendRange  float64
+	rpn        [2]byte
+}
+
+type playing struct {
+	voice     voice
+	channel   lib.Channel
+	note      byte
+	sustained bool
+}
+
+type timed struct {
+	tick  uint64
+	track int
+	index int
+	event any
+}
+
+// Maximum time (in seconds) after the last event for the voices to finish sounding.
+const tail = 3
+
+func NewRender() (*Render, error) {
+	return &Render{
+		SampleRate: 44100,
+		BitDepth:   16,
+	}, nil
+}
+
+func (r Render) Render(smf *midi.SMF, w io.Writer) error {
+	left, right, err := r.render(smf, builtin{})
+	if err != nil {
+		return err
+	}
+
+	return wav(w, left, right, r.SampleRate, r.BitDepth)
+}
+
+// render synthesises the channel events of a MIDI file with the synth.
+func (r Render) render(smf *midi.SMF, s synth) ([]float32, []float32, error) {
+	if r.SampleRate < 8000 || r.SampleRate > 192000 {
+		return nil, nil, fmt.Errorf("invalid sample rate (%v): expected a value in the interval [8000..192000]", r.SampleRate)
+	} else if r.BitDepth != 16 && r.BitDepth != 24 {
+		return nil, nil, fmt.Errorf("invalid bit depth (%v): expected 16 or 24", r.BitDepth)
+	}
+
+	tempoMap, err := timing.NewMap(smf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// ... timeline
+	list := []timed{}
+	end := uint64(0)
+
+	for i, track := range smf.Tracks {
+		for j, e := range track.Events {
+			list = append(list, timed{e.Tick(), i, j, e.Event})
+			end = max(end, e.Tick())
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].tick != list[j].tick {
+			return list[i].tick < list[j].tick
+		} else if list[i].track != list[j].track {
+			return list[i].track < list[j].track
+		}
+
+		return list[i].index < list[j].index
+	})
+
+	sample := func(tick uint64) int {
+		return int(math.Round(tempoMap.Time(tick).Seconds() * float64(r.SampleRate)))
+	}
+
+	length := sample(end)
+	left := make([]float32, length+tail*r.SampleRate)
+	right := make([]float32, length+tail*r.SampleRate)
+
+	channels := make([]*channel, 16)
+	for i := range channels {
+		channels[i] = newChannel(lib.Channel(i))
+	}
+
+	voices := []*playing{}
+	pos := 0
+
+	mix := func(to int) {
+		for _, v := range voices {
+			v.voice.render(left[pos:to], right[pos:to], channels[v.channel], r.SampleRate)
+		}
+
+		pos = to
+
+		active := voices[:0]
+		for _, v := range voices {
+			if !v.voice.done() {
+				active = append(active, v)
+			}
+		}
+
+		voices = active
+	}
+
+	release := func(v *playing) {
+		if channels[v.channel].sustain {
+			v.sustained = true
+		} else {
+			v.voice.release()
+		}
+	}
+
+	for _, e := range list {
+		if at := sample(e.tick); at > pos {
+			mix(at)
+		}
+
+		switch v := e.event.(type) {
+		case midievent.NoteOn:
+			if v.Velocity == 0 {
+				for _, p := range voices {
+					if p.channel == v.Channel && p.note == v.Note.Value && !p.sustained {
+						release(p)
+					}
+				}
+			} else {
+				voices = append(voices, &playing{
+					voice:   s.voice(channels[v.Channel], v.Note.Value, v.Velocity, r.SampleRate),
+					channel: v.Channel,
+					note:    v.Note.Value,
+				})
+			}
+
+		case midievent.NoteOff:
+			for _, p := range voices {
+				if p.channel == v.Channel && p.note == v.Note.Value && !p.sustained {
+					release(p)
+				}
+			}
+
+		case midievent.ProgramChange:
+			channels[v.Channel].bank = v.Bank
+			channels[v.Channel].program = v.Program
+
+		case midievent.PitchBend:
+			channels[v.Channel].bend = (float64(v.Bend) - 8192) / 8192
+
+		case midievent.Controller:
+			ch := channels[v.Channel]
+
+			switch v.Controller.ID {
+			case 7:
+				ch.volume = float64(v.Value) / 127
+			case 10:
+				ch.pan = float64(v.Value) / 127
+			case 11:
+				ch.expression = float64(v.Value) / 127
+			case 101:
+				ch.rpn[0] = v.Value
+			case 100:
+				ch.rpn[1] = v.Value
+			case 6:
+				if ch.rpn == [2]byte{0, 0} {
+					ch.bendRange = float64(v.Value)
+				}
+
+			case 64:
+				ch.sustain = v.Value >= 64
+				if !ch.sustain {
+					for _, p := range voices {
+						if p.channel == v.Channel && p.sustained {
+							p.sustained = false
+							p.voice.release()
+						}
+					}
+				}
+
+			case 120, 123:
+				for _, p := range voices {
+					if p.channel == v.Channel {
+						p.sustained = false
+						p.voice.release()
+					}
+				}
+
+			case 121:
+				ch.expression = 1
+				ch.bend = 0
+				ch.rpn = [2]byte{127, 127}
+			}
+		}
+	}
+
+	mix(length)
+
+	// ... release tail
+	for pos < len(left) && len(voices) > 0 {
+		mix(min(len(left), pos+1024))
+	}
+
+	return left[:pos], right[:pos], nil
+}
+
+func newChannel(number lib.Channel) *channel {
+	return &channel{
+		number:     number,
+		volume:     100.0 / 127,
+		expression: 1,
+		pan:        64.0 / 127,
+		bendRange:  2,
+		rpn:        [2]byte{127, 127},
+	}
+}
+
+// gains returns the (equal power) left and right gains for the channel pan.
+func (c channel) gains() (float64, float64) {
+	return math.Cos(c.pan * math.Pi / 2), math.Sin(c.pan * math.Pi / 2)
+}
@@ -0,0 +1,185 @@
+package render
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/binary"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/encoding/midi"
+)
+
+// reference is a half second note (at 120 BPM) followed by a half second of silence.
+//
+//go:embed test-files/reference.mid
+var reference []byte
+
+// ... same as reference but panned hard left
+//
+//go:embed test-files/pan.mid
+var pan []byte
+
+// ... same as reference but with a centred pitch bend (E0 00 40)
+//
+//go:embed test-files/pitch-bend.mid
+var pitchbend []byte
+
+func TestRender(t *testing.T) {
+	var b bytes.Buffer
+
+	op := Render{SampleRate: 8000, BitDepth: 16}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	if err := op.Render(smf, &b); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	wav := b.Bytes()
+	le := binary.LittleEndian
+
+	if string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" || string(wav[36:40]) != "data" {
+		t.Fatalf("Invalid WAV header %q", wav[0:44])
+	}
+
+	if channels := le.Uint16(wav[22:24]); channels != 2 {
+		t.Errorf("Incorrect number of channels - expected:%v, got:%v", 2, channels)
+	}
+
+	if rate := le.Uint32(wav[24:28]); rate != 8000 {
+		t.Errorf("Incorrect sample rate - expected:%v, got:%v", 8000, rate)
+	}
+
+	if bits := le.Uint16(wav[34:36]); bits != 16 {
+		t.Errorf("Incorrect bit depth - expected:%v, got:%v", 16, bits)
+	}
+
+	// ... 1s of audio
+	if size := le.Uint32(wav[40:44]); size != 8000*4 || len(wav) != 44+8000*4 {
+		t.Errorf("Incorrect data size - expected:%v, got:%v (%v)", 8000*4, size, len(wav)-44)
+	}
+
+	peak := func(from, to int) int {
+		p := 0
+		for i := 44 + from*4; i < 44+to*4; i += 2 {
+			p = max(p, abs16(int16(le.Uint16(wav[i:]))))
+		}
+		return p
+	}
+
+	if p := peak(0, 4000); p < 1000 {
+		t.Errorf("Expected audio during note - got peak %v", p)
+	}
+
+	if p := peak(5000, 8000); p != 0 {
+		t.Errorf("Expected silence after note release - got peak %v", p)
+	}
+}
+
+func TestRenderPan(t *testing.T) {
+	op := Render{SampleRate: 8000, BitDepth: 16}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(pan))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	left, right, err := op.render(smf, builtin{})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	l, r := float32(0), float32(0)
+	for i := range left {
+		l = max(l, abs(left[i]))
+		r = max(r, abs(right[i]))
+	}
+
+	if l < 0.01 || r > 0.0001 {
+		t.Errorf("Incorrect pan - expected left only, got left:%v right:%v", l, r)
+	}
+}
+
+func TestRender24Bit(t *testing.T) {
+	var b bytes.Buffer
+
+	op := Render{SampleRate: 8000, BitDepth: 24}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	if err := op.Render(smf, &b); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	wav := b.Bytes()
+	le := binary.LittleEndian
+
+	if align := le.Uint16(wav[32:34]); align != 6 {
+		t.Errorf("Incorrect block align - expected:%v, got:%v", 6, align)
+	}
+
+	if len(wav) != 44+8000*6 {
+		t.Errorf("Incorrect WAV file size - expected:%v, got:%v", 44+8000*6, len(wav))
+	}
+}
+
+func TestRenderDeterministic(t *testing.T) {
+	var b1, b2 bytes.Buffer
+
+	op := Render{SampleRate: 8000, BitDepth: 16}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	if err := op.Render(smf, &b1); err != nil {
+		t.Fatalf("%v", err)
+	} else if err := op.Render(smf, &b2); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Errorf("Expected identical renders")
+	}
+}
+
+func TestRenderCentredPitchBend(t *testing.T) {
+	var b1, b2 bytes.Buffer
+
+	op := Render{SampleRate: 8000, BitDepth: 16}
+
+	smf, err := midifile.NewDecoder().Decode(bytes.NewReader(reference))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	centred, err := midifile.NewDecoder().Decode(bytes.NewReader(pitchbend))
+	if err != nil {
+		t.Fatalf("error decoding MIDI file (%v)", err)
+	}
+
+	if err := op.Render(smf, &b1); err != nil {
+		t.Fatalf("%v", err)
+	} else if err := op.Render(centred, &b2); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Errorf("Expected centred pitch bend to render identically to no pitch bend")
+	}
+}
+
+func abs16(v int16) int {
+	if v < 0 {
+		return -int(v)
+	}
+
+	return int(v)
+}
@@ -0,0 +1,605 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/transcriptaze/midiasm/midi"
+)
+
+// SF2Render synthesises the notes of a MIDI file to a stereo WAV file with the presets from a
+// SoundFont. The channel bank and program select the preset and the preset and instrument
+// zones for each note are rendered with the zone generators and modulators i.e. the sample
+// loop points and tuning, the volume and modulation envelopes, the LFOs, the lowpass filter,
+// the attenuation and the pan.
+//
+// Channel volume, expression, pan, sustain pedal and pitch bend are applied as for Render, so
+// modulators with a MIDI controller source are ignored.
+type SF2Render struct {
+	SampleRate int
+	BitDepth   int
+	SoundFont  *SoundFont
+}
+
+// sampler is a voice that plays a SoundFont sample for a single instrument zone.
+type sampler struct {
+	data      []float32
+	end       int
+	loopStart int
+	loopEnd   int
+	loop      int
+	pos       float64
+	step      float64
+	cents     float64
+	pan       float64
+	g         [genCount]float64
+	volume    envelope
+	modulator envelope
+	modLFO    lfo
+	vibLFO    lfo
+	filter    biquad
+	n         int
+	released  bool
+	finished  bool
+}
+
+// envelope is a SoundFont DAHDSR envelope with a normalised output in the interval [0..1].
+type envelope struct {
+	delay   float64
+	attack  float64
+	hold    float64
+	decay   float64
+	sustain float64
+	release float64
+	t       float64
+	level   float64
+	from    float64
+	rt      float64
+	off     bool
+}
+
+type lfo struct {
+	delay float64
+	freq  float64
+	t     float64
+}
+
+type biquad struct {
+	enabled        bool
+	b0, b1, b2     float64
+	a1, a2         float64
+	x1, x2, y1, y2 float64
+}
+
+// Number of samples between updates of the pitch, filter and LFO values.
+const control = 32
+
+// Minimum volume envelope level (-96dB) before a voice is considered silent.
+const silent = 0.04
+
+// Default modulators (SF2.04 section 8.4) that are not controlled by a MIDI controller.
+var defaultModulators = []sf2modulator{
+	{source: 0x0502, destination: genInitialAttenuation, amount: 960},
+	{source: 0x0102, destination: genInitialFilterFc, amount: -2400},
+}
+
+func NewSF2Render(sf *SoundFont) (*SF2Render, error) {
+	return &SF2Render{
+		SampleRate: 44100,
+		BitDepth:   16,
+		SoundFont:  sf,
+	}, nil
+}
+
+func (r SF2Render) Render(smf *midi.SMF, w io.Writer) error {
+	if r.SoundFont == nil {
+		return fmt.Errorf("missing SoundFont")
+	}
+
+	render := Render{
+		SampleRate: r.SampleRate,
+		BitDepth:   r.BitDepth,
+	}
+
+	left, right, err := render.render(smf, r.SoundFont)
+	if err != nil {
+		return err
+	}
+
+	return wav(w, left, right, r.SampleRate, r.BitDepth)
+}
+
+// preset returns the preset for the channel bank and program. The bank is matched against the
+// bank select MSB and then LSB, falling back to bank 0. Channel 10 uses the percussion bank
+// (128), falling back to the standard drum kit.
+func (sf *SoundFont) preset(ch *channel) *sf2preset {
+	type bp struct {
+		bank    uint16
+		program uint16
+	}
+
+	program := uint16(ch.program)
+	list := []bp{{ch.bank >> 7, program}, {ch.bank & 0x7f, program}, {0, program}}
+
+	if ch.number == 9 {
+		list = []bp{{128, program}, {128, 0}}
+	}
+
+	for _, v := range list {
+		for i, p := range sf.presets {
+			if p.bank == v.bank && p.program == v.program {
+				return &sf.presets[i]
+			}
+		}
+	}
+
+	return nil
+}
+
+func (sf *SoundFont) voice(ch *channel, note byte, velocity byte, rate int) voice {
+	l := layers{}
+
+	preset := sf.preset(ch)
+	if preset == nil {
+		return l
+	}
+
+	pglobal := global(preset.zones)
+
+	for _, pz := range preset.zones {
+		if pz.instrument < 0 || !pz.matches(note, velocity) {
+			continue
+		}
+
+		instrument := sf.instruments[pz.instrument]
+		iglobal := global(instrument.zones)
+
+		for _, iz := range instrument.zones {
+			if iz.sample < 0 || !iz.matches(note, velocity) {
+				continue
+			}
+
+			s := sf.samples[iz.sample]
+			if s.kind&0x8000 != 0 {
+				continue
+			}
+
+			// ... instrument generators are absolute, preset generators are added to them
+			g := [genCount]float64{}
+			for k, v := range generatorDefaults {
+				g[k] = float64(v)
+			}
+
+			for _, z := range []sf2zone{iglobal, iz} {
+				for k, v := range z.generators {
+					g[k] = float64(v)
+				}
+			}
+
+			p := map[uint16]int16{}
+			for _, z := range []sf2zone{pglobal, pz} {
+				for k, v := range z.generators {
+					p[k] = v
+				}
+			}
+
+			for k, v := range p {
+				if !instrumentOnly[k] {
+					g[k] += float64(v)
+				}
+			}
+
+			// ... modulators
+			key, vel := note, velocity
+			if g[genKeynum] >= 0 {
+				key = byte(g[genKeynum])
+			}
+
+			if g[genVelocity] >= 0 {
+				vel = byte(g[genVelocity])
+			}
+
+			modulators := merge(merge(defaultModulators, iglobal.modulators), iz.modulators)
+			modulators = append(modulators, merge(pglobal.modulators, pz.modulators)...)
+
+			for _, m := range modulators {
+				if m.destination < genCount {
+					g[m.destination] += m.value(key, vel)
+				}
+			}
+
+			if v := newSampler(sf.data, s, g, key, rate); v != nil {
+				l = append(l, v)
+			}
+		}
+	}
+
+	return l
+}
+
+func (z sf2zone) matches(note, velocity byte) bool {
+	return note >= z.keys[0] && note <= z.keys[1] && velocity >= z.velocities[0] && velocity <= z.velocities[1]
+}
+
+// global returns the global zone from a list of zones (or an empty zone if there is no global
+// zone).
+func global(zones []sf2zone) sf2zone {
+	if len(zones) > 0 && zones[0].instrument < 0 && zones[0].sample < 0 {
+		return zones[0]
+	}
+
+	return sf2zone{}
+}
+
+// merge returns the modulators with the identical modulators in list replaced by the
+// modulators in overrides.
+func merge(list []sf2modulator, overrides []sf2modulator) []sf2modulator {
+	merged := append([]sf2modulator{}, list...)
+
+loop:
+	for _, m := range overrides {
+		for i, v := range merged {
+			if v.source == m.source && v.destination == m.destination && v.amtSource == m.amtSource && v.transform == m.transform {
+				merged[i] = m
+				continue loop
+			}
+		}
+
+		merged = append(merged, m)
+	}
+
+	return merged
+}
+
+// value returns the modulator output for a note. Modulators with a MIDI controller source or
+// a linked destination evaluate to 0.
+func (m sf2modulator) value(key, velocity byte) float64 {
+	if m.destination&0x8000 != 0 {
+		return 0
+	}
+
+	src, ok := source(m.source, key, velocity)
+	if !ok {
+		return 0
+	}
+
+	amt, ok := source(m.amtSource, key, velocity)
+	if !ok {
+		return 0
+	}
+
+	v := float64(m.amount) * src * amt
+	if m.transform == 2 {
+		v = math.Abs(v)
+	}
+
+	return v
+}
+
+// source maps a modulator source to a value in the interval [0..1] (unipolar) or [-1..1]
+// (bipolar).
+func source(src uint16, key, velocity byte) (float64, bool) {
+	var x float64
+
+	switch {
+	case src&0x80 != 0:
+		return 0, false
+
+	case src&0x7f == 0:
+		return 1, true
+
+	case src&0x7f == 2:
+		x = float64(velocity) / 128
+
+	case src&0x7f == 3:
+		x = float64(key) / 128
+
+	default:
+		return 0, false
+	}
+
+	if src&0x0100 != 0 {
+		x = 1 - x
+	}
+
+	switch src >> 10 {
+	case 1:
+		x = concave(x)
+	case 2:
+		x = 1 - concave(1-x)
+	case 3:
+		x = math.Floor(x + 0.5)
+	}
+
+	if src&0x0200 != 0 {
+		x = 2*x - 1
+	}
+
+	return x, true
+}
+
+func concave(x float64) float64 {
+	if x >= 1 {
+		return 1
+	}
+
+	return min(1, -20.0/96*math.Log10((1-x)*(1-x)))
+}
+
+func newSampler(data []float32, s sf2sample, g [genCount]float64, key byte, rate int) *sampler {
+	start := int(s.start) + int(g[genStartAddrsOffset]) + 32768*int(g[genStartAddrsCoarseOffset])
+	end := int(s.end) + int(g[genEndAddrsOffset]) + 32768*int(g[genEndAddrsCoarseOffset])
+	loopStart := int(s.loopStart) + int(g[genStartloopAddrsOffset]) + 32768*int(g[genStartloopAddrsCoarseOffset])
+	loopEnd := int(s.loopEnd) + int(g[genEndloopAddrsOffset]) + 32768*int(g[genEndloopAddrsCoarseOffset])
+
+	start = max(0, min(start, len(data)))
+	end = max(start, min(end, len(data)))
+	if end-start < 2 {
+		return nil
+	}
+
+	loop := int(g[genSampleModes]) & 0x03
+	if loop == 2 || loopStart < start || loopEnd > end || loopEnd-loopStart < 2 {
+		loop = 0
+	}
+
+	root := float64(s.pitch)
+	if g[genOverridingRootKey] >= 0 {
+		root = g[genOverridingRootKey]
+	} else if s.pitch > 127 {
+		root = 60
+	}
+
+	keynum := 60 - float64(key)
+
+	v := sampler{
+		data:      data,
+		end:       end,
+		loopStart: loopStart,
+		loopEnd:   loopEnd,
+		loop:      loop,
+		pos:       float64(start),
+		step:      float64(s.rate) / float64(rate),
+		cents:     (float64(key)-root)*g[genScaleTuning] + 100*g[genCoarseTune] + g[genFineTune] + float64(s.correction),
+		pan:       g[genPan] / 1000,
+		g:         g,
+		volume: envelope{
+			delay:   timecents(g[genDelayVolEnv]),
+			attack:  timecents(g[genAttackVolEnv]),
+			hold:    timecents(g[genHoldVolEnv] + g[genKeynumToVolEnvHold]*keynum),
+			decay:   timecents(g[genDecayVolEnv] + g[genKeynumToVolEnvDecay]*keynum),
+			sustain: 1 - max(0, min(1440, g[genSustainVolEnv]))/1000,
+			release: timecents(g[genReleaseVolEnv]),
+		},
+		modulator: envelope{
+			delay:   timecents(g[genDelayModEnv]),
+			attack:  timecents(g[genAttackModEnv]),
+			hold:    timecents(g[genHoldModEnv] + g[genKeynumToModEnvHold]*keynum),
+			decay:   timecents(g[genDecayModEnv] + g[genKeynumToModEnvDecay]*keynum),
+			sustain: 1 - max(0, min(1000, g[genSustainModEnv]))/1000,
+			release: timecents(g[genReleaseModEnv]),
+		},
+		modLFO: lfo{
+			delay: timecents(g[genDelayModLFO]),
+			freq:  8.176 * math.Pow(2, g[genFreqModLFO]/1200),
+		},
+		vibLFO: lfo{
+			delay: timecents(g[genDelayVibLFO]),
+			freq:  8.176 * math.Pow(2, g[genFreqVibLFO]/1200),
+		},
+	}
+
+	v.filter.enabled = g[genInitialFilterFc] < 13500 || g[genModEnvToFilterFc] != 0 || g[genModLfoToFilterFc] != 0
+
+	return &v
+}
+
+func (v *sampler) render(left, right []float32, ch *channel, rate int) {
+	dt := 1 / float64(rate)
+	bend := 100 * ch.bend * ch.bendRange
+	amplitude := ch.volume * ch.expression * master
+
+	pan := max(0, min(1, ch.pan+v.pan))
+	l, r := math.Cos(pan*math.Pi/2), math.Sin(pan*math.Pi/2)
+
+	var step, gain float64
+
+	for i := range left {
+		if v.finished {
+			return
+		}
+
+		// ... control rate updates
+		if v.n%control == 0 || i == 0 {
+			mod := v.modulator.level
+			modLFO := v.modLFO.value()
+			vibLFO := v.vibLFO.value()
+
+			cents := v.cents + bend + mod*v.g[genModEnvToPitch] + modLFO*v.g[genModLfoToPitch] + vibLFO*v.g[genVibLfoToPitch]
+			step = v.step * math.Pow(2, cents/1200)
+
+			attenuation := max(0, v.g[genInitialAttenuation]) - modLFO*v.g[genModLfoToVolume]
+			gain = amplitude * math.Pow(10, -attenuation/200)
+
+			if v.filter.enabled {
+				fc := v.g[genInitialFilterFc] + mod*v.g[genModEnvToFilterFc] + modLFO*v.g[genModLfoToFilterFc]
+				v.filter.lowpass(fc, v.g[genInitialFilterQ], rate)
+			}
+		}
+
+		v.n++
+
+		// ... envelopes
+		level, attack := v.volume.next(dt)
+		v.modulator.next(dt)
+		v.modLFO.t += dt
+		v.vibLFO.t += dt
+
+		if !attack {
+			level = math.Pow(10, -5*(1-level))
+		}
+
+		if v.volume.finished() {
+			v.finished = true
+			return
+		}
+
+		// ... sample
+		j := int(v.pos)
+		frac := v.pos - float64(j)
+		next := j + 1
+
+		looping := v.loop == 1 || (v.loop == 3 && !v.released)
+		if looping && next >= v.loopEnd {
+			next = v.loopStart
+		} else if next >= v.end {
+			next = j
+		}
+
+		s := float64(v.data[j]) + frac*float64(v.data[next]-v.data[j])
+
+		if v.filter.enabled {
+			s = v.filter.process(s)
+		}
+
+		s *= level * gain
+		left[i] += float32(s * l)
+		right[i] += float32(s * r)
+
+		v.pos += step
+		if looping {
+			for v.pos >= float64(v.loopEnd) {
+				v.pos -= float64(v.loopEnd - v.loopStart)
+			}
+		} else if v.pos >= float64(v.end) {
+			v.finished = true
+		}
+	}
+}
+
+func (v *sampler) release() {
+	v.released = true
+	v.volume.off = true
+	v.modulator.off = true
+}
+
+func (v *sampler) done() bool {
+	return v.finished
+}
+
+// next advances the envelope by dt seconds, returning the envelope level and whether the
+// envelope is in the delay or attack phase.
+func (e *envelope) next(dt float64) (float64, bool) {
+	attack := false
+
+	switch {
+	case e.off:
+		if e.rt == 0 {
+			e.from = e.level
+		}
+
+		e.level = max(0, e.from-e.rt/max(e.release, dt))
+		e.rt += dt
+		attack = e.t < e.delay+e.attack
+
+	case e.t < e.delay:
+		e.level = 0
+		attack = true
+
+	case e.t < e.delay+e.attack:
+		e.level = (e.t - e.delay) / e.attack
+		attack = true
+
+	case e.t < e.delay+e.attack+e.hold:
+		e.level = 1
+
+	default:
+		e.level = max(e.sustain, 1-(e.t-e.delay-e.attack-e.hold)/max(e.decay, dt))
+	}
+
+	e.t += dt
+
+	return e.level, attack
+}
+
+// finished returns true once the volume envelope has released (or decayed) to silence.
+func (e *envelope) finished() bool {
+	if e.t < e.delay+e.attack {
+		return e.off && e.level == 0
+	}
+
+	return (e.off || e.sustain <= silent) && e.level <= silent
+}
+
+// value returns the triangle wave LFO output in the interval [-1..1].
+func (l *lfo) value() float64 {
+	if l.t < l.delay {
+		return 0
+	}
+
+	phase := math.Mod((l.t-l.delay)*l.freq+0.25, 1)
+
+	return 1 - 4*math.Abs(phase-0.5)
+}
+
+// lowpass calculates the (RBJ) biquad lowpass coefficients for a cutoff frequency in absolute
+// cents and a resonance in centibels.
+func (f *biquad) lowpass(cents float64, q float64, rate int) {
+	fc := max(20, min(8.176*math.Pow(2, cents/1200), 0.45*float64(rate)))
+	Q := 0.7071 * math.Pow(10, max(0, min(960, q))/200)
+
+	w0 := 2 * math.Pi * fc / float64(rate)
+	alpha := math.Sin(w0) / (2 * Q)
+	cos := math.Cos(w0)
+	a0 := 1 + alpha
+
+	f.b0 = (1 - cos) / 2 / a0
+	f.b1 = (1 - cos) / a0
+	f.b2 = (1 - cos) / 2 / a0
+	f.a1 = -2 * cos / a0
+	f.a2 = (1 - alpha) / a0
+}
+
+func (f *biquad) process(x float64) float64 {
+	y := f.b0*x + f.b1*f.x1 + f.b2*f.x2 - f.a1*f.y1 - f.a2*f.y2
+
+	f.x2, f.x1 = f.x1, x
+	f.y2, f.y1 = f.y1, y
+
+	return y
+}
+
+// timecents converts a SoundFont time in timecents to seconds.
+func timecents(tc float64) float64 {
+	if tc <= -12000 {
+		return 0
+	}
+
+	return math.Pow(2, tc/1200)
+}
+
+// layers is the list of sample voices for a note.
+type layers []*sampler
+
+func (l layers) render(left, right []float32, ch *channel, rate int) {
+	for _, v := range l {
+		v.render(left, right, ch, rate)
+	}
+}
+
+func (l layers) release() {
+	for _, v := range l {
+		v.release()
+	}
+}
+
+func (l layers) done() bool {
+	for _, v := range l {
+		if !v.finished {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,428 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SoundFont is a parsed SoundFont 2 file i.e. the sample data and the presets with their zones,
+// generators and modulators.
+type SoundFont struct {
+	Name        string
+	presets     []sf2preset
+	instruments []sf2instrument
+	samples     []sf2sample
+	data        []float32
+}
+
+type sf2preset struct {
+	name    string
+	program uint16
+	bank    uint16
+	zones   []sf2zone
+}
+
+type sf2instrument struct {
+	name  string
+	zones []sf2zone
+}
+
+// sf2zone is a preset or instrument zone. For preset zones instrument is the index of the zone
+// instrument and for instrument zones sample is the index of the zone sample. Global zones have
+// neither.
+type sf2zone struct {
+	generators map[uint16]int16
+	keys       [2]byte
+	velocities [2]byte
+	modulators []sf2modulator
+	instrument int
+	sample     int
+}
+
+type sf2modulator struct {
+	source      uint16
+	destination uint16
+	amount      int16
+	amtSource   uint16
+	transform   uint16
+}
+
+type sf2sample struct {
+	name       string
+	start      uint32
+	end        uint32
+	loopStart  uint32
+	loopEnd    uint32
+	rate       uint32
+	pitch      byte
+	correction int8
+	kind       uint16
+}
+
+// SoundFont 2 generators (SF2.04 section 8.1.2).
+const (
+	genStartAddrsOffset           = 0
+	genEndAddrsOffset             = 1
+	genStartloopAddrsOffset       = 2
+	genEndloopAddrsOffset         = 3
+	genStartAddrsCoarseOffset     = 4
+	genModLfoToPitch              = 5
+	genVibLfoToPitch              = 6
+	genModEnvToPitch              = 7
+	genInitialFilterFc            = 8
+	genInitialFilterQ             = 9
+	genModLfoToFilterFc           = 10
+	genModEnvToFilterFc           = 11
+	genEndAddrsCoarseOffset       = 12
+	genModLfoToVolume             = 13
+	genPan                        = 17
+	genDelayModLFO                = 21
+	genFreqModLFO                 = 22
+	genDelayVibLFO                = 23
+	genFreqVibLFO                 = 24
+	genDelayModEnv                = 25
+	genAttackModEnv               = 26
+	genHoldModEnv                 = 27
+	genDecayModEnv                = 28
+	genSustainModEnv              = 29
+	genReleaseModEnv              = 30
+	genKeynumToModEnvHold         = 31
+	genKeynumToModEnvDecay        = 32
+	genDelayVolEnv                = 33
+	genAttackVolEnv               = 34
+	genHoldVolEnv                 = 35
+	genDecayVolEnv                = 36
+	genSustainVolEnv              = 37
+	genReleaseVolEnv              = 38
+	genKeynumToVolEnvHold         = 39
+	genKeynumToVolEnvDecay        = 40
+	genInstrument                 = 41
+	genKeyRange                   = 43
+	genVelRange                   = 44
+	genStartloopAddrsCoarseOffset = 45
+	genKeynum                     = 46
+	genVelocity                   = 47
+	genInitialAttenuation         = 48
+	genEndloopAddrsCoarseOffset   = 50
+	genCoarseTune                 = 51
+	genFineTune                   = 52
+	genSampleID                   = 53
+	genSampleModes                = 54
+	genScaleTuning                = 56
+	genExclusiveClass             = 57
+	genOverridingRootKey          = 58
+	genCount                      = 61
+)
+
+// Generator default values (SF2.04 section 8.1.3). The defaults not listed are 0.
+var generatorDefaults = map[uint16]int16{
+	genInitialFilterFc:   13500,
+	genDelayModLFO:       -12000,
+	genDelayVibLFO:       -12000,
+	genDelayModEnv:       -12000,
+	genAttackModEnv:      -12000,
+	genHoldModEnv:        -12000,
+	genDecayModEnv:       -12000,
+	genReleaseModEnv:     -12000,
+	genDelayVolEnv:       -12000,
+	genAttackVolEnv:      -12000,
+	genHoldVolEnv:        -12000,
+	genDecayVolEnv:       -12000,
+	genReleaseVolEnv:     -12000,
+	genKeynum:            -1,
+	genVelocity:          -1,
+	genScaleTuning:       100,
+	genOverridingRootKey: -1,
+}
+
+// Generators that are only valid in instrument zones (SF2.04 section 8.1.3) and are ignored in
+// preset zones.
+var instrumentOnly = map[uint16]bool{
+	genStartAddrsOffset:           true,
+	genEndAddrsOffset:             true,
+	genStartloopAddrsOffset:       true,
+	genEndloopAddrsOffset:         true,
+	genStartAddrsCoarseOffset:     true,
+	genEndAddrsCoarseOffset:       true,
+	genStartloopAddrsCoarseOffset: true,
+	genEndloopAddrsCoarseOffset:   true,
+	genKeynum:                     true,
+	genVelocity:                   true,
+	genSampleModes:                true,
+	genExclusiveClass:             true,
+	genOverridingRootKey:          true,
+}
+
+type chunk struct {
+	id   string
+	data []byte
+}
+
+// LoadSoundFont parses a SoundFont 2 file.
+func LoadSoundFont(r io.Reader) (*SoundFont, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < 12 || string(b[0:4]) != "RIFF" || string(b[8:12]) != "sfbk" {
+		return nil, fmt.Errorf("invalid SoundFont: expected RIFF 'sfbk' header")
+	}
+
+	lists, err := chunks(b[12:min(len(b), 8+int(binary.LittleEndian.Uint32(b[4:8])))])
+	if err != nil {
+		return nil, err
+	}
+
+	info := map[string][]byte{}
+	sdta := map[string][]byte{}
+	pdta := map[string][]byte{}
+
+	for _, l := range lists {
+		if l.id != "LIST" || len(l.data) < 4 {
+			continue
+		}
+
+		list, err := chunks(l.data[4:])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range list {
+			switch string(l.data[0:4]) {
+			case "INFO":
+				info[c.id] = c.data
+			case "sdta":
+				sdta[c.id] = c.data
+			case "pdta":
+				pdta[c.id] = c.data
+			}
+		}
+	}
+
+	sf := SoundFont{
+		Name: strings.TrimRight(string(info["INAM"]), "\x00"),
+	}
+
+	// ... sample data
+	smpl := sdta["smpl"]
+	sm24 := sdta["sm24"]
+
+	sf.data = make([]float32, len(smpl)/2)
+	for i := range sf.data {
+		v := int32(int16(binary.LittleEndian.Uint16(smpl[2*i:]))) << 8
+		if len(sm24) == len(sf.data) {
+			v |= int32(sm24[i])
+		}
+
+		sf.data[i] = float32(v) / (1 << 23)
+	}
+
+	// ... hydra
+	for _, id := range []string{"phdr", "pbag", "pmod", "pgen", "inst", "ibag", "imod", "igen", "shdr"} {
+		if _, ok := pdta[id]; !ok {
+			return nil, fmt.Errorf("invalid SoundFont: missing '%v' chunk", id)
+		}
+	}
+
+	if sf.samples, err = samples(pdta["shdr"], len(sf.data)); err != nil {
+		return nil, err
+	}
+
+	instruments := []sf2instrument{}
+	if err := records(pdta["inst"], 22, func(r []byte, next []byte) error {
+		zones, err := zones(pdta["ibag"], pdta["igen"], pdta["imod"], r[20:22], next[20:22], genSampleID, len(sf.samples))
+		if err != nil {
+			return err
+		}
+
+		instruments = append(instruments, sf2instrument{
+			name:  name(r[0:20]),
+			zones: zones,
+		})
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := records(pdta["phdr"], 38, func(r []byte, next []byte) error {
+		zones, err := zones(pdta["pbag"], pdta["pgen"], pdta["pmod"], r[24:26], next[24:26], genInstrument, len(instruments))
+		if err != nil {
+			return err
+		}
+
+		sf.presets = append(sf.presets, sf2preset{
+			name:    name(r[0:20]),
+			program: binary.LittleEndian.Uint16(r[20:22]),
+			bank:    binary.LittleEndian.Uint16(r[22:24]),
+			zones:   zones,
+		})
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sf.instruments = instruments
+
+	return &sf, nil
+}
+
+// chunks splits RIFF data into a list of chunks.
+func chunks(b []byte) ([]chunk, error) {
+	list := []chunk{}
+
+	for len(b) >= 8 {
+		id := string(b[0:4])
+		size := int(binary.LittleEndian.Uint32(b[4:8]))
+		if 8+size > len(b) {
+			return nil, fmt.Errorf("invalid SoundFont: truncated '%v' chunk", id)
+		}
+
+		list = append(list, chunk{id, b[8 : 8+size]})
+
+		b = b[min(len(b), 8+size+size%2):]
+	}
+
+	return list, nil
+}
+
+// records invokes f for each fixed size record in a hydra chunk along with the following
+// record, omitting the terminal record.
+func records(b []byte, size int, f func(r []byte, next []byte) error) error {
+	if len(b)%size != 0 || len(b) < size {
+		return fmt.Errorf("invalid SoundFont: invalid hydra chunk size (%v)", len(b))
+	}
+
+	for i := 0; i+2*size <= len(b); i += size {
+		if err := f(b[i:i+size], b[i+size:i+2*size]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// zones returns the zones for the bags in the interval [from,to). The first zone is a global
+// zone if it does not end with the terminal (instrument or sampleID) generator.
+func zones(bag, gen, mod []byte, from, to []byte, terminal uint16, count int) ([]sf2zone, error) {
+	le := binary.LittleEndian
+	p := int(le.Uint16(from))
+	q := int(le.Uint16(to))
+
+	if p > q || 4*(q+1) > len(bag) {
+		return nil, fmt.Errorf("invalid SoundFont: invalid zone index (%v..%v)", p, q)
+	}
+
+	list := []sf2zone{}
+
+	for i := p; i < q; i++ {
+		g0, g1 := int(le.Uint16(bag[4*i:])), int(le.Uint16(bag[4*i+4:]))
+		m0, m1 := int(le.Uint16(bag[4*i+2:])), int(le.Uint16(bag[4*i+6:]))
+
+		if g0 > g1 || 4*g1 > len(gen) || m0 > m1 || 10*m1 > len(mod) {
+			return nil, fmt.Errorf("invalid SoundFont: invalid generator/modulator index")
+		}
+
+		zone := sf2zone{
+			generators: map[uint16]int16{},
+			keys:       [2]byte{0, 127},
+			velocities: [2]byte{0, 127},
+			instrument: -1,
+			sample:     -1,
+		}
+
+		for j := g0; j < g1; j++ {
+			op := le.Uint16(gen[4*j:])
+			amount := gen[4*j+2 : 4*j+4]
+
+			switch op {
+			case genKeyRange:
+				zone.keys = [2]byte{amount[0], amount[1]}
+
+			case genVelRange:
+				zone.velocities = [2]byte{amount[0], amount[1]}
+
+			case terminal:
+				if index := int(le.Uint16(amount)); index < count {
+					zone.instrument, zone.sample = index, index
+				}
+
+			default:
+				if op < genCount {
+					zone.generators[op] = int16(le.Uint16(amount))
+				}
+			}
+
+			if op == terminal {
+				break
+			}
+		}
+
+		for j := m0; j < m1; j++ {
+			r := mod[10*j:]
+			zone.modulators = append(zone.modulators, sf2modulator{
+				source:      le.Uint16(r[0:]),
+				destination: le.Uint16(r[2:]),
+				amount:      int16(le.Uint16(r[4:])),
+				amtSource:   le.Uint16(r[6:]),
+				transform:   le.Uint16(r[8:]),
+			})
+		}
+
+		global := zone.instrument < 0 && i == p && g1 > g0
+
+		if terminal == genSampleID {
+			zone.instrument = -1
+		} else {
+			zone.sample = -1
+		}
+
+		if global || zone.instrument >= 0 || zone.sample >= 0 {
+			list = append(list, zone)
+		}
+	}
+
+	return list, nil
+}
+
+func samples(b []byte, length int) ([]sf2sample, error) {
+	le := binary.LittleEndian
+	list := []sf2sample{}
+
+	err := records(b, 46, func(r []byte, next []byte) error {
+		s := sf2sample{
+			name:       name(r[0:20]),
+			start:      le.Uint32(r[20:]),
+			end:        le.Uint32(r[24:]),
+			loopStart:  le.Uint32(r[28:]),
+			loopEnd:    le.Uint32(r[32:]),
+			rate:       le.Uint32(r[36:]),
+			pitch:      r[40],
+			correction: int8(r[41]),
+			kind:       le.Uint16(r[44:]),
+		}
+
+		if s.kind&0x8000 == 0 && (s.start > s.end || int(s.end) > length || s.rate == 0) {
+			return fmt.Errorf("invalid SoundFont: invalid sample header '%v'", s.name)
+		}
+
+		list = append(list, s)
+
+		return nil
+	})
+
+	return list, err
+}
+
+func name(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return strings.TrimSpace(string(b))
+}
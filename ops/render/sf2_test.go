@@ -0,0 +1,306 @@
+package render
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/transcriptaze/midiasm/midi"
+	"github.com/transcriptaze/midiasm/midi/events"
+	"github.com/transcriptaze/midiasm/midi/events/meta"
+	"github.com/transcriptaze/midiasm/midi/events/midi"
+	"github.com/transcriptaze/midiasm/midi/lib"
+)
+
+// soundfont builds a minimal SoundFont with a looped 440Hz sine wave sample (root key 69) for
+// preset 0:0 (keys 48-84) and a one-shot noise burst for the percussion preset 128:0.
+func soundfont() []byte {
+	le := binary.LittleEndian
+
+	chunk := func(id string, data ...[]byte) []byte {
+		b := bytes.Join(data, nil)
+		h := make([]byte, 8)
+		copy(h, id)
+		le.PutUint32(h[4:], uint32(len(b)))
+		if len(b)%2 != 0 {
+			b = append(b, 0)
+		}
+		return append(h, b...)
+	}
+
+	list := func(id string, chunks ...[]byte) []byte {
+		return chunk("LIST", append([][]byte{[]byte(id)}, chunks...)...)
+	}
+
+	record := func(name string, fields ...any) []byte {
+		var b bytes.Buffer
+		if name != "" {
+			n := make([]byte, 20)
+			copy(n, name)
+			b.Write(n)
+		}
+		for _, f := range fields {
+			binary.Write(&b, le, f)
+		}
+		return b.Bytes()
+	}
+
+	gen := func(op uint16, amount any) []byte {
+		return record("", op, amount)
+	}
+
+	// ... samples: 2 cycles of a 440Hz sine at 8800Hz, 200 samples of noise, 46 sample pads
+	var smpl bytes.Buffer
+	for i := 0; i < 40; i++ {
+		binary.Write(&smpl, le, int16(16384*math.Sin(2*math.Pi*float64(i)/20)))
+	}
+	smpl.Write(make([]byte, 2*46))
+
+	seed := uint32(1)
+	for i := 0; i < 200; i++ {
+		binary.Write(&smpl, le, int16(8192*noise(0, &seed)))
+	}
+	smpl.Write(make([]byte, 2*46))
+
+	shdr := [][]byte{
+		record("Sine", uint32(0), uint32(40), uint32(0), uint32(40), uint32(8800), byte(69), int8(0), uint16(0), uint16(1)),
+		record("Noise", uint32(86), uint32(286), uint32(86), uint32(286), uint32(8000), byte(60), int8(0), uint16(0), uint16(1)),
+		record("EOS", uint32(0), uint32(0), uint32(0), uint32(0), uint32(0), byte(0), int8(0), uint16(0), uint16(0)),
+	}
+
+	igen := [][]byte{
+		gen(genReleaseVolEnv, int16(-3986)), // global zone: 0.1s release
+		gen(genKeyRange, [2]byte{48, 84}),
+		gen(genSampleModes, int16(1)),
+		gen(genSampleID, uint16(0)),
+		gen(genSampleID, uint16(1)),
+		gen(0, uint16(0)),
+	}
+
+	ibag := [][]byte{
+		record("", uint16(0), uint16(0)),
+		record("", uint16(1), uint16(0)),
+		record("", uint16(4), uint16(0)),
+		record("", uint16(5), uint16(0)),
+	}
+
+	inst := [][]byte{
+		record("Sine", uint16(0)),
+		record("Noise", uint16(2)),
+		record("EOI", uint16(3)),
+	}
+
+	pgen := [][]byte{
+		gen(genInstrument, uint16(0)),
+		gen(genInstrument, uint16(1)),
+		gen(0, uint16(0)),
+	}
+
+	pbag := [][]byte{
+		record("", uint16(0), uint16(0)),
+		record("", uint16(1), uint16(0)),
+		record("", uint16(2), uint16(0)),
+	}
+
+	phdr := [][]byte{
+		record("Sine", uint16(0), uint16(0), uint16(0), uint32(0), uint32(0), uint32(0)),
+		record("Drums", uint16(0), uint16(128), uint16(1), uint32(0), uint32(0), uint32(0)),
+		record("EOP", uint16(0), uint16(0), uint16(2), uint32(0), uint32(0), uint32(0)),
+	}
+
+	mod := record("", uint16(0), uint16(0), int16(0), uint16(0), uint16(0))
+
+	sfbk := bytes.Join([][]byte{
+		[]byte("sfbk"),
+		list("INFO", chunk("ifil", record("", uint16(2), uint16(1))), chunk("INAM", []byte("Test\x00"))),
+		list("sdta", chunk("smpl", smpl.Bytes())),
+		list("pdta",
+			chunk("phdr", phdr...),
+			chunk("pbag", pbag...),
+			chunk("pmod", mod),
+			chunk("pgen", pgen...),
+			chunk("inst", inst...),
+			chunk("ibag", ibag...),
+			chunk("imod", mod),
+			chunk("igen", igen...),
+			chunk("shdr", shdr...)),
+	}, nil)
+
+	return chunk("RIFF", sfbk)
+}
+
+// notes is a half second note (at 120 BPM) on a channel.
+func notes(channel lib.Channel, note byte) *midi.SMF {
+	n := midievent.Note{Value: note}
+
+	return &midi.SMF{
+		MThd: &midi.MThd{Format: 1, Tracks: 2, PPQN: 480, Division: 480},
+		Tracks: []*midi.MTrk{
+			&midi.MTrk{
+				Events: []*events.Event{
+					&events.Event{Event: metaevent.MakeTempo(0, 0, 500000)},
+					&events.Event{Event: metaevent.MakeEndOfTrack(960, 0)},
+				},
+			},
+			&midi.MTrk{
+				TrackNumber: 1,
+				Events: []*events.Event{
+					&events.Event{Event: midievent.MakeNoteOn(0, 0, channel, n, 127)},
+					&events.Event{Event: midievent.MakeNoteOff(480, 480, channel, n, 64)},
+					&events.Event{Event: metaevent.MakeEndOfTrack(960, 480)},
+				},
+			},
+		},
+	}
+}
+
+func TestLoadSoundFont(t *testing.T) {
+	sf, err := LoadSoundFont(bytes.NewReader(soundfont()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if sf.Name != "Test" {
+		t.Errorf("Incorrect SoundFont name - expected:%v, got:%v", "Test", sf.Name)
+	}
+
+	if len(sf.presets) != 2 || sf.presets[1].name != "Drums" || sf.presets[1].bank != 128 {
+		t.Fatalf("Incorrect presets %+v", sf.presets)
+	}
+
+	if len(sf.instruments) != 2 || len(sf.instruments[0].zones) != 2 || len(sf.instruments[1].zones) != 1 {
+		t.Fatalf("Incorrect instruments %+v", sf.instruments)
+	}
+
+	zone := sf.instruments[0].zones[1]
+	if zone.sample != 0 || zone.keys != [2]byte{48, 84} || zone.generators[genSampleModes] != 1 {
+		t.Errorf("Incorrect instrument zone %+v", zone)
+	}
+
+	if g := global(sf.instruments[0].zones); g.generators[genReleaseVolEnv] != -3986 {
+		t.Errorf("Incorrect global zone %+v", g)
+	}
+
+	if len(sf.samples) != 2 || sf.samples[1].start != 86 || sf.samples[1].rate != 8000 {
+		t.Errorf("Incorrect samples %+v", sf.samples)
+	}
+
+	if _, err := LoadSoundFont(bytes.NewReader([]byte("RIFF\x04\x00\x00\x00WAVE"))); err == nil {
+		t.Errorf("Expected error for invalid SoundFont")
+	}
+}
+
+func TestSF2Render(t *testing.T) {
+	sf, err := LoadSoundFont(bytes.NewReader(soundfont()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	tests := []struct {
+		note      byte
+		frequency float64
+	}{
+		{69, 440},
+		{81, 880},
+		{57, 220},
+	}
+
+	for _, test := range tests {
+		op := Render{SampleRate: 8000, BitDepth: 16}
+
+		left, _, err := op.render(notes(0, test.note), sf)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+
+		// ... zero crossings over the sustained part of the note
+		crossings := 0
+		for i := 1000; i < 3000; i++ {
+			if (left[i-1] < 0) != (left[i] < 0) {
+				crossings++
+			}
+		}
+
+		if f := float64(crossings) / 2 / 0.25; math.Abs(f-test.frequency) > 8 {
+			t.Errorf("Incorrect frequency for note %v - expected:%v, got:%v", test.note, test.frequency, f)
+		}
+
+		// ... 0.1s release
+		peak := float32(0)
+		for i := 4000 + 1000; i < len(left); i++ {
+			peak = max(peak, abs(left[i]))
+		}
+
+		if peak != 0 {
+			t.Errorf("Expected silence after release for note %v - got peak %v", test.note, peak)
+		}
+	}
+}
+
+func TestSF2RenderKeyRange(t *testing.T) {
+	sf, err := LoadSoundFont(bytes.NewReader(soundfont()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	op := Render{SampleRate: 8000, BitDepth: 16}
+
+	left, _, err := op.render(notes(0, 100), sf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, v := range left {
+		if v != 0 {
+			t.Fatalf("Expected silence for note outside key range")
+		}
+	}
+}
+
+func TestSF2RenderPercussion(t *testing.T) {
+	sf, err := LoadSoundFont(bytes.NewReader(soundfont()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	op := Render{SampleRate: 8000, BitDepth: 16}
+
+	left, _, err := op.render(notes(9, 38), sf)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// ... one-shot 200 sample noise burst, pitched down from 60 to 38
+	last := 0
+	for i, v := range left {
+		if v != 0 {
+			last = i
+		}
+	}
+
+	if expected := int(200 / math.Pow(2, -22.0/12)); last < expected-10 || last > expected+10 {
+		t.Errorf("Incorrect percussion sample length - expected:%v, got:%v", expected, last)
+	}
+}
+
+func TestSF2RenderDeterministic(t *testing.T) {
+	var b1, b2 bytes.Buffer
+
+	sf, err := LoadSoundFont(bytes.NewReader(soundfont()))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	op := SF2Render{SampleRate: 8000, BitDepth: 16, SoundFont: sf}
+
+	if err := op.Render(notes(0, 69), &b1); err != nil {
+		t.Fatalf("%v", err)
+	} else if err := op.Render(notes(0, 69), &b2); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Errorf("Expected identical renders")
+	}
+}
@@ -0,0 +1,209 @@
+package render
+
+import (
+	"math"
+)
+
+// builtin is the built-in synthesiser, with an oscillator and ADSR envelope for each General
+// MIDI instrument family and a simple drum kit for the percussion channel.
+type builtin struct{}
+
+type instrument struct {
+	wave    func(phase float64, seed *uint32) float64
+	attack  float64
+	decay   float64
+	sustain float64
+	release float64
+	sweep   float64
+	oneshot bool
+}
+
+type tone struct {
+	instrument
+	freq     float64
+	gain     float64
+	phase    float64
+	t        int
+	released int
+	level    float64
+	from     float64
+	finished bool
+	seed     uint32
+}
+
+// Overall gain for each voice, leaving headroom for chords and multiple channels.
+const master = 0.25
+
+// Instruments for the 16 General MIDI instrument families (program / 8).
+var families = []instrument{
+	{wave: piano, attack: 0.002, decay: 1.2, sustain: 0, release: 0.3},      // Piano
+	{wave: bell, attack: 0.001, decay: 0.8, sustain: 0, release: 0.4},       // Chromatic Percussion
+	{wave: organ, attack: 0.01, decay: 0.1, sustain: 0.9, release: 0.05},    // Organ
+	{wave: saw, attack: 0.002, decay: 0.8, sustain: 0, release: 0.2},        // Guitar
+	{wave: triangle, attack: 0.005, decay: 0.6, sustain: 0.3, release: 0.1}, // Bass
+	{wave: saw, attack: 0.08, decay: 0.2, sustain: 0.8, release: 0.2},       // Strings
+	{wave: saw, attack: 0.15, decay: 0.3, sustain: 0.8, release: 0.4},       // Ensemble
+	{wave: saw, attack: 0.04, decay: 0.2, sustain: 0.7, release: 0.1},       // Brass
+	{wave: square, attack: 0.03, decay: 0.1, sustain: 0.8, release: 0.08},   // Reed
+	{wave: sine, attack: 0.04, decay: 0.1, sustain: 0.9, release: 0.1},      // Pipe
+	{wave: square, attack: 0.005, decay: 0.1, sustain: 0.8, release: 0.1},   // Synth Lead
+	{wave: saw, attack: 0.3, decay: 0.5, sustain: 0.7, release: 0.6},        // Synth Pad
+	{wave: saw, attack: 0.1, decay: 0.5, sustain: 0.5, release: 0.5},        // Synth Effects
+	{wave: triangle, attack: 0.002, decay: 0.6, sustain: 0, release: 0.2},   // Ethnic
+	{wave: sine, attack: 0.001, decay: 0.3, sustain: 0, release: 0.1},       // Percussive
+	{wave: noise, attack: 0.05, decay: 0.3, sustain: 0.5, release: 0.3},     // Sound Effects
+}
+
+func (s builtin) voice(ch *channel, note byte, velocity byte, rate int) voice {
+	v := tone{
+		instrument: families[ch.program/8],
+		freq:       440 * math.Pow(2, (float64(note)-69)/12),
+		gain:       float64(velocity) / 127,
+		released:   -1,
+		seed:       uint32(note)*2654435761 + 1,
+	}
+
+	if ch.number == 9 {
+		v.instrument, v.freq = drum(note)
+	}
+
+	return &v
+}
+
+// drum returns the instrument and frequency for a General MIDI percussion note.
+func drum(note byte) (instrument, float64) {
+	switch note {
+	case 35, 36: // kick
+		return instrument{wave: sine, attack: 0.001, decay: 0.25, sweep: 2, oneshot: true}, 50
+
+	case 38, 40: // snare
+		return instrument{wave: snare, attack: 0.001, decay: 0.12, oneshot: true}, 180
+
+	case 41, 43, 45, 47, 48, 50: // toms
+		return instrument{wave: sine, attack: 0.001, decay: 0.3, sweep: 0.5, oneshot: true}, 80 + float64(note-41)*12
+
+	case 42, 44: // closed hi-hat
+		return instrument{wave: noise, attack: 0.001, decay: 0.04, oneshot: true}, 0
+
+	case 46: // open hi-hat
+		return instrument{wave: noise, attack: 0.001, decay: 0.3, oneshot: true}, 0
+
+	case 49, 51, 52, 55, 57, 59: // cymbals
+		return instrument{wave: noise, attack: 0.001, decay: 0.8, oneshot: true}, 0
+
+	default:
+		return instrument{wave: noise, attack: 0.001, decay: 0.08, oneshot: true}, 0
+	}
+}
+
+func (v *tone) render(left, right []float32, ch *channel, rate int) {
+	bend := math.Pow(2, ch.bend*ch.bendRange/12)
+	amplitude := v.gain * ch.volume * ch.expression * master
+	l, r := ch.gains()
+
+	for i := range left {
+		if v.finished {
+			return
+		}
+
+		t := float64(v.t) / float64(rate)
+		level := v.envelope(t, rate)
+
+		freq := v.freq * bend
+		if v.sweep > 0 {
+			freq *= 1 + v.sweep*math.Exp(-t*30)
+		}
+
+		v.phase += freq / float64(rate)
+		v.phase -= math.Floor(v.phase)
+
+		s := v.wave(v.phase, &v.seed) * level * amplitude
+		left[i] += float32(s * l)
+		right[i] += float32(s * r)
+
+		v.t++
+	}
+}
+
+// envelope returns the envelope level at t seconds, marking the voice as finished once the
+// release has completed or a note without sustain has decayed to silence.
+func (v *tone) envelope(t float64, rate int) float64 {
+	if v.released >= 0 {
+		dt := float64(v.t-v.released) / float64(rate)
+		if dt >= v.instrument.release {
+			v.finished = true
+			return 0
+		}
+
+		return v.from * (1 - dt/v.instrument.release)
+	}
+
+	switch {
+	case t < v.attack:
+		v.level = t / v.attack
+
+	default:
+		v.level = v.sustain + (1-v.sustain)*math.Exp(-(t-v.attack)/v.decay)
+		if v.sustain == 0 && v.level < 0.0005 {
+			v.finished = true
+		}
+	}
+
+	return v.level
+}
+
+func (v *tone) release() {
+	if !v.oneshot && v.released < 0 {
+		v.released = v.t
+		v.from = v.level
+	}
+}
+
+func (v *tone) done() bool {
+	return v.finished
+}
+
+func sine(phase float64, seed *uint32) float64 {
+	return math.Sin(2 * math.Pi * phase)
+}
+
+func triangle(phase float64, seed *uint32) float64 {
+	return 4*math.Abs(phase-0.5) - 1
+}
+
+func saw(phase float64, seed *uint32) float64 {
+	return 2*phase - 1
+}
+
+func square(phase float64, seed *uint32) float64 {
+	if phase < 0.5 {
+		return 0.7
+	}
+
+	return -0.7
+}
+
+func piano(phase float64, seed *uint32) float64 {
+	return (math.Sin(2*math.Pi*phase) + 0.5*math.Sin(4*math.Pi*phase) + 0.25*math.Sin(6*math.Pi*phase)) / 1.75
+}
+
+func organ(phase float64, seed *uint32) float64 {
+	return (math.Sin(2*math.Pi*phase) + 0.5*math.Sin(4*math.Pi*phase) + 0.3*math.Sin(8*math.Pi*phase)) / 1.8
+}
+
+func bell(phase float64, seed *uint32) float64 {
+	return (math.Sin(2*math.Pi*phase) + 0.3*math.Sin(8*math.Pi*phase) + 0.2*math.Sin(14*math.Pi*phase)) / 1.5
+}
+
+// noise is a deterministic (xorshift) white noise generator, so that renders are repeatable.
+func noise(phase float64, seed *uint32) float64 {
+	*seed ^= *seed << 13
+	*seed ^= *seed >> 17
+	*seed ^= *seed << 5
+
+	return float64(*seed)/float64(math.MaxUint32)*2 - 1
+}
+
+func snare(phase float64, seed *uint32) float64 {
+	return 0.7*noise(phase, seed) + 0.3*math.Sin(2*math.Pi*phase)
+}
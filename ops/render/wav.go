@@ -0,0 +1,81 @@
+package render
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// wav writes the stereo samples as a PCM WAV file with the sample rate and bit depth, scaling
+// the samples down if necessary so that the peak level doesn't clip.
+func wav(w io.Writer, left, right []float32, rate int, bits int) error {
+	if bits != 16 && bits != 24 {
+		return fmt.Errorf("invalid bit depth (%v): expected 16 or 24", bits)
+	}
+
+	peak := float32(0)
+	for i := range left {
+		peak = max(peak, abs(left[i]), abs(right[i]))
+	}
+
+	gain := float32(1)
+	if peak > 1 {
+		gain = 1 / peak
+	}
+
+	width := bits / 8
+	align := 2 * width
+	size := len(left) * align
+
+	b := bufio.NewWriter(w)
+
+	header := []any{
+		[]byte("RIFF"),
+		uint32(36 + size),
+		[]byte("WAVE"),
+		[]byte("fmt "),
+		uint32(16),
+		uint16(1),
+		uint16(2),
+		uint32(rate),
+		uint32(rate * align),
+		uint16(align),
+		uint16(bits),
+		[]byte("data"),
+		uint32(size),
+	}
+
+	for _, v := range header {
+		if err := binary.Write(b, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+
+	scale := float64(int(1)<<(bits-1) - 1)
+	sample := make([]byte, align)
+
+	for i := range left {
+		for j, v := range []float32{left[i], right[i]} {
+			s := int32(math.Round(float64(v*gain) * scale))
+			for k := 0; k < width; k++ {
+				sample[j*width+k] = byte(s >> (8 * k))
+			}
+		}
+
+		if _, err := b.Write(sample); err != nil {
+			return err
+		}
+	}
+
+	return b.Flush()
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+
+	return v
+}
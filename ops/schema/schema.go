@@ -0,0 +1,90 @@
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/transcriptaze/midiasm/log"
+)
+
+const LOG_TAG = "schema"
+
+// Version is the version of the JSON format produced by 'export' and accepted by 'assemble'.
+const Version = 1
+
+//go:embed midiasm.schema.json
+var Schema []byte
+
+// Violation is a schema violation at the location in the JSON document identified by
+// the JSON pointer.
+type Violation struct {
+	Pointer string
+	Message string
+}
+
+var compiled struct {
+	once   sync.Once
+	schema any
+	err    error
+}
+
+func (v Violation) String() string {
+	if v.Pointer == "" {
+		return fmt.Sprintf("(root): %v", v.Message)
+	}
+
+	return fmt.Sprintf("%v: %v", v.Pointer, v.Message)
+}
+
+// Validate validates a JSON document against the midiasm JSON schema and returns the
+// list of schema violations (if any). An error is returned if the document is not
+// valid JSON.
+func Validate(r io.Reader) ([]Violation, error) {
+	compiled.once.Do(func() {
+		compiled.schema, compiled.err = parse(bytes.NewReader(Schema))
+	})
+
+	if compiled.err != nil {
+		return nil, compiled.err
+	}
+
+	document, err := parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	v := validator{
+		root: compiled.schema,
+	}
+
+	v.validate(compiled.schema, document, "")
+
+	for _, violation := range v.violations {
+		debugf("%v", violation)
+	}
+
+	return v.violations, nil
+}
+
+func parse(r io.Reader) (any, error) {
+	var v any
+
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	} else if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON document: unexpected data after the top-level value")
+	}
+
+	return v, nil
+}
+
+func debugf(format string, args ...any) {
+	log.Debugf(LOG_TAG, format, args...)
+}
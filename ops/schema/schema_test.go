@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const valid = `{
+  "version": 1,
+  "header": { "tag": "MThd", "format": 1, "division": 480 },
+  "tracks": [
+    {
+      "tag": "MTrk",
+      "track-number": 0,
+      "events": [
+        { "event": { "tag": "TrackName", "delta": 0, "status": 255, "type": 3, "name": "Reference" } },
+        { "event": { "tag": "Tempo", "delta": 0, "tempo": 500000 } },
+        { "event": { "tag": "KeySignature", "delta": 0, "accidentals": -3, "key-type": 1, "key": "C minor" } },
+        { "event": { "tag": "NoteOn", "delta": 0, "channel": 9, "note": { "value": 36, "name": "C2" }, "velocity": 100 } },
+        { "event": { "tag": "Controller", "delta": 0, "channel": 0, "controller": { "id": 7 }, "value": 100 } },
+        { "event": { "tag": "SysExMessage", "delta": 0, "manufacturer": { "id": [126] }, "data": [0, 9, 1], "single": true } },
+        { "event": { "tag": "EndOfTrack", "delta": 0 } }
+      ]
+    }
+  ]
+}`
+
+func TestValidate(t *testing.T) {
+	violations, err := Validate(strings.NewReader(valid))
+	if err != nil {
+		t.Fatalf("error validating JSON (%v)", err)
+	}
+
+	if len(violations) != 0 {
+		t.Errorf("unexpected schema violations\n%v", violations)
+	}
+}
+
+func TestValidateViolations(t *testing.T) {
+	tests := []struct {
+		json     string
+		expected []Violation
+	}{
+		{
+			`{"header": {"tag":"MThd","format":1,"division":480}, "tracks":[]}`,
+			[]Violation{{"", "missing required property 'version'"}},
+		},
+		{
+			`{"version":2, "header": {"tag":"MThd","format":1,"division":480}, "tracks":[]}`,
+			[]Violation{{"/version", "expected 1, got 2"}},
+		},
+		{
+			`{"version":1, "header": {"tag":"MThd","format":3,"PPQN":480}, "tracks":[]}`,
+			[]Violation{
+				{"/header", "missing required property 'division'"},
+				{"/header/PPQN", "unexpected property 'PPQN'"},
+				{"/header/format", "invalid value (3): expected one of 0, 1, 2"},
+			},
+		},
+		{
+			`{"version":1, "header": {"tag":"MThd","format":1,"division":480}, "tracks":[{"tag":"MTrk","tracknumber":0,"events":[]}]}`,
+			[]Violation{{"/tracks/0/tracknumber", "unexpected property 'tracknumber'"}},
+		},
+		{
+			`{"version":1, "header": {"tag":"MThd","format":1,"division":480}, "tracks":[{"tag":"MTrk","events":[
+			   {"event":{"tag":"NoteOn","delta":0,"channel":16,"note":{"value":60},"velocity":"loud"}}
+			 ]}]}`,
+			[]Violation{
+				{"/tracks/0/events/0/event/channel", "invalid value (16): expected a value less than or equal to 15"},
+				{"/tracks/0/events/0/event/velocity", "expected integer, got string"},
+			},
+		},
+		{
+			`{"version":1, "header": {"tag":"MThd","format":1,"division":480}, "tracks":[{"tag":"MTrk","events":[
+			   {"event":{"tag":"CuePoint","delta":-1,"cue-point":"Here"}},
+			   {"event":{"tag":"Noise","delta":0}},
+			   {"tag":"EndOfTrack","delta":0}
+			 ]}]}`,
+			[]Violation{
+				{"/tracks/0/events/0/event", "missing required property 'cuepoint'"},
+				{"/tracks/0/events/0/event/cue-point", "unexpected property 'cue-point'"},
+				{"/tracks/0/events/0/event/delta", "invalid value (-1): expected a value greater than or equal to 0"},
+				{"/tracks/0/events/1/event/tag", `invalid value ("Noise"): expected one of "SequenceNumber", "Text", "Copyright", "TrackName", "InstrumentName", "Lyric", "Marker", "CuePoint", "ProgramName", "DeviceName", "MIDIChannelPrefix", "MIDIPort", "EndOfTrack", "Tempo", "SMPTEOffset", "TimeSignature", "KeySignature", "SequencerSpecificEvent", "NoteOff", "NoteOn", "PolyphonicPressure", "Controller", "ProgramChange", "ChannelPressure", "PitchBend", "SysExMessage", "SysExContinuation", "SysExEscape"`},
+				{"/tracks/0/events/2", "missing required property 'event'"},
+				{"/tracks/0/events/2/delta", "unexpected property 'delta'"},
+				{"/tracks/0/events/2/tag", "unexpected property 'tag'"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		violations, err := Validate(strings.NewReader(test.json))
+		if err != nil {
+			t.Fatalf("error validating JSON (%v)", err)
+		}
+
+		if !reflect.DeepEqual(violations, test.expected) {
+			t.Errorf("incorrect schema violations\n   expected:%v\n   got:     %v", test.expected, violations)
+		}
+	}
+}
+
+func TestValidateInvalidJSON(t *testing.T) {
+	if _, err := Validate(strings.NewReader(`{"version":1,`)); err == nil {
+		t.Errorf("expected error validating invalid JSON")
+	}
+}
+
+func TestEscape(t *testing.T) {
+	if s := escape("a/b~c"); s != "a~1b~0c" {
+		t.Errorf("incorrectly escaped JSON pointer token - expected:%v, got:%v", "a~1b~0c", s)
+	}
+}
@@ -0,0 +1,331 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// validator implements the subset of JSON Schema (draft 2020-12) used by the midiasm schema
+// i.e. $ref (local only), type, const, enum, minimum, maximum, properties, required,
+// additionalProperties, items, minItems, maxItems, allOf and if/then/else.
+type validator struct {
+	root       any
+	violations []Violation
+}
+
+func (v *validator) validate(schema any, value any, pointer string) {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			v.violationf(pointer, "not allowed")
+		}
+		return
+
+	case map[string]any:
+		v.object(s, value, pointer)
+
+	default:
+		v.violationf(pointer, "invalid schema (%v)", schema)
+	}
+}
+
+func (v *validator) object(schema map[string]any, value any, pointer string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		if s, err := v.resolve(ref); err != nil {
+			v.violationf(pointer, "%v", err)
+		} else {
+			v.validate(s, value, pointer)
+		}
+	}
+
+	if t, ok := schema["type"]; ok && !v.typeOf(t, value, pointer) {
+		return
+	}
+
+	if c, ok := schema["const"]; ok && !equal(c, value) {
+		v.violationf(pointer, "expected %v, got %v", format(c), format(value))
+	}
+
+	if enum, ok := schema["enum"].([]any); ok {
+		match := false
+		for _, e := range enum {
+			if equal(e, value) {
+				match = true
+				break
+			}
+		}
+
+		if !match {
+			values := []string{}
+			for _, e := range enum {
+				values = append(values, format(e))
+			}
+
+			v.violationf(pointer, "invalid value (%v): expected one of %v", format(value), strings.Join(values, ", "))
+		}
+	}
+
+	if n, ok := value.(json.Number); ok {
+		v.number(schema, n, pointer)
+	}
+
+	if object, ok := value.(map[string]any); ok {
+		v.properties(schema, object, pointer)
+	}
+
+	if array, ok := value.([]any); ok {
+		v.items(schema, array, pointer)
+	}
+
+	if allOf, ok := schema["allOf"].([]any); ok {
+		for _, s := range allOf {
+			v.validate(s, value, pointer)
+		}
+	}
+
+	if condition, ok := schema["if"]; ok {
+		test := validator{root: v.root}
+		test.validate(condition, value, pointer)
+
+		if len(test.violations) == 0 {
+			if then, ok := schema["then"]; ok {
+				v.validate(then, value, pointer)
+			}
+		} else if otherwise, ok := schema["else"]; ok {
+			v.validate(otherwise, value, pointer)
+		}
+	}
+}
+
+func (v *validator) typeOf(t any, value any, pointer string) bool {
+	types := []string{}
+
+	switch tt := t.(type) {
+	case string:
+		types = append(types, tt)
+
+	case []any:
+		for _, s := range tt {
+			types = append(types, fmt.Sprintf("%v", s))
+		}
+	}
+
+	for _, t := range types {
+		if is(t, value) {
+			return true
+		}
+	}
+
+	v.violationf(pointer, "expected %v, got %v", strings.Join(types, " or "), typename(value))
+
+	return false
+}
+
+func (v *validator) number(schema map[string]any, n json.Number, pointer string) {
+	f, err := n.Float64()
+	if err != nil {
+		v.violationf(pointer, "invalid number (%v)", n)
+		return
+	}
+
+	if minimum, ok := schema["minimum"].(json.Number); ok {
+		if m, err := minimum.Float64(); err == nil && f < m {
+			v.violationf(pointer, "invalid value (%v): expected a value greater than or equal to %v", n, minimum)
+		}
+	}
+
+	if maximum, ok := schema["maximum"].(json.Number); ok {
+		if m, err := maximum.Float64(); err == nil && f > m {
+			v.violationf(pointer, "invalid value (%v): expected a value less than or equal to %v", n, maximum)
+		}
+	}
+}
+
+func (v *validator) properties(schema map[string]any, object map[string]any, pointer string) {
+	properties, _ := schema["properties"].(map[string]any)
+
+	if required, ok := schema["required"].([]any); ok {
+		for _, r := range required {
+			if name, ok := r.(string); ok {
+				if _, ok := object[name]; !ok {
+					v.violationf(pointer, "missing required property '%v'", name)
+				}
+			}
+		}
+	}
+
+	keys := []string{}
+	for k := range object {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if s, ok := properties[k]; ok {
+			v.validate(s, object[k], pointer+"/"+escape(k))
+		} else if additional, ok := schema["additionalProperties"]; ok {
+			if b, ok := additional.(bool); ok && !b {
+				v.violationf(pointer+"/"+escape(k), "unexpected property '%v'", k)
+			} else {
+				v.validate(additional, object[k], pointer+"/"+escape(k))
+			}
+		}
+	}
+}
+
+func (v *validator) items(schema map[string]any, array []any, pointer string) {
+	if minItems, ok := schema["minItems"].(json.Number); ok {
+		if m, err := minItems.Int64(); err == nil && int64(len(array)) < m {
+			v.violationf(pointer, "expected at least %v items, got %v", m, len(array))
+		}
+	}
+
+	if maxItems, ok := schema["maxItems"].(json.Number); ok {
+		if m, err := maxItems.Int64(); err == nil && int64(len(array)) > m {
+			v.violationf(pointer, "expected at most %v items, got %v", m, len(array))
+		}
+	}
+
+	if items, ok := schema["items"]; ok {
+		for i, item := range array {
+			v.validate(items, item, fmt.Sprintf("%v/%v", pointer, i))
+		}
+	}
+}
+
+// resolve returns the schema for a local JSON pointer reference e.g. #/$defs/NoteOn.
+func (v *validator) resolve(ref string) (any, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported schema reference (%v)", ref)
+	}
+
+	schema := v.root
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#"), "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		if object, ok := schema.(map[string]any); !ok {
+			return nil, fmt.Errorf("invalid schema reference (%v)", ref)
+		} else if schema, ok = object[token]; !ok {
+			return nil, fmt.Errorf("invalid schema reference (%v)", ref)
+		}
+	}
+
+	return schema, nil
+}
+
+func (v *validator) violationf(pointer string, format string, args ...any) {
+	v.violations = append(v.violations, Violation{
+		Pointer: pointer,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func is(t string, value any) bool {
+	switch t {
+	case "object":
+		_, ok := value.(map[string]any)
+		return ok
+
+	case "array":
+		_, ok := value.([]any)
+		return ok
+
+	case "string":
+		_, ok := value.(string)
+		return ok
+
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+
+	case "null":
+		return value == nil
+
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+
+	case "integer":
+		if n, ok := value.(json.Number); ok {
+			f, err := n.Float64()
+			return err == nil && f == math.Trunc(f)
+		}
+	}
+
+	return false
+}
+
+func typename(value any) string {
+	switch value.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func equal(p, q any) bool {
+	switch u := p.(type) {
+	case json.Number:
+		if v, ok := q.(json.Number); ok {
+			a, err1 := u.Float64()
+			b, err2 := v.Float64()
+
+			return err1 == nil && err2 == nil && a == b
+		}
+
+	case []any:
+		if v, ok := q.([]any); ok && len(u) == len(v) {
+			for i := range u {
+				if !equal(u[i], v[i]) {
+					return false
+				}
+			}
+
+			return true
+		}
+
+	case map[string]any:
+		if v, ok := q.(map[string]any); ok && len(u) == len(v) {
+			for k := range u {
+				if !equal(u[k], v[k]) {
+					return false
+				}
+			}
+
+			return true
+		}
+
+	default:
+		return p == q
+	}
+
+	return false
+}
+
+func format(value any) string {
+	if bytes, err := json.Marshal(value); err == nil {
+		return string(bytes)
+	}
+
+	return fmt.Sprintf("%v", value)
+}
+
+// escape escapes a property name for use as a JSON pointer reference token (RFC 6901).
+func escape(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -57,6 +57,11 @@ func transpose(mtrk midi.MTrk, steps int) *midi.MTrk {
 				Event: v.Transpose(mtrk.Context, steps),
 			})
 
+		case midievent.PolyphonicPressure:
+			track.Events = append(track.Events, &events.Event{
+				Event: v.Transpose(mtrk.Context, steps),
+			})
+
 		default:
 			track.Events = append(track.Events, event)
 		}